@@ -0,0 +1,38 @@
+package blobstore
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when a blob does not exist
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore stores file contents by key. Implementations must be safe for
+// concurrent use.
+type BlobStore interface {
+	// Put stores size bytes read from r under key, replacing any blob with that key
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Open opens a blob for reading. Seeking lets callers serve byte ranges.
+	Open(key string) (io.ReadSeekCloser, error)
+	// Delete removes a blob. Deleting a missing blob is not an error.
+	Delete(key string) error
+}
+
+// validKey checks that a key is a plain name that cannot escape a directory
+// or bucket prefix
+func validKey(key string) bool {
+	if key == "" || len(key) > 255 {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// errInvalidKey is returned for keys that validKey rejects
+var errInvalidKey = errors.New("invalid blob key")
@@ -0,0 +1,182 @@
+package blobstore
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestS3Store serves a MockS3 and returns an S3Store signing requests for it
+func newTestS3Store(t *testing.T, secretAccessKey string) *S3Store {
+	t.Helper()
+
+	server := httptest.NewServer(NewMockS3("test-key", "test-secret", "us-east-1"))
+	t.Cleanup(server.Close)
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "attachments",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: secretAccessKey,
+		Prefix:          "app-",
+		PathStyle:       true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+	return store
+}
+
+// stores lists the blob stores every test runs against
+func stores(t *testing.T) map[string]BlobStore {
+	return map[string]BlobStore{
+		"s3":    newTestS3Store(t, "test-secret"),
+		"local": NewLocalStore(t.TempDir()),
+	}
+}
+
+func readAll(t *testing.T, store BlobStore, key string) string {
+	t.Helper()
+
+	blob, err := store.Open(key)
+	if err != nil {
+		t.Fatalf("Open(%q): %v", key, err)
+	}
+	defer blob.Close()
+
+	data, err := io.ReadAll(blob)
+	if err != nil {
+		t.Fatalf("reading %q: %v", key, err)
+	}
+	return string(data)
+}
+
+func TestRoundTrip(t *testing.T) {
+	const content = "The quick brown fox jumps over the lazy dog"
+
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.Put("blob1", strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if got := readAll(t, store, "blob1"); got != content {
+				t.Errorf("read %q, want %q", got, content)
+			}
+
+			// Putting a key again replaces the blob
+			if err := store.Put("blob1", strings.NewReader("replaced"), 8, "text/plain"); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if got := readAll(t, store, "blob1"); got != "replaced" {
+				t.Errorf("read %q after replacing, want %q", got, "replaced")
+			}
+
+			if err := store.Delete("blob1"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, err := store.Open("blob1"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Open after Delete = %v, want ErrNotFound", err)
+			}
+			if err := store.Delete("blob1"); err != nil {
+				t.Errorf("deleting a missing blob: %v", err)
+			}
+		})
+	}
+}
+
+func TestSeek(t *testing.T) {
+	const content = "0123456789abcdefghij"
+
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.Put("ranged", strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+
+			blob, err := store.Open("ranged")
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer blob.Close()
+
+			size, err := blob.Seek(0, io.SeekEnd)
+			if err != nil || size != int64(len(content)) {
+				t.Fatalf("Seek to end = %d, %v, want %d", size, err, len(content))
+			}
+
+			tests := []struct {
+				offset int64
+				whence int
+				length int
+				want   string
+			}{
+				{5, io.SeekStart, 5, "56789"},
+				{2, io.SeekCurrent, 3, "cde"},
+				{-4, io.SeekEnd, 4, "ghij"},
+				{0, io.SeekStart, 3, "012"},
+			}
+			for _, tt := range tests {
+				if _, err := blob.Seek(tt.offset, tt.whence); err != nil {
+					t.Fatalf("Seek(%d, %d): %v", tt.offset, tt.whence, err)
+				}
+				buf := make([]byte, tt.length)
+				if _, err := io.ReadFull(blob, buf); err != nil {
+					t.Fatalf("reading after Seek(%d, %d): %v", tt.offset, tt.whence, err)
+				}
+				if string(buf) != tt.want {
+					t.Errorf("read %q after Seek(%d, %d), want %q", buf, tt.offset, tt.whence, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidKeys(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"", "../escape", "a/b", "dot.ted", strings.Repeat("a", 256)} {
+				if err := store.Put(key, strings.NewReader("x"), 1, "text/plain"); err == nil {
+					t.Errorf("Put accepted key %q", key)
+				}
+				if _, err := store.Open(key); err == nil {
+					t.Errorf("Open accepted key %q", key)
+				}
+				if err := store.Delete(key); err == nil {
+					t.Errorf("Delete accepted key %q", key)
+				}
+			}
+		})
+	}
+}
+
+func TestS3RejectsBadSignature(t *testing.T) {
+	store := newTestS3Store(t, "wrong-secret")
+
+	err := store.Put("blob1", strings.NewReader("data"), 4, "text/plain")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("Put with the wrong secret = %v, want a signature error", err)
+	}
+}
+
+func TestS3ObjectURL(t *testing.T) {
+	tests := []struct {
+		pathStyle bool
+		want      string
+	}{
+		{true, "https://s3.example.com/bucket/app-key"},
+		{false, "https://bucket.s3.example.com/app-key"},
+	}
+
+	for _, tt := range tests {
+		store, err := NewS3Store(S3Config{Endpoint: "https://s3.example.com/", Bucket: "bucket", Prefix: "app-", PathStyle: tt.pathStyle})
+		if err != nil {
+			t.Fatalf("NewS3Store: %v", err)
+		}
+		if got := store.objectURL("key"); got != tt.want {
+			t.Errorf("objectURL with path style %v = %q, want %q", tt.pathStyle, got, tt.want)
+		}
+	}
+}
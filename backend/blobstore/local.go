@@ -0,0 +1,91 @@
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore keeps blobs as files in a directory on the local filesystem.
+// Files are spread over subdirectories named after the first two characters
+// of their key.
+type LocalStore struct {
+	Dir string
+}
+
+// NewLocalStore creates a new LocalStore
+func NewLocalStore(dir string) *LocalStore {
+	return &LocalStore{Dir: dir}
+}
+
+// path returns the file a blob is stored in
+func (s *LocalStore) path(key string) string {
+	if len(key) < 2 {
+		return filepath.Join(s.Dir, key)
+	}
+	return filepath.Join(s.Dir, key[:2], key)
+}
+
+// Put writes a blob to a temporary file and moves it into place, so readers
+// never see a partly written blob
+func (s *LocalStore) Put(key string, r io.Reader, size int64, contentType string) error {
+	if !validKey(key) {
+		return errInvalidKey
+	}
+
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if written != size {
+		return fmt.Errorf("blob %s: wrote %d bytes, expected %d", key, written, size)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open opens a blob file
+func (s *LocalStore) Open(key string) (io.ReadSeekCloser, error) {
+	if !validKey(key) {
+		return nil, errInvalidKey
+	}
+
+	file, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Delete removes a blob file
+func (s *LocalStore) Delete(key string) error {
+	if !validKey(key) {
+		return errInvalidKey
+	}
+
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+package blobstore
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mockMaxObjectSize bounds the objects the mock S3 server keeps in memory
+const mockMaxObjectSize = 100 << 20
+
+// mockClockSkew is how far a request's signing time may be from the server's clock
+const mockClockSkew = 15 * time.Minute
+
+// MockS3 is a minimal S3-compatible server for local development. It keeps
+// objects in memory and supports the path-style PUT, HEAD, GET (with ranges)
+// and DELETE object requests used by S3Store, checking their signatures.
+type MockS3 struct {
+	credentials credentials
+
+	mu      sync.RWMutex
+	objects map[string]mockObject
+}
+
+// mockObject is an object stored by the mock S3 server
+type mockObject struct {
+	data        []byte
+	contentType string
+	modified    time.Time
+}
+
+// NewMockS3 creates a MockS3 accepting requests signed with the given access key
+func NewMockS3(accessKeyID string, secretAccessKey string, region string) *MockS3 {
+	return &MockS3{
+		credentials: credentials{
+			accessKeyID:     accessKeyID,
+			secretAccessKey: secretAccessKey,
+			region:          region,
+		},
+		objects: make(map[string]mockObject),
+	}
+}
+
+// ServeHTTP serves an object request. The path is /<bucket>/<key>.
+func (m *MockS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := m.verify(r); err != nil {
+		writeS3Error(w, http.StatusForbidden, "SignatureDoesNotMatch", err.Error())
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if !strings.Contains(name, "/") {
+		writeS3Error(w, http.StatusBadRequest, "InvalidRequest", "only object requests are supported")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(io.LimitReader(r.Body, mockMaxObjectSize+1))
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+			return
+		}
+		if len(data) > mockMaxObjectSize {
+			writeS3Error(w, http.StatusBadRequest, "EntityTooLarge", "object is too large")
+			return
+		}
+		m.mu.Lock()
+		m.objects[name] = mockObject{data: data, contentType: r.Header.Get("Content-Type"), modified: time.Now()}
+		m.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		m.mu.RLock()
+		object, ok := m.objects[name]
+		m.mu.RUnlock()
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey", "the object does not exist")
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		http.ServeContent(w, r, "", object.modified, bytes.NewReader(object.data))
+	case http.MethodDelete:
+		m.mu.Lock()
+		delete(m.objects, name)
+		m.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "method not allowed")
+	}
+}
+
+// verify checks the Signature Version 4 of a request
+func (m *MockS3) verify(r *http.Request) error {
+	// AWS4-HMAC-SHA256 Credential=<key>/<scope>, SignedHeaders=<headers>, Signature=<signature>
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, sigV4Algorithm+" ") {
+		return fmt.Errorf("missing %s authorization", sigV4Algorithm)
+	}
+	fields := make(map[string]string)
+	for _, field := range strings.Split(strings.TrimPrefix(authorization, sigV4Algorithm+" "), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		fields[name] = value
+	}
+
+	accessKeyID, scope, _ := strings.Cut(fields["Credential"], "/")
+	if accessKeyID != m.credentials.accessKeyID {
+		return fmt.Errorf("unknown access key %q", accessKeyID)
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse(amzDateFormat, amzDate)
+	if err != nil {
+		return fmt.Errorf("invalid X-Amz-Date %q", amzDate)
+	}
+	if skew := time.Since(signedAt); skew > mockClockSkew || skew < -mockClockSkew {
+		return fmt.Errorf("request was signed at %s", amzDate)
+	}
+	if scope != m.credentials.scope(amzDate[:8]) {
+		return fmt.Errorf("invalid credential scope %q", scope)
+	}
+
+	expected := m.credentials.signature(r, amzDate, scope, strings.Split(fields["SignedHeaders"], ";"))
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(fields["Signature"])) != 1 {
+		return fmt.Errorf("signature does not match")
+	}
+	return nil
+}
+
+// writeS3Error writes an error response in the S3 XML format
+func writeS3Error(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, html.EscapeString(message))
+}
@@ -0,0 +1,233 @@
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config configures an S3Store
+type S3Config struct {
+	// Endpoint is the address of the S3 API, e.g. https://s3.eu-west-1.amazonaws.com
+	// or http://localhost:9000 for a local stand-in
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Prefix is prepended to every key, which lets several apps share a bucket
+	Prefix string
+	// PathStyle addresses the bucket as a path (endpoint/bucket/key) instead of
+	// a subdomain (bucket.endpoint/key), as most S3-compatible servers expect
+	PathStyle bool
+}
+
+// S3Store keeps blobs in a bucket of Amazon S3 or an S3-compatible server.
+// Requests are signed with AWS Signature Version 4.
+type S3Store struct {
+	endpoint    *url.URL
+	bucket      string
+	prefix      string
+	pathStyle   bool
+	credentials credentials
+	client      *http.Client
+}
+
+// NewS3Store creates a new S3Store
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, errors.New("S3 bucket is required")
+	}
+
+	return &S3Store{
+		endpoint:  endpoint,
+		bucket:    cfg.Bucket,
+		prefix:    cfg.Prefix,
+		pathStyle: cfg.PathStyle,
+		credentials: credentials{
+			accessKeyID:     cfg.AccessKeyID,
+			secretAccessKey: cfg.SecretAccessKey,
+			region:          cfg.Region,
+		},
+		// No overall timeout: downloads of large blobs are streamed to slow clients
+		client: &http.Client{Transport: http.DefaultTransport},
+	}, nil
+}
+
+// objectURL returns the address of the object stored under a key
+func (s *S3Store) objectURL(key string) string {
+	u := *s.endpoint
+	if s.pathStyle {
+		u.Path += "/" + s.bucket + "/" + s.prefix + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path += "/" + s.prefix + key
+	}
+	return u.String()
+}
+
+// do signs and sends a request for the object stored under a key
+func (s *S3Store) do(method string, key string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.objectURL(key), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	s.credentials.sign(req, time.Now())
+	return s.client.Do(req)
+}
+
+// Put uploads a blob
+func (s *S3Store) Put(key string, r io.Reader, size int64, contentType string) error {
+	if !validKey(key) {
+		return errInvalidKey
+	}
+
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(key), io.NopCloser(r))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	s.credentials.sign(req, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("uploading", key, resp)
+	}
+	return nil
+}
+
+// Open looks up the size of a blob. Its contents are fetched when read, with
+// a ranged request starting at the current offset.
+func (s *S3Store) Open(key string) (io.ReadSeekCloser, error) {
+	if !validKey(key) {
+		return nil, errInvalidKey
+	}
+
+	resp, err := s.do(http.MethodHead, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error("opening", key, resp)
+	}
+	return &s3Object{store: s, key: key, size: resp.ContentLength}, nil
+}
+
+// Delete removes a blob
+func (s *S3Store) Delete(key string) error {
+	if !validKey(key) {
+		return errInvalidKey
+	}
+
+	resp, err := s.do(http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error("deleting", key, resp)
+	}
+	return nil
+}
+
+// s3Error describes a failed S3 request
+func s3Error(action string, key string, resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("S3 error %s blob %s: %s %s", action, key, resp.Status, strings.TrimSpace(string(message)))
+}
+
+// s3Object reads an S3 object from an offset
+type s3Object struct {
+	store  *S3Store
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+// Read reads from the object, starting a ranged GET at the current offset if none is open
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+
+	if o.body == nil {
+		header := http.Header{}
+		header.Set("Range", "bytes="+strconv.FormatInt(o.offset, 10)+"-")
+		resp, err := o.store.do(http.MethodGet, o.key, nil, header)
+		if err != nil {
+			return 0, err
+		}
+		if resp.StatusCode != http.StatusPartialContent && !(resp.StatusCode == http.StatusOK && o.offset == 0) {
+			defer resp.Body.Close()
+			return 0, s3Error("reading", o.key, resp)
+		}
+		o.body = resp.Body
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	if err == io.EOF && o.offset < o.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Seek moves the offset; the next Read starts a new request from there
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	var target int64
+	switch whence {
+	case io.SeekStart:
+		target = offset
+	case io.SeekCurrent:
+		target = o.offset + offset
+	case io.SeekEnd:
+		target = o.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if target < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	if target != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = target
+	return target, nil
+}
+
+// Close closes the open GET request, if any
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
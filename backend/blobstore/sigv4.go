@@ -0,0 +1,150 @@
+package blobstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4 constants for S3
+const (
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	sigV4Service   = "s3"
+	amzDateFormat  = "20060102T150405Z"
+	// unsignedPayload lets uploads be streamed without hashing them first
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// sigV4Headers are the headers included in every signature
+var sigV4Headers = []string{"host", "x-amz-content-sha256", "x-amz-date"}
+
+// credentials are an S3 access key pair and the region requests are signed for
+type credentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	region          string
+}
+
+// sign adds the x-amz-date, x-amz-content-sha256 and Authorization headers of
+// an AWS Signature Version 4 to a request
+func (c credentials) sign(req *http.Request, now time.Time) {
+	amzDate := now.UTC().Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	scope := c.scope(amzDate[:8])
+	signature := c.signature(req, amzDate, scope, sigV4Headers)
+	req.Header.Set("Authorization", sigV4Algorithm+
+		" Credential="+c.accessKeyID+"/"+scope+
+		", SignedHeaders="+strings.Join(sigV4Headers, ";")+
+		", Signature="+signature)
+}
+
+// scope returns the credential scope for a date (YYYYMMDD)
+func (c credentials) scope(date string) string {
+	return date + "/" + c.region + "/" + sigV4Service + "/aws4_request"
+}
+
+// signature computes the hex signature of a request over the signed headers
+func (c credentials) signature(req *http.Request, amzDate string, scope string, signedHeaders []string) string {
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		hexSHA256(canonicalRequest(req, signedHeaders)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.secretAccessKey), amzDate[:8])
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, sigV4Service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// canonicalRequest builds the canonical form of a request that gets signed
+func canonicalRequest(req *http.Request, signedHeaders []string) string {
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.EscapedPath()),
+		canonicalQuery(req),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+}
+
+// canonicalQuery sorts and encodes the query parameters of a request
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name)+"="+uriEncode(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// escapePath re-encodes an escaped URL path the way Signature Version 4
+// expects, leaving the slashes between segments alone
+func escapePath(escaped string) string {
+	segments := strings.Split(escaped, "/")
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segments[i] = uriEncode(unescaped)
+		}
+	}
+	path := strings.Join(segments, "/")
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// uriEncode percent-encodes every byte except the unreserved characters of RFC 3986
+func uriEncode(s string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&15])
+	}
+	return b.String()
+}
+
+// hmacSHA256 computes the HMAC-SHA256 of data
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// hexSHA256 returns the hex SHA-256 digest of s
+func hexSHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"go-react-redux-app/blobstore"
+	"go-react-redux-app/config"
+	"go-react-redux-app/migrations"
+	"go-react-redux-app/models"
+	"go-react-redux-app/oidc"
+	"go-react-redux-app/password"
+	"go-react-redux-app/utils"
+)
+
+// runCommand runs a management command given on the command line
+func runCommand(cfg *config.Config, passwordPolicy *password.Policy, args []string) error {
+	defer cfg.Close()
+
+	switch args[0] {
+	case "migrate":
+		return runMigrate(cfg, args[1:])
+	case "create-admin":
+		return runCreateAdmin(cfg, passwordPolicy, args[1:])
+	case "mock-oidc":
+		return runMockOIDC(args[1:])
+	case "mock-s3":
+		return runMockS3(cfg, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q (available: migrate, create-admin, mock-oidc, mock-s3)", args[0])
+	}
+}
+
+// runMigrate handles `migrate up|down [steps]|status`
+func runMigrate(cfg *config.Config, args []string) error {
+	if cfg.Storage == config.StorageMemory {
+		return errors.New("migrations require a database connection")
+	}
+
+	migrator, err := migrations.New(cfg.DB)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [steps]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		count, err := migrator.Up()
+		if err != nil {
+			return err
+		}
+		log.Printf("Applied %d migration(s)", count)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+		}
+		count, err := migrator.Down(steps)
+		if err != nil {
+			return err
+		}
+		log.Printf("Rolled back %d migration(s)", count)
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, status := range statuses {
+			state, appliedAt := "pending", ""
+			if status.Applied {
+				state = "applied"
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Modified {
+				state = "modified"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate subcommand %q (available: up, down, status)", args[0])
+	}
+
+	return nil
+}
+
+// runCreateAdmin handles `create-admin <username> <email>`. It creates an
+// admin account, or promotes and re-enables the account if the username is
+// taken. The password is read from ADMIN_PASSWORD and has to follow the
+// password policy; when that is unset a random one is generated and printed.
+func runCreateAdmin(cfg *config.Config, passwordPolicy *password.Policy, args []string) error {
+	if cfg.Storage == config.StorageMemory {
+		return errors.New("create-admin requires a database connection")
+	}
+	if len(args) != 2 {
+		return errors.New("usage: create-admin <username> <email>")
+	}
+	username, email := args[0], args[1]
+
+	if cfg.AutoMigrate {
+		if err := applyMigrations(cfg); err != nil {
+			return err
+		}
+	}
+
+	userStore := models.NewUserStore(cfg.DB)
+
+	user, err := userStore.GetByUsername(username)
+	if err == nil {
+		user.Role = models.UserRoleAdmin
+		if err := userStore.Update(user); err != nil {
+			return err
+		}
+		if err := userStore.SetDisabled(user.ID, false); err != nil {
+			return err
+		}
+		log.Printf("Promoted existing user %s to admin", username)
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	adminPassword := os.Getenv("ADMIN_PASSWORD")
+	generated := adminPassword == ""
+	if generated {
+		adminPassword, err = utils.GenerateRandomToken(18)
+		if err != nil {
+			return err
+		}
+	} else if err := passwordPolicy.Validate(adminPassword, username, email); err != nil {
+		return fmt.Errorf("ADMIN_PASSWORD: %v", err)
+	}
+
+	now := time.Now()
+	err = userStore.Create(&models.User{
+		ID:            uuid.New().String(),
+		Username:      username,
+		Email:         email,
+		Password:      adminPassword,
+		Role:          models.UserRoleAdmin,
+		EmailVerified: true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Created admin user %s", username)
+	if generated {
+		fmt.Printf("Generated password: %s\n", adminPassword)
+	}
+	return nil
+}
+
+// runMockOIDC handles `mock-oidc [addr]`. It serves a mock OpenID Connect
+// provider for trying out SSO locally; its issuer is read from
+// MOCK_OIDC_ISSUER and defaults to http://localhost:9000.
+func runMockOIDC(args []string) error {
+	addr := ":9000"
+	if len(args) > 0 {
+		addr = args[0]
+	}
+
+	issuer := os.Getenv("MOCK_OIDC_ISSUER")
+	if issuer == "" {
+		issuer = "http://localhost:9000"
+	}
+
+	provider, err := oidc.NewMockProvider(issuer)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Mock OpenID Connect provider %s listening on %s", provider.Issuer, addr)
+	return http.ListenAndServe(addr, provider.Handler())
+}
+
+// runMockS3 handles `mock-s3 [addr]`. It serves an in-memory S3-compatible
+// server for trying out BLOB_STORE=s3 locally; it accepts requests signed
+// with S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY for S3_REGION.
+func runMockS3(cfg *config.Config, args []string) error {
+	addr := ":9100"
+	if len(args) > 0 {
+		addr = args[0]
+	}
+
+	if cfg.S3.AccessKeyID == "" || cfg.S3.SecretAccessKey == "" {
+		return errors.New("mock-s3 requires S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY")
+	}
+
+	log.Printf("Mock S3 server for region %s listening on %s", cfg.S3.Region, addr)
+	return http.ListenAndServe(addr, blobstore.NewMockS3(cfg.S3.AccessKeyID, cfg.S3.SecretAccessKey, cfg.S3.Region))
+}
+
+// applyMigrations brings the database schema up to date on server start
+func applyMigrations(cfg *config.Config) error {
+	migrator, err := migrations.New(cfg.DB)
+	if err != nil {
+		return err
+	}
+
+	count, err := migrator.Up()
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		log.Printf("Database schema migrated (%d migration(s) applied)", count)
+	} else {
+		log.Println("Database schema is up to date")
+	}
+	return nil
+}
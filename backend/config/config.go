@@ -1,21 +1,151 @@
 package config
 
 import (
-	"database/sql"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"go-react-redux-app/blobstore"
+	"go-react-redux-app/database"
+	"go-react-redux-app/oidc"
+	"go-react-redux-app/password"
+	"go-react-redux-app/signing"
+)
+
+// Storage drivers supported by the server
+const (
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+	StorageMemory   = "memory"
+)
+
+// Environments the server can run in
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// insecureJWTKeys are placeholder secrets from the documentation and examples
+var insecureJWTKeys = []string{"your-secret-key", "your-super-secret-key-change-in-production"}
+
+// minJWTKeyLength is the minimum length of an HS256 secret outside development
+const minJWTKeyLength = 32
+
+// Mailers supported by the server
+const (
+	MailerSMTP   = "smtp"
+	MailerOutbox = "outbox"
+)
+
+// Blob stores supported by the server
+const (
+	BlobStoreLocal = "local"
+	BlobStoreS3    = "s3"
+)
+
+// defaultAttachmentTypes are the MIME types attachments may have unless
+// ATTACHMENT_ALLOWED_TYPES is set. HTML and SVG are left out since browsers
+// would run scripts in them.
+var defaultAttachmentTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"application/pdf",
+	"text/plain",
+	"text/csv",
+	"text/markdown",
+	"application/zip",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+// Login trackers supported by the server
+const (
+	LoginTrackerMemory   = "memory"
+	LoginTrackerDatabase = "database"
 )
 
 // Config holds all configuration for the server
 type Config struct {
-	DB     *sql.DB
-	Port   int
-	JWTKey string
+	DB              *database.DB
+	Storage         string
+	AutoMigrate     bool
+	Port            int
+	Env             string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// Token signing configuration
+	JWTAlgorithm      string
+	JWTKey            string
+	JWTKeysDir        string
+	JWTKeyRotation    time.Duration
+	JWTKeyGracePeriod time.Duration
+
+	// Mail configuration
+	Mailer       string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
+	OutboxDir    string
+
+	// AppURL is the frontend address used to build links in emails
+	AppURL                   string
+	RequireEmailVerification bool
+	// APIURL is the public address of this server, which identity providers redirect back to
+	APIURL string
+
+	// OIDCProviders are the identity providers users can log in with
+	OIDCProviders []oidc.Config
+
+	// TOTPIssuer names the application in authenticator apps
+	TOTPIssuer string
+
+	// Login throttling configuration
+	LoginTracker          string
+	LoginMaxFailures      int
+	LoginMaxFailuresPerIP int
+	LoginBackoffBase      time.Duration
+	LoginLockoutDuration  time.Duration
+	LoginAttemptWindow    time.Duration
+	// TrustProxyHeaders takes the client IP from X-Forwarded-For when behind a reverse proxy
+	TrustProxyHeaders bool
+
+	// Password hashing configuration
+	PasswordHashAlgorithm string
+	Argon2id              password.Argon2idParams
+	BcryptCost            int
+
+	// Password policy configuration
+	PasswordMinLength     int
+	PasswordMaxLength     int
+	PasswordBlocklist     bool
+	PasswordBlocklistFile string
+
+	// EnforceTaskDependencies refuses finishing a task while tasks blocking it are open
+	EnforceTaskDependencies bool
+
+	// RecurrenceInterval is how often recurring tasks are checked for occurrences that are due
+	RecurrenceInterval time.Duration
+
+	// Attachment storage configuration
+	BlobStore string
+	BlobDir   string
+	S3        blobstore.S3Config
+	// BlobCleanupInterval is how often blobs of deleted attachments are looked for
+	BlobCleanupInterval time.Duration
+
+	// Attachment limits; AttachmentAllowedTypes may contain wildcards like image/*
+	AttachmentMaxSize      int64
+	AttachmentAllowedTypes []string
 }
 
 // LoadConfig loads the configuration from environment variables
@@ -32,6 +162,8 @@ func LoadConfig() *Config {
 	dbPassword := getEnv("DB_PASSWORD", "postgres")
 	dbName := getEnv("DB_NAME", "projectmanagement")
 	sslMode := getEnv("DB_SSLMODE", "disable")
+	storage := getEnv("STORAGE_DRIVER", StoragePostgres)
+	sqlitePath := getEnv("SQLITE_PATH", "projectmanagement.db")
 
 	// Server configuration
 	port, err := strconv.Atoi(getEnv("PORT", "8080"))
@@ -39,33 +171,301 @@ func LoadConfig() *Config {
 		log.Fatal("Invalid PORT environment variable")
 	}
 
+	env := getEnv("APP_ENV", EnvProduction)
+	if env != EnvDevelopment && env != EnvProduction {
+		log.Fatalf("Invalid APP_ENV %q: must be %q or %q", env, EnvDevelopment, EnvProduction)
+	}
+
 	// JWT configuration
+	jwtAlgorithm := getEnv("JWT_ALGORITHM", signing.HS256)
+	if !signing.IsValidAlgorithm(jwtAlgorithm) {
+		log.Fatalf("Invalid JWT_ALGORITHM %q: must be %q, %q or %q", jwtAlgorithm, signing.HS256, signing.RS256, signing.EdDSA)
+	}
 	jwtKey := getEnv("JWT_KEY", "your-secret-key")
+	if jwtAlgorithm == signing.HS256 && env != EnvDevelopment && !isSecureJWTKey(jwtKey) {
+		log.Fatalf("JWT_KEY is a placeholder or shorter than %d characters; set a strong JWT_KEY, "+
+			"use JWT_ALGORITHM=%s or %s, or set APP_ENV=%s for local development",
+			minJWTKeyLength, signing.RS256, signing.EdDSA, EnvDevelopment)
+	}
+	accessTokenTTL := getDuration("ACCESS_TOKEN_TTL", 15*time.Minute)
+	refreshTokenTTL := getDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	jwtKeyRotation := getDuration("JWT_KEY_ROTATION", 30*24*time.Hour)
+	jwtKeyGracePeriod := getDuration("JWT_KEY_GRACE_PERIOD", 24*time.Hour)
+	if jwtKeyGracePeriod < accessTokenTTL {
+		log.Fatal("JWT_KEY_GRACE_PERIOD must be at least ACCESS_TOKEN_TTL, or tokens would outlive their key")
+	}
+
+	// Migration configuration
+	autoMigrate, err := strconv.ParseBool(getEnv("AUTO_MIGRATE", "true"))
+	if err != nil {
+		log.Fatal("Invalid AUTO_MIGRATE environment variable")
+	}
+
+	// Mail configuration
+	mailer := getEnv("MAILER", MailerOutbox)
+	if mailer != MailerSMTP && mailer != MailerOutbox {
+		log.Fatalf("Invalid MAILER %q: must be %q or %q", mailer, MailerSMTP, MailerOutbox)
+	}
+	smtpPort, err := strconv.Atoi(getEnv("SMTP_PORT", "587"))
+	if err != nil {
+		log.Fatal("Invalid SMTP_PORT environment variable")
+	}
+	requireEmailVerification, err := strconv.ParseBool(getEnv("REQUIRE_EMAIL_VERIFICATION", "false"))
+	if err != nil {
+		log.Fatal("Invalid REQUIRE_EMAIL_VERIFICATION environment variable")
+	}
+
+	// Login throttling configuration
+	loginTrackerDefault := LoginTrackerDatabase
+	if storage == StorageMemory {
+		loginTrackerDefault = LoginTrackerMemory
+	}
+	loginTracker := getEnv("LOGIN_TRACKER", loginTrackerDefault)
+	if loginTracker != LoginTrackerMemory && loginTracker != LoginTrackerDatabase {
+		log.Fatalf("Invalid LOGIN_TRACKER %q: must be %q or %q", loginTracker, LoginTrackerMemory, LoginTrackerDatabase)
+	}
+	loginMaxFailures := getPositiveInt("LOGIN_MAX_FAILURES", 5)
+	loginMaxFailuresPerIP := getPositiveInt("LOGIN_MAX_FAILURES_PER_IP", 20)
+	trustProxyHeaders, err := strconv.ParseBool(getEnv("TRUST_PROXY_HEADERS", "false"))
+	if err != nil {
+		log.Fatal("Invalid TRUST_PROXY_HEADERS environment variable")
+	}
+
+	// Password hashing and policy configuration
+	passwordHashAlgorithm := getEnv("PASSWORD_HASH_ALGORITHM", password.Argon2id)
+	if passwordHashAlgorithm != password.Argon2id && passwordHashAlgorithm != password.Bcrypt {
+		log.Fatalf("Invalid PASSWORD_HASH_ALGORITHM %q: must be %q or %q", passwordHashAlgorithm, password.Argon2id, password.Bcrypt)
+	}
+	argon2Parallelism := getPositiveInt("ARGON2_PARALLELISM", int(password.DefaultArgon2idParams.Parallelism))
+	if argon2Parallelism > 255 {
+		log.Fatal("ARGON2_PARALLELISM must be at most 255")
+	}
+	argon2id := password.DefaultArgon2idParams
+	argon2id.Memory = uint32(getPositiveInt("ARGON2_MEMORY", int(password.DefaultArgon2idParams.Memory)))
+	argon2id.Iterations = uint32(getPositiveInt("ARGON2_ITERATIONS", int(password.DefaultArgon2idParams.Iterations)))
+	argon2id.Parallelism = uint8(argon2Parallelism)
+	bcryptCost := getPositiveInt("BCRYPT_COST", password.DefaultBcryptCost)
+	if bcryptCost < 4 || bcryptCost > 31 {
+		log.Fatal("BCRYPT_COST must be between 4 and 31")
+	}
+
+	// bcrypt only accepts 72 bytes, so that is also the longest password allowed with it
+	passwordMaxLengthDefault := 128
+	if passwordHashAlgorithm == password.Bcrypt {
+		passwordMaxLengthDefault = password.BcryptMaxLength
+	}
+	passwordMinLength := getPositiveInt("PASSWORD_MIN_LENGTH", 8)
+	passwordMaxLength := getPositiveInt("PASSWORD_MAX_LENGTH", passwordMaxLengthDefault)
+	if passwordMaxLength < passwordMinLength {
+		log.Fatal("PASSWORD_MAX_LENGTH must be at least PASSWORD_MIN_LENGTH")
+	}
+	if passwordHashAlgorithm == password.Bcrypt && passwordMaxLength > password.BcryptMaxLength {
+		log.Fatalf("PASSWORD_MAX_LENGTH must be at most %d with bcrypt", password.BcryptMaxLength)
+	}
+	passwordBlocklist, err := strconv.ParseBool(getEnv("PASSWORD_BLOCKLIST", "true"))
+	if err != nil {
+		log.Fatal("Invalid PASSWORD_BLOCKLIST environment variable")
+	}
+
+	enforceTaskDependencies, err := strconv.ParseBool(getEnv("ENFORCE_TASK_DEPENDENCIES", "false"))
+	if err != nil {
+		log.Fatal("Invalid ENFORCE_TASK_DEPENDENCIES environment variable")
+	}
+
+	// Attachment configuration
+	blobStore := getEnv("BLOB_STORE", BlobStoreLocal)
+	if blobStore != BlobStoreLocal && blobStore != BlobStoreS3 {
+		log.Fatalf("Invalid BLOB_STORE %q: must be %q or %q", blobStore, BlobStoreLocal, BlobStoreS3)
+	}
+	s3Region := getEnv("S3_REGION", "us-east-1")
+	s3PathStyle, err := strconv.ParseBool(getEnv("S3_PATH_STYLE", "false"))
+	if err != nil {
+		log.Fatal("Invalid S3_PATH_STYLE environment variable")
+	}
+	attachmentAllowedTypes := defaultAttachmentTypes
+	if value := getEnv("ATTACHMENT_ALLOWED_TYPES", ""); value != "" {
+		attachmentAllowedTypes = nil
+		for _, mediaType := range strings.Split(value, ",") {
+			if mediaType = strings.ToLower(strings.TrimSpace(mediaType)); mediaType != "" {
+				attachmentAllowedTypes = append(attachmentAllowedTypes, mediaType)
+			}
+		}
+	}
+
+	cfg := &Config{
+		Storage:         storage,
+		AutoMigrate:     autoMigrate,
+		Port:            port,
+		Env:             env,
+		AccessTokenTTL:  accessTokenTTL,
+		RefreshTokenTTL: refreshTokenTTL,
+
+		JWTAlgorithm:      jwtAlgorithm,
+		JWTKey:            jwtKey,
+		JWTKeysDir:        getEnv("JWT_KEYS_DIR", "keys"),
+		JWTKeyRotation:    jwtKeyRotation,
+		JWTKeyGracePeriod: jwtKeyGracePeriod,
+
+		Mailer:       mailer,
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     smtpPort,
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		MailFrom:     getEnv("MAIL_FROM", "no-reply@localhost"),
+		OutboxDir:    getEnv("MAIL_OUTBOX_DIR", "outbox"),
+
+		AppURL:                   strings.TrimRight(getEnv("APP_URL", "http://localhost:3000"), "/"),
+		RequireEmailVerification: requireEmailVerification,
+		APIURL:                   strings.TrimRight(getEnv("API_URL", fmt.Sprintf("http://localhost:%d", port)), "/"),
+
+		OIDCProviders: loadOIDCProviders(),
+
+		TOTPIssuer: getEnv("TOTP_ISSUER", "Project Management"),
+
+		LoginTracker:          loginTracker,
+		LoginMaxFailures:      loginMaxFailures,
+		LoginMaxFailuresPerIP: loginMaxFailuresPerIP,
+		LoginBackoffBase:      getDuration("LOGIN_BACKOFF_BASE", time.Second),
+		LoginLockoutDuration:  getDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		LoginAttemptWindow:    getDuration("LOGIN_ATTEMPT_WINDOW", 15*time.Minute),
+		TrustProxyHeaders:     trustProxyHeaders,
+
+		PasswordHashAlgorithm: passwordHashAlgorithm,
+		Argon2id:              argon2id,
+		BcryptCost:            bcryptCost,
+
+		PasswordMinLength:     passwordMinLength,
+		PasswordMaxLength:     passwordMaxLength,
+		PasswordBlocklist:     passwordBlocklist,
+		PasswordBlocklistFile: getEnv("PASSWORD_BLOCKLIST_FILE", ""),
+
+		EnforceTaskDependencies: enforceTaskDependencies,
+
+		RecurrenceInterval: getDuration("RECURRENCE_INTERVAL", time.Minute),
+
+		BlobStore: blobStore,
+		BlobDir:   getEnv("BLOB_DIR", "uploads"),
+		S3: blobstore.S3Config{
+			Endpoint:        getEnv("S3_ENDPOINT", "https://s3."+s3Region+".amazonaws.com"),
+			Region:          s3Region,
+			Bucket:          getEnv("S3_BUCKET", ""),
+			AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+			SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+			Prefix:          getEnv("S3_PREFIX", ""),
+			PathStyle:       s3PathStyle,
+		},
+		BlobCleanupInterval: getDuration("BLOB_CLEANUP_INTERVAL", time.Hour),
+
+		AttachmentMaxSize:      int64(getPositiveInt("ATTACHMENT_MAX_SIZE", 10<<20)),
+		AttachmentAllowedTypes: attachmentAllowedTypes,
+	}
+
+	switch storage {
+	case StorageMemory:
+		log.Println("Running in memory mode")
+		return cfg
+	case StorageSQLite:
+		db, err := database.Open(database.SQLite, sqlitePath)
+		if err == nil {
+			err = db.Ping()
+		}
+		if err != nil {
+			log.Fatalf("Could not open SQLite database %s: %v", sqlitePath, err)
+		}
+		log.Println("Connected to SQLite database", sqlitePath)
+		cfg.DB = db
+		return cfg
+	case StoragePostgres:
+	default:
+		log.Fatalf("Invalid STORAGE_DRIVER %q: must be %q, %q or %q", storage, StoragePostgres, StorageSQLite, StorageMemory)
+	}
 
 	// Connect to database
 	dbInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		dbHost, dbPort, dbUser, dbPassword, dbName, sslMode)
 
-	db, err := sql.Open("postgres", dbInfo)
-	if err != nil {
-		log.Println("Warning: Could not connect to database:", err)
-		log.Println("Running in memory mode")
-	} else {
+	// Without a database every write would be lost, so only run in memory
+	// mode when STORAGE_DRIVER=memory asks for it
+	db, err := database.Open(database.Postgres, dbInfo)
+	if err == nil {
 		err = db.Ping()
+	}
+	if err != nil {
+		log.Fatalf("Could not connect to database: %v", err)
+	}
+
+	log.Println("Connected to database")
+	cfg.DB = db
+	return cfg
+}
+
+// loadOIDCProviders reads the identity providers listed in OIDC_PROVIDERS.
+// Each provider is configured with variables prefixed OIDC_<ID>_, e.g.
+// OIDC_CORP_ISSUER for the provider "corp".
+func loadOIDCProviders() []oidc.Config {
+	var providers []oidc.Config
+	for _, id := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if id == "" {
+			continue
+		}
+
+		invalid := strings.IndexFunc(id, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_')
+		})
+		if invalid >= 0 {
+			log.Fatalf("Invalid OIDC provider %q: use only letters, digits and underscores", id)
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(id) + "_"
+		provider := oidc.Config{
+			ID:           id,
+			Name:         getEnv(prefix+"NAME", id),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid profile email")),
+			GroupsClaim:  getEnv(prefix+"GROUPS_CLAIM", "groups"),
+			AdminGroups:  splitList(getEnv(prefix+"ADMIN_GROUPS", "")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			log.Fatalf("OIDC provider %q needs %sISSUER and %sCLIENT_ID", id, prefix, prefix)
+		}
+
+		var err error
+		provider.AutoProvision, err = strconv.ParseBool(getEnv(prefix+"AUTO_PROVISION", "true"))
+		if err != nil {
+			log.Fatalf("Invalid %sAUTO_PROVISION environment variable", prefix)
+		}
+		provider.TrustEmail, err = strconv.ParseBool(getEnv(prefix+"TRUST_EMAIL", "false"))
 		if err != nil {
-			log.Println("Warning: Could not ping database:", err)
-			log.Println("Running in memory mode")
-			db = nil
-		} else {
-			log.Println("Connected to database")
+			log.Fatalf("Invalid %sTRUST_EMAIL environment variable", prefix)
+		}
+
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
+	return items
+}
 
-	return &Config{
-		DB:     db,
-		Port:   port,
-		JWTKey: jwtKey,
+// isSecureJWTKey checks that an HS256 secret is neither a known placeholder nor too short
+func isSecureJWTKey(key string) bool {
+	for _, placeholder := range insecureJWTKeys {
+		if key == placeholder {
+			return false
+		}
 	}
+	return len(key) >= minJWTKeyLength
 }
 
 // getEnv gets an environment variable or returns a default value
@@ -77,37 +477,35 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
-// Close closes the database connection
-func (c *Config) Close() {
-	if c.DB != nil {
-		c.DB.Close()
+// getDuration gets a duration environment variable (e.g. "15m") or returns a default value
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
 	}
-}
-
-// InitDB initializes the database with the schema
-func (c *Config) InitDB() error {
-	if c.DB == nil {
-		return fmt.Errorf("database connection is not established")
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Fatalf("Invalid %s environment variable: %q", key, value)
 	}
+	return duration
+}
 
-	// Check if schema file exists
-	schemaFile := "schema.sql"
-	if _, err := os.Stat(schemaFile); os.IsNotExist(err) {
-		return fmt.Errorf("schema file not found: %s", schemaFile)
+// getPositiveInt gets a positive integer environment variable or returns a default value
+func getPositiveInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
 	}
-
-	// Read schema file
-	schema, err := os.ReadFile(schemaFile)
-	if err != nil {
-		return fmt.Errorf("error reading schema file: %v", err)
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Fatalf("Invalid %s environment variable: %q", key, value)
 	}
+	return n
+}
 
-	// Execute schema
-	_, err = c.DB.Exec(string(schema))
-	if err != nil {
-		return fmt.Errorf("error executing schema: %v", err)
+// Close closes the database connection
+func (c *Config) Close() {
+	if c.DB != nil {
+		c.DB.Close()
 	}
-
-	log.Println("Database schema initialized successfully")
-	return nil
 }
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"net/http"
+
+	"go-react-redux-app/middleware"
+	"go-react-redux-app/policy"
+	"go-react-redux-app/utils"
+)
+
+// authorize checks that the principal making the request may perform the
+// action on the resource, writing an error response and returning false otherwise
+func authorize(w http.ResponseWriter, r *http.Request, authz *policy.Policy, action policy.Action, resource policy.Resource) bool {
+	principal, err := middleware.GetPrincipalFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return false
+	}
+
+	allowed, err := authz.Can(principal, action, resource)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error checking access")
+		return false
+	}
+	if !allowed {
+		utils.RespondWithError(w, http.StatusForbidden, "Forbidden")
+		return false
+	}
+
+	return true
+}
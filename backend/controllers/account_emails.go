@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"go-react-redux-app/mailer"
+	"go-react-redux-app/models"
+	"go-react-redux-app/utils"
+)
+
+// Lifetimes of the single-use tokens sent by email
+const (
+	passwordResetTokenTTL     = time.Hour
+	emailVerificationTokenTTL = 48 * time.Hour
+)
+
+// accountEmails sends the emails containing single-use account links
+type accountEmails struct {
+	TokenStore models.UserTokenRepository
+	Mailer     mailer.Mailer
+	AppURL     string
+}
+
+// newAccountEmails creates a new accountEmails
+func newAccountEmails(tokenStore models.UserTokenRepository, mail mailer.Mailer, appURL string) *accountEmails {
+	return &accountEmails{
+		TokenStore: tokenStore,
+		Mailer:     mail,
+		AppURL:     appURL,
+	}
+}
+
+// sendVerification emails the user a link to verify their email address
+func (e *accountEmails) sendVerification(user *models.User) error {
+	token, err := e.createToken(user.ID, models.TokenPurposeEmailVerification, emailVerificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := e.AppURL + "/verify-email?token=" + url.QueryEscape(token)
+	return e.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease verify your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			user.Username, link, emailVerificationTokenTTL),
+	})
+}
+
+// sendPasswordReset emails the user a link to choose a new password
+func (e *accountEmails) sendPasswordReset(user *models.User) error {
+	token, err := e.createToken(user.ID, models.TokenPurposePasswordReset, passwordResetTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := e.AppURL + "/reset-password?token=" + url.QueryEscape(token)
+	return e.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. If it was you, open the link below to choose a new one:\n\n%s\n\nThe link expires in %s. If you didn't ask for this, you can ignore this email.\n",
+			user.Username, link, passwordResetTokenTTL),
+	})
+}
+
+// createToken stores a new single-use token for the user and returns its value.
+// Any earlier unused token for the same purpose stops working.
+func (e *accountEmails) createToken(userID string, purpose string, ttl time.Duration) (string, error) {
+	return createUserToken(e.TokenStore, userID, purpose, ttl)
+}
+
+// createUserToken stores a new single-use token for the user in a token store and returns its value
+func createUserToken(tokenStore models.UserTokenRepository, userID string, purpose string, ttl time.Duration) (string, error) {
+	value, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = tokenStore.Create(&models.UserToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(value),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
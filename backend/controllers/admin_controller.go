@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go-react-redux-app/lockout"
+	"go-react-redux-app/mailer"
+	"go-react-redux-app/middleware"
+	"go-react-redux-app/models"
+	"go-react-redux-app/utils"
+)
+
+// AdminController handles user management requests from admins
+type AdminController struct {
+	UserStore    models.UserRepository
+	SessionStore models.SessionRepository
+	TwoFactors   models.TwoFactorRepository
+	Logins       lockout.Tracker
+	Attempts     models.LoginAttemptRepository
+
+	emails *accountEmails
+}
+
+// NewAdminController creates a new AdminController
+func NewAdminController(userStore models.UserRepository, sessionStore models.SessionRepository, tokenStore models.UserTokenRepository, twoFactors models.TwoFactorRepository, logins lockout.Tracker, attempts models.LoginAttemptRepository, mail mailer.Mailer, appURL string) *AdminController {
+	return &AdminController{
+		UserStore:    userStore,
+		SessionStore: sessionStore,
+		TwoFactors:   twoFactors,
+		Logins:       logins,
+		Attempts:     attempts,
+
+		emails: newAccountEmails(tokenStore, mail, appURL),
+	}
+}
+
+// loginHistoryLimit is the number of login attempts returned by GetLoginAttempts
+const loginHistoryLimit = 50
+
+// RoleRequest represents a request to change a user's global role
+type RoleRequest struct {
+	Role string `json:"role"`
+}
+
+// ListUsers handles listing users. It supports the q (search), role, page and pageSize query parameters.
+func (c *AdminController) ListUsers(w http.ResponseWriter, r *http.Request) {
+	pagination, err := parsePagination(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	role := r.URL.Query().Get("role")
+	if role != "" && !models.IsValidUserRole(role) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Role must be user or admin")
+		return
+	}
+
+	users, total, err := c.UserStore.List(models.UserQuery{
+		Search: r.URL.Query().Get("q"),
+		Role:   role,
+		Limit:  pagination.PageSize,
+		Offset: pagination.Offset(),
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error listing users")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Users retrieved successfully", PageResponse{
+		Items:    users,
+		Total:    total,
+		Page:     pagination.Page,
+		PageSize: pagination.PageSize,
+	})
+}
+
+// GetUser handles getting a user by ID
+func (c *AdminController) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.targetUser(w, r, false)
+	if !ok {
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "User retrieved successfully", user)
+}
+
+// UpdateRole handles changing a user's global role
+func (c *AdminController) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.targetUser(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req RoleRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if !models.IsValidUserRole(req.Role) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Role must be user or admin")
+		return
+	}
+
+	user.Role = req.Role
+	err = c.UserStore.Update(user)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating role")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Role updated successfully", user)
+}
+
+// DisableUser handles disabling an account and revoking all of its sessions
+func (c *AdminController) DisableUser(w http.ResponseWriter, r *http.Request) {
+	c.setDisabled(w, r, true)
+}
+
+// EnableUser handles re-enabling a disabled account
+func (c *AdminController) EnableUser(w http.ResponseWriter, r *http.Request) {
+	c.setDisabled(w, r, false)
+}
+
+// ForcePasswordReset handles forcing a user to choose a new password. The
+// current password stops working, every session is revoked and the user is
+// emailed a password reset link.
+func (c *AdminController) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.targetUser(w, r, true)
+	if !ok {
+		return
+	}
+
+	// Replace the password with a random one nobody knows
+	password, err := utils.GenerateRandomToken(32)
+	if err == nil {
+		err = user.SetPassword(password)
+	}
+	if err == nil {
+		err = c.UserStore.UpdatePassword(user.ID, user.Password)
+	}
+	if err == nil {
+		err = c.SessionStore.RevokeAllForUser(user.ID)
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error resetting password")
+		return
+	}
+
+	if err := c.emails.sendPasswordReset(user); err != nil {
+		log.Printf("Error sending password reset email to user %s: %v", user.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Password was reset but the email could not be sent")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Password reset email sent", nil)
+}
+
+// ResetTwoFactor handles turning off two-factor authentication for a user
+// who lost both their authenticator and their recovery codes
+func (c *AdminController) ResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.targetUser(w, r, true)
+	if !ok {
+		return
+	}
+
+	if err := c.TwoFactors.Delete(user.ID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error resetting two-factor authentication")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication reset successfully", nil)
+}
+
+// UnlockUser handles clearing the failed logins of a locked account
+func (c *AdminController) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.targetUser(w, r, false)
+	if !ok {
+		return
+	}
+
+	if err := c.Logins.Unlock(user.Username); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error unlocking account")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Account unlocked successfully", nil)
+}
+
+// GetLoginAttempts handles getting the most recent login attempts for a user's username
+func (c *AdminController) GetLoginAttempts(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.targetUser(w, r, false)
+	if !ok {
+		return
+	}
+
+	attempts, err := c.Attempts.GetByUsername(user.Username, loginHistoryLimit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving login attempts")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Login attempts retrieved successfully", attempts)
+}
+
+// DeleteUser handles deleting a user along with the projects they own
+func (c *AdminController) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.targetUser(w, r, true)
+	if !ok {
+		return
+	}
+
+	err := c.UserStore.Delete(user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error deleting user")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "User deleted successfully", nil)
+}
+
+// setDisabled disables or re-enables the account in the request path
+func (c *AdminController) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	user, ok := c.targetUser(w, r, true)
+	if !ok {
+		return
+	}
+
+	err := c.UserStore.SetDisabled(user.ID, disabled)
+	if err == nil && disabled {
+		err = c.SessionStore.RevokeAllForUser(user.ID)
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating account")
+		return
+	}
+
+	user, err = c.UserStore.GetByID(user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating account")
+		return
+	}
+
+	message := "Account enabled successfully"
+	if disabled {
+		message = "Account disabled successfully"
+	}
+	utils.RespondWithSuccess(w, http.StatusOK, message, user)
+}
+
+// targetUser loads the user in the request path. Admins may not modify their
+// own account through these endpoints, so they can't lock themselves out.
+func (c *AdminController) targetUser(w http.ResponseWriter, r *http.Request, modify bool) (*models.User, bool) {
+	userID := mux.Vars(r)["id"]
+
+	if modify {
+		admin, err := middleware.GetUserFromContext(r.Context())
+		if err != nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return nil, false
+		}
+		if admin.ID == userID {
+			utils.RespondWithError(w, http.StatusBadRequest, "Admins cannot change their own account here")
+			return nil, false
+		}
+	}
+
+	user, err := c.UserStore.GetByID(userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found")
+			return nil, false
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	return user, true
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go-react-redux-app/middleware"
+	"go-react-redux-app/models"
+	"go-react-redux-app/utils"
+)
+
+// apiTokenPrefixLength is how many characters of a token are kept to identify it
+const apiTokenPrefixLength = len(models.APITokenPrefix) + 8
+
+// APITokenController handles requests of users managing their personal access tokens
+type APITokenController struct {
+	APITokenStore models.APITokenRepository
+}
+
+// NewAPITokenController creates a new APITokenController
+func NewAPITokenController(apiTokenStore models.APITokenRepository) *APITokenController {
+	return &APITokenController{
+		APITokenStore: apiTokenStore,
+	}
+}
+
+// CreateAPITokenRequest represents a request to create a personal access token
+type CreateAPITokenRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// CreateAPITokenResponse represents a newly created token. The token value is only ever returned here.
+type CreateAPITokenResponse struct {
+	*models.APIToken
+	Token string `json:"token"`
+}
+
+// GetTokens handles listing the personal access tokens of the authenticated user
+func (c *APITokenController) GetTokens(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	tokens, err := c.APITokenStore.GetByUser(user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Tokens retrieved successfully", tokens)
+}
+
+// CreateToken handles creating a personal access token
+func (c *APITokenController) CreateToken(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req CreateAPITokenRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate the request
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Token name is required")
+		return
+	}
+
+	if len(req.Scopes) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "At least one scope is required")
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !models.IsValidScope(scope) {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid scope: "+scope+" (available: "+strings.Join(models.Scopes, ", ")+")")
+			return
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Expiry must be in the future")
+		return
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating token")
+		return
+	}
+	value := models.APITokenPrefix + secret
+
+	token := &models.APIToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Name:      req.Name,
+		Prefix:    value[:apiTokenPrefixLength],
+		TokenHash: utils.HashToken(value),
+		Scopes:    req.Scopes,
+		CreatedAt: time.Now(),
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	err = c.APITokenStore.Create(token)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating token")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusCreated, "Token created successfully; copy it now, it won't be shown again", CreateAPITokenResponse{
+		APIToken: token,
+		Token:    value,
+	})
+}
+
+// DeleteToken handles revoking a personal access token of the authenticated user
+func (c *APITokenController) DeleteToken(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	err = c.APITokenStore.Delete(user.ID, mux.Vars(r)["id"])
+	if err != nil {
+		if err == models.ErrAPITokenNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Token not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Token revoked successfully", nil)
+}
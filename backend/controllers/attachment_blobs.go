@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"go-react-redux-app/blobstore"
+	"go-react-redux-app/models"
+)
+
+// blobLockStripes is the number of locks blob hashes are spread over
+const blobLockStripes = 16
+
+// AttachmentBlobs keeps attachment contents in the blob store, one blob per
+// distinct SHA-256 hash. Attachments disappear along with their tasks,
+// projects and uploaders, so instead of following every delete it collects
+// the blobs no attachment refers to anymore.
+type AttachmentBlobs struct {
+	Attachments models.AttachmentRepository
+	Blobs       blobstore.BlobStore
+
+	// locks keep a blob from being collected while it is being stored for a new attachment
+	locks   [blobLockStripes]sync.Mutex
+	trigger chan struct{}
+}
+
+// NewAttachmentBlobs creates a new AttachmentBlobs
+func NewAttachmentBlobs(attachments models.AttachmentRepository, blobs blobstore.BlobStore) *AttachmentBlobs {
+	return &AttachmentBlobs{
+		Attachments: attachments,
+		Blobs:       blobs,
+		trigger:     make(chan struct{}, 1),
+	}
+}
+
+// lock locks the stripe of a blob hash and returns its unlock function
+func (b *AttachmentBlobs) lock(hash string) func() {
+	var stripe byte
+	if hash != "" {
+		stripe = hash[0] % blobLockStripes
+	}
+	b.locks[stripe].Lock()
+	return b.locks[stripe].Unlock
+}
+
+// Save creates an attachment, storing its contents unless a blob with the same hash already exists
+func (b *AttachmentBlobs) Save(attachment *models.Attachment, content io.Reader) error {
+	unlock := b.lock(attachment.SHA256)
+	defer unlock()
+
+	stored := false
+	existing, err := b.Blobs.Open(attachment.SHA256)
+	switch err {
+	case nil:
+		existing.Close()
+	case blobstore.ErrNotFound:
+		if err := b.Blobs.Put(attachment.SHA256, content, attachment.Size, attachment.ContentType); err != nil {
+			return err
+		}
+		stored = true
+	default:
+		return err
+	}
+
+	if err := b.Attachments.Create(attachment); err != nil {
+		// Nothing records a blob stored just now, so Collect would never find it
+		if stored {
+			if deleteErr := b.Blobs.Delete(attachment.SHA256); deleteErr != nil {
+				log.Printf("Error deleting blob %s: %v", attachment.SHA256, deleteErr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// Collect removes the blobs no attachment refers to anymore
+func (b *AttachmentBlobs) Collect() error {
+	hashes, err := b.Attachments.GetOrphanedBlobs()
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if err := b.collect(hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collect removes one blob if it is still unreferenced
+func (b *AttachmentBlobs) collect(hash string) error {
+	unlock := b.lock(hash)
+	defer unlock()
+
+	deleted, err := b.Attachments.DeleteBlob(hash)
+	if err != nil || !deleted {
+		return err
+	}
+	return b.Blobs.Delete(hash)
+}
+
+// Trigger asks the background collection started by Start to run soon
+func (b *AttachmentBlobs) Trigger() {
+	select {
+	case b.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs Collect in the background every interval and whenever triggered
+func (b *AttachmentBlobs) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-b.trigger:
+			}
+			if err := b.Collect(); err != nil {
+				log.Printf("Error collecting attachment blobs: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go-react-redux-app/blobstore"
+	"go-react-redux-app/middleware"
+	"go-react-redux-app/models"
+	"go-react-redux-app/policy"
+	"go-react-redux-app/signing"
+)
+
+// blobTest keeps attachments of tasks in memory with their blobs in a mock S3 bucket
+type blobTest struct {
+	t        *testing.T
+	db       *models.MemoryDB
+	blobs    *AttachmentBlobs
+	store    *blobstore.S3Store
+	projects *models.MemoryProjectStore
+	tasks    *models.MemoryTaskStore
+	saved    int
+}
+
+func newBlobTest(t *testing.T) *blobTest {
+	t.Helper()
+
+	server := httptest.NewServer(blobstore.NewMockS3("test-key", "test-secret", "us-east-1"))
+	t.Cleanup(server.Close)
+	store, err := blobstore.NewS3Store(blobstore.S3Config{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "attachments",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+		PathStyle:       true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+
+	db := models.NewMemoryDB()
+	now := time.Now()
+	owner := &models.User{ID: "owner-id", Username: "owner", Email: "owner@example.com", Password: "correct horse battery", Role: models.UserRoleUser, CreatedAt: now, UpdatedAt: now}
+	if err := models.NewMemoryUserStore(db).Create(owner); err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	test := &blobTest{
+		t:        t,
+		db:       db,
+		blobs:    NewAttachmentBlobs(models.NewMemoryAttachmentStore(db), store),
+		store:    store,
+		projects: models.NewMemoryProjectStore(db),
+		tasks:    models.NewMemoryTaskStore(db),
+	}
+	if err := test.projects.Create(&models.Project{ID: "project-id", Name: "Project", OwnerID: owner.ID, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	return test
+}
+
+func (b *blobTest) createTask(id string) {
+	b.t.Helper()
+
+	now := time.Now()
+	err := b.tasks.Create(models.Task{ID: id, Title: id, Status: "Pending", StatusCategory: models.StatusCategoryTodo, Priority: "low", ProjectID: "project-id", CreatedAt: now, UpdatedAt: now})
+	if err != nil {
+		b.t.Fatalf("creating task: %v", err)
+	}
+}
+
+// attach saves an attachment with the given contents on a task and returns its blob key
+func (b *blobTest) attach(taskID string, content string) string {
+	b.t.Helper()
+
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+	b.saved++
+	attachment := &models.Attachment{
+		ID:          fmt.Sprintf("attachment-%d", b.saved),
+		TaskID:      taskID,
+		Filename:    "notes.txt",
+		ContentType: "text/plain",
+		Size:        int64(len(content)),
+		SHA256:      hash,
+		UploadedBy:  "owner-id",
+		CreatedAt:   time.Now(),
+	}
+	if err := b.blobs.Save(attachment, strings.NewReader(content)); err != nil {
+		b.t.Fatalf("saving attachment: %v", err)
+	}
+	return hash
+}
+
+func (b *blobTest) exists(hash string) bool {
+	b.t.Helper()
+
+	blob, err := b.store.Open(hash)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		b.t.Fatalf("opening blob: %v", err)
+	}
+	blob.Close()
+	return true
+}
+
+func TestDeletingTaskRemovesBlobs(t *testing.T) {
+	test := newBlobTest(t)
+	test.createTask("task-1")
+	test.createTask("task-2")
+
+	only := test.attach("task-1", "only on task 1")
+	shared := test.attach("task-1", "on both tasks")
+	if again := test.attach("task-2", "on both tasks"); again != shared {
+		t.Fatalf("identical contents got different blobs")
+	}
+	kept := test.attach("task-2", "only on task 2")
+
+	if err := test.tasks.Delete("task-1"); err != nil {
+		t.Fatalf("deleting task: %v", err)
+	}
+	if err := test.blobs.Collect(); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+
+	if test.exists(only) {
+		t.Error("blob of the deleted task was kept")
+	}
+	if !test.exists(shared) {
+		t.Error("blob still used by another task was removed")
+	}
+	if !test.exists(kept) {
+		t.Error("blob of another task was removed")
+	}
+}
+
+func TestDeletingProjectRemovesBlobs(t *testing.T) {
+	test := newBlobTest(t)
+	test.createTask("task-1")
+	test.createTask("task-2")
+	hashes := []string{test.attach("task-1", "first"), test.attach("task-2", "second")}
+
+	if err := test.projects.Delete("project-id"); err != nil {
+		t.Fatalf("deleting project: %v", err)
+	}
+	if err := test.blobs.Collect(); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+
+	for _, hash := range hashes {
+		if test.exists(hash) {
+			t.Errorf("blob %s of the deleted project was kept", hash[:8])
+		}
+	}
+}
+
+func TestSaveDeduplicatesBlobs(t *testing.T) {
+	test := newBlobTest(t)
+	test.createTask("task-1")
+
+	hash := test.attach("task-1", "same contents")
+	if err := test.blobs.Collect(); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if !test.exists(hash) {
+		t.Fatal("blob in use was collected")
+	}
+
+	// A second attachment reuses the stored blob
+	if again := test.attach("task-1", "same contents"); again != hash {
+		t.Fatalf("identical contents got different blobs")
+	}
+	attachments, err := test.blobs.Attachments.GetByTask("task-1")
+	if err != nil || len(attachments) != 2 {
+		t.Fatalf("attachments = %d, %v, want 2", len(attachments), err)
+	}
+}
+
+func TestSaveRemovesBlobWhenCreateFails(t *testing.T) {
+	test := newBlobTest(t)
+
+	content := "attached to a missing task"
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+	attachment := &models.Attachment{
+		ID:          "attachment-1",
+		TaskID:      "missing-task",
+		Filename:    "notes.txt",
+		ContentType: "text/plain",
+		Size:        int64(len(content)),
+		SHA256:      hash,
+		UploadedBy:  "owner-id",
+		CreatedAt:   time.Now(),
+	}
+	if err := test.blobs.Save(attachment, strings.NewReader(content)); err == nil {
+		t.Fatal("Save attached a file to a missing task")
+	}
+	if test.exists(hash) {
+		t.Error("blob of the failed attachment was kept")
+	}
+}
+
+// failingBlobStore fails every Put after the first puts ones
+type failingBlobStore struct {
+	blobstore.BlobStore
+	puts int
+}
+
+func (f *failingBlobStore) Put(key string, r io.Reader, size int64, contentType string) error {
+	if f.puts == 0 {
+		return errors.New("blob store is full")
+	}
+	f.puts--
+	return f.BlobStore.Put(key, r, size, contentType)
+}
+
+func TestUploadDiscardsSavedAttachmentsOnFailure(t *testing.T) {
+	test := newBlobTest(t)
+	test.createTask("task-1")
+	test.blobs.Blobs = &failingBlobStore{BlobStore: test.store, puts: 1}
+
+	users := models.NewMemoryUserStore(test.db)
+	sessions := models.NewMemorySessionStore(test.db)
+	now := time.Now()
+	if err := sessions.Create(&models.Session{ID: "session-id", UserID: "owner-id", CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("creating session: %v", err)
+	}
+	auth := middleware.NewAuth(signing.NewHMACKeyManager("a test secret that is long enough"), time.Minute, sessions, users, models.NewMemoryAPITokenStore(test.db), false)
+	token, err := auth.GenerateToken("owner-id", "owner", models.UserRoleUser, "session-id")
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	controller := NewAttachmentController(test.blobs.Attachments, test.tasks, test.blobs, policy.New(test.projects), 1<<20, []string{"text/plain"})
+	router := mux.NewRouter()
+	router.Handle("/api/tasks/{id}/attachments", auth.Middleware(http.HandlerFunc(controller.UploadAttachments))).Methods("POST")
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for _, content := range []string{"first file", "second file"} {
+		part, err := form.CreateFormFile("file", "notes.txt")
+		if err != nil {
+			t.Fatalf("CreateFormFile: %v", err)
+		}
+		part.Write([]byte(content))
+	}
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/task-1/attachments", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("upload returned %d, want %d: %s", rec.Code, http.StatusInternalServerError, rec.Body.String())
+	}
+
+	attachments, err := test.blobs.Attachments.GetByTask("task-1")
+	if err != nil || len(attachments) != 0 {
+		t.Fatalf("attachments = %d, %v, want none", len(attachments), err)
+	}
+	if err := test.blobs.Collect(); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	sum := sha256.Sum256([]byte("first file"))
+	if test.exists(hex.EncodeToString(sum[:])) {
+		t.Error("blob of the discarded attachment was kept")
+	}
+}
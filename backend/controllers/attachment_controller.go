@@ -0,0 +1,433 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go-react-redux-app/blobstore"
+	"go-react-redux-app/middleware"
+	"go-react-redux-app/models"
+	"go-react-redux-app/policy"
+	"go-react-redux-app/utils"
+)
+
+// Upload limits that don't depend on configuration
+const (
+	maxFilesPerUpload = 10
+	maxFilenameLength = 255
+	// multipartOverhead allows for the part headers and boundaries of an upload
+	multipartOverhead = 1 << 20
+	// sniffLength is how much of a file content sniffing looks at
+	sniffLength = 512
+)
+
+// attachmentTypeRefinements maps file extensions to the specific type of
+// files that content sniffing only recognizes as a generic container
+var attachmentTypeRefinements = map[string]map[string]string{
+	"application/zip": {
+		".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	},
+	"text/plain": {
+		".csv":      "text/csv",
+		".md":       "text/markdown",
+		".markdown": "text/markdown",
+	},
+}
+
+// AttachmentController handles requests for files attached to tasks
+type AttachmentController struct {
+	Attachments models.AttachmentRepository
+	TaskStore   models.TaskRepository
+	Blobs       *AttachmentBlobs
+	Policy      *policy.Policy
+	// MaxSize is the largest file accepted, in bytes
+	MaxSize int64
+	// AllowedTypes are the accepted MIME types; "image/*" accepts every image type
+	AllowedTypes []string
+}
+
+// NewAttachmentController creates a new AttachmentController
+func NewAttachmentController(attachments models.AttachmentRepository, taskStore models.TaskRepository, blobs *AttachmentBlobs, authz *policy.Policy, maxSize int64, allowedTypes []string) *AttachmentController {
+	return &AttachmentController{
+		Attachments:  attachments,
+		TaskStore:    taskStore,
+		Blobs:        blobs,
+		Policy:       authz,
+		MaxSize:      maxSize,
+		AllowedTypes: allowedTypes,
+	}
+}
+
+// upload is an uploaded file spooled to a temporary file and checked
+type upload struct {
+	file        *os.File
+	filename    string
+	contentType string
+	size        int64
+	hash        string
+}
+
+// GetAttachments handles listing a task's attachments
+func (c *AttachmentController) GetAttachments(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, policy.ActionView)
+	if !ok {
+		return
+	}
+
+	attachments, err := c.Attachments.GetByTask(task.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving attachments")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Attachments retrieved successfully", attachments)
+}
+
+// UploadAttachments handles attaching files to a task. The files are sent as
+// multipart/form-data in one or more "file" fields. If any file is
+// rejected, none is attached.
+func (c *AttachmentController) UploadAttachments(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, policy.ActionUpdate)
+	if !ok {
+		return
+	}
+
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, c.MaxSize*maxFilesPerUpload+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Request must be multipart/form-data")
+		return
+	}
+
+	var uploads []*upload
+	defer func() {
+		for _, u := range uploads {
+			u.file.Close()
+			os.Remove(u.file.Name())
+		}
+	}()
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			c.respondWithUploadError(w, err)
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+		if len(uploads) == maxFilesPerUpload {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("At most %d files can be uploaded at once", maxFilesPerUpload))
+			return
+		}
+
+		u, err := c.spool(part)
+		part.Close()
+		if u != nil {
+			uploads = append(uploads, u)
+		}
+		if err != nil {
+			c.respondWithUploadError(w, err)
+			return
+		}
+	}
+
+	if len(uploads) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "No files uploaded; send them in the file field")
+		return
+	}
+
+	attachments := make([]*models.Attachment, 0, len(uploads))
+	for _, u := range uploads {
+		if _, err := u.file.Seek(0, io.SeekStart); err != nil {
+			c.discard(attachments)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error storing attachment")
+			return
+		}
+
+		attachment := &models.Attachment{
+			ID:          uuid.New().String(),
+			TaskID:      task.ID,
+			Filename:    u.filename,
+			ContentType: u.contentType,
+			Size:        u.size,
+			SHA256:      u.hash,
+			UploadedBy:  user.ID,
+			CreatedAt:   time.Now(),
+		}
+		if err := c.Blobs.Save(attachment, u.file); err != nil {
+			c.discard(attachments)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error storing attachment")
+			return
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	utils.RespondWithSuccess(w, http.StatusCreated, "Attachments uploaded successfully", attachments)
+}
+
+// discard deletes the attachments saved so far by a failed upload. Their
+// blobs are left for the collection, which is asked to run.
+func (c *AttachmentController) discard(attachments []*models.Attachment) {
+	for _, attachment := range attachments {
+		if err := c.Attachments.Delete(attachment.ID); err != nil {
+			log.Printf("Error deleting attachment %s: %v", attachment.ID, err)
+		}
+	}
+	if len(attachments) > 0 {
+		c.Blobs.Trigger()
+	}
+}
+
+// uploadError is a rejected upload, reported to the client with its status
+type uploadError struct {
+	status  int
+	message string
+}
+
+// Error returns the message for the client
+func (e *uploadError) Error() string {
+	return e.message
+}
+
+// respondWithUploadError reports why an upload failed
+func (c *AttachmentController) respondWithUploadError(w http.ResponseWriter, err error) {
+	var rejected *uploadError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &rejected):
+		utils.RespondWithError(w, rejected.status, rejected.message)
+	case errors.As(err, &tooLarge):
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge, "Upload is too large")
+	default:
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid multipart upload")
+	}
+}
+
+// spool copies an uploaded file to a temporary file, hashing it on the way,
+// and checks its size and type. The upload is returned even when rejected so
+// that its temporary file gets removed.
+func (c *AttachmentController) spool(part *multipart.Part) (*upload, error) {
+	filename := sanitizeFilename(part.FileName())
+	if filename == "" {
+		return nil, &uploadError{http.StatusBadRequest, "Every file needs a filename"}
+	}
+
+	file, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		return nil, err
+	}
+	u := &upload{file: file, filename: filename}
+
+	hash := sha256.New()
+	u.size, err = io.Copy(io.MultiWriter(file, hash), io.LimitReader(part, c.MaxSize+1))
+	if err != nil {
+		return u, err
+	}
+	if u.size > c.MaxSize {
+		return u, &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("%s is larger than the %s limit", filename, formatSize(c.MaxSize))}
+	}
+	if u.size == 0 {
+		return u, &uploadError{http.StatusBadRequest, filename + " is empty"}
+	}
+	u.hash = hex.EncodeToString(hash.Sum(nil))
+
+	head := make([]byte, sniffLength)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return u, err
+	}
+	u.contentType = detectContentType(head[:n], filename)
+
+	mediaType, _, _ := mime.ParseMediaType(u.contentType)
+	if !isAllowedType(mediaType, c.AllowedTypes) {
+		return u, &uploadError{http.StatusUnsupportedMediaType, fmt.Sprintf("%s has a file type that is not allowed (%s)", filename, mediaType)}
+	}
+	return u, nil
+}
+
+// DownloadAttachment handles streaming an attachment. Range requests are
+// supported. Images and PDFs are shown in the browser with ?inline=true;
+// everything else is always downloaded.
+func (c *AttachmentController) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, policy.ActionView)
+	if !ok {
+		return
+	}
+	attachment, ok := c.loadAttachment(w, r, task)
+	if !ok {
+		return
+	}
+
+	blob, err := c.Blobs.Blobs.Open(attachment.SHA256)
+	if err != nil {
+		if err == blobstore.ErrNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Attachment contents not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error opening attachment")
+		return
+	}
+	defer blob.Close()
+
+	disposition := "attachment"
+	mediaType, _, _ := mime.ParseMediaType(attachment.ContentType)
+	if inline, _ := strconv.ParseBool(r.URL.Query().Get("inline")); inline && (strings.HasPrefix(mediaType, "image/") || mediaType == "application/pdf") {
+		disposition = "inline"
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private")
+	w.Header().Set("ETag", `"`+attachment.SHA256+`"`)
+	http.ServeContent(w, r, "", attachment.CreatedAt, blob)
+}
+
+// DeleteAttachment handles removing an attachment from a task. Its blob is
+// removed from the blob store once no other attachment has the same contents.
+func (c *AttachmentController) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, policy.ActionUpdate)
+	if !ok {
+		return
+	}
+	attachment, ok := c.loadAttachment(w, r, task)
+	if !ok {
+		return
+	}
+
+	if err := c.Attachments.Delete(attachment.ID); err != nil {
+		if err == models.ErrAttachmentNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Attachment not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error deleting attachment")
+		return
+	}
+	c.Blobs.Trigger()
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Attachment deleted successfully", nil)
+}
+
+// loadTask loads the task in the path after checking that the user may perform the action on it
+func (c *AttachmentController) loadTask(w http.ResponseWriter, r *http.Request, action policy.Action) (*models.Task, bool) {
+	task, err := c.TaskStore.GetByID(mux.Vars(r)["id"])
+	if err != nil {
+		if err == models.ErrTaskNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Task not found")
+			return nil, false
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	if !authorize(w, r, c.Policy, action, policy.Task(task.ProjectID)) {
+		return nil, false
+	}
+	return task, true
+}
+
+// loadAttachment loads the attachment in the path, which has to be on the task
+func (c *AttachmentController) loadAttachment(w http.ResponseWriter, r *http.Request, task *models.Task) (*models.Attachment, bool) {
+	attachment, err := c.Attachments.GetByID(mux.Vars(r)["attachmentId"])
+	if err != nil && err != models.ErrAttachmentNotFound {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving attachment")
+		return nil, false
+	}
+	if err == models.ErrAttachmentNotFound || attachment.TaskID != task.ID {
+		utils.RespondWithError(w, http.StatusNotFound, "Attachment not found")
+		return nil, false
+	}
+	return attachment, true
+}
+
+// detectContentType determines the MIME type of a file from its first bytes.
+// The filename only narrows down generic types, so a renamed file cannot
+// pass for a different kind of file.
+func detectContentType(head []byte, filename string) string {
+	sniffed := http.DetectContentType(head)
+	mediaType, params, err := mime.ParseMediaType(sniffed)
+	if err != nil {
+		return "application/octet-stream"
+	}
+
+	if refined, ok := attachmentTypeRefinements[mediaType][strings.ToLower(filepath.Ext(filename))]; ok {
+		return mime.FormatMediaType(refined, params)
+	}
+	return sniffed
+}
+
+// isAllowedType checks a media type against a list of allowed types, which
+// may end in /* to allow a whole top-level type
+func isAllowedType(mediaType string, allowed []string) bool {
+	for _, candidate := range allowed {
+		if candidate == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(candidate, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeFilename strips directories and control characters from an
+// uploaded filename and shortens it to fit the database column
+func sanitizeFilename(name string) string {
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+
+	for len(name) > maxFilenameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
+
+// formatSize formats a number of bytes for error messages
+func formatSize(bytes int64) string {
+	switch {
+	case bytes >= 1<<20:
+		return strconv.FormatFloat(float64(bytes)/(1<<20), 'f', -1, 64) + " MB"
+	case bytes >= 1<<10:
+		return strconv.FormatFloat(float64(bytes)/(1<<10), 'f', -1, 64) + " KB"
+	default:
+		return strconv.FormatInt(bytes, 10) + " bytes"
+	}
+}
@@ -2,29 +2,71 @@ package controllers
 
 import (
 	"encoding/json"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
+	"go-react-redux-app/lockout"
+	"go-react-redux-app/mailer"
 	"go-react-redux-app/middleware"
 	"go-react-redux-app/models"
+	"go-react-redux-app/password"
 	"go-react-redux-app/utils"
 )
 
+// AuthSettings holds the configurable behaviour of the AuthController
+type AuthSettings struct {
+	RefreshTokenTTL time.Duration
+	// AppURL is the frontend address links in emails point to
+	AppURL string
+	// RequireEmailVerification blocks logins until the email address is verified
+	RequireEmailVerification bool
+	// TrustProxyHeaders takes the client IP from proxy headers for login throttling and sessions
+	TrustProxyHeaders bool
+	// PasswordPolicy is enforced on registration and password resets
+	PasswordPolicy *password.Policy
+}
+
 // AuthController handles authentication requests
 type AuthController struct {
-	UserStore *models.UserStore
-	Auth      *middleware.Auth
+	UserStore    models.UserRepository
+	SessionStore models.SessionRepository
+	TokenStore   models.UserTokenRepository
+	TwoFactors   models.TwoFactorRepository
+	Auth         *middleware.Auth
+	Mailer       mailer.Mailer
+	Logins       lockout.Tracker
+	Settings     AuthSettings
+
+	emails *accountEmails
 }
 
 // NewAuthController creates a new AuthController
-func NewAuthController(userStore *models.UserStore, auth *middleware.Auth) *AuthController {
+func NewAuthController(userStore models.UserRepository, sessionStore models.SessionRepository, tokenStore models.UserTokenRepository, twoFactors models.TwoFactorRepository, auth *middleware.Auth, mail mailer.Mailer, logins lockout.Tracker, settings AuthSettings) *AuthController {
 	return &AuthController{
-		UserStore: userStore,
-		Auth:      auth,
+		UserStore:    userStore,
+		SessionStore: sessionStore,
+		TokenStore:   tokenStore,
+		TwoFactors:   twoFactors,
+		Auth:         auth,
+		Mailer:       mail,
+		Logins:       logins,
+		Settings:     settings,
+
+		emails: newAccountEmails(tokenStore, mail, settings.AppURL),
 	}
 }
 
+// MFAChallengeResponse represents the response to a correct password when the
+// user has two-factor authentication enabled
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfaRequired"`
+	MFAToken    string `json:"mfaToken"`
+}
+
 // RegisterRequest represents a request to register a new user
 type RegisterRequest struct {
 	Username  string `json:"username"`
@@ -40,10 +82,44 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// TwoFactorLoginRequest represents the second step of a two-factor login
+type TwoFactorLoginRequest struct {
+	MFAToken string `json:"mfaToken"`
+	// Code is a TOTP code or a recovery code
+	Code string `json:"code"`
+}
+
+// OIDCExchangeRequest represents a request to exchange the one-time code of an SSO login for tokens
+type OIDCExchangeRequest struct {
+	Code string `json:"code"`
+}
+
+// RefreshRequest represents a request to exchange a refresh token
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// EmailRequest represents a request that only carries an email address
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest represents a request to set a new password with a reset token
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// VerifyEmailRequest represents a request to verify an email address
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
 // AuthResponse represents a response to an authentication request
 type AuthResponse struct {
-	Token string       `json:"token"`
-	User  *models.User `json:"user"`
+	Token        string       `json:"token"`
+	RefreshToken string       `json:"refreshToken"`
+	User         *models.User `json:"user"`
 }
 
 // Register handles user registration
@@ -61,6 +137,11 @@ func (c *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := c.Settings.PasswordPolicy.Validate(req.Password, req.Username, req.Email); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Check if the username is already taken
 	_, err = c.UserStore.GetByUsername(req.Username)
 	if err == nil {
@@ -76,7 +157,7 @@ func (c *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 		Password:  req.Password,
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
-		Role:      "user",
+		Role:      models.UserRoleUser,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -87,21 +168,29 @@ func (c *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate a token
-	token, err := c.Auth.GenerateToken(user.ID, user.Username, user.Role)
+	// A failed email must not fail the registration; the user can ask for it again
+	if err := c.emails.sendVerification(user); err != nil {
+		log.Printf("Error sending verification email to user %s: %v", user.ID, err)
+	}
+
+	// Unverified users cannot log in, so don't hand out a session either
+	if c.Settings.RequireEmailVerification {
+		user.Password = ""
+		utils.RespondWithSuccess(w, http.StatusCreated, "User registered successfully, please verify your email address", map[string]interface{}{
+			"user": user,
+		})
+		return
+	}
+
+	// Start a session and generate its tokens
+	response, err := c.startSession(r, user)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error generating token")
 		return
 	}
 
-	// Remove the password from the user object
-	user.Password = ""
-
-	// Return the token and user
-	utils.RespondWithSuccess(w, http.StatusCreated, "User registered successfully", AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	// Return the tokens and user
+	utils.RespondWithSuccess(w, http.StatusCreated, "User registered successfully", response)
 }
 
 // Login handles user login
@@ -119,34 +208,228 @@ func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse without checking the password while the account or IP is throttled
+	ip := utils.ClientIP(r, c.Settings.TrustProxyHeaders)
+	if c.throttled(w, req.Username, "", ip) {
+		return
+	}
+
 	// Get the user
 	user, err := c.UserStore.GetByUsername(req.Username)
 	if err != nil {
+		c.recordLogin(req.Username, "", ip, models.LoginFailed)
 		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid username or password")
 		return
 	}
 
 	// Check the password
 	if !user.CheckPassword(req.Password) {
+		c.recordLogin(req.Username, user.ID, ip, models.LoginFailed)
 		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid username or password")
 		return
 	}
 
-	// Generate a token
-	token, err := c.Auth.GenerateToken(user.ID, user.Username, user.Role)
+	// Upgrade hashes made with an older algorithm or parameters while the password is at hand
+	if user.PasswordNeedsRehash() {
+		c.rehashPassword(user, req.Password)
+	}
+
+	twoFactor, err := c.TwoFactors.Get(user.ID)
+	if err != nil && err != models.ErrTwoFactorNotFound {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	mfaRequired := err == nil && twoFactor.IsEnabled()
+
+	// A refused login doesn't clear earlier failures
+	if !c.checkAccount(w, user) {
+		c.recordLogin(req.Username, user.ID, ip, models.LoginRefused)
+		return
+	}
+
+	// A login waiting for its second factor doesn't clear earlier failures yet
+	if mfaRequired {
+		c.recordLogin(req.Username, user.ID, ip, models.LoginChallenged)
+	} else {
+		c.recordLogin(req.Username, user.ID, ip, models.LoginSucceeded)
+	}
+
+	// Hand out a challenge token instead of a session until the second factor is checked
+	if mfaRequired {
+		mfaToken, err := c.Auth.GenerateMFAToken(user.ID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error generating token")
+			return
+		}
+		utils.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication required", MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		})
+		return
+	}
+
+	// Start a session and generate its tokens
+	response, err := c.startSession(r, user)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error generating token")
 		return
 	}
 
-	// Remove the password from the user object
-	user.Password = ""
+	// Return the tokens and user
+	utils.RespondWithSuccess(w, http.StatusOK, "Login successful", response)
+}
 
-	// Return the token and user
-	utils.RespondWithSuccess(w, http.StatusOK, "Login successful", AuthResponse{
-		Token: token,
-		User:  user,
-	})
+// LoginTwoFactor handles the second step of a two-factor login: it exchanges
+// the challenge token from Login and a TOTP or recovery code for a session.
+// Wrong codes count as failed logins, so guessing them is throttled too.
+func (c *AuthController) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorLoginRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.MFAToken == "" || req.Code == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Challenge token and code are required")
+		return
+	}
+
+	userID, err := c.Auth.ParseMFAToken(req.MFAToken)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge token, please log in again")
+		return
+	}
+
+	user, err := c.UserStore.GetByID(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge token, please log in again")
+		return
+	}
+
+	ip := utils.ClientIP(r, c.Settings.TrustProxyHeaders)
+	if c.throttled(w, user.Username, user.ID, ip) {
+		return
+	}
+
+	// Two-factor authentication may have been reset since the challenge was issued
+	twoFactor, err := c.TwoFactors.Get(user.ID)
+	if err != nil || !twoFactor.IsEnabled() {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge token, please log in again")
+		return
+	}
+
+	valid, err := verifySecondFactor(c.TwoFactors, twoFactor, req.Code)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error verifying code")
+		return
+	}
+	if !valid {
+		c.recordLogin(user.Username, user.ID, ip, models.LoginFailed)
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid two-factor code")
+		return
+	}
+	if !c.checkAccount(w, user) {
+		c.recordLogin(user.Username, user.ID, ip, models.LoginRefused)
+		return
+	}
+	c.recordLogin(user.Username, user.ID, ip, models.LoginSucceeded)
+
+	response, err := c.startSession(r, user)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Login successful", response)
+}
+
+// ExchangeOIDCCode handles exchanging the one-time code the frontend
+// receives at the end of an SSO login for a session. Two-factor
+// authentication is left to the identity provider.
+func (c *AuthController) ExchangeOIDCCode(w http.ResponseWriter, r *http.Request) {
+	var req OIDCExchangeRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Code == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Code is required")
+		return
+	}
+
+	token, err := c.TokenStore.Consume(utils.HashToken(req.Code), models.TokenPurposeOIDCLogin)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired code")
+		return
+	}
+
+	user, err := c.UserStore.GetByID(token.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired code")
+		return
+	}
+
+	if !c.checkAccount(w, user) {
+		return
+	}
+	c.recordLogin(user.Username, user.ID, utils.ClientIP(r, c.Settings.TrustProxyHeaders), models.LoginSucceeded)
+
+	response, err := c.startSession(r, user)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Login successful", response)
+}
+
+// throttled checks if logins for the username or from the IP address must
+// wait because of earlier failures. It writes a 429 response with a
+// Retry-After header and returns true if so.
+func (c *AuthController) throttled(w http.ResponseWriter, username string, userID string, ip string) bool {
+	wait, err := c.Logins.Check(username, ip)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return true
+	}
+	if wait <= 0 {
+		return false
+	}
+
+	c.recordLogin(username, userID, ip, models.LoginBlocked)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	utils.RespondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts, please try again later")
+	return true
+}
+
+// checkAccount refuses logins to disabled and, if required, unverified
+// accounts. It writes an error response and returns false if the user may
+// not log in.
+func (c *AuthController) checkAccount(w http.ResponseWriter, user *models.User) bool {
+	if user.IsDisabled() {
+		utils.RespondWithError(w, http.StatusForbidden, "Account is disabled")
+		return false
+	}
+
+	if c.Settings.RequireEmailVerification && !user.EmailVerified {
+		utils.RespondWithError(w, http.StatusForbidden, "Email address has not been verified")
+		return false
+	}
+
+	return true
+}
+
+// rehashPassword stores a new hash of the user's password. Failing to do so
+// doesn't fail the login; the old hash keeps working.
+func (c *AuthController) rehashPassword(user *models.User, plain string) {
+	if err := user.SetPassword(plain); err != nil {
+		log.Printf("Error rehashing password of user %s: %v", user.ID, err)
+		return
+	}
+	if err := c.UserStore.UpdatePassword(user.ID, user.Password); err != nil {
+		log.Printf("Error storing rehashed password of user %s: %v", user.ID, err)
+	}
+}
+
+// recordLogin records the outcome of a login. A failure to record must not
+// fail the login itself, so it is only logged.
+func (c *AuthController) recordLogin(username string, userID string, ip string, outcome string) {
+	if err := c.Logins.Record(username, userID, ip, outcome); err != nil {
+		log.Printf("Error recording login attempt for %q: %v", username, err)
+	}
 }
 
 // VerifyToken handles token verification
@@ -174,3 +457,272 @@ func (c *AuthController) VerifyToken(w http.ResponseWriter, r *http.Request) {
 		"user": dbUser,
 	})
 }
+
+// Refresh handles exchanging a refresh token for a new access token. Refresh
+// tokens rotate on every use; presenting one that was already used means it
+// leaked, so the whole session is revoked.
+func (c *AuthController) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.RefreshToken == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Refresh token is required")
+		return
+	}
+
+	refreshToken, err := c.SessionStore.GetRefreshTokenByHash(utils.HashToken(req.RefreshToken))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+
+	session, err := c.SessionStore.GetByID(refreshToken.SessionID)
+	if err != nil || !session.IsActive() {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Session has been revoked or has expired")
+		return
+	}
+
+	// Claim the token; only one request can win, any other use is a replay
+	fresh, err := c.SessionStore.MarkRefreshTokenUsed(refreshToken.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error refreshing token")
+		return
+	}
+	if !fresh {
+		log.Printf("Refresh token reuse detected for session %s, revoking it", session.ID)
+		c.SessionStore.Revoke(session.ID)
+		utils.RespondWithError(w, http.StatusUnauthorized, "Refresh token has already been used")
+		return
+	}
+
+	if time.Now().After(refreshToken.ExpiresAt) {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Refresh token has expired")
+		return
+	}
+
+	user, err := c.UserStore.GetByID(session.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	if user.IsDisabled() {
+		utils.RespondWithError(w, http.StatusForbidden, "Account is disabled")
+		return
+	}
+
+	response, err := c.issueTokens(user, session.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+
+	if err := c.SessionStore.Touch(session.ID, utils.ClientIP(r, c.Settings.TrustProxyHeaders), time.Now()); err != nil {
+		log.Printf("Error updating last use of session %s: %v", session.ID, err)
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Token refreshed successfully", response)
+}
+
+// Logout handles revoking the session the request was authenticated with
+func (c *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := middleware.GetSessionIDFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	err = c.SessionStore.Revoke(sessionID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error logging out")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Logged out successfully", nil)
+}
+
+// ForgotPassword handles sending a password reset link. It responds the same
+// way whether or not the email belongs to an account, so it can't be used to
+// discover registered addresses.
+func (c *AuthController) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req EmailRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Email == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+
+	user, err := c.UserStore.GetByEmail(req.Email)
+	if err == nil {
+		if err := c.emails.sendPasswordReset(user); err != nil {
+			log.Printf("Error sending password reset email to user %s: %v", user.ID, err)
+		}
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "If an account with that email exists, a password reset link has been sent", nil)
+}
+
+// ResetPassword handles setting a new password with a password reset token.
+// Every session of the user is revoked afterwards.
+func (c *AuthController) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Token == "" || req.Password == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Token and password are required")
+		return
+	}
+
+	// The token is only used up once the new password passes the policy
+	token, err := c.TokenStore.Get(utils.HashToken(req.Token), models.TokenPurposePasswordReset)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+
+	user, err := c.UserStore.GetByID(token.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+
+	if err := c.Settings.PasswordPolicy.Validate(req.Password, user.Username, user.Email); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := user.SetPassword(req.Password); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error resetting password")
+		return
+	}
+
+	if err := c.TokenStore.ResetPassword(token, user.Password); err != nil {
+		if err == models.ErrUserTokenInvalid {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error resetting password")
+		return
+	}
+
+	// Following the emailed link proves the user owns the address
+	if !user.EmailVerified {
+		if err := c.UserStore.SetEmailVerified(user.ID, true); err != nil {
+			log.Printf("Error verifying email of user %s: %v", user.ID, err)
+		}
+	}
+
+	if err := c.SessionStore.RevokeAllForUser(user.ID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error revoking sessions")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Password has been reset", nil)
+}
+
+// VerifyEmail handles verifying an email address with a verification token
+func (c *AuthController) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Token == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	token, err := c.TokenStore.Consume(utils.HashToken(req.Token), models.TokenPurposeEmailVerification)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+
+	if err := c.UserStore.SetEmailVerified(token.UserID, true); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error verifying email")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Email address verified", nil)
+}
+
+// ResendVerification handles sending a new email verification link. Like
+// ForgotPassword, the response does not reveal whether the account exists.
+func (c *AuthController) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req EmailRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Email == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+
+	user, err := c.UserStore.GetByEmail(req.Email)
+	if err == nil && !user.EmailVerified {
+		if err := c.emails.sendVerification(user); err != nil {
+			log.Printf("Error sending verification email to user %s: %v", user.ID, err)
+		}
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "If an unverified account with that email exists, a verification link has been sent", nil)
+}
+
+// startSession creates a new session for the user, recording the device the
+// request came from, and issues its first tokens
+func (c *AuthController) startSession(r *http.Request, user *models.User) (*AuthResponse, error) {
+	now := time.Now()
+	session := &models.Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		UserAgent:  utils.UserAgent(r),
+		IP:         utils.ClientIP(r, c.Settings.TrustProxyHeaders),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(c.Settings.RefreshTokenTTL),
+	}
+
+	if err := c.SessionStore.Create(session); err != nil {
+		return nil, err
+	}
+
+	return c.issueTokens(user, session.ID)
+}
+
+// issueTokens generates an access token and a new refresh token for a session
+func (c *AuthController) issueTokens(user *models.User, sessionID string) (*AuthResponse, error) {
+	token, err := c.Auth.GenerateToken(user.ID, user.Username, user.Role, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(c.Settings.RefreshTokenTTL)
+	err = c.SessionStore.CreateRefreshToken(&models.RefreshToken{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		TokenHash: utils.HashToken(refreshToken),
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Each refresh keeps the session alive for another refresh token lifetime
+	if err := c.SessionStore.Extend(sessionID, expiresAt); err != nil {
+		return nil, err
+	}
+
+	// Remove the password from the user object
+	user.Password = ""
+
+	return &AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		User:         user,
+	}, nil
+}
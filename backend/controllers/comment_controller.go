@@ -0,0 +1,324 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go-react-redux-app/middleware"
+	"go-react-redux-app/models"
+	"go-react-redux-app/policy"
+	"go-react-redux-app/utils"
+)
+
+// Limits on comment bodies
+const (
+	maxCommentLength = 10000
+	// maxMentionsPerComment bounds the user lookups made for one comment
+	maxMentionsPerComment = 50
+)
+
+// CommentController handles requests for comments on tasks
+type CommentController struct {
+	CommentStore models.CommentRepository
+	TaskStore    models.TaskRepository
+	UserStore    models.UserRepository
+	Policy       *policy.Policy
+}
+
+// NewCommentController creates a new CommentController
+func NewCommentController(commentStore models.CommentRepository, taskStore models.TaskRepository, userStore models.UserRepository, authz *policy.Policy) *CommentController {
+	return &CommentController{
+		CommentStore: commentStore,
+		TaskStore:    taskStore,
+		UserStore:    userStore,
+		Policy:       authz,
+	}
+}
+
+// CommentRequest represents a request to create or edit a comment. The body is Markdown.
+type CommentRequest struct {
+	Body string `json:"body"`
+}
+
+// GetComments handles listing a task's comments, oldest first. It supports the page and pageSize query parameters.
+func (c *CommentController) GetComments(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, policy.ActionView)
+	if !ok {
+		return
+	}
+
+	pagination, err := parsePagination(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	comments, total, err := c.CommentStore.GetByTask(task.ID, pagination.PageSize, pagination.Offset())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving comments")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Comments retrieved successfully", PageResponse{
+		Items:    comments,
+		Total:    total,
+		Page:     pagination.Page,
+		PageSize: pagination.PageSize,
+	})
+}
+
+// CreateComment handles adding a comment to a task. Users @mentioned in the
+// body who can view the task are recorded for notifications.
+func (c *CommentController) CreateComment(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, policy.ActionCreate)
+	if !ok {
+		return
+	}
+
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	body, ok := decodeCommentBody(w, r)
+	if !ok {
+		return
+	}
+
+	mentions, err := c.resolveMentions(body, task.ProjectID, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error resolving mentions")
+		return
+	}
+
+	comment := &models.Comment{
+		ID:        uuid.New().String(),
+		TaskID:    task.ID,
+		AuthorID:  user.ID,
+		Body:      body,
+		Mentions:  mentions,
+		CreatedAt: time.Now(),
+	}
+	if err := c.CommentStore.Create(comment); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating comment")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusCreated, "Comment created successfully", comment)
+}
+
+// UpdateComment handles editing a comment, which only its author may do.
+// Edited comments are marked with the time of the edit.
+func (c *CommentController) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, policy.ActionUpdate)
+	if !ok {
+		return
+	}
+
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	comment, ok := c.loadComment(w, r, task)
+	if !ok {
+		return
+	}
+	if comment.AuthorID != user.ID {
+		utils.RespondWithError(w, http.StatusForbidden, "Only the author can edit a comment")
+		return
+	}
+
+	body, ok := decodeCommentBody(w, r)
+	if !ok {
+		return
+	}
+	if body == comment.Body {
+		utils.RespondWithSuccess(w, http.StatusOK, "Comment updated successfully", comment)
+		return
+	}
+
+	mentions, err := c.resolveMentions(body, task.ProjectID, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error resolving mentions")
+		return
+	}
+
+	now := time.Now()
+	comment.Body = body
+	comment.Mentions = mentions
+	comment.Edited = true
+	comment.EditedAt = &now
+	if err := c.CommentStore.Update(comment); err != nil {
+		if err == models.ErrCommentNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Comment not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating comment")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Comment updated successfully", comment)
+}
+
+// DeleteComment handles deleting a comment. Authors may delete their own
+// comments and project owners anyone's.
+func (c *CommentController) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, policy.ActionDelete)
+	if !ok {
+		return
+	}
+
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	comment, ok := c.loadComment(w, r, task)
+	if !ok {
+		return
+	}
+	if comment.AuthorID != user.ID && !authorize(w, r, c.Policy, policy.ActionModerate, policy.Comment(task.ProjectID)) {
+		return
+	}
+
+	if err := c.CommentStore.Delete(comment.ID); err != nil {
+		if err == models.ErrCommentNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Comment not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error deleting comment")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Comment deleted successfully", nil)
+}
+
+// GetMentions handles listing the comments the authenticated user was
+// @mentioned in, newest first. It supports the page and pageSize query parameters.
+func (c *CommentController) GetMentions(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	pagination, err := parsePagination(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mentions, total, err := c.CommentStore.GetMentions(user.ID, pagination.PageSize, pagination.Offset())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving mentions")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Mentions retrieved successfully", PageResponse{
+		Items:    mentions,
+		Total:    total,
+		Page:     pagination.Page,
+		PageSize: pagination.PageSize,
+	})
+}
+
+// loadTask loads the task in the path after checking that the user may
+// perform the action on its comments
+func (c *CommentController) loadTask(w http.ResponseWriter, r *http.Request, action policy.Action) (*models.Task, bool) {
+	task, err := c.TaskStore.GetByID(mux.Vars(r)["id"])
+	if err != nil {
+		if err == models.ErrTaskNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Task not found")
+			return nil, false
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	if !authorize(w, r, c.Policy, action, policy.Comment(task.ProjectID)) {
+		return nil, false
+	}
+	return task, true
+}
+
+// loadComment loads the comment in the path, which has to be on the task
+func (c *CommentController) loadComment(w http.ResponseWriter, r *http.Request, task *models.Task) (*models.Comment, bool) {
+	comment, err := c.CommentStore.GetByID(mux.Vars(r)["commentId"])
+	if err != nil && err != models.ErrCommentNotFound {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving comment")
+		return nil, false
+	}
+	if err == models.ErrCommentNotFound || comment.TaskID != task.ID {
+		utils.RespondWithError(w, http.StatusNotFound, "Comment not found")
+		return nil, false
+	}
+	return comment, true
+}
+
+// decodeCommentBody reads and validates the body of a comment request
+func decodeCommentBody(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req CommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return "", false
+	}
+
+	if strings.TrimSpace(req.Body) == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Comment body is required")
+		return "", false
+	}
+	if utf8.RuneCountInString(req.Body) > maxCommentLength {
+		utils.RespondWithError(w, http.StatusBadRequest, "Comment body must be at most "+strconv.Itoa(maxCommentLength)+" characters")
+		return "", false
+	}
+	return req.Body, true
+}
+
+// resolveMentions looks up the users @mentioned in a comment body. Unknown
+// usernames, disabled users, users who cannot view the project's comments and
+// the author are left out.
+func (c *CommentController) resolveMentions(body string, projectID string, authorID string) ([]models.MentionedUser, error) {
+	usernames := utils.ParseMentions(body)
+	if len(usernames) > maxMentionsPerComment {
+		usernames = usernames[:maxMentionsPerComment]
+	}
+
+	mentions := []models.MentionedUser{}
+	for _, username := range usernames {
+		user, err := c.UserStore.GetByUsername(username)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if user.ID == authorID || user.IsDisabled() {
+			continue
+		}
+
+		allowed, err := c.Policy.Can(policy.Principal{UserID: user.ID, Role: user.Role}, policy.ActionView, policy.Comment(projectID))
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			mentions = append(mentions, models.MentionedUser{UserID: user.ID, Username: user.Username})
+		}
+	}
+
+	sort.Slice(mentions, func(i, j int) bool {
+		return mentions[i].Username < mentions[j].Username
+	})
+	return mentions, nil
+}
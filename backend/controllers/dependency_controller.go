@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go-react-redux-app/middleware"
+	"go-react-redux-app/models"
+	"go-react-redux-app/policy"
+	"go-react-redux-app/utils"
+)
+
+// DependencyController handles requests for "blocks / blocked by" links between tasks
+type DependencyController struct {
+	TaskStore    models.TaskRepository
+	Dependencies models.TaskDependencyRepository
+	Policy       *policy.Policy
+}
+
+// NewDependencyController creates a new DependencyController
+func NewDependencyController(taskStore models.TaskRepository, dependencies models.TaskDependencyRepository, authz *policy.Policy) *DependencyController {
+	return &DependencyController{
+		TaskStore:    taskStore,
+		Dependencies: dependencies,
+		Policy:       authz,
+	}
+}
+
+// AddDependencyRequest represents a request to mark a task as blocked by another task
+type AddDependencyRequest struct {
+	BlockerID string `json:"blockerId"`
+}
+
+// DependenciesResponse lists the tasks on both sides of a task's dependencies.
+// Tasks in projects the user cannot view are left out.
+type DependenciesResponse struct {
+	BlockedBy []models.Task `json:"blockedBy"`
+	Blocks    []models.Task `json:"blocks"`
+}
+
+// GetDependencies handles getting the tasks blocking a task and the tasks it blocks
+func (c *DependencyController) GetDependencies(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, mux.Vars(r)["id"], policy.ActionView)
+	if !ok {
+		return
+	}
+
+	blockers, err := c.Dependencies.GetBlockers(task.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	blocking, err := c.Dependencies.GetBlocking(task.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	principal, err := middleware.GetPrincipalFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	visible := c.visibleFilter(principal)
+
+	response := DependenciesResponse{BlockedBy: []models.Task{}, Blocks: []models.Task{}}
+	for _, blocker := range blockers {
+		if visible(blocker.ProjectID) {
+			response.BlockedBy = append(response.BlockedBy, blocker)
+		}
+	}
+	for _, blocked := range blocking {
+		if visible(blocked.ProjectID) {
+			response.Blocks = append(response.Blocks, blocked)
+		}
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Dependencies retrieved successfully", response)
+}
+
+// AddDependency handles marking the task in the path as blocked by another
+// task, which may be in any project the user can view
+func (c *DependencyController) AddDependency(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, mux.Vars(r)["id"], policy.ActionUpdate)
+	if !ok {
+		return
+	}
+
+	var req AddDependencyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.BlockerID == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "blockerId is required")
+		return
+	}
+	if req.BlockerID == task.ID {
+		utils.RespondWithError(w, http.StatusBadRequest, "A task cannot block itself")
+		return
+	}
+
+	blocker, ok := c.loadTask(w, r, req.BlockerID, policy.ActionView)
+	if !ok {
+		return
+	}
+
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	dependency := &models.TaskDependency{
+		BlockerID: blocker.ID,
+		BlockedID: task.ID,
+		CreatedBy: user.ID,
+		CreatedAt: time.Now(),
+	}
+	err = c.Dependencies.Create(dependency)
+	switch err {
+	case nil:
+	case models.ErrDependencyExists:
+		utils.RespondWithError(w, http.StatusConflict, "Task is already blocked by that task")
+		return
+	case models.ErrDependencyCycle:
+		utils.RespondWithError(w, http.StatusBadRequest, "Dependency would create a cycle: the task already blocks that task")
+		return
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusCreated, "Dependency added successfully", dependency)
+}
+
+// RemoveDependency handles removing a task's blocker
+func (c *DependencyController) RemoveDependency(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	task, ok := c.loadTask(w, r, vars["id"], policy.ActionUpdate)
+	if !ok {
+		return
+	}
+
+	err := c.Dependencies.Delete(vars["blockerId"], task.ID)
+	if err != nil {
+		if err == models.ErrDependencyNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Dependency not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Dependency removed successfully", nil)
+}
+
+// loadTask loads a task after checking that the user may perform the action on it
+func (c *DependencyController) loadTask(w http.ResponseWriter, r *http.Request, taskID string, action policy.Action) (*models.Task, bool) {
+	task, err := c.TaskStore.GetByID(taskID)
+	if err != nil {
+		if err == models.ErrTaskNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Task not found")
+			return nil, false
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	if !authorize(w, r, c.Policy, action, policy.Task(task.ProjectID)) {
+		return nil, false
+	}
+	return task, true
+}
+
+// visibleFilter returns a check of whether the principal may view tasks in a
+// project, remembering the answer for each project
+func (c *DependencyController) visibleFilter(principal policy.Principal) func(projectID string) bool {
+	allowed := make(map[string]bool)
+	return func(projectID string) bool {
+		if ok, seen := allowed[projectID]; seen {
+			return ok
+		}
+		ok, err := c.Policy.Can(principal, policy.ActionView, policy.Task(projectID))
+		allowed[projectID] = err == nil && ok
+		return allowed[projectID]
+	}
+}
@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"net/http"
+
+	"go-react-redux-app/signing"
+	"go-react-redux-app/utils"
+)
+
+// JWKSController publishes the public token signing keys
+type JWKSController struct {
+	Keys *signing.KeyManager
+}
+
+// NewJWKSController creates a new JWKSController
+func NewJWKSController(keys *signing.KeyManager) *JWKSController {
+	return &JWKSController{
+		Keys: keys,
+	}
+}
+
+// GetJWKS handles getting the JSON Web Key Set. It is served as a bare JWKS
+// document rather than in the API response envelope, as JWT libraries expect.
+func (c *JWKSController) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	// Let verifiers cache the set, but not so long they miss a rotation
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	utils.RespondWithJSON(w, http.StatusOK, c.Keys.JWKS())
+}
@@ -0,0 +1,348 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go-react-redux-app/models"
+	"go-react-redux-app/policy"
+	"go-react-redux-app/utils"
+)
+
+// Limits and defaults for labels
+const (
+	maxLabelNameLength = 50
+	defaultLabelColor  = "#6b7280"
+)
+
+// labelColorPattern matches colors in the #rrggbb form
+var labelColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// LabelController handles requests for project labels and their assignment to tasks
+type LabelController struct {
+	LabelStore   models.LabelRepository
+	TaskStore    models.TaskRepository
+	ProjectStore models.ProjectRepository
+	Policy       *policy.Policy
+}
+
+// NewLabelController creates a new LabelController
+func NewLabelController(labelStore models.LabelRepository, taskStore models.TaskRepository, projectStore models.ProjectRepository, authz *policy.Policy) *LabelController {
+	return &LabelController{
+		LabelStore:   labelStore,
+		TaskStore:    taskStore,
+		ProjectStore: projectStore,
+		Policy:       authz,
+	}
+}
+
+// LabelRequest represents a request to create or edit a label. When editing,
+// fields left empty keep their current value.
+type LabelRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// AddTaskLabelRequest represents a request to assign a label to a task
+type AddTaskLabelRequest struct {
+	LabelID string `json:"labelId"`
+}
+
+// SetTaskLabelsRequest represents a request to replace the labels of a task
+type SetTaskLabelsRequest struct {
+	LabelIDs []string `json:"labelIds"`
+}
+
+// GetLabels handles listing a project's labels, ordered by name
+func (c *LabelController) GetLabels(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := c.loadProject(w, r, policy.ActionView)
+	if !ok {
+		return
+	}
+
+	labels, err := c.LabelStore.GetByProject(projectID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving labels")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Labels retrieved successfully", labels)
+}
+
+// CreateLabel handles adding a label to a project
+func (c *LabelController) CreateLabel(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := c.loadProject(w, r, policy.ActionCreate)
+	if !ok {
+		return
+	}
+
+	var req LabelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	label := &models.Label{
+		ID:        uuid.New().String(),
+		ProjectID: projectID,
+		Name:      req.Name,
+		Color:     req.Color,
+		CreatedAt: time.Now(),
+	}
+	if label.Color == "" {
+		label.Color = defaultLabelColor
+	}
+	if !normalizeLabel(w, label) {
+		return
+	}
+
+	if err := c.LabelStore.Create(label); err != nil {
+		if err == models.ErrLabelExists {
+			utils.RespondWithError(w, http.StatusConflict, "The project already has a label with that name")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating label")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusCreated, "Label created successfully", label)
+}
+
+// UpdateLabel handles renaming or recoloring a label
+func (c *LabelController) UpdateLabel(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := c.loadProject(w, r, policy.ActionUpdate)
+	if !ok {
+		return
+	}
+	label, ok := c.loadLabel(w, mux.Vars(r)["labelId"], projectID)
+	if !ok {
+		return
+	}
+
+	var req LabelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Name != "" {
+		label.Name = req.Name
+	}
+	if req.Color != "" {
+		label.Color = req.Color
+	}
+	if !normalizeLabel(w, label) {
+		return
+	}
+
+	if err := c.LabelStore.Update(label); err != nil {
+		switch err {
+		case models.ErrLabelExists:
+			utils.RespondWithError(w, http.StatusConflict, "The project already has a label with that name")
+		case models.ErrLabelNotFound:
+			utils.RespondWithError(w, http.StatusNotFound, "Label not found")
+		default:
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error updating label")
+		}
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Label updated successfully", label)
+}
+
+// DeleteLabel handles deleting a label, which removes it from every task
+func (c *LabelController) DeleteLabel(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := c.loadProject(w, r, policy.ActionDelete)
+	if !ok {
+		return
+	}
+	label, ok := c.loadLabel(w, mux.Vars(r)["labelId"], projectID)
+	if !ok {
+		return
+	}
+
+	if err := c.LabelStore.Delete(label.ID); err != nil {
+		if err == models.ErrLabelNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Label not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error deleting label")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Label deleted successfully", nil)
+}
+
+// AddTaskLabel handles assigning one of its project's labels to a task
+func (c *LabelController) AddTaskLabel(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r)
+	if !ok {
+		return
+	}
+
+	var req AddTaskLabelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.LabelID == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "labelId is required")
+		return
+	}
+	if _, ok := c.loadLabel(w, req.LabelID, task.ProjectID); !ok {
+		return
+	}
+
+	if err := c.LabelStore.AddToTask(task.ID, req.LabelID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error assigning label")
+		return
+	}
+
+	c.respondWithTaskLabels(w, task.ID, "Label assigned successfully")
+}
+
+// RemoveTaskLabel handles taking a label off a task
+func (c *LabelController) RemoveTaskLabel(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.LabelStore.RemoveFromTask(task.ID, mux.Vars(r)["labelId"]); err != nil {
+		if err == models.ErrLabelNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "The task does not have that label")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error removing label")
+		return
+	}
+
+	c.respondWithTaskLabels(w, task.ID, "Label removed successfully")
+}
+
+// SetTaskLabels handles replacing all labels of a task
+func (c *LabelController) SetTaskLabels(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r)
+	if !ok {
+		return
+	}
+
+	var req SetTaskLabelsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.LabelIDs == nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "labelIds is required")
+		return
+	}
+
+	projectLabels, err := c.LabelStore.GetByProject(task.ProjectID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving labels")
+		return
+	}
+	inProject := make(map[string]bool, len(projectLabels))
+	for _, label := range projectLabels {
+		inProject[label.ID] = true
+	}
+	for _, labelID := range req.LabelIDs {
+		if !inProject[labelID] {
+			utils.RespondWithError(w, http.StatusBadRequest, "Label "+labelID+" is not a label of the task's project")
+			return
+		}
+	}
+
+	if err := c.LabelStore.SetTaskLabels(task.ID, req.LabelIDs); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error assigning labels")
+		return
+	}
+
+	c.respondWithTaskLabels(w, task.ID, "Labels assigned successfully")
+}
+
+// respondWithTaskLabels responds with the current labels of a task
+func (c *LabelController) respondWithTaskLabels(w http.ResponseWriter, taskID string, message string) {
+	task, err := c.TaskStore.GetByID(taskID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving labels")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, message, task.Labels)
+}
+
+// loadProject checks that the project in the path exists and that the user
+// may perform the action on its labels
+func (c *LabelController) loadProject(w http.ResponseWriter, r *http.Request, action policy.Action) (string, bool) {
+	projectID := mux.Vars(r)["id"]
+
+	if _, err := c.ProjectStore.GetByID(projectID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Project not found")
+		return "", false
+	}
+
+	if !authorize(w, r, c.Policy, action, policy.Label(projectID)) {
+		return "", false
+	}
+	return projectID, true
+}
+
+// loadLabel loads a label, which has to be defined in the given project
+func (c *LabelController) loadLabel(w http.ResponseWriter, labelID string, projectID string) (*models.Label, bool) {
+	label, err := c.LabelStore.GetByID(labelID)
+	if err != nil {
+		if err == models.ErrLabelNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Label not found")
+			return nil, false
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving label")
+		return nil, false
+	}
+
+	if label.ProjectID != projectID {
+		utils.RespondWithError(w, http.StatusNotFound, "Label not found")
+		return nil, false
+	}
+	return label, true
+}
+
+// loadTask loads the task in the path after checking that the user may update it
+func (c *LabelController) loadTask(w http.ResponseWriter, r *http.Request) (*models.Task, bool) {
+	task, err := c.TaskStore.GetByID(mux.Vars(r)["id"])
+	if err != nil {
+		if err == models.ErrTaskNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Task not found")
+			return nil, false
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	if !authorize(w, r, c.Policy, policy.ActionUpdate, policy.Task(task.ProjectID)) {
+		return nil, false
+	}
+	return task, true
+}
+
+// normalizeLabel trims the label's name, lower-cases its color and checks
+// both, responding with an error if either is invalid
+func normalizeLabel(w http.ResponseWriter, label *models.Label) bool {
+	label.Name = strings.TrimSpace(label.Name)
+	label.Color = strings.ToLower(label.Color)
+
+	if label.Name == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Name is required")
+		return false
+	}
+	if utf8.RuneCountInString(label.Name) > maxLabelNameLength {
+		utils.RespondWithError(w, http.StatusBadRequest, "Name must be at most 50 characters")
+		return false
+	}
+	if strings.Contains(label.Name, ",") {
+		utils.RespondWithError(w, http.StatusBadRequest, "Name must not contain commas")
+		return false
+	}
+	if !labelColorPattern.MatchString(label.Color) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Color must be in the #rrggbb form")
+		return false
+	}
+	return true
+}
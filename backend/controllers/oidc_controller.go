@@ -0,0 +1,527 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go-react-redux-app/middleware"
+	"go-react-redux-app/models"
+	"go-react-redux-app/oidc"
+	"go-react-redux-app/utils"
+)
+
+const (
+	// oidcFlowTTL is how long a user has to log in at the identity provider
+	oidcFlowTTL = 10 * time.Minute
+	// oidcLoginCodeTTL is how long the frontend has to exchange the code from a finished SSO login
+	oidcLoginCodeTTL = time.Minute
+	// oidcLinkTicketTTL is how long a link ticket can be used to start linking a provider account
+	oidcLinkTicketTTL = 5 * time.Minute
+
+	// oidcStateCookie ties a callback to the browser that started the login,
+	// so nobody can complete their own login in someone else's browser
+	oidcStateCookie = "oidc_state"
+)
+
+// OIDCController handles single sign-on through OpenID Connect identity providers
+type OIDCController struct {
+	UserStore  models.UserRepository
+	Identities models.IdentityRepository
+	Flows      models.OIDCFlowRepository
+	TokenStore models.UserTokenRepository
+	Providers  []*oidc.Provider
+	// AppURL is the frontend address SSO logins return to
+	AppURL string
+	// APIURL is the public address of this server
+	APIURL string
+}
+
+// NewOIDCController creates a new OIDCController
+func NewOIDCController(userStore models.UserRepository, identities models.IdentityRepository, flows models.OIDCFlowRepository, tokenStore models.UserTokenRepository, providers []*oidc.Provider, appURL string, apiURL string) *OIDCController {
+	return &OIDCController{
+		UserStore:  userStore,
+		Identities: identities,
+		Flows:      flows,
+		TokenStore: tokenStore,
+		Providers:  providers,
+		AppURL:     appURL,
+		APIURL:     apiURL,
+	}
+}
+
+// ProviderResponse represents an identity provider users can log in with
+type ProviderResponse struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	LoginURL string `json:"loginUrl"`
+}
+
+// LinkResponse represents the address the browser has to open to link a provider account
+type LinkResponse struct {
+	URL string `json:"url"`
+}
+
+// ssoError is a failed SSO login whose message can be shown to the user
+type ssoError struct {
+	message string
+}
+
+func (e *ssoError) Error() string {
+	return e.message
+}
+
+// GetProviders handles listing the identity providers users can log in with
+func (c *OIDCController) GetProviders(w http.ResponseWriter, r *http.Request) {
+	providers := []ProviderResponse{}
+	for _, provider := range c.Providers {
+		providers = append(providers, ProviderResponse{
+			ID:       provider.ID,
+			Name:     provider.Name,
+			LoginURL: c.loginURL(provider),
+		})
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Identity providers retrieved successfully", providers)
+}
+
+// StartLogin handles sending the browser to an identity provider. With a
+// link query parameter (see StartLink) the provider account is linked to the
+// user who requested the ticket instead of logging in.
+func (c *OIDCController) StartLogin(w http.ResponseWriter, r *http.Request) {
+	provider := c.provider(mux.Vars(r)["provider"])
+	if provider == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Identity provider not found")
+		return
+	}
+
+	userID := ""
+	if ticket := r.URL.Query().Get("link"); ticket != "" {
+		token, err := c.TokenStore.Consume(utils.HashToken(ticket), models.TokenPurposeOIDCLink)
+		if err != nil {
+			c.redirectError(w, r, "The link request is invalid or has expired, please try again")
+			return
+		}
+		userID = token.UserID
+	}
+
+	state, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.redirectError(w, r, "Could not start the login, please try again")
+		return
+	}
+	nonce, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.redirectError(w, r, "Could not start the login, please try again")
+		return
+	}
+	verifier, err := oidc.GenerateVerifier()
+	if err != nil {
+		c.redirectError(w, r, "Could not start the login, please try again")
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		log.Printf("Error contacting identity provider %s: %v", provider.ID, err)
+		c.redirectError(w, r, "The identity provider is unavailable, please try again later")
+		return
+	}
+
+	now := time.Now()
+	err = c.Flows.Create(&models.OIDCFlow{
+		StateHash:    utils.HashToken(state),
+		Provider:     provider.ID,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		UserID:       userID,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(oidcFlowTTL),
+	})
+	if err != nil {
+		c.redirectError(w, r, "Could not start the login, please try again")
+		return
+	}
+
+	c.setStateCookie(w, state, int(oidcFlowTTL.Seconds()))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback handles the browser returning from an identity provider. A
+// successful login redirects to APP_URL/oidc/callback with a one-time code
+// that the frontend exchanges for tokens at /api/auth/oidc/exchange; a
+// finished link redirects there with linked=<provider>, and failures with
+// error=<message>.
+func (c *OIDCController) Callback(w http.ResponseWriter, r *http.Request) {
+	provider := c.provider(mux.Vars(r)["provider"])
+	if provider == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Identity provider not found")
+		return
+	}
+
+	query := r.URL.Query()
+	state := query.Get("state")
+	cookie, cookieErr := r.Cookie(oidcStateCookie)
+	c.setStateCookie(w, "", -1)
+	if state == "" || cookieErr != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		c.redirectError(w, r, "The login is invalid or has expired, please try again")
+		return
+	}
+
+	flow, err := c.Flows.Consume(utils.HashToken(state))
+	if err != nil || flow.Provider != provider.ID {
+		c.redirectError(w, r, "The login is invalid or has expired, please try again")
+		return
+	}
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		if description := query.Get("error_description"); description != "" {
+			providerErr = description
+		}
+		c.redirectError(w, r, "The identity provider refused the login: "+providerErr)
+		return
+	}
+
+	claims, err := provider.Exchange(r.Context(), query.Get("code"), flow.CodeVerifier, flow.Nonce)
+	if err != nil {
+		log.Printf("Error completing login with identity provider %s: %v", provider.ID, err)
+		c.redirectError(w, r, "Could not verify the login with the identity provider")
+		return
+	}
+
+	if flow.UserID != "" {
+		c.link(w, r, provider, flow.UserID, claims)
+		return
+	}
+
+	user, err := c.resolveUser(provider, claims)
+	if err != nil {
+		var userErr *ssoError
+		if errors.As(err, &userErr) {
+			c.redirectError(w, r, userErr.message)
+			return
+		}
+		log.Printf("Error logging in with identity provider %s: %v", provider.ID, err)
+		c.redirectError(w, r, "Could not log you in, please try again")
+		return
+	}
+
+	code, err := createUserToken(c.TokenStore, user.ID, models.TokenPurposeOIDCLogin, oidcLoginCodeTTL)
+	if err != nil {
+		c.redirectError(w, r, "Could not log you in, please try again")
+		return
+	}
+
+	c.redirectToApp(w, r, url.Values{"code": {code}})
+}
+
+// GetIdentities handles listing the provider accounts linked to the authenticated user
+func (c *OIDCController) GetIdentities(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	identities, err := c.Identities.GetByUser(user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving identities")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Identities retrieved successfully", identities)
+}
+
+// StartLink handles starting to link a provider account to the authenticated
+// user. The returned URL has to be opened in the browser, since the API call
+// itself can't be redirected to the identity provider.
+func (c *OIDCController) StartLink(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	provider := c.provider(mux.Vars(r)["provider"])
+	if provider == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Identity provider not found")
+		return
+	}
+
+	ticket, err := createUserToken(c.TokenStore, user.ID, models.TokenPurposeOIDCLink, oidcLinkTicketTTL)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error starting link")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Open the URL in the browser to link your account", LinkResponse{
+		URL: c.loginURL(provider) + "?link=" + url.QueryEscape(ticket),
+	})
+}
+
+// DeleteIdentity handles unlinking a provider account from the authenticated user
+func (c *OIDCController) DeleteIdentity(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	err = c.Identities.Delete(user.ID, mux.Vars(r)["id"])
+	if err != nil {
+		if err == models.ErrIdentityNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Identity not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error unlinking identity")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Identity unlinked successfully", nil)
+}
+
+// link links a provider account to a user at the end of a link flow
+func (c *OIDCController) link(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, userID string, claims *oidc.Claims) {
+	existing, err := c.Identities.GetBySubject(provider.ID, claims.Subject)
+	if err == nil {
+		if existing.UserID != userID {
+			c.redirectError(w, r, "This "+provider.Name+" account is already linked to another user")
+			return
+		}
+		c.redirectToApp(w, r, url.Values{"linked": {provider.ID}})
+		return
+	}
+	if err != models.ErrIdentityNotFound {
+		c.redirectError(w, r, "Could not link your account, please try again")
+		return
+	}
+
+	err = c.Identities.Create(c.newIdentity(provider, userID, claims))
+	if err != nil {
+		if err == models.ErrIdentityExists {
+			c.redirectError(w, r, "Your account is already linked to another "+provider.Name+" account")
+			return
+		}
+		c.redirectError(w, r, "Could not link your account, please try again")
+		return
+	}
+
+	c.redirectToApp(w, r, url.Values{"linked": {provider.ID}})
+}
+
+// resolveUser finds the user a provider account logs in as. Unknown
+// accounts are linked to the local account with the same email if both
+// verified it and the provider is trusted to verify emails, or else provisioned if the
+// provider allows it. The role is synced from the groups on every login.
+func (c *OIDCController) resolveUser(provider *oidc.Provider, claims *oidc.Claims) (*models.User, error) {
+	now := time.Now()
+
+	var user *models.User
+	identity, err := c.Identities.GetBySubject(provider.ID, claims.Subject)
+	switch {
+	case err == nil:
+		user, err = c.UserStore.GetByID(identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.Identities.Touch(identity.ID, claims.Email, now); err != nil {
+			return nil, err
+		}
+	case err == models.ErrIdentityNotFound:
+		user, err = c.firstLogin(provider, claims)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if role := provider.Role(claims.Groups); role != "" && role != user.Role {
+		user.Role = role
+		if err := c.UserStore.Update(user); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// firstLogin links or provisions the account for a provider account that logs in for the first time
+func (c *OIDCController) firstLogin(provider *oidc.Provider, claims *oidc.Claims) (*models.User, error) {
+	if claims.Email == "" {
+		return nil, &ssoError{"The identity provider did not share your email address"}
+	}
+
+	user, err := c.UserStore.GetByEmail(claims.Email)
+	switch {
+	case err == nil:
+		// Both sides must have verified the address, or whoever registered it
+		// first could take over the other account
+		if !provider.TrustEmail || !claims.EmailVerified || !user.EmailVerified {
+			return nil, &ssoError{"An account with this email address already exists. Log in with your password and link " + provider.Name + " from your profile."}
+		}
+	case err == sql.ErrNoRows:
+		if !provider.AutoProvision {
+			return nil, &ssoError{"No account exists for " + claims.Email + ", ask an administrator to create one"}
+		}
+		user, err = c.provision(provider, claims)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	identity := c.newIdentity(provider, user.ID, claims)
+	now := time.Now()
+	identity.LastLoginAt = &now
+	if err := c.Identities.Create(identity); err != nil {
+		if err == models.ErrIdentityExists {
+			return nil, &ssoError{"Your account is already linked to another " + provider.Name + " account"}
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// provision creates an account for a provider account. Its password is
+// random; the user can set one with the password reset flow if they want to
+// log in without the provider.
+func (c *OIDCController) provision(provider *oidc.Provider, claims *oidc.Claims) (*models.User, error) {
+	username, err := c.uniqueUsername(claims)
+	if err != nil {
+		return nil, err
+	}
+	password, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	role := provider.Role(claims.Groups)
+	if role == "" {
+		role = models.UserRoleUser
+	}
+
+	now := time.Now()
+	user := &models.User{
+		ID:            uuid.New().String(),
+		Username:      username,
+		Email:         claims.Email,
+		Password:      password,
+		FirstName:     claims.GivenName,
+		LastName:      claims.FamilyName,
+		Role:          role,
+		EmailVerified: claims.EmailVerified,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := c.UserStore.Create(user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Provisioned user %s from identity provider %s", user.Username, provider.ID)
+	return user, nil
+}
+
+// uniqueUsername derives a free username from the preferred username or the email address
+func (c *OIDCController) uniqueUsername(claims *oidc.Claims) (string, error) {
+	base := sanitizeUsername(claims.PreferredUsername)
+	if base == "" {
+		local, _, _ := strings.Cut(claims.Email, "@")
+		base = sanitizeUsername(local)
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	for i := 1; i <= 100; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = fmt.Sprintf("%s%d", base, i)
+		}
+
+		_, err := c.UserStore.GetByUsername(candidate)
+		if err == sql.ErrNoRows {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", errors.New("no free username for " + base)
+}
+
+// sanitizeUsername keeps the characters of a username that are safe to use
+// and leaves room for a numeric suffix within the 50 character column
+func sanitizeUsername(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-' {
+			b.WriteRune(r)
+		}
+		if b.Len() == 45 {
+			break
+		}
+	}
+	return b.String()
+}
+
+// newIdentity creates the identity of a provider account for a user
+func (c *OIDCController) newIdentity(provider *oidc.Provider, userID string, claims *oidc.Claims) *models.Identity {
+	return &models.Identity{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Provider:  provider.ID,
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		CreatedAt: time.Now(),
+	}
+}
+
+// provider finds a configured provider by ID
+func (c *OIDCController) provider(id string) *oidc.Provider {
+	for _, provider := range c.Providers {
+		if provider.ID == id {
+			return provider
+		}
+	}
+	return nil
+}
+
+// loginURL returns the address that starts a login with a provider
+func (c *OIDCController) loginURL(provider *oidc.Provider) string {
+	return c.APIURL + "/api/auth/oidc/" + provider.ID + "/login"
+}
+
+// setStateCookie sets the state cookie, or deletes it when maxAge is negative
+func (c *OIDCController) setStateCookie(w http.ResponseWriter, state string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/auth/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(c.APIURL, "https://"),
+		// Lax still sends the cookie on the top-level redirect back from the provider
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// redirectError sends the browser back to the frontend with an error message
+func (c *OIDCController) redirectError(w http.ResponseWriter, r *http.Request, message string) {
+	c.redirectToApp(w, r, url.Values{"error": {message}})
+}
+
+// redirectToApp sends the browser back to the frontend's SSO callback page
+func (c *OIDCController) redirectToApp(w http.ResponseWriter, r *http.Request, params url.Values) {
+	http.Redirect(w, r, c.AppURL+"/oidc/callback?"+params.Encode(), http.StatusFound)
+}
@@ -0,0 +1,345 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go-react-redux-app/models"
+	"go-react-redux-app/oidc"
+	"go-react-redux-app/utils"
+)
+
+const (
+	testAppURL = "http://app.test"
+	testAPIURL = "http://api.test"
+)
+
+// ssoTest runs SSO logins through an OIDCController backed by memory stores
+// against a mock identity provider
+type ssoTest struct {
+	t          *testing.T
+	router     *mux.Router
+	users      *models.MemoryUserStore
+	identities *models.MemoryIdentityStore
+	tokens     *models.MemoryUserTokenStore
+}
+
+func newSSOTest(t *testing.T, cfg oidc.Config) *ssoTest {
+	t.Helper()
+
+	var mock *oidc.MockProvider
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mock.Handler().ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	var err error
+	mock, err = oidc.NewMockProvider(server.URL)
+	if err != nil {
+		t.Fatalf("NewMockProvider: %v", err)
+	}
+
+	cfg.ID = "mock"
+	cfg.Name = "Mock"
+	cfg.Issuer = server.URL
+	cfg.ClientID = "app"
+	cfg.GroupsClaim = "groups"
+	provider := oidc.NewProvider(cfg, testAPIURL+"/api/auth/oidc/mock/callback")
+
+	db := models.NewMemoryDB()
+	test := &ssoTest{
+		t:          t,
+		router:     mux.NewRouter(),
+		users:      models.NewMemoryUserStore(db),
+		identities: models.NewMemoryIdentityStore(db),
+		tokens:     models.NewMemoryUserTokenStore(db),
+	}
+	controller := NewOIDCController(test.users, test.identities, models.NewMemoryOIDCFlowStore(db), test.tokens, []*oidc.Provider{provider}, testAppURL, testAPIURL)
+	test.router.HandleFunc("/api/auth/oidc/{provider}/login", controller.StartLogin).Methods("GET")
+	test.router.HandleFunc("/api/auth/oidc/{provider}/callback", controller.Callback).Methods("GET")
+	return test
+}
+
+// serve sends a request to the controller and returns the address it redirects to
+func (s *ssoTest) serve(target string, cookies []*http.Cookie) (*url.URL, []*http.Cookie) {
+	s.t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		s.t.Fatalf("GET %s returned %d, want a redirect: %s", target, rec.Code, rec.Body.String())
+	}
+
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		s.t.Fatalf("parsing redirect: %v", err)
+	}
+	return location, rec.Result().Cookies()
+}
+
+// authorize starts a login and submits the mock provider's login page. The
+// authorization request can be changed by tamper before it is submitted. It
+// returns the callback address and the state cookie.
+func (s *ssoTest) authorize(form url.Values, tamper func(url.Values)) (string, []*http.Cookie) {
+	s.t.Helper()
+
+	authURL, cookies := s.serve(testAPIURL+"/api/auth/oidc/mock/login", nil)
+	query := authURL.Query()
+	if tamper != nil {
+		tamper(query)
+	}
+	for name, values := range query {
+		form[name] = values
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.PostForm(authURL.Scheme+"://"+authURL.Host+authURL.Path, form)
+	if err != nil {
+		s.t.Fatalf("submitting login: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		s.t.Fatalf("login returned %s, want a redirect", resp.Status)
+	}
+	return resp.Header.Get("Location"), cookies
+}
+
+// logIn runs a whole login and returns the query the frontend receives
+func (s *ssoTest) logIn(form url.Values) url.Values {
+	s.t.Helper()
+
+	callback, cookies := s.authorize(form, nil)
+	location, _ := s.serve(callback, cookies)
+	if !strings.HasPrefix(location.String(), testAppURL+"/oidc/callback?") {
+		s.t.Fatalf("redirected to %s, want the frontend", location)
+	}
+	return location.Query()
+}
+
+// exchange redeems the one-time code of a finished login like the frontend
+// does, and returns the user it logs in as
+func (s *ssoTest) exchange(result url.Values) *models.User {
+	s.t.Helper()
+
+	if message := result.Get("error"); message != "" {
+		s.t.Fatalf("login failed: %s", message)
+	}
+	token, err := s.tokens.Consume(utils.HashToken(result.Get("code")), models.TokenPurposeOIDCLogin)
+	if err != nil {
+		s.t.Fatalf("exchanging code: %v", err)
+	}
+	user, err := s.users.GetByID(token.UserID)
+	if err != nil {
+		s.t.Fatalf("loading user: %v", err)
+	}
+	return user
+}
+
+// createUser adds a local account with a password
+func (s *ssoTest) createUser(username string, email string, verified bool) *models.User {
+	s.t.Helper()
+
+	now := time.Now()
+	user := &models.User{
+		ID:            username + "-id",
+		Username:      username,
+		Email:         email,
+		Password:      "correct horse battery",
+		Role:          models.UserRoleUser,
+		EmailVerified: verified,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.users.Create(user); err != nil {
+		s.t.Fatalf("creating user: %v", err)
+	}
+	return user
+}
+
+func ssoForm(username string, email string, groups string) url.Values {
+	return url.Values{
+		"username":       {username},
+		"email":          {email},
+		"email_verified": {"true"},
+		"given_name":     {"Jane"},
+		"family_name":    {"Doe"},
+		"groups":         {groups},
+	}
+}
+
+func TestSSOProvisionsUser(t *testing.T) {
+	test := newSSOTest(t, oidc.Config{AutoProvision: true, AdminGroups: []string{"admins"}})
+
+	user := test.exchange(test.logIn(ssoForm("jane", "jane@example.com", "admins")))
+	if user.Username != "jane" || user.Email != "jane@example.com" || user.FirstName != "Jane" || user.LastName != "Doe" {
+		t.Errorf("provisioned user = %+v", user)
+	}
+	if user.Role != models.UserRoleAdmin || !user.EmailVerified {
+		t.Errorf("role = %q, verified = %v, want admin and verified", user.Role, user.EmailVerified)
+	}
+
+	identity, err := test.identities.GetBySubject("mock", "mock|jane")
+	if err != nil || identity.UserID != user.ID {
+		t.Fatalf("identity = %+v, %v, want one for %s", identity, err, user.ID)
+	}
+
+	// Logging in again finds the same user and syncs the role from the groups
+	again := test.exchange(test.logIn(ssoForm("jane", "jane@example.com", "developers")))
+	if again.ID != user.ID || again.Role != models.UserRoleUser {
+		t.Errorf("second login = %s with role %q, want %s with role user", again.ID, again.Role, user.ID)
+	}
+}
+
+func TestSSOPicksFreeUsername(t *testing.T) {
+	test := newSSOTest(t, oidc.Config{AutoProvision: true})
+	test.createUser("jane", "other@example.com", true)
+
+	user := test.exchange(test.logIn(ssoForm("jane", "jane@example.com", "")))
+	if user.Username != "jane2" {
+		t.Errorf("username = %q, want jane2", user.Username)
+	}
+}
+
+func TestSSOWithoutProvisioning(t *testing.T) {
+	test := newSSOTest(t, oidc.Config{})
+
+	result := test.logIn(ssoForm("jane", "jane@example.com", ""))
+	if !strings.Contains(result.Get("error"), "No account exists") {
+		t.Errorf("error = %q, want no account", result.Get("error"))
+	}
+}
+
+func TestSSOLinksVerifiedEmail(t *testing.T) {
+	test := newSSOTest(t, oidc.Config{AutoProvision: true, TrustEmail: true})
+	existing := test.createUser("jdoe", "jane@example.com", true)
+
+	user := test.exchange(test.logIn(ssoForm("jane", "jane@example.com", "")))
+	if user.ID != existing.ID {
+		t.Fatalf("logged in as %s, want the existing user %s", user.ID, existing.ID)
+	}
+	identity, err := test.identities.GetBySubject("mock", "mock|jane")
+	if err != nil || identity.UserID != existing.ID {
+		t.Errorf("identity = %+v, %v, want one for %s", identity, err, existing.ID)
+	}
+}
+
+func TestSSORefusesUnverifiedEmail(t *testing.T) {
+	tests := []struct {
+		name             string
+		trustEmail       bool
+		localVerified    bool
+		providerVerified bool
+	}{
+		{"provider not trusted", false, true, true},
+		{"local address unverified", true, false, true},
+		{"provider address unverified", true, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := newSSOTest(t, oidc.Config{AutoProvision: true, TrustEmail: tt.trustEmail})
+			test.createUser("jdoe", "jane@example.com", tt.localVerified)
+
+			form := ssoForm("jane", "jane@example.com", "")
+			if !tt.providerVerified {
+				form.Del("email_verified")
+			}
+			result := test.logIn(form)
+			if !strings.Contains(result.Get("error"), "already exists") {
+				t.Errorf("error = %q, want an existing account", result.Get("error"))
+			}
+			if _, err := test.identities.GetBySubject("mock", "mock|jane"); err != models.ErrIdentityNotFound {
+				t.Errorf("identity was linked: %v", err)
+			}
+		})
+	}
+}
+
+func TestSSORejectsStateMismatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		cookies func([]*http.Cookie) []*http.Cookie
+		query   func(url.Values)
+	}{
+		{"missing cookie", func([]*http.Cookie) []*http.Cookie { return nil }, nil},
+		{"other cookie", func([]*http.Cookie) []*http.Cookie {
+			return []*http.Cookie{{Name: oidcStateCookie, Value: "someone-elses-state"}}
+		}, nil},
+		{"other state", nil, func(query url.Values) { query.Set("state", "someone-elses-state") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := newSSOTest(t, oidc.Config{AutoProvision: true})
+
+			callback, cookies := test.authorize(ssoForm("jane", "jane@example.com", ""), nil)
+			if tt.cookies != nil {
+				cookies = tt.cookies(cookies)
+			}
+			callbackURL, _ := url.Parse(callback)
+			query := callbackURL.Query()
+			if tt.query != nil {
+				tt.query(query)
+			}
+			callbackURL.RawQuery = query.Encode()
+
+			location, _ := test.serve(callbackURL.String(), cookies)
+			if !strings.Contains(location.Query().Get("error"), "invalid or has expired") {
+				t.Errorf("redirected to %s, want an invalid login", location)
+			}
+			if _, err := test.users.GetByUsername("jane"); err == nil {
+				t.Error("user was provisioned")
+			}
+		})
+	}
+}
+
+func TestSSORejectsReplayedCallback(t *testing.T) {
+	test := newSSOTest(t, oidc.Config{AutoProvision: true})
+
+	callback, cookies := test.authorize(ssoForm("jane", "jane@example.com", ""), nil)
+	first, _ := test.serve(callback, cookies)
+	test.exchange(first.Query())
+
+	second, _ := test.serve(callback, cookies)
+	if !strings.Contains(second.Query().Get("error"), "invalid or has expired") {
+		t.Errorf("replayed callback redirected to %s, want an invalid login", second)
+	}
+}
+
+func TestSSORejectsPKCEMismatch(t *testing.T) {
+	test := newSSOTest(t, oidc.Config{AutoProvision: true})
+
+	// An attacker who swaps in their own challenge can't redeem the code with our verifier
+	callback, cookies := test.authorize(ssoForm("jane", "jane@example.com", ""), func(query url.Values) {
+		query.Set("code_challenge", oidc.Challenge("attacker-verifier-that-is-long-enough-to-be-valid"))
+	})
+	location, _ := test.serve(callback, cookies)
+	if !strings.Contains(location.Query().Get("error"), "Could not verify the login") {
+		t.Errorf("redirected to %s, want a failed verification", location)
+	}
+	if _, err := test.users.GetByUsername("jane"); err == nil {
+		t.Error("user was provisioned")
+	}
+}
+
+func TestSSOCodeIsSingleUse(t *testing.T) {
+	test := newSSOTest(t, oidc.Config{AutoProvision: true})
+
+	result := test.logIn(ssoForm("jane", "jane@example.com", ""))
+	test.exchange(result)
+	if _, err := test.tokens.Consume(utils.HashToken(result.Get("code")), models.TokenPurposeOIDCLogin); err == nil {
+		t.Error("login code was redeemed twice")
+	}
+}
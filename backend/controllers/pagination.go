@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// Page size limits for paginated endpoints
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Pagination is a requested page, parsed from the page and pageSize query parameters
+type Pagination struct {
+	Page     int
+	PageSize int
+}
+
+// Offset returns the number of items before the page
+func (p Pagination) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// PageResponse represents one page of a list
+type PageResponse struct {
+	Items    interface{} `json:"items"`
+	Total    int         `json:"total"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"pageSize"`
+}
+
+// parsePagination reads the page (1-based) and pageSize query parameters
+func parsePagination(r *http.Request) (Pagination, error) {
+	p := Pagination{Page: 1, PageSize: defaultPageSize}
+
+	if value := r.URL.Query().Get("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			return p, errors.New("page must be a positive integer")
+		}
+		p.Page = page
+	}
+
+	if value := r.URL.Query().Get("pageSize"); value != "" {
+		pageSize, err := strconv.Atoi(value)
+		if err != nil || pageSize < 1 || pageSize > maxPageSize {
+			return p, errors.New("pageSize must be between 1 and " + strconv.Itoa(maxPageSize))
+		}
+		p.PageSize = pageSize
+	}
+
+	return p, nil
+}
@@ -9,18 +9,26 @@ import (
 	"github.com/gorilla/mux"
 	"go-react-redux-app/models"
 	"go-react-redux-app/middleware"
+	"go-react-redux-app/policy"
 	"go-react-redux-app/utils"
 )
 
 // ProjectController handles project requests
 type ProjectController struct {
-	ProjectStore *models.ProjectStore
+	ProjectStore models.ProjectRepository
+	UserStore    models.UserRepository
+	Policy       *policy.Policy
+	// Blobs is told to collect the attachments of deleted projects
+	Blobs *AttachmentBlobs
 }
 
 // NewProjectController creates a new ProjectController
-func NewProjectController(projectStore *models.ProjectStore) *ProjectController {
+func NewProjectController(projectStore models.ProjectRepository, userStore models.UserRepository, authz *policy.Policy, blobs *AttachmentBlobs) *ProjectController {
 	return &ProjectController{
 		ProjectStore: projectStore,
+		UserStore:    userStore,
+		Policy:       authz,
+		Blobs:        blobs,
 	}
 }
 
@@ -98,13 +106,6 @@ func (c *ProjectController) GetProject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectID := vars["id"]
 
-	// Get the user from the context
-	user, err := middleware.GetUserFromContext(r.Context())
-	if err != nil {
-		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
-		return
-	}
-
 	// Get the project
 	project, err := c.ProjectStore.GetByID(projectID)
 	if err != nil {
@@ -112,14 +113,9 @@ func (c *ProjectController) GetProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if the user owns the project
-	if project.OwnerID != user.ID {
-		// Check if the user has admin role
-		userRole, ok := r.Context().Value("userRole").(string)
-		if !ok || userRole != "admin" {
-			utils.RespondWithError(w, http.StatusForbidden, "Forbidden")
-			return
-		}
+	// Check that the user may view the project
+	if !authorize(w, r, c.Policy, policy.ActionView, policy.Project(project.ID)) {
+		return
 	}
 
 	utils.RespondWithSuccess(w, http.StatusOK, "Project retrieved successfully", project)
@@ -144,13 +140,6 @@ func (c *ProjectController) UpdateProject(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Get the user from the context
-	user, err := middleware.GetUserFromContext(r.Context())
-	if err != nil {
-		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
-		return
-	}
-
 	// Get the project
 	project, err := c.ProjectStore.GetByID(projectID)
 	if err != nil {
@@ -158,14 +147,9 @@ func (c *ProjectController) UpdateProject(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Check if the user owns the project
-	if project.OwnerID != user.ID {
-		// Check if the user has admin role
-		userRole, ok := r.Context().Value("userRole").(string)
-		if !ok || userRole != "admin" {
-			utils.RespondWithError(w, http.StatusForbidden, "Forbidden")
-			return
-		}
+	// Check that the user may update the project
+	if !authorize(w, r, c.Policy, policy.ActionUpdate, policy.Project(project.ID)) {
+		return
 	}
 
 	// Update the project
@@ -189,6 +173,166 @@ func (c *ProjectController) DeleteProject(w http.ResponseWriter, r *http.Request
 	vars := mux.Vars(r)
 	projectID := vars["id"]
 
+	// Get the project
+	project, err := c.ProjectStore.GetByID(projectID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	// Check that the user may delete the project
+	if !authorize(w, r, c.Policy, policy.ActionDelete, policy.Project(project.ID)) {
+		return
+	}
+
+	// Delete the project
+	err = c.ProjectStore.Delete(projectID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error deleting project")
+		return
+	}
+	c.Blobs.Trigger()
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Project deleted successfully", nil)
+}
+
+// MemberRequest represents a request to add a project member or change their role
+type MemberRequest struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// GetMembers handles listing the members of a project
+func (c *ProjectController) GetMembers(w http.ResponseWriter, r *http.Request) {
+	projectID := mux.Vars(r)["id"]
+
+	if _, err := c.ProjectStore.GetByID(projectID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	// Any member may see who else is on the project
+	if !authorize(w, r, c.Policy, policy.ActionView, policy.Project(projectID)) {
+		return
+	}
+
+	members, err := c.ProjectStore.GetMembers(projectID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error getting project members")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Project members retrieved successfully", members)
+}
+
+// AddMember handles adding a user to a project
+func (c *ProjectController) AddMember(w http.ResponseWriter, r *http.Request) {
+	projectID := mux.Vars(r)["id"]
+
+	var req MemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate the request
+	if req.UserID == "" && req.Username == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "User ID or username is required")
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.ProjectRoleViewer
+	}
+	if !models.IsValidProjectRole(req.Role) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Role must be owner, editor or viewer")
+		return
+	}
+
+	project, ok := c.requireManager(w, r, projectID)
+	if !ok {
+		return
+	}
+
+	// Resolve the user being added
+	var member *models.User
+	var err error
+	if req.UserID != "" {
+		member, err = c.UserStore.GetByID(req.UserID)
+	} else {
+		member, err = c.UserStore.GetByUsername(req.Username)
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if _, err := c.ProjectStore.GetMemberRole(project.ID, member.ID); err == nil {
+		utils.RespondWithError(w, http.StatusConflict, "User is already a member of the project")
+		return
+	}
+
+	err = c.ProjectStore.AddMember(&models.ProjectMember{
+		ProjectID: project.ID,
+		UserID:    member.ID,
+		Role:      req.Role,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error adding project member")
+		return
+	}
+
+	c.respondWithMember(w, http.StatusCreated, "Project member added successfully", project.ID, member.ID)
+}
+
+// UpdateMember handles changing a project member's role
+func (c *ProjectController) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectID := vars["id"]
+	memberID := vars["userId"]
+
+	var req MemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if !models.IsValidProjectRole(req.Role) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Role must be owner, editor or viewer")
+		return
+	}
+
+	project, ok := c.requireManager(w, r, projectID)
+	if !ok {
+		return
+	}
+
+	// The project creator always stays an owner
+	if memberID == project.OwnerID && req.Role != models.ProjectRoleOwner {
+		utils.RespondWithError(w, http.StatusBadRequest, "The project owner's role cannot be changed")
+		return
+	}
+
+	err := c.ProjectStore.UpdateMemberRole(project.ID, memberID, req.Role)
+	if err == models.ErrMemberNotFound {
+		utils.RespondWithError(w, http.StatusNotFound, "Project member not found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating project member")
+		return
+	}
+
+	c.respondWithMember(w, http.StatusOK, "Project member updated successfully", project.ID, memberID)
+}
+
+// RemoveMember handles removing a user from a project. Members may always remove themselves.
+func (c *ProjectController) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectID := vars["id"]
+	memberID := vars["userId"]
+
 	// Get the user from the context
 	user, err := middleware.GetUserFromContext(r.Context())
 	if err != nil {
@@ -196,29 +340,69 @@ func (c *ProjectController) DeleteProject(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Get the project
+	var project *models.Project
+	if memberID == user.ID {
+		project, err = c.ProjectStore.GetByID(projectID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+	} else {
+		var ok bool
+		project, ok = c.requireManager(w, r, projectID)
+		if !ok {
+			return
+		}
+	}
+
+	if memberID == project.OwnerID {
+		utils.RespondWithError(w, http.StatusBadRequest, "The project owner cannot be removed")
+		return
+	}
+
+	err = c.ProjectStore.RemoveMember(project.ID, memberID)
+	if err == models.ErrMemberNotFound {
+		utils.RespondWithError(w, http.StatusNotFound, "Project member not found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error removing project member")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Project member removed successfully", nil)
+}
+
+// requireManager loads a project and checks that the current user may manage
+// its members, writing an error response and returning false otherwise
+func (c *ProjectController) requireManager(w http.ResponseWriter, r *http.Request, projectID string) (*models.Project, bool) {
 	project, err := c.ProjectStore.GetByID(projectID)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusNotFound, "Project not found")
-		return
+		return nil, false
 	}
 
-	// Check if the user owns the project
-	if project.OwnerID != user.ID {
-		// Check if the user has admin role
-		userRole, ok := r.Context().Value("userRole").(string)
-		if !ok || userRole != "admin" {
-			utils.RespondWithError(w, http.StatusForbidden, "Forbidden")
-			return
-		}
+	if !authorize(w, r, c.Policy, policy.ActionManageMembers, policy.Project(project.ID)) {
+		return nil, false
 	}
 
-	// Delete the project
-	err = c.ProjectStore.Delete(projectID)
+	return project, true
+}
+
+// respondWithMember responds with a single member of a project
+func (c *ProjectController) respondWithMember(w http.ResponseWriter, status int, message, projectID, userID string) {
+	members, err := c.ProjectStore.GetMembers(projectID)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Error deleting project")
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error getting project members")
 		return
 	}
 
-	utils.RespondWithSuccess(w, http.StatusOK, "Project deleted successfully", nil)
+	for _, member := range members {
+		if member.UserID == userID {
+			utils.RespondWithSuccess(w, status, message, member)
+			return
+		}
+	}
+
+	utils.RespondWithError(w, http.StatusNotFound, "Project member not found")
 }
@@ -0,0 +1,425 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go-react-redux-app/middleware"
+	"go-react-redux-app/models"
+	"go-react-redux-app/policy"
+	"go-react-redux-app/recurrence"
+	"go-react-redux-app/utils"
+)
+
+// upcomingOccurrences is how many upcoming occurrences are shown with a recurrence
+const upcomingOccurrences = 5
+
+// Scopes of a change to a recurring task
+const (
+	// scopeThis changes only the occurrence the request is made on
+	scopeThis = "this"
+	// scopeFuture changes the recurrence from that occurrence on
+	scopeFuture = "future"
+)
+
+// RecurrenceController handles requests for recurring tasks
+type RecurrenceController struct {
+	Recurrences models.RecurrenceRepository
+	TaskStore   models.TaskRepository
+	// Scheduler creates the occurrences that are due
+	Scheduler *TaskRecurrences
+	Policy    *policy.Policy
+}
+
+// NewRecurrenceController creates a new RecurrenceController
+func NewRecurrenceController(recurrences models.RecurrenceRepository, taskStore models.TaskRepository, scheduler *TaskRecurrences, authz *policy.Policy) *RecurrenceController {
+	return &RecurrenceController{
+		Recurrences: recurrences,
+		TaskStore:   taskStore,
+		Scheduler:   scheduler,
+		Policy:      authz,
+	}
+}
+
+// RecurrenceResponse is a recurrence along with its next occurrences after
+// the latest one created so far
+type RecurrenceResponse struct {
+	*models.Recurrence
+	Upcoming []time.Time `json:"upcoming"`
+}
+
+// CreateRecurrenceRequest represents a request to make a task recur. Start is
+// the first occurrence and defaults to the task's due date; Timezone
+// defaults to UTC.
+type CreateRecurrenceRequest struct {
+	Rule     string     `json:"rule"`
+	Timezone string     `json:"timezone"`
+	Start    *time.Time `json:"start"`
+}
+
+// UpdateRecurrenceRequest represents a request to change a recurring task.
+// Scope "this" changes only the occurrence in the path; "future" changes the
+// recurrence and its open occurrences from that one on. Fields left out stay
+// as they are.
+type UpdateRecurrenceRequest struct {
+	Scope       string  `json:"scope"`
+	Rule        *string `json:"rule"`
+	Timezone    *string `json:"timezone"`
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	Priority    *string `json:"priority"`
+	AssigneeID  *string `json:"assigneeId"`
+}
+
+// GetRecurrence handles getting the recurrence a task is an occurrence of
+func (c *RecurrenceController) GetRecurrence(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, policy.ActionView)
+	if !ok {
+		return
+	}
+	rec, ok := c.loadRecurrence(w, task)
+	if !ok {
+		return
+	}
+
+	c.respond(w, http.StatusOK, "Recurrence retrieved successfully", rec)
+}
+
+// CreateRecurrence handles making a top-level task recur. The task becomes
+// the first occurrence and is due at the start.
+func (c *RecurrenceController) CreateRecurrence(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, policy.ActionUpdate)
+	if !ok {
+		return
+	}
+
+	var req CreateRecurrenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if task.RecurrenceID != "" {
+		utils.RespondWithError(w, http.StatusConflict, "Task already recurs")
+		return
+	}
+	if task.ParentTaskID != "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Only top-level tasks can recur")
+		return
+	}
+
+	start := task.DueDate
+	if req.Start != nil {
+		start = *req.Start
+	}
+	if start.IsZero() {
+		utils.RespondWithError(w, http.StatusBadRequest, "start is required when the task has no due date")
+		return
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	rule, location, ok := parseSchedule(w, req.Rule, req.Timezone)
+	if !ok {
+		return
+	}
+	schedule := recurrence.NewSchedule(rule, start, location)
+	if _, ok := schedule.Next(schedule.Start); !ok {
+		utils.RespondWithError(w, http.StatusBadRequest, "The rule has no occurrences after the start")
+		return
+	}
+
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	now := time.Now()
+	rec := &models.Recurrence{
+		ID:               uuid.New().String(),
+		ProjectID:        task.ProjectID,
+		Rule:             rule.String(),
+		Timezone:         location.String(),
+		StartsAt:         schedule.Start.UTC(),
+		LastOccurrenceAt: schedule.Start.UTC(),
+		Title:            task.Title,
+		Description:      task.Description,
+		Priority:         task.Priority,
+		AssigneeID:       task.AssigneeID,
+		CreatedBy:        user.ID,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	err = c.Recurrences.Create(rec, task.ID)
+	if err == models.ErrTaskNotFound {
+		utils.RespondWithError(w, http.StatusConflict, "Task already recurs")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Scheduler.Trigger()
+
+	c.respond(w, http.StatusCreated, "Recurrence created successfully", rec)
+}
+
+// UpdateRecurrence handles changing one occurrence of a recurring task, or
+// the recurrence from that occurrence on. A new rule or time zone starts a
+// new schedule at the occurrence, replacing the open occurrences after it.
+// Without one, COUNT goes on counting the occurrences before it.
+func (c *RecurrenceController) UpdateRecurrence(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, policy.ActionUpdate)
+	if !ok {
+		return
+	}
+
+	var req UpdateRecurrenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Scope != scopeThis && req.Scope != scopeFuture {
+		utils.RespondWithError(w, http.StatusBadRequest, "scope must be this or future")
+		return
+	}
+	if (req.Title != nil && strings.TrimSpace(*req.Title) == "") || (req.Priority != nil && *req.Priority == "") {
+		utils.RespondWithError(w, http.StatusBadRequest, "Title and priority can't be empty")
+		return
+	}
+
+	rec, ok := c.loadRecurrence(w, task)
+	if !ok {
+		return
+	}
+
+	if req.Scope == scopeThis {
+		if req.Rule != nil || req.Timezone != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "The rule and time zone can only be changed for future occurrences")
+			return
+		}
+		req.apply(task)
+		if err := c.TaskStore.Update(task); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		utils.RespondWithSuccess(w, http.StatusOK, "Occurrence updated successfully", task)
+		return
+	}
+
+	if rec.EndsAt != nil {
+		utils.RespondWithError(w, http.StatusConflict, "The recurrence was already changed from a later occurrence on; change it from there")
+		return
+	}
+	occurrences, err := c.Recurrences.GetOccurrences(rec.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	at := *task.OccurrenceAt
+	next := *rec
+	req.applyTemplate(&next)
+	next.UpdatedAt = time.Now()
+
+	var replaced []models.Task
+	if req.Rule != nil || req.Timezone != nil {
+		current, err := rec.Schedule()
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		ruleValue, timezone := rec.Rule, rec.Timezone
+		if req.Rule != nil {
+			ruleValue = *req.Rule
+		}
+		if req.Timezone != nil {
+			timezone = *req.Timezone
+		}
+		rule, location, ok := parseSchedule(w, ruleValue, timezone)
+		if !ok {
+			return
+		}
+		if req.Rule == nil && rule.Count > 0 {
+			rule.Count -= current.CountBefore(at)
+		}
+		next.Rule = rule.String()
+		next.Timezone = location.String()
+
+		// Open occurrences after this one were created on the old schedule
+		next.LastOccurrenceAt = at
+		for _, occurrence := range occurrences {
+			if !occurrence.OccurrenceAt.After(at) {
+				continue
+			}
+			if occurrence.IsDone() {
+				next.LastOccurrenceAt = *occurrence.OccurrenceAt
+			} else {
+				replaced = append(replaced, occurrence)
+			}
+		}
+
+		if at.Equal(rec.StartsAt) {
+			err = c.Recurrences.Update(&next)
+		} else {
+			next.ID = uuid.New().String()
+			next.StartsAt = at
+			next.CreatedAt = next.UpdatedAt
+			err = c.Recurrences.Split(rec, &next)
+		}
+	} else {
+		err = c.Recurrences.Update(&next)
+	}
+	if err == models.ErrRecurrenceNotFound {
+		utils.RespondWithError(w, http.StatusConflict, "The recurrence was changed in the meantime; try again")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	for _, occurrence := range replaced {
+		if err := c.TaskStore.Delete(occurrence.ID); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	for _, occurrence := range occurrences {
+		if occurrence.OccurrenceAt.Before(at) || occurrence.IsDone() || containsTask(replaced, occurrence.ID) {
+			continue
+		}
+		req.apply(&occurrence)
+		if err := c.TaskStore.Update(&occurrence); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	c.Scheduler.Trigger()
+
+	c.respond(w, http.StatusOK, "Recurrence updated successfully", &next)
+}
+
+// DeleteRecurrence handles stopping a recurring task. Its occurrences so far
+// stay as ordinary tasks.
+func (c *RecurrenceController) DeleteRecurrence(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, policy.ActionUpdate)
+	if !ok {
+		return
+	}
+	rec, ok := c.loadRecurrence(w, task)
+	if !ok {
+		return
+	}
+	if rec.EndsAt != nil {
+		utils.RespondWithError(w, http.StatusConflict, "The recurrence was already changed from a later occurrence on; stop it from there")
+		return
+	}
+
+	if err := c.Recurrences.Delete(rec.ID); err != nil && err != models.ErrRecurrenceNotFound {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Recurrence stopped successfully", nil)
+}
+
+// apply sets the fields the request changes on an occurrence
+func (req *UpdateRecurrenceRequest) apply(task *models.Task) {
+	if req.Title != nil {
+		task.Title = strings.TrimSpace(*req.Title)
+	}
+	if req.Description != nil {
+		task.Description = *req.Description
+	}
+	if req.Priority != nil {
+		task.Priority = *req.Priority
+	}
+	if req.AssigneeID != nil {
+		task.AssigneeID = *req.AssigneeID
+	}
+}
+
+// applyTemplate sets the fields the request changes on the template of a recurrence
+func (req *UpdateRecurrenceRequest) applyTemplate(rec *models.Recurrence) {
+	task := models.Task{Title: rec.Title, Description: rec.Description, Priority: rec.Priority, AssigneeID: rec.AssigneeID}
+	req.apply(&task)
+	rec.Title, rec.Description, rec.Priority, rec.AssigneeID = task.Title, task.Description, task.Priority, task.AssigneeID
+}
+
+// containsTask checks if a list of tasks contains the task with the given ID
+func containsTask(tasks []models.Task, id string) bool {
+	for _, task := range tasks {
+		if task.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSchedule parses a rule and time zone, responding with an error if either is invalid
+func parseSchedule(w http.ResponseWriter, value string, timezone string) (*recurrence.Rule, *time.Location, bool) {
+	rule, err := recurrence.Parse(value)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid rule: "+err.Error())
+		return nil, nil, false
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "Local" {
+		utils.RespondWithError(w, http.StatusBadRequest, "timezone must be an IANA time zone such as Europe/Berlin")
+		return nil, nil, false
+	}
+	return rule, location, true
+}
+
+// respond responds with a recurrence and its upcoming occurrences
+func (c *RecurrenceController) respond(w http.ResponseWriter, status int, message string, rec *models.Recurrence) {
+	upcoming := []time.Time{}
+	if rec.EndsAt == nil {
+		if schedule, err := rec.Schedule(); err == nil {
+			upcoming = schedule.Upcoming(rec.LastOccurrenceAt, upcomingOccurrences)
+		}
+	}
+	utils.RespondWithSuccess(w, status, message, RecurrenceResponse{rec, upcoming})
+}
+
+// loadRecurrence loads the recurrence the task is an occurrence of,
+// responding with 404 if it does not recur
+func (c *RecurrenceController) loadRecurrence(w http.ResponseWriter, task *models.Task) (*models.Recurrence, bool) {
+	if task.RecurrenceID == "" {
+		utils.RespondWithError(w, http.StatusNotFound, "Task does not recur")
+		return nil, false
+	}
+	rec, err := c.Recurrences.GetByID(task.RecurrenceID)
+	if err == models.ErrRecurrenceNotFound {
+		utils.RespondWithError(w, http.StatusNotFound, "Task does not recur")
+		return nil, false
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return rec, true
+}
+
+// loadTask loads the task in the path, checking that the user may perform the action on it
+func (c *RecurrenceController) loadTask(w http.ResponseWriter, r *http.Request, action policy.Action) (*models.Task, bool) {
+	task, err := c.TaskStore.GetByID(mux.Vars(r)["id"])
+	if err != nil {
+		if err == models.ErrTaskNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Task not found")
+			return nil, false
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	if !authorize(w, r, c.Policy, action, policy.Task(task.ProjectID)) {
+		return nil, false
+	}
+	return task, true
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go-react-redux-app/middleware"
+	"go-react-redux-app/models"
+	"go-react-redux-app/utils"
+)
+
+// SessionController handles requests of users managing the devices they are logged in on
+type SessionController struct {
+	SessionStore models.SessionRepository
+}
+
+// NewSessionController creates a new SessionController
+func NewSessionController(sessionStore models.SessionRepository) *SessionController {
+	return &SessionController{
+		SessionStore: sessionStore,
+	}
+}
+
+// SessionResponse is a session along with whether the request was made with it
+type SessionResponse struct {
+	*models.Session
+	Current bool `json:"current"`
+}
+
+// GetSessions handles listing the active sessions of the authenticated user
+func (c *SessionController) GetSessions(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	currentID, _ := middleware.GetSessionIDFromContext(r.Context())
+
+	sessions, err := c.SessionStore.GetActiveByUser(user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving sessions")
+		return
+	}
+
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			Session: session,
+			Current: session.ID == currentID,
+		})
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Sessions retrieved successfully", response)
+}
+
+// RevokeSession handles revoking one session of the authenticated user. Revoking
+// the current session logs the user out.
+func (c *SessionController) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	session, err := c.SessionStore.GetByID(mux.Vars(r)["id"])
+	if err != nil && err != models.ErrSessionNotFound {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error revoking session")
+		return
+	}
+	// Someone else's session is reported the same as a missing one
+	if err == models.ErrSessionNotFound || session.UserID != user.ID || !session.IsActive() {
+		utils.RespondWithError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	if err := c.SessionStore.Revoke(session.ID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error revoking session")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Session revoked successfully", nil)
+}
+
+// RevokeOtherSessions handles revoking every session of the authenticated user
+// except the one the request was made with
+func (c *SessionController) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	sessionID, err := middleware.GetSessionIDFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := c.SessionStore.RevokeAllForUserExcept(user.ID, sessionID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error revoking sessions")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Other sessions revoked successfully", nil)
+}
@@ -2,9 +2,13 @@ package controllers
 
 import (
 	"encoding/json"
+	"fmt"
 	"go-react-redux-app/middleware"
 	"go-react-redux-app/models"
+	"go-react-redux-app/policy"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,19 +19,64 @@ import (
 
 // TaskController handles task-related requests
 type TaskController struct {
-	TaskStore    *models.TaskStore
-	ProjectStore *models.ProjectStore
+	TaskStore    models.TaskRepository
+	ProjectStore models.ProjectRepository
+	FieldStore   models.CustomFieldRepository
+	// WorkflowStore defines the statuses tasks can have and move between
+	WorkflowStore models.WorkflowRepository
+	Policy        *policy.Policy
+	// EnforceDependencies refuses finishing a task while tasks blocking it are open
+	EnforceDependencies bool
+	// Blobs is told to collect the attachments of deleted tasks
+	Blobs *AttachmentBlobs
+	// Recurrences creates the next occurrence of recurring tasks that are finished
+	Recurrences *TaskRecurrences
 }
 
 // NewTaskController creates a new TaskController
-func NewTaskController(taskStore *models.TaskStore, projectStore *models.ProjectStore) *TaskController {
+func NewTaskController(taskStore models.TaskRepository, projectStore models.ProjectRepository, fieldStore models.CustomFieldRepository, workflowStore models.WorkflowRepository, authz *policy.Policy, enforceDependencies bool, blobs *AttachmentBlobs, recurrences *TaskRecurrences) *TaskController {
 	return &TaskController{
-		TaskStore:    taskStore,
-		ProjectStore: projectStore,
+		TaskStore:           taskStore,
+		ProjectStore:        projectStore,
+		FieldStore:          fieldStore,
+		WorkflowStore:       workflowStore,
+		Policy:              authz,
+		EnforceDependencies: enforceDependencies,
+		Blobs:               blobs,
+		Recurrences:         recurrences,
 	}
 }
 
-// GetAllTasks handles getting all tasks
+// UpdateTaskRequest represents a request to update a task. ParentTaskID is a
+// pointer so that leaving it out (or sending null) keeps the current parent,
+// while "" turns the task into a top-level task. Estimates left out keep
+// their value and null clears them.
+type UpdateTaskRequest struct {
+	models.Task
+	ParentTaskID      *string         `json:"parentTaskId"`
+	OriginalEstimate  json.RawMessage `json:"originalEstimate"`
+	RemainingEstimate json.RawMessage `json:"remainingEstimate"`
+}
+
+// maxEstimate bounds task estimates, in seconds
+const maxEstimate = 10000 * 60 * 60
+
+// TaskNode is a task with its subtasks, as returned by GetTaskTree
+type TaskNode struct {
+	models.Task
+	Subtasks []*TaskNode `json:"subtasks"`
+}
+
+// Ways of handling the subtasks of a deleted task
+const (
+	// deleteChildren deletes the subtasks along with the task
+	deleteChildren = "delete"
+	// promoteChildren moves the direct subtasks up to the task's own parent
+	promoteChildren = "promote"
+)
+
+// GetAllTasks handles getting all tasks. Like GetTasks it can be filtered by
+// label and filtered or sorted by custom fields.
 func (c *TaskController) GetAllTasks(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
 	user, err := middleware.GetUserFromContext(r.Context())
@@ -36,138 +85,218 @@ func (c *TaskController) GetAllTasks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get all tasks
-	tasks, err := c.TaskStore.GetAll()
+	// Admins see every task, everyone else only tasks in projects they belong to
+	var tasks []models.Task
+	if user.Role == policy.RoleAdmin {
+		tasks, err = c.TaskStore.GetAll()
+	} else {
+		tasks, err = c.TaskStore.GetByUser(user.ID)
+	}
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	models.RollUpProgress(tasks)
 
-	// If user is not an admin, filter tasks to only include those from projects they have access to
-	if user.Role != "admin" {
-		// Get projects for user
-		projects, err := c.ProjectStore.GetByUser(user.ID)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-
-		// Create a map of project IDs for quick lookup
-		projectIDs := make(map[string]bool)
-		for _, project := range projects {
-			projectIDs[project.ID] = true
-		}
-
-		// Filter tasks
-		filteredTasks := []*models.Task{}
-		for _, task := range tasks {
-			if projectIDs[task.ProjectID] {
-				filteredTasks = append(filteredTasks, &task)
-			}
-		}
-
-		utils.RespondWithSuccess(w, http.StatusOK, "Tasks retrieved successfully", filteredTasks)
+	tasks, ok := c.queryByFields(w, r, user.ID, filterByLabels(tasks, r))
+	if !ok {
 		return
 	}
 
-	// If user is admin, return all tasks
 	utils.RespondWithSuccess(w, http.StatusOK, "Tasks retrieved successfully", tasks)
 }
 
-// GetTasks handles getting all tasks for a project
+// GetTasks handles getting all tasks for a project. Each label query
+// parameter, given as a label ID or name, keeps only the tasks that have it.
+// Custom field parameters are described at filterByFields and sortByField.
 func (c *TaskController) GetTasks(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectID := vars["projectId"]
 
-	// Get user from context
+	// Check that the user may view tasks in the project
+	if !authorize(w, r, c.Policy, policy.ActionView, policy.Task(projectID)) {
+		return
+	}
+
+	// Get tasks for project
+	tasks, err := c.TaskStore.GetByProject(projectID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	models.RollUpProgress(tasks)
+
 	user, err := middleware.GetUserFromContext(r.Context())
 	if err != nil {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
+	tasks, ok := c.queryByFields(w, r, user.ID, filterByLabels(tasks, r))
+	if !ok {
+		return
+	}
 
-	// Check if user has access to project
-	if user.Role != "admin" {
-		// Get projects for user
-		projects, err := c.ProjectStore.GetByUser(user.ID)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
-			return
+	utils.RespondWithSuccess(w, http.StatusOK, "Tasks retrieved successfully", tasks)
+}
+
+// queryByFields applies the request's custom field filters and sort to the
+// tasks, responding with an error if they are invalid. The field definitions
+// are only loaded when the request uses them.
+func (c *TaskController) queryByFields(w http.ResponseWriter, r *http.Request, userID string, tasks []models.Task) ([]models.Task, bool) {
+	if !hasFieldQuery(r) {
+		return tasks, true
+	}
+
+	projectIDs := []string{}
+	seen := make(map[string]bool)
+	for _, task := range tasks {
+		if !seen[task.ProjectID] {
+			seen[task.ProjectID] = true
+			projectIDs = append(projectIDs, task.ProjectID)
 		}
+	}
+	if projectID := mux.Vars(r)["projectId"]; projectID != "" && !seen[projectID] {
+		projectIDs = append(projectIDs, projectID)
+	}
 
-		// Check if user has access to project
-		hasAccess := false
-		for _, project := range projects {
-			if project.ID == projectID {
-				hasAccess = true
-				break
+	fields, err := c.FieldStore.GetByProjects(projectIDs)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving custom fields")
+		return nil, false
+	}
+
+	tasks, err = filterByFields(tasks, fields, r, userID)
+	if err == nil {
+		err = sortByField(tasks, fields, r)
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return tasks, true
+}
+
+// filterByLabels keeps the tasks that have every label in the request's label
+// query parameters, which may be repeated or comma separated and match a
+// label's ID or its name, ignoring case. Progress is rolled up beforehand, so
+// it still counts the subtasks that are filtered out.
+func filterByLabels(tasks []models.Task, r *http.Request) []models.Task {
+	var wanted []string
+	for _, value := range r.URL.Query()["label"] {
+		for _, label := range strings.Split(value, ",") {
+			if label = strings.TrimSpace(label); label != "" {
+				wanted = append(wanted, label)
 			}
 		}
+	}
 
-		if !hasAccess {
-			utils.RespondWithError(w, http.StatusForbidden, "Forbidden")
-			return
+	filtered := []models.Task{}
+	for _, task := range tasks {
+		if hasLabels(task, wanted) {
+			filtered = append(filtered, task)
 		}
 	}
+	return filtered
+}
 
-	// Get tasks for project
-	tasks, err := c.TaskStore.GetByProject(projectID)
-	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
-		return
+// hasLabels checks if a task has all of the labels, given as IDs or names
+func hasLabels(task models.Task, wanted []string) bool {
+	for _, want := range wanted {
+		found := false
+		for _, label := range task.Labels {
+			if label.ID == want || strings.EqualFold(label.Name, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
 	}
-
-	utils.RespondWithSuccess(w, http.StatusOK, "Tasks retrieved successfully", tasks)
+	return true
 }
 
 // GetTask handles getting a task by ID
 func (c *TaskController) GetTask(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	taskID := vars["id"]
+	// Get task with the progress of its subtasks
+	task, _, ok := c.loadSubtree(w, r)
+	if !ok {
+		return
+	}
 
-	// Get user from context
-	user, err := middleware.GetUserFromContext(r.Context())
-	if err != nil {
-		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+	utils.RespondWithSuccess(w, http.StatusOK, "Task retrieved successfully", task)
+}
+
+// GetSubtasks handles getting the direct subtasks of a task
+func (c *TaskController) GetSubtasks(w http.ResponseWriter, r *http.Request) {
+	task, subtree, ok := c.loadSubtree(w, r)
+	if !ok {
 		return
 	}
 
-	// Get task
-	task, err := c.TaskStore.GetByID(taskID)
-	if err != nil {
-		if err.Error() == "task not found" {
-			utils.RespondWithError(w, http.StatusNotFound, "Task not found")
-			return
+	children := []models.Task{}
+	for _, subtask := range subtree {
+		if subtask.ParentTaskID == task.ID {
+			children = append(children, subtask)
 		}
-		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Subtasks retrieved successfully", children)
+}
+
+// GetTaskTree handles getting a task with all of its subtasks, nested at any depth
+func (c *TaskController) GetTaskTree(w http.ResponseWriter, r *http.Request) {
+	task, subtree, ok := c.loadSubtree(w, r)
+	if !ok {
 		return
 	}
 
-	// Check if user has access to project
-	if user.Role != "admin" {
-		// Get projects for user
-		projects, err := c.ProjectStore.GetByUser(user.ID)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
-			return
+	nodes := make(map[string]*TaskNode, len(subtree))
+	for _, subtask := range subtree {
+		nodes[subtask.ID] = &TaskNode{Task: subtask, Subtasks: []*TaskNode{}}
+	}
+	// subtree is newest first, so appending keeps every level newest first.
+	// A subtask whose parent was moved out of the tree while it was being
+	// loaded is left out along with its own subtasks.
+	for _, subtask := range subtree {
+		if subtask.ID == task.ID {
+			continue
 		}
-
-		// Check if user has access to project
-		hasAccess := false
-		for _, project := range projects {
-			if project.ID == task.ProjectID {
-				hasAccess = true
-				break
-			}
+		if parent, ok := nodes[subtask.ParentTaskID]; ok {
+			parent.Subtasks = append(parent.Subtasks, nodes[subtask.ID])
 		}
+	}
 
-		if !hasAccess {
-			utils.RespondWithError(w, http.StatusForbidden, "Forbidden")
-			return
+	utils.RespondWithSuccess(w, http.StatusOK, "Task tree retrieved successfully", nodes[task.ID])
+}
+
+// loadSubtree loads the task in the request path with all of its subtasks,
+// progress rolled up, after checking that the user may view it. The task
+// itself is part of the returned subtree.
+func (c *TaskController) loadSubtree(w http.ResponseWriter, r *http.Request) (*models.Task, []models.Task, bool) {
+	task, err := c.TaskStore.GetByID(mux.Vars(r)["id"])
+	if err != nil {
+		if err == models.ErrTaskNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Task not found")
+			return nil, nil, false
 		}
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, nil, false
 	}
 
-	utils.RespondWithSuccess(w, http.StatusOK, "Task retrieved successfully", task)
+	if !authorize(w, r, c.Policy, policy.ActionView, policy.Task(task.ProjectID)) {
+		return nil, nil, false
+	}
+
+	descendants, err := c.TaskStore.GetDescendants(task.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, nil, false
+	}
+
+	subtree := append([]models.Task{*task}, descendants...)
+	models.RollUpProgress(subtree)
+	return &subtree[0], subtree, true
 }
 
 // CreateTask handles creating a new task
@@ -180,59 +309,49 @@ func (c *TaskController) CreateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Log the incoming task data
-	taskJSON, _ := json.Marshal(task)
-	println("Received task data:", string(taskJSON))
-
-	// Get user from context
-	user, err := middleware.GetUserFromContext(r.Context())
-	if err != nil {
-		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+	// Check that the user may create tasks in the project
+	if !authorize(w, r, c.Policy, policy.ActionCreate, policy.Task(task.ProjectID)) {
 		return
 	}
 
-	// Check if user has access to project
-	if user.Role != "admin" {
-		// Get projects for user
-		projects, err := c.ProjectStore.GetByUser(user.ID)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
+	// A subtask lives in the same project as its parent
+	if task.ParentTaskID != "" && !c.checkParent(w, "", task.ParentTaskID, task.ProjectID) {
+		return
+	}
 
-		// Check if user has access to project
-		hasAccess := false
-		for _, project := range projects {
-			if project.ID == task.ProjectID {
-				hasAccess = true
-				break
-			}
-		}
+	// New tasks start in the workflow's first state unless they name another
+	if !c.checkStatus(w, &task, nil) {
+		return
+	}
 
-		if !hasAccess {
-			utils.RespondWithError(w, http.StatusForbidden, "Forbidden")
-			return
-		}
+	// The remaining estimate starts out as the original estimate
+	if task.RemainingEstimate == nil {
+		task.RemainingEstimate = task.OriginalEstimate
 	}
+	if !checkEstimates(w, &task) {
+		return
+	}
+	task.Progress = nil
+	task.Blocked = false
+	task.Labels = []models.Label{}
+	task.CustomFields = map[string]json.RawMessage{}
+	task.RecurrenceID = ""
+	task.OccurrenceAt = nil
 
 	// Set task ID and timestamps
 	task.ID = uuid.New().String()
 	task.CreatedAt = time.Now()
 	task.UpdatedAt = time.Now()
 
-	// Explicitly set AssigneeID to empty string to handle null in database
-	// This is critical for handling foreign key constraint
-	if task.AssigneeID == "" || task.AssigneeID == "null" {
+	// An unset assignee is stored as NULL to satisfy the foreign key
+	if task.AssigneeID == "null" {
 		task.AssigneeID = ""
-		println("Setting task.AssigneeID to empty string")
-	} else {
-		println("Using provided assigneeID:", task.AssigneeID)
 	}
 
 	// Create task
 	err = c.TaskStore.Create(task)
 	if err != nil {
-		println("Error creating task:", err.Error())
+		log.Printf("Error creating task: %v", err)
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -245,13 +364,6 @@ func (c *TaskController) UpdateTask(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	taskID := vars["id"]
 
-	// Get user from context
-	user, err := middleware.GetUserFromContext(r.Context())
-	if err != nil {
-		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
-		return
-	}
-
 	// Get existing task
 	existingTask, err := c.TaskStore.GetByID(taskID)
 	if err != nil {
@@ -259,37 +371,40 @@ func (c *TaskController) UpdateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if user has access to the project
-	if user.Role != "admin" {
-		// Get projects for user
-		userProjects, err := c.ProjectStore.GetByUser(user.ID)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-
-		// Check if user has access to this project
-		hasAccess := false
-		for _, project := range userProjects {
-			if project.ID == existingTask.ProjectID {
-				hasAccess = true
-				break
-			}
-		}
-
-		if !hasAccess {
-			utils.RespondWithError(w, http.StatusForbidden, "You don't have access to this task")
-			return
-		}
+	// Check that the user may update tasks in the project
+	if !authorize(w, r, c.Policy, policy.ActionUpdate, policy.Task(existingTask.ProjectID)) {
+		return
 	}
 
 	// Decode request body
-	var updatedTask models.Task
-	err = json.NewDecoder(r.Body).Decode(&updatedTask)
+	var req UpdateTaskRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
+	updatedTask := req.Task
+	updatedTask.ParentTaskID = existingTask.ParentTaskID
+	if req.ParentTaskID != nil {
+		updatedTask.ParentTaskID = *req.ParentTaskID
+	}
+	updatedTask.Progress = nil
+	updatedTask.Blocked = existingTask.Blocked
+	updatedTask.Labels = existingTask.Labels
+	updatedTask.CustomFields = existingTask.CustomFields
+	updatedTask.RecurrenceID = existingTask.RecurrenceID
+	updatedTask.OccurrenceAt = existingTask.OccurrenceAt
+	if updatedTask.OriginalEstimate, err = decodeEstimate(req.OriginalEstimate, existingTask.OriginalEstimate); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "originalEstimate must be a number of seconds")
+		return
+	}
+	if updatedTask.RemainingEstimate, err = decodeEstimate(req.RemainingEstimate, existingTask.RemainingEstimate); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "remainingEstimate must be a number of seconds")
+		return
+	}
+	if updatedTask.RemainingEstimate == nil && req.RemainingEstimate == nil && existingTask.OriginalEstimate == nil {
+		updatedTask.RemainingEstimate = updatedTask.OriginalEstimate
+	}
 
 	// Validate task
 	if updatedTask.Title == "" {
@@ -312,6 +427,10 @@ func (c *TaskController) UpdateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkEstimates(w, &updatedTask) {
+		return
+	}
+
 	// Check if project exists
 	_, err = c.ProjectStore.GetByID(updatedTask.ProjectID)
 	if err != nil {
@@ -319,6 +438,33 @@ func (c *TaskController) UpdateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Moving a task requires write access to the target project as well.
+	// Its subtasks move along with it, leaving the old project's labels and
+	// custom field values behind.
+	if updatedTask.ProjectID != existingTask.ProjectID {
+		if !authorize(w, r, c.Policy, policy.ActionCreate, policy.Task(updatedTask.ProjectID)) {
+			return
+		}
+		updatedTask.Labels = []models.Label{}
+		updatedTask.CustomFields = map[string]json.RawMessage{}
+	}
+
+	// The parent has to be in the task's project and must not be the task or one of its subtasks
+	if updatedTask.ParentTaskID != "" && !c.checkParent(w, taskID, updatedTask.ParentTaskID, updatedTask.ProjectID) {
+		return
+	}
+
+	// The status has to follow the project's workflow
+	if !c.checkStatus(w, &updatedTask, existingTask) {
+		return
+	}
+
+	// A blocked task can't be finished while its blockers are open
+	if c.EnforceDependencies && existingTask.Blocked && updatedTask.IsDone() && !existingTask.IsDone() {
+		utils.RespondWithError(w, http.StatusConflict, "Task is blocked by tasks that are not done yet")
+		return
+	}
+
 	// Set ID and timestamps
 	updatedTask.ID = taskID
 	updatedTask.CreatedAt = existingTask.CreatedAt
@@ -330,6 +476,13 @@ func (c *TaskController) UpdateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Finishing the latest occurrence of a recurring task creates the next one
+	if updatedTask.IsDone() && !existingTask.IsDone() {
+		if err := c.Recurrences.Completed(&updatedTask); err != nil {
+			log.Printf("Error creating the occurrence after task %s: %v", updatedTask.ID, err)
+		}
+	}
+
 	utils.RespondWithSuccess(w, http.StatusOK, "Task updated successfully", updatedTask)
 }
 
@@ -338,13 +491,6 @@ func (c *TaskController) DeleteTask(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	taskID := vars["id"]
 
-	// Get user from context
-	user, err := middleware.GetUserFromContext(r.Context())
-	if err != nil {
-		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
-		return
-	}
-
 	// Get task
 	task, err := c.TaskStore.GetByID(taskID)
 	if err != nil {
@@ -356,36 +502,186 @@ func (c *TaskController) DeleteTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if user has access to project
-	if user.Role != "admin" {
-		// Get projects for user
-		projects, err := c.ProjectStore.GetByUser(user.ID)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
-			return
+	// Check that the user may delete tasks in the project
+	if !authorize(w, r, c.Policy, policy.ActionDelete, policy.Task(task.ProjectID)) {
+		return
+	}
+
+	// Delete task; its subtasks are deleted too unless they are promoted
+	switch r.URL.Query().Get("children") {
+	case "", deleteChildren:
+		err = c.TaskStore.Delete(taskID)
+	case promoteChildren:
+		err = c.TaskStore.DeleteKeepingChildren(taskID)
+	default:
+		utils.RespondWithError(w, http.StatusBadRequest, "children must be delete or promote")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Blobs.Trigger()
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Task deleted successfully", nil)
+}
+
+// decodeEstimate reads an estimate of an update request. An estimate left
+// out keeps the current value and null clears it.
+func decodeEstimate(raw json.RawMessage, current *int64) (*int64, error) {
+	if raw == nil {
+		return current, nil
+	}
+	var estimate *int64
+	if err := json.Unmarshal(raw, &estimate); err != nil {
+		return nil, err
+	}
+	return estimate, nil
+}
+
+// checkEstimates checks that the task's estimates are within bounds,
+// responding with an error if they are not
+func checkEstimates(w http.ResponseWriter, task *models.Task) bool {
+	for _, estimate := range []*int64{task.OriginalEstimate, task.RemainingEstimate} {
+		if estimate != nil && (*estimate < 0 || *estimate > maxEstimate) {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Estimates must be between 0 and %d seconds", maxEstimate))
+			return false
 		}
+	}
+	return true
+}
 
-		// Check if user has access to project
-		hasAccess := false
-		for _, project := range projects {
-			if project.ID == task.ProjectID {
-				hasAccess = true
-				break
-			}
+// checkStatus checks the task's status against the workflow of its project
+// and sets the status's spelling and category, responding with an error if
+// the status is not allowed. previous is the task before the change, or nil
+// for a new task. A task may keep a status the workflow doesn't have, as
+// subtasks moved from another project do, and may leave it for any state.
+func (c *TaskController) checkStatus(w http.ResponseWriter, task *models.Task, previous *models.Task) bool {
+	if previous != nil && previous.ProjectID == task.ProjectID && previous.Status == task.Status {
+		task.StatusCategory = previous.StatusCategory
+		return true
+	}
+
+	workflow, err := c.WorkflowStore.Get(task.ProjectID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving workflow")
+		return false
+	}
+
+	if task.Status == "" {
+		task.Status = workflow.Initial().Name
+	}
+	state := workflow.State(task.Status)
+	if state == nil {
+		names := make([]string, len(workflow.States))
+		for i, state := range workflow.States {
+			names[i] = state.Name
 		}
+		utils.RespondWithError(w, http.StatusBadRequest, "Status must be one of: "+strings.Join(names, ", "))
+		return false
+	}
+	task.Status = state.Name
+	task.StatusCategory = state.Category
 
-		if !hasAccess {
-			utils.RespondWithError(w, http.StatusForbidden, "Forbidden")
-			return
+	// Only moves within the workflow are restricted
+	if previous == nil || previous.ProjectID != task.ProjectID || previous.Status == state.Name || workflow.State(previous.Status) == nil {
+		return true
+	}
+	from := workflow.State(previous.Status).Name
+	transition, ok := workflow.Transition(from, state.Name)
+	if !ok {
+		utils.RespondWithError(w, http.StatusConflict, fmt.Sprintf("The workflow doesn't allow moving a task from %s to %s", from, state.Name))
+		return false
+	}
+	if transition == nil {
+		return true
+	}
+
+	missing, err := c.missingFields(task, transition.RequiredFields)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving custom fields")
+		return false
+	}
+	if len(missing) > 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Moving a task to %s requires: %s", state.Name, strings.Join(missing, ", ")))
+		return false
+	}
+	return true
+}
+
+// missingFields lists the names of the required fields the task has not set.
+// Custom fields that no longer exist are not required.
+func (c *TaskController) missingFields(task *models.Task, required []string) ([]string, error) {
+	var fields []models.CustomField
+	missing := []string{}
+	for _, name := range required {
+		switch name {
+		case models.RequiredAssignee:
+			if task.AssigneeID == "" {
+				missing = append(missing, name)
+			}
+		case models.RequiredDueDate:
+			if task.DueDate.IsZero() {
+				missing = append(missing, name)
+			}
+		case models.RequiredDescription:
+			if strings.TrimSpace(task.Description) == "" {
+				missing = append(missing, name)
+			}
+		default:
+			if _, ok := task.CustomFields[name]; ok {
+				continue
+			}
+			if fields == nil {
+				var err error
+				if fields, err = c.FieldStore.GetByProject(task.ProjectID); err != nil {
+					return nil, err
+				}
+			}
+			if field := findField(fields, task.ProjectID, name); field != nil {
+				missing = append(missing, field.Name)
+			}
 		}
 	}
+	return missing, nil
+}
+
+// checkParent checks that a task may be placed under a parent task. The parent
+// must exist in the same project and, for an existing task, must be neither
+// the task itself nor one of its subtasks, which would create a cycle.
+func (c *TaskController) checkParent(w http.ResponseWriter, taskID string, parentID string, projectID string) bool {
+	if parentID == taskID {
+		utils.RespondWithError(w, http.StatusBadRequest, "A task cannot be its own parent")
+		return false
+	}
 
-	// Delete task
-	err = c.TaskStore.Delete(taskID)
+	parent, err := c.TaskStore.GetByID(parentID)
 	if err != nil {
+		if err == models.ErrTaskNotFound {
+			utils.RespondWithError(w, http.StatusBadRequest, "Parent task not found")
+			return false
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
-		return
+		return false
+	}
+	if parent.ProjectID != projectID {
+		utils.RespondWithError(w, http.StatusBadRequest, "Parent task must be in the same project")
+		return false
 	}
 
-	utils.RespondWithSuccess(w, http.StatusOK, "Task deleted successfully", nil)
+	if taskID == "" {
+		return true
+	}
+	descendants, err := c.TaskStore.GetDescendants(taskID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	for _, descendant := range descendants {
+		if descendant.ID == parentID {
+			utils.RespondWithError(w, http.StatusBadRequest, "A task cannot be moved under one of its own subtasks")
+			return false
+		}
+	}
+	return true
 }
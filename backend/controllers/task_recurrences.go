@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"go-react-redux-app/models"
+)
+
+// TaskRecurrences creates the occurrences of recurring tasks. The next
+// occurrence of a recurrence is created as soon as its latest one is done,
+// or otherwise when the moment of the next occurrence arrives. Each
+// recurrence records its latest occurrence, and an occurrence is only
+// created after it, so nothing is created twice when the server restarts or
+// both happen at once.
+type TaskRecurrences struct {
+	Recurrences models.RecurrenceRepository
+	Workflows   models.WorkflowRepository
+
+	trigger chan struct{}
+}
+
+// NewTaskRecurrences creates a new TaskRecurrences
+func NewTaskRecurrences(recurrences models.RecurrenceRepository, workflows models.WorkflowRepository) *TaskRecurrences {
+	return &TaskRecurrences{
+		Recurrences: recurrences,
+		Workflows:   workflows,
+		trigger:     make(chan struct{}, 1),
+	}
+}
+
+// Completed creates the occurrence after a task that was just finished, if
+// the task is the latest occurrence of a recurrence that goes on
+func (s *TaskRecurrences) Completed(task *models.Task) error {
+	if task.RecurrenceID == "" || task.OccurrenceAt == nil {
+		return nil
+	}
+
+	rec, err := s.Recurrences.GetByID(task.RecurrenceID)
+	if err == models.ErrRecurrenceNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if rec.EndsAt != nil || !rec.LastOccurrenceAt.Equal(*task.OccurrenceAt) {
+		return nil
+	}
+
+	schedule, err := rec.Schedule()
+	if err != nil {
+		return err
+	}
+	next, ok := schedule.Next(rec.LastOccurrenceAt)
+	if !ok {
+		return nil
+	}
+	_, err = s.addOccurrence(rec, next)
+	return err
+}
+
+// CreateDue creates the occurrences whose moment has arrived. When several
+// occurrences of a recurrence were missed, such as while the server was
+// down, only the latest of them is created.
+func (s *TaskRecurrences) CreateDue() error {
+	recs, err := s.Recurrences.GetActive()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range recs {
+		rec := &recs[i]
+		schedule, err := rec.Schedule()
+		if err != nil {
+			log.Printf("Error scheduling recurrence %s: %v", rec.ID, err)
+			continue
+		}
+		due, ok := schedule.Latest(rec.LastOccurrenceAt, now)
+		if !ok {
+			continue
+		}
+		if _, err := s.addOccurrence(rec, due); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addOccurrence creates the occurrence of a recurrence at a moment, in the
+// first state of its project's workflow. It reports false if the recurrence
+// already has an occurrence at or after that moment.
+func (s *TaskRecurrences) addOccurrence(rec *models.Recurrence, at time.Time) (bool, error) {
+	workflow, err := s.Workflows.Get(rec.ProjectID)
+	if err != nil {
+		return false, err
+	}
+	initial := workflow.Initial()
+
+	at = at.UTC()
+	now := time.Now()
+	task := &models.Task{
+		ID:             uuid.New().String(),
+		Title:          rec.Title,
+		Description:    rec.Description,
+		Status:         initial.Name,
+		StatusCategory: initial.Category,
+		Priority:       rec.Priority,
+		ProjectID:      rec.ProjectID,
+		AssigneeID:     rec.AssigneeID,
+		DueDate:        at,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		RecurrenceID:   rec.ID,
+		OccurrenceAt:   &at,
+	}
+	return s.Recurrences.AddOccurrence(rec, task)
+}
+
+// Trigger asks the background scheduling started by Start to run soon
+func (s *TaskRecurrences) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs CreateDue in the background right away, then every interval
+// and whenever triggered
+func (s *TaskRecurrences) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := s.CreateDue(); err != nil {
+				log.Printf("Error creating task occurrences: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-s.trigger:
+			}
+		}
+	}()
+}
@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"go-react-redux-app/database"
+	"go-react-redux-app/migrations"
+	"go-react-redux-app/models"
+)
+
+// recurrenceStores are the stores TaskRecurrences works with on one driver
+type recurrenceStores struct {
+	users       models.UserRepository
+	projects    models.ProjectRepository
+	tasks       models.TaskRepository
+	recurrences models.RecurrenceRepository
+	workflows   models.WorkflowRepository
+}
+
+// drivers lists the storage drivers the recurrence tests run against. The
+// SQLite database is migrated like the server does, so its unique index on
+// the occurrences of a recurrence is in place.
+func drivers(t *testing.T) map[string]func() recurrenceStores {
+	return map[string]func() recurrenceStores{
+		"memory": func() recurrenceStores {
+			db := models.NewMemoryDB()
+			return recurrenceStores{
+				users:       models.NewMemoryUserStore(db),
+				projects:    models.NewMemoryProjectStore(db),
+				tasks:       models.NewMemoryTaskStore(db),
+				recurrences: models.NewMemoryRecurrenceStore(db),
+				workflows:   models.NewMemoryWorkflowStore(db),
+			}
+		},
+		"sqlite": func() recurrenceStores {
+			db, err := database.Open(database.SQLite, filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatalf("opening database: %v", err)
+			}
+			t.Cleanup(func() { db.Close() })
+			migrator, err := migrations.New(db)
+			if err != nil {
+				t.Fatalf("loading migrations: %v", err)
+			}
+			if _, err := migrator.Up(); err != nil {
+				t.Fatalf("migrating: %v", err)
+			}
+			return recurrenceStores{
+				users:       models.NewUserStore(db),
+				projects:    models.NewProjectStore(db),
+				tasks:       models.NewTaskStore(db),
+				recurrences: models.NewRecurrenceStore(db),
+				workflows:   models.NewWorkflowStore(db),
+			}
+		},
+	}
+}
+
+// newRecurringTask creates a task repeating daily from start and returns it
+// as the first occurrence of its recurrence
+func newRecurringTask(t *testing.T, stores recurrenceStores, start time.Time) *models.Task {
+	t.Helper()
+
+	now := time.Now()
+	owner := &models.User{ID: "owner-id", Username: "owner", Email: "owner@example.com", Password: "correct horse battery", Role: models.UserRoleUser, CreatedAt: now, UpdatedAt: now}
+	if err := stores.users.Create(owner); err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	if err := stores.projects.Create(&models.Project{ID: "project-id", Name: "Project", OwnerID: owner.ID, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	task := models.Task{ID: "task-1", Title: "Stand-up", Status: "Pending", StatusCategory: models.StatusCategoryTodo, Priority: "low", ProjectID: "project-id", CreatedAt: now, UpdatedAt: now}
+	if err := stores.tasks.Create(task); err != nil {
+		t.Fatalf("creating task: %v", err)
+	}
+
+	rec := &models.Recurrence{
+		ID:               "recurrence-id",
+		ProjectID:        "project-id",
+		Rule:             "FREQ=DAILY",
+		Timezone:         "Europe/Berlin",
+		StartsAt:         start,
+		LastOccurrenceAt: start,
+		Title:            task.Title,
+		Priority:         task.Priority,
+		CreatedBy:        owner.ID,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if err := stores.recurrences.Create(rec, task.ID); err != nil {
+		t.Fatalf("creating recurrence: %v", err)
+	}
+
+	first, err := stores.tasks.GetByID(task.ID)
+	if err != nil {
+		t.Fatalf("loading task: %v", err)
+	}
+	return first
+}
+
+// occurrences returns the moments of the occurrences of the recurrence, oldest first
+func occurrences(t *testing.T, stores recurrenceStores) []time.Time {
+	t.Helper()
+
+	tasks, err := stores.recurrences.GetOccurrences("recurrence-id")
+	if err != nil {
+		t.Fatalf("GetOccurrences: %v", err)
+	}
+	moments := make([]time.Time, 0, len(tasks))
+	for _, task := range tasks {
+		moments = append(moments, *task.OccurrenceAt)
+	}
+	sort.Slice(moments, func(i, j int) bool { return moments[i].Before(moments[j]) })
+	return moments
+}
+
+func TestCompletedCreatesNextOccurrenceOnce(t *testing.T) {
+	start := time.Date(2030, 3, 30, 7, 0, 0, 0, time.UTC)
+
+	for name, open := range drivers(t) {
+		t.Run(name, func(t *testing.T) {
+			stores := open()
+			first := newRecurringTask(t, stores, start)
+			scheduler := NewTaskRecurrences(stores.recurrences, stores.workflows)
+
+			for i := 0; i < 2; i++ {
+				if err := scheduler.Completed(first); err != nil {
+					t.Fatalf("Completed: %v", err)
+				}
+			}
+
+			// The next day is at 08:00 in Berlin as well, after summer time started
+			want := []time.Time{start, time.Date(2030, 3, 31, 6, 0, 0, 0, time.UTC)}
+			got := occurrences(t, stores)
+			if len(got) != len(want) {
+				t.Fatalf("occurrences = %v, want %v", got, want)
+			}
+			for i := range want {
+				if !got[i].Equal(want[i]) {
+					t.Errorf("occurrence %d = %v, want %v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestConcurrentCompletionsCreateOneOccurrence(t *testing.T) {
+	start := time.Date(2030, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	for name, open := range drivers(t) {
+		t.Run(name, func(t *testing.T) {
+			stores := open()
+			first := newRecurringTask(t, stores, start)
+			scheduler := NewTaskRecurrences(stores.recurrences, stores.workflows)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, 8)
+			for i := 0; i < cap(errs); i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					task := *first
+					errs <- scheduler.Completed(&task)
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatalf("Completed: %v", err)
+				}
+			}
+
+			if got := occurrences(t, stores); len(got) != 2 {
+				t.Errorf("occurrences = %v, want the first and one more", got)
+			}
+		})
+	}
+}
+
+func TestAddOccurrenceRejectsStaleRecurrence(t *testing.T) {
+	start := time.Date(2030, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	for name, open := range drivers(t) {
+		t.Run(name, func(t *testing.T) {
+			stores := open()
+			newRecurringTask(t, stores, start)
+			scheduler := NewTaskRecurrences(stores.recurrences, stores.workflows)
+
+			// A copy loaded before another server created the next occurrence
+			stale, err := stores.recurrences.GetByID("recurrence-id")
+			if err != nil {
+				t.Fatalf("GetByID: %v", err)
+			}
+			next := start.Add(24 * time.Hour)
+			if added, err := scheduler.addOccurrence(stale, next); err != nil || !added {
+				t.Fatalf("addOccurrence = %v, %v, want it added", added, err)
+			}
+
+			stale.LastOccurrenceAt = start
+			for _, at := range []time.Time{next, start.Add(time.Hour)} {
+				if added, err := scheduler.addOccurrence(stale, at); err != nil || added {
+					t.Errorf("addOccurrence(%v) with a stale recurrence = %v, %v, want nothing added", at, added, err)
+				}
+			}
+
+			if got := occurrences(t, stores); len(got) != 2 {
+				t.Errorf("occurrences = %v, want 2", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-react-redux-app/models"
+	"go-react-redux-app/totp"
+	"go-react-redux-app/utils"
+)
+
+// recoveryCodeCount is the number of recovery codes issued at a time
+const recoveryCodeCount = 10
+
+// recoveryCodeEncoding writes recovery codes in lower-case base32, which avoids look-alike characters
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// TwoFactorController handles users managing TOTP two-factor authentication
+type TwoFactorController struct {
+	UserStore      models.UserRepository
+	TwoFactorStore models.TwoFactorRepository
+	// Issuer names the application in authenticator apps
+	Issuer string
+}
+
+// NewTwoFactorController creates a new TwoFactorController
+func NewTwoFactorController(userStore models.UserRepository, twoFactorStore models.TwoFactorRepository, issuer string) *TwoFactorController {
+	return &TwoFactorController{
+		UserStore:      userStore,
+		TwoFactorStore: twoFactorStore,
+		Issuer:         issuer,
+	}
+}
+
+// TwoFactorCodeRequest represents a request carrying a TOTP code or a recovery code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// TwoFactorPasswordRequest represents a request that has to be confirmed with
+// the password, and for some actions a second factor too
+type TwoFactorPasswordRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// TwoFactorStatus represents the two-factor state of the authenticated user
+type TwoFactorStatus struct {
+	Enabled                bool       `json:"enabled"`
+	Pending                bool       `json:"pending"`
+	EnabledAt              *time.Time `json:"enabledAt,omitempty"`
+	RecoveryCodesRemaining int        `json:"recoveryCodesRemaining"`
+}
+
+// TwoFactorEnrollment represents a new secret for the user to add to an authenticator app
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// RecoveryCodesResponse represents freshly issued recovery codes. They are
+// shown once; only their hashes are stored.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// GetStatus handles getting the two-factor state of the authenticated user
+func (c *TwoFactorController) GetStatus(w http.ResponseWriter, r *http.Request) {
+	user, ok := loadCurrentUser(w, r, c.UserStore)
+	if !ok {
+		return
+	}
+
+	status := TwoFactorStatus{}
+	twoFactor, err := c.TwoFactorStore.Get(user.ID)
+	if err != nil && err != models.ErrTwoFactorNotFound {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving two-factor authentication")
+		return
+	}
+	if err == nil {
+		status.Enabled = twoFactor.IsEnabled()
+		status.Pending = !twoFactor.IsEnabled()
+		status.EnabledAt = twoFactor.ConfirmedAt
+	}
+	if status.Enabled {
+		status.RecoveryCodesRemaining, err = c.TwoFactorStore.CountRecoveryCodes(user.ID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving two-factor authentication")
+			return
+		}
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication retrieved successfully", status)
+}
+
+// Enroll handles starting two-factor enrollment. It returns a new secret and
+// its otpauth URI; two-factor authentication is only turned on once Confirm
+// receives a code generated from it.
+func (c *TwoFactorController) Enroll(w http.ResponseWriter, r *http.Request) {
+	user, ok := loadCurrentUser(w, r, c.UserStore)
+	if !ok {
+		return
+	}
+
+	var req TwoFactorPasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Password == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Password is required")
+		return
+	}
+
+	if !user.CheckPassword(req.Password) {
+		utils.RespondWithError(w, http.StatusForbidden, "Password is incorrect")
+		return
+	}
+
+	existing, err := c.TwoFactorStore.Get(user.ID)
+	if err == nil && existing.IsEnabled() {
+		utils.RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error enrolling two-factor authentication")
+		return
+	}
+
+	err = c.TwoFactorStore.Enroll(&models.TwoFactor{
+		UserID:    user.ID,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error enrolling two-factor authentication")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusCreated, "Add the secret to your authenticator app and confirm it with a code", TwoFactorEnrollment{
+		Secret: secret,
+		URI:    totp.URI(c.Issuer, user.Username, secret),
+	})
+}
+
+// Confirm handles confirming a pending enrollment with a TOTP code. It turns
+// two-factor authentication on and returns the recovery codes.
+func (c *TwoFactorController) Confirm(w http.ResponseWriter, r *http.Request) {
+	user, ok := loadCurrentUser(w, r, c.UserStore)
+	if !ok {
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Code == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Code is required")
+		return
+	}
+
+	twoFactor, err := c.TwoFactorStore.Get(user.ID)
+	if err != nil || twoFactor.IsEnabled() {
+		utils.RespondWithError(w, http.StatusBadRequest, "No two-factor enrollment is pending")
+		return
+	}
+
+	step, valid := totp.Validate(twoFactor.Secret, req.Code, time.Now())
+	if !valid {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err == nil {
+		err = c.TwoFactorStore.Confirm(user.ID, step, hashes)
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error enabling two-factor authentication")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication enabled, store your recovery codes safely", RecoveryCodesResponse{
+		RecoveryCodes: codes,
+	})
+}
+
+// RegenerateRecoveryCodes handles replacing all recovery codes of the authenticated user
+func (c *TwoFactorController) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := loadCurrentUser(w, r, c.UserStore)
+	if !ok {
+		return
+	}
+
+	if _, ok := c.confirmWithSecondFactor(w, r, user); !ok {
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err == nil {
+		err = c.TwoFactorStore.ReplaceRecoveryCodes(user.ID, hashes)
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error generating recovery codes")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Recovery codes regenerated successfully", RecoveryCodesResponse{
+		RecoveryCodes: codes,
+	})
+}
+
+// Disable handles turning two-factor authentication off for the authenticated user
+func (c *TwoFactorController) Disable(w http.ResponseWriter, r *http.Request) {
+	user, ok := loadCurrentUser(w, r, c.UserStore)
+	if !ok {
+		return
+	}
+
+	if _, ok := c.confirmWithSecondFactor(w, r, user); !ok {
+		return
+	}
+
+	if err := c.TwoFactorStore.Delete(user.ID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error disabling two-factor authentication")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication disabled", nil)
+}
+
+// confirmWithSecondFactor checks the password and a TOTP or recovery code in
+// the request body. It writes an error response and returns false if they
+// don't match or two-factor authentication is not enabled.
+func (c *TwoFactorController) confirmWithSecondFactor(w http.ResponseWriter, r *http.Request, user *models.User) (*models.TwoFactor, bool) {
+	var req TwoFactorPasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Password == "" || req.Code == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Password and code are required")
+		return nil, false
+	}
+
+	twoFactor, err := c.TwoFactorStore.Get(user.ID)
+	if err != nil || !twoFactor.IsEnabled() {
+		utils.RespondWithError(w, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return nil, false
+	}
+
+	if !user.CheckPassword(req.Password) {
+		utils.RespondWithError(w, http.StatusForbidden, "Password is incorrect")
+		return nil, false
+	}
+
+	valid, err := verifySecondFactor(c.TwoFactorStore, twoFactor, req.Code)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error verifying code")
+		return nil, false
+	}
+	if !valid {
+		utils.RespondWithError(w, http.StatusForbidden, "Invalid code")
+		return nil, false
+	}
+
+	return twoFactor, true
+}
+
+// verifySecondFactor checks a TOTP code or, failing that, a recovery code.
+// Accepted codes are used up: a TOTP code can't be replayed and a recovery
+// code works only once.
+func verifySecondFactor(store models.TwoFactorRepository, twoFactor *models.TwoFactor, code string) (bool, error) {
+	if step, valid := totp.Validate(twoFactor.Secret, code, time.Now()); valid {
+		return store.UseStep(twoFactor.UserID, step)
+	}
+
+	normalized := normalizeRecoveryCode(code)
+	if normalized == "" {
+		return false, nil
+	}
+	return store.UseRecoveryCode(twoFactor.UserID, utils.HashToken(normalized))
+}
+
+// generateRecoveryCodes returns new recovery codes along with the hashes to store
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		// 10 random bytes give 16 characters, written in groups of four
+		buf := make([]byte, 10)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := recoveryCodeEncoding.EncodeToString(buf)
+		codes[i] = raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		hashes[i] = utils.HashToken(raw)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode strips the separators and case users may type a recovery code with
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return code
+}
@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-react-redux-app/mailer"
+	"go-react-redux-app/middleware"
+	"go-react-redux-app/models"
+	"go-react-redux-app/password"
+	"go-react-redux-app/utils"
+)
+
+// UserController handles requests of users managing their own account
+type UserController struct {
+	UserStore    models.UserRepository
+	SessionStore models.SessionRepository
+	ProjectStore models.ProjectRepository
+	// PasswordPolicy is enforced on password changes
+	PasswordPolicy *password.Policy
+
+	emails *accountEmails
+}
+
+// NewUserController creates a new UserController
+func NewUserController(userStore models.UserRepository, sessionStore models.SessionRepository, projectStore models.ProjectRepository, tokenStore models.UserTokenRepository, mail mailer.Mailer, appURL string, passwordPolicy *password.Policy) *UserController {
+	return &UserController{
+		UserStore:      userStore,
+		SessionStore:   sessionStore,
+		ProjectStore:   projectStore,
+		PasswordPolicy: passwordPolicy,
+
+		emails: newAccountEmails(tokenStore, mail, appURL),
+	}
+}
+
+// UpdateProfileRequest represents a request to update the profile. Omitted fields are left unchanged.
+type UpdateProfileRequest struct {
+	FirstName *string `json:"firstName"`
+	LastName  *string `json:"lastName"`
+	Email     *string `json:"email"`
+}
+
+// ChangePasswordRequest represents a request to change the password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// DeleteAccountRequest represents a request to delete the account
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// GetMe handles getting the authenticated user
+func (c *UserController) GetMe(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "User retrieved successfully", user)
+}
+
+// UpdateMe handles updating the profile of the authenticated user. Changing
+// the email address marks it unverified and sends a new verification link.
+func (c *UserController) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateProfileRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.FirstName != nil {
+		user.FirstName = strings.TrimSpace(*req.FirstName)
+	}
+	if req.LastName != nil {
+		user.LastName = strings.TrimSpace(*req.LastName)
+	}
+
+	emailChanged := false
+	if req.Email != nil {
+		email := strings.TrimSpace(*req.Email)
+		if email == "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "Email cannot be empty")
+			return
+		}
+		if email != user.Email {
+			if existing, err := c.UserStore.GetByEmail(email); err == nil && existing.ID != user.ID {
+				utils.RespondWithError(w, http.StatusConflict, "Email is already in use")
+				return
+			}
+			user.Email = email
+			emailChanged = true
+		}
+	}
+
+	user.UpdatedAt = time.Now()
+	err = c.UserStore.Update(user)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating profile")
+		return
+	}
+
+	if emailChanged {
+		if err := c.UserStore.SetEmailVerified(user.ID, false); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error updating profile")
+			return
+		}
+		user.EmailVerified = false
+
+		if err := c.emails.sendVerification(user); err != nil {
+			log.Printf("Error sending verification email to user %s: %v", user.ID, err)
+		}
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Profile updated successfully", user)
+}
+
+// ChangePassword handles changing the password of the authenticated user.
+// Every other session of the user is revoked; the current one stays valid.
+func (c *UserController) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req ChangePasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Current password and new password are required")
+		return
+	}
+
+	if !user.CheckPassword(req.CurrentPassword) {
+		utils.RespondWithError(w, http.StatusForbidden, "Current password is incorrect")
+		return
+	}
+
+	if err := c.PasswordPolicy.Validate(req.NewPassword, user.Username, user.Email); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := user.SetPassword(req.NewPassword); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error changing password")
+		return
+	}
+
+	if err := c.UserStore.UpdatePassword(user.ID, user.Password); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error changing password")
+		return
+	}
+
+	sessionID, _ := middleware.GetSessionIDFromContext(r.Context())
+	if err := c.SessionStore.RevokeAllForUserExcept(user.ID, sessionID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error revoking sessions")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Password changed successfully", nil)
+}
+
+// DeleteMe handles deleting the account of the authenticated user. Projects
+// the user owns are deleted with it, so owners of projects that still have
+// other members must transfer or delete them first.
+func (c *UserController) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req DeleteAccountRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Password == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Password is required")
+		return
+	}
+
+	if !user.CheckPassword(req.Password) {
+		utils.RespondWithError(w, http.StatusForbidden, "Password is incorrect")
+		return
+	}
+
+	projects, err := c.ProjectStore.GetByOwner(user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error deleting account")
+		return
+	}
+	for _, project := range projects {
+		members, err := c.ProjectStore.GetMembers(project.ID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error deleting account")
+			return
+		}
+		if len(members) > 1 {
+			utils.RespondWithError(w, http.StatusConflict, "Project "+project.Name+" has other members; transfer or delete it first")
+			return
+		}
+	}
+
+	err = c.UserStore.Delete(user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error deleting account")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Account deleted successfully", nil)
+}
+
+// currentUser loads the authenticated user from the store. It writes an
+// error response and returns false if that fails.
+func (c *UserController) currentUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	return loadCurrentUser(w, r, c.UserStore)
+}
+
+// loadCurrentUser loads the authenticated user from a user store. It writes
+// an error response and returns false if that fails.
+func loadCurrentUser(w http.ResponseWriter, r *http.Request, userStore models.UserRepository) (*models.User, bool) {
+	contextUser, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+
+	user, err := userStore.GetByID(contextUser.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found")
+		return nil, false
+	}
+
+	return user, true
+}
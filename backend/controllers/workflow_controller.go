@@ -0,0 +1,305 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"go-react-redux-app/models"
+	"go-react-redux-app/policy"
+	"go-react-redux-app/utils"
+)
+
+// Limits on workflow definitions
+const (
+	maxWorkflowStates      = 50
+	maxWorkflowTransitions = 500
+	maxStateNameLength     = 50
+)
+
+// WorkflowController handles requests for project workflows
+type WorkflowController struct {
+	WorkflowStore models.WorkflowRepository
+	TaskStore     models.TaskRepository
+	ProjectStore  models.ProjectRepository
+	FieldStore    models.CustomFieldRepository
+	Policy        *policy.Policy
+}
+
+// NewWorkflowController creates a new WorkflowController
+func NewWorkflowController(workflowStore models.WorkflowRepository, taskStore models.TaskRepository, projectStore models.ProjectRepository, fieldStore models.CustomFieldRepository, authz *policy.Policy) *WorkflowController {
+	return &WorkflowController{
+		WorkflowStore: workflowStore,
+		TaskStore:     taskStore,
+		ProjectStore:  projectStore,
+		FieldStore:    fieldStore,
+		Policy:        authz,
+	}
+}
+
+// WorkflowResponse is a project's workflow along with the statuses its tasks
+// use that are not states of the workflow
+type WorkflowResponse struct {
+	*models.Workflow
+	UnmappedStatuses []string `json:"unmappedStatuses"`
+}
+
+// SetWorkflowRequest represents a request to replace a project's workflow.
+// Default goes back to the default workflow instead. StatusMap moves tasks
+// from statuses the new workflow does not have to one of its states.
+type SetWorkflowRequest struct {
+	States      []models.WorkflowState      `json:"states"`
+	Transitions []models.WorkflowTransition `json:"transitions"`
+	Default     bool                        `json:"default"`
+	StatusMap   map[string]string           `json:"statusMap"`
+}
+
+// GetWorkflow handles getting a project's workflow
+func (c *WorkflowController) GetWorkflow(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := c.loadProject(w, r, policy.ActionView)
+	if !ok {
+		return
+	}
+
+	workflow, err := c.WorkflowStore.Get(projectID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving workflow")
+		return
+	}
+	unmapped, err := c.unmappedStatuses(workflow)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving tasks")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Workflow retrieved successfully", WorkflowResponse{workflow, unmapped})
+}
+
+// SetWorkflow handles replacing a project's workflow. Every status the
+// project's tasks use has to be a state of the new workflow or be mapped to
+// one in statusMap.
+func (c *WorkflowController) SetWorkflow(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := c.loadProject(w, r, policy.ActionUpdate)
+	if !ok {
+		return
+	}
+
+	var req SetWorkflowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	workflow := models.DefaultWorkflow(projectID)
+	if !req.Default {
+		now := time.Now()
+		workflow = &models.Workflow{
+			ProjectID:   projectID,
+			States:      req.States,
+			Transitions: req.Transitions,
+			UpdatedAt:   &now,
+		}
+		if !c.normalizeWorkflow(w, workflow) {
+			return
+		}
+	} else if len(req.States) > 0 || len(req.Transitions) > 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Leave out states and transitions to go back to the default workflow")
+		return
+	}
+
+	// Statuses are mapped ignoring case, and only to states, so a status
+	// is never mapped to another one that is mapped in turn
+	froms := make([]string, 0, len(req.StatusMap))
+	for from := range req.StatusMap {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	statusMap := make(map[string]string, len(req.StatusMap))
+	mapped := make(map[string]string, len(req.StatusMap))
+	for _, from := range froms {
+		to := req.StatusMap[from]
+		state := workflow.State(to)
+		if state == nil {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("statusMap maps %q to %q, which is not a state of the workflow", from, to))
+			return
+		}
+		if workflow.State(from) != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("statusMap can only map statuses the workflow does not have, not %q", from))
+			return
+		}
+		if other, ok := mapped[strings.ToLower(from)]; ok && statusMap[other] != state.Name {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("statusMap maps %q and %q to different states", other, from))
+			return
+		}
+		mapped[strings.ToLower(from)] = from
+		statusMap[from] = state.Name
+	}
+
+	// Tasks whose status only differs from a state in case take its spelling
+	unmapped, err := c.unmappedStatuses(workflow)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving tasks")
+		return
+	}
+	missing := []string{}
+	for _, status := range unmapped {
+		if _, ok := mapped[strings.ToLower(status)]; ok {
+			continue
+		}
+		if state := workflow.State(status); state != nil {
+			statusMap[status] = state.Name
+			continue
+		}
+		missing = append(missing, status)
+	}
+	if len(missing) > 0 {
+		utils.RespondWithError(w, http.StatusConflict, "Tasks use statuses the workflow does not have; map them to its states in statusMap: "+strings.Join(missing, ", "))
+		return
+	}
+
+	if err := c.WorkflowStore.Set(workflow, statusMap); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error saving workflow")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Workflow updated successfully", WorkflowResponse{workflow, []string{}})
+}
+
+// unmappedStatuses lists the statuses of the project's tasks that are not
+// spelled exactly like a state of the workflow
+func (c *WorkflowController) unmappedStatuses(workflow *models.Workflow) ([]string, error) {
+	tasks, err := c.TaskStore.GetByProject(workflow.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	unmapped := []string{}
+	for _, task := range tasks {
+		if seen[task.Status] {
+			continue
+		}
+		seen[task.Status] = true
+		if state := workflow.State(task.Status); state == nil || state.Name != task.Status {
+			unmapped = append(unmapped, task.Status)
+		}
+	}
+	sort.Strings(unmapped)
+	return unmapped, nil
+}
+
+// normalizeWorkflow trims the names in a workflow, resolves the states and
+// custom fields its transitions refer to and checks it, responding with an
+// error if it is invalid
+func (c *WorkflowController) normalizeWorkflow(w http.ResponseWriter, workflow *models.Workflow) bool {
+	if len(workflow.States) == 0 || len(workflow.States) > maxWorkflowStates {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("A workflow needs between 1 and %d states", maxWorkflowStates))
+		return false
+	}
+	if len(workflow.Transitions) > maxWorkflowTransitions {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("A workflow can have at most %d transitions", maxWorkflowTransitions))
+		return false
+	}
+
+	states := make([]models.WorkflowState, 0, len(workflow.States))
+	for _, state := range workflow.States {
+		state.Name = strings.TrimSpace(state.Name)
+		switch {
+		case state.Name == "" || utf8.RuneCountInString(state.Name) > maxStateNameLength:
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("State names must be between 1 and %d characters", maxStateNameLength))
+			return false
+		case state.Name == models.AnyState:
+			utils.RespondWithError(w, http.StatusBadRequest, "A state can't be named *")
+			return false
+		case !models.IsStatusCategory(state.Category):
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("The category of %s must be todo, in_progress or done", state.Name))
+			return false
+		}
+		for _, other := range states {
+			if strings.EqualFold(other.Name, state.Name) {
+				utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("State %q is listed twice", state.Name))
+				return false
+			}
+		}
+		states = append(states, state)
+	}
+	workflow.States = states
+
+	var fields []models.CustomField
+	transitions := make([]models.WorkflowTransition, 0, len(workflow.Transitions))
+	for _, transition := range workflow.Transitions {
+		from := workflow.State(strings.TrimSpace(transition.From))
+		to := workflow.State(strings.TrimSpace(transition.To))
+		switch {
+		case to == nil:
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Transitions must lead to a state of the workflow, not %q", transition.To))
+			return false
+		case from == nil && strings.TrimSpace(transition.From) != models.AnyState:
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Transitions must start at a state of the workflow or *, not %q", transition.From))
+			return false
+		case from != nil && from.Name == to.Name:
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("A transition can't lead from %s to itself", to.Name))
+			return false
+		}
+		transition.From = models.AnyState
+		if from != nil {
+			transition.From = from.Name
+		}
+		transition.To = to.Name
+		for _, other := range transitions {
+			if other.From == transition.From && other.To == transition.To {
+				utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("The transition from %s to %s is listed twice", transition.From, transition.To))
+				return false
+			}
+		}
+
+		required := []string{}
+		for _, name := range transition.RequiredFields {
+			switch name {
+			case models.RequiredAssignee, models.RequiredDueDate, models.RequiredDescription:
+			default:
+				if fields == nil {
+					var err error
+					if fields, err = c.FieldStore.GetByProject(workflow.ProjectID); err != nil {
+						utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving custom fields")
+						return false
+					}
+				}
+				field := findField(fields, workflow.ProjectID, name)
+				if field == nil {
+					utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Required fields must be assignee, dueDate, description or a custom field of the project, not %q", name))
+					return false
+				}
+				name = field.ID
+			}
+			if !containsString(required, name) {
+				required = append(required, name)
+			}
+		}
+		transition.RequiredFields = required
+		transitions = append(transitions, transition)
+	}
+	workflow.Transitions = transitions
+	return true
+}
+
+// loadProject checks that the project in the path exists and that the user
+// may perform the action on its workflow
+func (c *WorkflowController) loadProject(w http.ResponseWriter, r *http.Request, action policy.Action) (string, bool) {
+	projectID := mux.Vars(r)["id"]
+
+	if _, err := c.ProjectStore.GetByID(projectID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Project not found")
+		return "", false
+	}
+
+	if !authorize(w, r, c.Policy, action, policy.Workflow(projectID)) {
+		return "", false
+	}
+	return projectID, true
+}
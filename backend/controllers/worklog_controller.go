@@ -0,0 +1,585 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go-react-redux-app/middleware"
+	"go-react-redux-app/models"
+	"go-react-redux-app/policy"
+	"go-react-redux-app/utils"
+)
+
+// Limits on worklogs
+const (
+	// maxWorklogDuration bounds time logged by hand; a timer logs however long it ran
+	maxWorklogDuration   = 24 * 60 * 60
+	maxWorklogNoteLength = 1000
+)
+
+// Groupings of a worklog report
+const (
+	groupByTask    = "task"
+	groupByProject = "project"
+	groupByUser    = "user"
+)
+
+// reportDateLayout is the format of the dates bounding a worklog report
+const reportDateLayout = "2006-01-02"
+
+// WorklogController handles requests for worklogs, timers and time reports
+type WorklogController struct {
+	WorklogStore models.WorklogRepository
+	TaskStore    models.TaskRepository
+	Policy       *policy.Policy
+}
+
+// NewWorklogController creates a new WorklogController
+func NewWorklogController(worklogStore models.WorklogRepository, taskStore models.TaskRepository, authz *policy.Policy) *WorklogController {
+	return &WorklogController{
+		WorklogStore: worklogStore,
+		TaskStore:    taskStore,
+		Policy:       authz,
+	}
+}
+
+// WorklogRequest represents a request to log or edit time. Duration is in
+// seconds. When logging, StartedAt defaults to Duration before now; when
+// editing, fields left out keep their value.
+type WorklogRequest struct {
+	StartedAt *time.Time `json:"startedAt"`
+	Duration  *int64     `json:"duration"`
+	Note      *string    `json:"note"`
+}
+
+// TimerRequest represents a request to start or stop a timer. A note given
+// when stopping replaces the one given when starting.
+type TimerRequest struct {
+	Note *string `json:"note"`
+}
+
+// TimerResponse is a running timer along with the seconds it has run
+type TimerResponse struct {
+	*models.Timer
+	Elapsed int64 `json:"elapsed"`
+}
+
+// WorklogReport is the time logged over a range of days, in seconds, in total
+// and per task, project or user
+type WorklogReport struct {
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Timezone string         `json:"timezone"`
+	GroupBy  string         `json:"groupBy"`
+	Duration int64          `json:"duration"`
+	Entries  int            `json:"entries"`
+	Groups   []WorklogGroup `json:"groups"`
+}
+
+// WorklogGroup is the time logged on a task or project or by a user. Time
+// of deleted users is grouped under an empty ID.
+type WorklogGroup struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	ProjectID string `json:"projectId,omitempty"`
+	Duration  int64  `json:"duration"`
+	Entries   int    `json:"entries"`
+}
+
+// GetWorklogs handles listing a task's worklogs, latest first. It supports the page and pageSize query parameters.
+func (c *WorklogController) GetWorklogs(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, mux.Vars(r)["id"], policy.ActionView)
+	if !ok {
+		return
+	}
+
+	pagination, err := parsePagination(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	worklogs, total, err := c.WorklogStore.GetByTask(task.ID, pagination.PageSize, pagination.Offset())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving worklogs")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Worklogs retrieved successfully", PageResponse{
+		Items:    worklogs,
+		Total:    total,
+		Page:     pagination.Page,
+		PageSize: pagination.PageSize,
+	})
+}
+
+// CreateWorklog handles logging time the user spent on a task
+func (c *WorklogController) CreateWorklog(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, mux.Vars(r)["id"], policy.ActionCreate)
+	if !ok {
+		return
+	}
+
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req WorklogRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Duration == nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "duration is required")
+		return
+	}
+
+	now := time.Now()
+	worklog := &models.Worklog{
+		ID:        uuid.New().String(),
+		TaskID:    task.ID,
+		UserID:    user.ID,
+		StartedAt: now.Add(-time.Duration(*req.Duration) * time.Second),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if !req.apply(w, worklog) {
+		return
+	}
+
+	if err := c.WorklogStore.Create(worklog); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating worklog")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusCreated, "Worklog created successfully", worklog)
+}
+
+// UpdateWorklog handles editing a worklog. Users edit their own worklogs and
+// project owners anyone's.
+func (c *WorklogController) UpdateWorklog(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, mux.Vars(r)["id"], policy.ActionUpdate)
+	if !ok {
+		return
+	}
+	worklog, ok := c.loadWorklog(w, r, task)
+	if !ok {
+		return
+	}
+
+	var req WorklogRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !req.apply(w, worklog) {
+		return
+	}
+	worklog.UpdatedAt = time.Now()
+
+	if err := c.WorklogStore.Update(worklog); err != nil {
+		if err == models.ErrWorklogNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Worklog not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating worklog")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Worklog updated successfully", worklog)
+}
+
+// DeleteWorklog handles deleting a worklog. Users delete their own worklogs
+// and project owners anyone's.
+func (c *WorklogController) DeleteWorklog(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, mux.Vars(r)["id"], policy.ActionDelete)
+	if !ok {
+		return
+	}
+	worklog, ok := c.loadWorklog(w, r, task)
+	if !ok {
+		return
+	}
+
+	if err := c.WorklogStore.Delete(worklog.ID); err != nil {
+		if err == models.ErrWorklogNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Worklog not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error deleting worklog")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Worklog deleted successfully", nil)
+}
+
+// GetTimer handles getting the user's running timer
+func (c *WorklogController) GetTimer(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	timer, err := c.WorklogStore.GetTimer(user.ID)
+	if err == models.ErrTimerNotFound {
+		utils.RespondWithError(w, http.StatusNotFound, "No timer is running")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving timer")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Timer retrieved successfully", TimerResponse{timer, elapsedSeconds(timer)})
+}
+
+// StartTimer handles starting a timer on a task. A user can only have one
+// timer running at a time.
+func (c *WorklogController) StartTimer(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.loadTask(w, r, mux.Vars(r)["id"], policy.ActionCreate)
+	if !ok {
+		return
+	}
+
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req TimerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	timer := &models.Timer{
+		UserID:    user.ID,
+		TaskID:    task.ID,
+		StartedAt: time.Now().Truncate(time.Second),
+	}
+	if req.Note != nil {
+		timer.Note = strings.TrimSpace(*req.Note)
+		if !checkWorklogNote(w, timer.Note) {
+			return
+		}
+	}
+
+	if err := c.WorklogStore.StartTimer(timer); err != nil {
+		if err == models.ErrTimerRunning {
+			running, _ := c.WorklogStore.GetTimer(user.ID)
+			if running != nil {
+				utils.RespondWithError(w, http.StatusConflict, fmt.Sprintf("A timer is already running on task %s; stop it first", running.TaskID))
+				return
+			}
+			utils.RespondWithError(w, http.StatusConflict, "A timer is already running; stop it first")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error starting timer")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusCreated, "Timer started successfully", TimerResponse{timer, 0})
+}
+
+// StopTimer handles stopping the user's running timer, logging the time
+// since it started on its task
+func (c *WorklogController) StopTimer(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req TimerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	timer, err := c.WorklogStore.GetTimer(user.ID)
+	if err == models.ErrTimerNotFound {
+		utils.RespondWithError(w, http.StatusNotFound, "No timer is running")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving timer")
+		return
+	}
+
+	// The user may have lost access to the task while the timer ran
+	if _, ok := c.loadTask(w, r, timer.TaskID, policy.ActionCreate); !ok {
+		return
+	}
+
+	now := time.Now()
+	worklog := &models.Worklog{
+		ID:        uuid.New().String(),
+		TaskID:    timer.TaskID,
+		UserID:    user.ID,
+		StartedAt: timer.StartedAt,
+		Duration:  elapsedSeconds(timer),
+		Note:      timer.Note,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if worklog.Duration < 1 {
+		worklog.Duration = 1
+	}
+	if req.Note != nil {
+		worklog.Note = strings.TrimSpace(*req.Note)
+		if !checkWorklogNote(w, worklog.Note) {
+			return
+		}
+	}
+
+	if err := c.WorklogStore.StopTimer(timer, worklog); err != nil {
+		if err == models.ErrTimerNotFound {
+			utils.RespondWithError(w, http.StatusConflict, "The timer was stopped in the meantime")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error stopping timer")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Timer stopped successfully", worklog)
+}
+
+// DiscardTimer handles throwing away the user's running timer without logging its time
+func (c *WorklogController) DiscardTimer(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := c.WorklogStore.DeleteTimer(user.ID); err != nil {
+		if err == models.ErrTimerNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "No timer is running")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error discarding timer")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Timer discarded successfully", nil)
+}
+
+// GetReport handles totalling the time logged from one day to another, both
+// included, per task, project or user. Days are taken in the timezone query
+// parameter, UTC by default, and the range defaults to the current month up
+// to today. It covers the projects the user belongs to, or every project for
+// admins, and can be narrowed with the projectId, taskId and userId ("me"
+// for the user) query parameters.
+func (c *WorklogController) GetReport(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := r.URL.Query()
+	timezone := query.Get("timezone")
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "Local" {
+		utils.RespondWithError(w, http.StatusBadRequest, "timezone must be an IANA time zone such as Europe/Berlin")
+		return
+	}
+
+	now := time.Now().In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	from := today.AddDate(0, 0, 1-today.Day())
+	to := today
+	if value := query.Get("from"); value != "" {
+		if from, err = time.ParseInLocation(reportDateLayout, value, location); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "from must be a date like 2026-01-31")
+			return
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if to, err = time.ParseInLocation(reportDateLayout, value, location); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "to must be a date like 2026-01-31")
+			return
+		}
+	}
+	if to.Before(from) {
+		utils.RespondWithError(w, http.StatusBadRequest, "from must not be after to")
+		return
+	}
+
+	groupBy := query.Get("groupBy")
+	switch groupBy {
+	case "":
+		groupBy = groupByProject
+	case groupByTask, groupByProject, groupByUser:
+	default:
+		utils.RespondWithError(w, http.StatusBadRequest, "groupBy must be task, project or user")
+		return
+	}
+
+	filter := models.WorklogFilter{
+		From:      from,
+		To:        to.AddDate(0, 0, 1),
+		ProjectID: query.Get("projectId"),
+		TaskID:    query.Get("taskId"),
+		UserID:    query.Get("userId"),
+	}
+	if filter.UserID == "me" {
+		filter.UserID = user.ID
+	}
+	if user.Role != policy.RoleAdmin {
+		filter.MemberID = user.ID
+	}
+
+	totals, err := c.WorklogStore.GetTotals(filter)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving worklogs")
+		return
+	}
+
+	report := WorklogReport{
+		From:     from.Format(reportDateLayout),
+		To:       to.Format(reportDateLayout),
+		Timezone: location.String(),
+		GroupBy:  groupBy,
+		Groups:   groupWorklogTotals(totals, groupBy),
+	}
+	for _, total := range totals {
+		report.Duration += total.Duration
+		report.Entries += total.Entries
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Report retrieved successfully", report)
+}
+
+// groupWorklogTotals adds up the totals per task, project or user, the most time first
+func groupWorklogTotals(totals []models.WorklogTotal, groupBy string) []WorklogGroup {
+	groups := []WorklogGroup{}
+	index := make(map[string]int)
+	for _, total := range totals {
+		group := WorklogGroup{ID: total.UserID, Name: total.Username}
+		switch groupBy {
+		case groupByTask:
+			group = WorklogGroup{ID: total.TaskID, Name: total.TaskTitle, ProjectID: total.ProjectID}
+		case groupByProject:
+			group = WorklogGroup{ID: total.ProjectID, Name: total.ProjectName}
+		}
+
+		i, ok := index[group.ID]
+		if !ok {
+			i = len(groups)
+			index[group.ID] = i
+			groups = append(groups, group)
+		}
+		groups[i].Duration += total.Duration
+		groups[i].Entries += total.Entries
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Duration != groups[j].Duration {
+			return groups[i].Duration > groups[j].Duration
+		}
+		if groups[i].Name != groups[j].Name {
+			return groups[i].Name < groups[j].Name
+		}
+		return groups[i].ID < groups[j].ID
+	})
+	return groups
+}
+
+// apply validates the fields of the request and sets them on the worklog,
+// responding with an error if one is invalid
+func (req *WorklogRequest) apply(w http.ResponseWriter, worklog *models.Worklog) bool {
+	if req.Duration != nil {
+		if *req.Duration < 1 || *req.Duration > maxWorklogDuration {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("duration must be between 1 and %d seconds", maxWorklogDuration))
+			return false
+		}
+		worklog.Duration = *req.Duration
+	}
+	if req.StartedAt != nil {
+		worklog.StartedAt = *req.StartedAt
+	}
+	worklog.StartedAt = worklog.StartedAt.UTC().Truncate(time.Second)
+	if worklog.StartedAt.After(time.Now()) {
+		utils.RespondWithError(w, http.StatusBadRequest, "startedAt can't be in the future")
+		return false
+	}
+	if req.Note != nil {
+		worklog.Note = strings.TrimSpace(*req.Note)
+		if !checkWorklogNote(w, worklog.Note) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkWorklogNote checks the length of a worklog note, responding with an error if it is too long
+func checkWorklogNote(w http.ResponseWriter, note string) bool {
+	if utf8.RuneCountInString(note) > maxWorklogNoteLength {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("note must be at most %d characters", maxWorklogNoteLength))
+		return false
+	}
+	return true
+}
+
+// elapsedSeconds returns how many whole seconds a timer has run
+func elapsedSeconds(timer *models.Timer) int64 {
+	return int64(time.Since(timer.StartedAt) / time.Second)
+}
+
+// loadWorklog loads the worklog in the path, which has to be on the task,
+// and checks that the user logged it or may moderate worklogs
+func (c *WorklogController) loadWorklog(w http.ResponseWriter, r *http.Request, task *models.Task) (*models.Worklog, bool) {
+	worklog, err := c.WorklogStore.GetByID(mux.Vars(r)["worklogId"])
+	if err == models.ErrWorklogNotFound || (err == nil && worklog.TaskID != task.ID) {
+		utils.RespondWithError(w, http.StatusNotFound, "Worklog not found")
+		return nil, false
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving worklog")
+		return nil, false
+	}
+
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+	if worklog.UserID != user.ID && !authorize(w, r, c.Policy, policy.ActionModerate, policy.Worklog(task.ProjectID)) {
+		return nil, false
+	}
+	return worklog, true
+}
+
+// loadTask loads a task, checking that the user may perform the action on its worklogs
+func (c *WorklogController) loadTask(w http.ResponseWriter, r *http.Request, taskID string, action policy.Action) (*models.Task, bool) {
+	task, err := c.TaskStore.GetByID(taskID)
+	if err != nil {
+		if err == models.ErrTaskNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Task not found")
+			return nil, false
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	if !authorize(w, r, c.Policy, action, policy.Worklog(task.ProjectID)) {
+		return nil, false
+	}
+	return task, true
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// Dialect identifies the SQL flavour spoken by a database driver
+type Dialect string
+
+// Supported dialects
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// placeholderPattern matches Postgres-style positional placeholders ($1, $2, ...)
+var placeholderPattern = regexp.MustCompile(`\$(\d+)`)
+
+// Rebind rewrites a query written with Postgres placeholders for the dialect.
+// SQLite understands numbered ?NNN parameters, so $1 becomes ?1 and arguments
+// keep their positions even when a placeholder is used more than once.
+func (d Dialect) Rebind(query string) string {
+	if d == SQLite {
+		return placeholderPattern.ReplaceAllString(query, "?$1")
+	}
+	return query
+}
+
+// Now returns the SQL expression for the current timestamp
+func (d Dialect) Now() string {
+	if d == SQLite {
+		return "CURRENT_TIMESTAMP"
+	}
+	return "NOW()"
+}
+
+// DB wraps a *sql.DB and rewrites queries for its dialect, so stores can be
+// written once with Postgres placeholders and run on every supported driver
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+}
+
+// Open opens a database for the given dialect and data source name
+func Open(dialect Dialect, dsn string) (*DB, error) {
+	switch dialect {
+	case Postgres:
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			return nil, err
+		}
+		return &DB{DB: db, Dialect: Postgres}, nil
+	case SQLite:
+		// Foreign keys are off by default in SQLite, so ON DELETE CASCADE and
+		// SET NULL would silently do nothing without the pragma.
+		db, err := sql.Open("sqlite", fmt.Sprintf(
+			"file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite",
+			dsn,
+		))
+		if err != nil {
+			return nil, err
+		}
+		return &DB{DB: db, Dialect: SQLite}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database dialect: %s", dialect)
+	}
+}
+
+// Exec executes a query without returning any rows
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(db.Dialect.Rebind(query), args...)
+}
+
+// Query executes a query that returns rows
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(db.Dialect.Rebind(query), args...)
+}
+
+// QueryRow executes a query that is expected to return at most one row
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(db.Dialect.Rebind(query), args...)
+}
+
+// Begin starts a transaction
+func (db *DB) Begin() (*Tx, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, Dialect: db.Dialect}, nil
+}
+
+// Tx wraps a *sql.Tx and rewrites queries for its dialect
+type Tx struct {
+	*sql.Tx
+	Dialect Dialect
+}
+
+// Exec executes a query without returning any rows
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(tx.Dialect.Rebind(query), args...)
+}
+
+// Query executes a query that returns rows
+func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.Dialect.Rebind(query), args...)
+}
+
+// QueryRow executes a query that is expected to return at most one row
+func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRow(tx.Dialect.Rebind(query), args...)
+}
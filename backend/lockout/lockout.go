@@ -0,0 +1,74 @@
+package lockout
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"go-react-redux-app/models"
+)
+
+// Policy decides how long an account or IP address has to wait after failed logins
+type Policy struct {
+	// AccountThreshold is the number of failures after which an account is locked
+	AccountThreshold int
+	// IPThreshold is the number of failures after which an IP address is locked
+	IPThreshold int
+	// BaseDelay is the wait after the first failure; it doubles with every further failure
+	BaseDelay time.Duration
+	// LockoutDuration is how long a lock lasts, and the longest backoff
+	LockoutDuration time.Duration
+	// Window is how far back failures are counted
+	Window time.Duration
+}
+
+// wait returns how much longer to wait after the given failures. The wait
+// grows exponentially until the threshold is reached and the lock lasts
+// the full lockout duration.
+func (p Policy) wait(failures models.Failures, threshold int, now time.Time) time.Duration {
+	if failures.Count == 0 {
+		return 0
+	}
+
+	delay := p.LockoutDuration
+	if failures.Count < threshold {
+		delay = p.BaseDelay
+		for i := 1; i < failures.Count && delay < p.LockoutDuration; i++ {
+			delay *= 2
+		}
+		delay = min(delay, p.LockoutDuration)
+	}
+
+	return max(failures.Last.Add(delay).Sub(now), 0)
+}
+
+// Tracker tracks failed logins per account and per IP address. Every attempt
+// is also written to the login history.
+type Tracker interface {
+	// Check returns how long the username and IP address must wait before the
+	// next attempt. Zero means the attempt may go ahead.
+	Check(username string, ip string) (time.Duration, error)
+	// Record records the outcome of an attempt
+	Record(username string, userID string, ip string, outcome string) error
+	// Unlock clears the failures of an account
+	Unlock(username string) error
+}
+
+// newAttempt creates a login history entry
+func newAttempt(username string, userID string, ip string, outcome string, now time.Time) *models.LoginAttempt {
+	return &models.LoginAttempt{
+		ID:        uuid.New().String(),
+		Username:  truncate(username, 50),
+		UserID:    userID,
+		IP:        truncate(ip, 45),
+		Outcome:   outcome,
+		CreatedAt: now,
+	}
+}
+
+// truncate cuts a string to the size of its column
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,113 @@
+package lockout
+
+import (
+	"sync"
+	"time"
+
+	"go-react-redux-app/models"
+)
+
+// MemoryTracker keeps failures in process memory. It is fast, but the state
+// is per instance and lost on restart. The login history is still recorded.
+type MemoryTracker struct {
+	Policy   Policy
+	Attempts models.LoginAttemptRepository
+
+	mu       sync.Mutex
+	accounts map[string][]time.Time
+	ips      map[string][]time.Time
+	// swept is when keys whose failures all fell out of the window were last dropped
+	swept time.Time
+}
+
+// NewMemoryTracker creates a new MemoryTracker
+func NewMemoryTracker(policy Policy, attempts models.LoginAttemptRepository) *MemoryTracker {
+	return &MemoryTracker{
+		Policy:   policy,
+		Attempts: attempts,
+		accounts: make(map[string][]time.Time),
+		ips:      make(map[string][]time.Time),
+	}
+}
+
+// Check returns how long the username and IP address must wait before the next attempt
+func (t *MemoryTracker) Check(username string, ip string) (time.Duration, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	return max(
+		t.Policy.wait(t.failures(t.accounts, username, now), t.Policy.AccountThreshold, now),
+		t.Policy.wait(t.failures(t.ips, ip, now), t.Policy.IPThreshold, now),
+	), nil
+}
+
+// Record records the outcome of an attempt
+func (t *MemoryTracker) Record(username string, userID string, ip string, outcome string) error {
+	now := time.Now()
+
+	t.mu.Lock()
+	t.sweep(now)
+	switch outcome {
+	case models.LoginFailed:
+		t.accounts[username] = append(t.prune(t.accounts, username, now), now)
+		t.ips[ip] = append(t.prune(t.ips, ip, now), now)
+	case models.LoginSucceeded:
+		delete(t.accounts, username)
+	}
+	t.mu.Unlock()
+
+	return t.Attempts.Record(newAttempt(username, userID, ip, outcome, now))
+}
+
+// Unlock clears the failures of an account
+func (t *MemoryTracker) Unlock(username string) error {
+	t.mu.Lock()
+	delete(t.accounts, username)
+	t.mu.Unlock()
+
+	return t.Attempts.Record(newAttempt(username, "", "", models.LoginUnlocked, time.Now()))
+}
+
+// sweep drops the keys whose failures all fell out of the window, at most
+// once per window, so that keys that are never looked at again don't pile
+// up. The caller must hold the lock.
+func (t *MemoryTracker) sweep(now time.Time) {
+	if now.Sub(t.swept) < t.Policy.Window {
+		return
+	}
+	t.swept = now
+
+	for _, m := range []map[string][]time.Time{t.accounts, t.ips} {
+		for key := range m {
+			t.prune(m, key, now)
+		}
+	}
+}
+
+// failures summarizes the failures of a key within the window. The caller must hold the lock.
+func (t *MemoryTracker) failures(m map[string][]time.Time, key string, now time.Time) models.Failures {
+	times := t.prune(m, key, now)
+	if len(times) == 0 {
+		return models.Failures{}
+	}
+	return models.Failures{Count: len(times), Last: times[len(times)-1]}
+}
+
+// prune drops the failures of a key that fell out of the window and returns
+// the rest. The caller must hold the lock.
+func (t *MemoryTracker) prune(m map[string][]time.Time, key string, now time.Time) []time.Time {
+	times := m[key]
+	since := now.Add(-t.Policy.Window)
+	i := 0
+	for i < len(times) && !times[i].After(since) {
+		i++
+	}
+
+	if i == len(times) {
+		delete(m, key)
+		return nil
+	}
+	m[key] = times[i:]
+	return m[key]
+}
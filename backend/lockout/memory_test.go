@@ -0,0 +1,73 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+
+	"go-react-redux-app/models"
+)
+
+func newTestTracker() *MemoryTracker {
+	policy := Policy{
+		AccountThreshold: 3,
+		IPThreshold:      10,
+		BaseDelay:        time.Second,
+		LockoutDuration:  time.Minute,
+		Window:           time.Hour,
+	}
+	return NewMemoryTracker(policy, models.NewMemoryLoginAttemptStore(models.NewMemoryDB()))
+}
+
+func TestMemoryTrackerSweepsExpiredKeys(t *testing.T) {
+	tracker := newTestTracker()
+
+	for _, username := range []string{"alice", "bob"} {
+		if err := tracker.Record(username, "", "10.0.0.1", models.LoginFailed); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	// Let the failures fall out of the window
+	past := time.Now().Add(-2 * time.Hour)
+	tracker.mu.Lock()
+	for _, m := range []map[string][]time.Time{tracker.accounts, tracker.ips} {
+		for key := range m {
+			m[key] = []time.Time{past}
+		}
+	}
+	tracker.swept = past
+	tracker.mu.Unlock()
+
+	if err := tracker.Record("carol", "", "10.0.0.2", models.LoginFailed); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if len(tracker.accounts) != 1 || tracker.accounts["carol"] == nil {
+		t.Errorf("accounts = %v, want only carol", tracker.accounts)
+	}
+	if len(tracker.ips) != 1 || tracker.ips["10.0.0.2"] == nil {
+		t.Errorf("ips = %v, want only 10.0.0.2", tracker.ips)
+	}
+}
+
+func TestMemoryTrackerSuccessClearsAccount(t *testing.T) {
+	tracker := newTestTracker()
+
+	for i := 0; i < 3; i++ {
+		if err := tracker.Record("alice", "", "10.0.0.1", models.LoginFailed); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	if wait, _ := tracker.Check("alice", "10.0.0.9"); wait <= 0 {
+		t.Fatal("account is not locked after reaching the threshold")
+	}
+
+	if err := tracker.Record("alice", "", "10.0.0.1", models.LoginSucceeded); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if wait, _ := tracker.Check("alice", "10.0.0.9"); wait != 0 {
+		t.Errorf("Check = %v after a successful login, want 0", wait)
+	}
+}
@@ -0,0 +1,52 @@
+package lockout
+
+import (
+	"time"
+
+	"go-react-redux-app/models"
+)
+
+// StoreTracker works out failures from the login history, so the state is
+// shared by every instance using the same database
+type StoreTracker struct {
+	Policy   Policy
+	Attempts models.LoginAttemptRepository
+}
+
+// NewStoreTracker creates a new StoreTracker
+func NewStoreTracker(policy Policy, attempts models.LoginAttemptRepository) *StoreTracker {
+	return &StoreTracker{
+		Policy:   policy,
+		Attempts: attempts,
+	}
+}
+
+// Check returns how long the username and IP address must wait before the next attempt
+func (t *StoreTracker) Check(username string, ip string) (time.Duration, error) {
+	now := time.Now()
+	since := now.Add(-t.Policy.Window)
+
+	account, err := t.Attempts.AccountFailures(truncate(username, 50), since)
+	if err != nil {
+		return 0, err
+	}
+	address, err := t.Attempts.IPFailures(truncate(ip, 45), since)
+	if err != nil {
+		return 0, err
+	}
+
+	return max(
+		t.Policy.wait(account, t.Policy.AccountThreshold, now),
+		t.Policy.wait(address, t.Policy.IPThreshold, now),
+	), nil
+}
+
+// Record records the outcome of an attempt
+func (t *StoreTracker) Record(username string, userID string, ip string, outcome string) error {
+	return t.Attempts.Record(newAttempt(username, userID, ip, outcome, time.Now()))
+}
+
+// Unlock clears the failures of an account
+func (t *StoreTracker) Unlock(username string) error {
+	return t.Attempts.Record(newAttempt(username, "", "", models.LoginUnlocked, time.Now()))
+}
@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxMailer writes emails as .eml files to a directory instead of sending
+// them. It is meant for development and for servers without an SMTP relay.
+type OutboxMailer struct {
+	Dir  string
+	From string
+}
+
+// NewOutboxMailer creates a new OutboxMailer
+func NewOutboxMailer(dir string, from string) *OutboxMailer {
+	return &OutboxMailer{
+		Dir:  dir,
+		From: from,
+	}
+}
+
+// Send writes the message to the outbox directory
+func (m *OutboxMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405"), uuid.New().String())
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, format(m.From, msg), 0o600); err != nil {
+		return err
+	}
+
+	log.Printf("Mail to %s (%q) written to %s", msg.To, msg.Subject, path)
+	return nil
+}
@@ -5,64 +5,220 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"go-react-redux-app/blobstore"
 	"go-react-redux-app/config"
 	"go-react-redux-app/controllers"
+	"go-react-redux-app/lockout"
+	"go-react-redux-app/mailer"
 	"go-react-redux-app/middleware"
 	"go-react-redux-app/models"
+	"go-react-redux-app/oidc"
+	"go-react-redux-app/password"
+	"go-react-redux-app/policy"
 	"go-react-redux-app/routes"
+	"go-react-redux-app/signing"
 )
 
 func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
 
-	// Initialize the database
-	if err := cfg.InitDB(); err != nil {
-		log.Fatalf("Error initializing database: %v", err)
+	// Configure password hashing and the password policy
+	passwordPolicy, err := setupPasswords(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Run a management command instead of the server if one was given
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, passwordPolicy, os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	defer cfg.DB.Close()
 
 	// Create the router
 	router := mux.NewRouter()
 
 	// Initialize stores
-	userStore := models.NewUserStore(cfg.DB)
-	projectStore := models.NewProjectStore(cfg.DB)
-	taskStore := models.NewTaskStore(cfg.DB)
+	var (
+		userStore    models.UserRepository
+		projectStore models.ProjectRepository
+		taskStore    models.TaskRepository
+		dependencies models.TaskDependencyRepository
+		labels       models.LabelRepository
+		fields       models.CustomFieldRepository
+		workflows    models.WorkflowRepository
+		recurrences  models.RecurrenceRepository
+		worklogs     models.WorklogRepository
+		comments     models.CommentRepository
+		attachments  models.AttachmentRepository
+		sessionStore models.SessionRepository
+		tokenStore   models.UserTokenRepository
+		apiTokens    models.APITokenRepository
+		attempts     models.LoginAttemptRepository
+		twoFactors   models.TwoFactorRepository
+		identities   models.IdentityRepository
+		oidcFlows    models.OIDCFlowRepository
+	)
 
-	// Create database tables
-	if err := userStore.CreateTables(); err != nil {
-		log.Fatalf("Error creating user tables: %v", err)
-	}
-	
-	if err := projectStore.CreateTables(); err != nil {
-		log.Fatalf("Error creating project tables: %v", err)
+	if cfg.Storage == config.StorageMemory {
+		memoryDB := models.NewMemoryDB()
+		userStore = models.NewMemoryUserStore(memoryDB)
+		projectStore = models.NewMemoryProjectStore(memoryDB)
+		taskStore = models.NewMemoryTaskStore(memoryDB)
+		dependencies = models.NewMemoryTaskDependencyStore(memoryDB)
+		labels = models.NewMemoryLabelStore(memoryDB)
+		fields = models.NewMemoryCustomFieldStore(memoryDB)
+		workflows = models.NewMemoryWorkflowStore(memoryDB)
+		recurrences = models.NewMemoryRecurrenceStore(memoryDB)
+		worklogs = models.NewMemoryWorklogStore(memoryDB)
+		comments = models.NewMemoryCommentStore(memoryDB)
+		attachments = models.NewMemoryAttachmentStore(memoryDB)
+		sessionStore = models.NewMemorySessionStore(memoryDB)
+		tokenStore = models.NewMemoryUserTokenStore(memoryDB)
+		apiTokens = models.NewMemoryAPITokenStore(memoryDB)
+		attempts = models.NewMemoryLoginAttemptStore(memoryDB)
+		twoFactors = models.NewMemoryTwoFactorStore(memoryDB)
+		identities = models.NewMemoryIdentityStore(memoryDB)
+		oidcFlows = models.NewMemoryOIDCFlowStore(memoryDB)
+	} else {
+		defer cfg.Close()
+
+		// Apply pending schema migrations
+		if cfg.AutoMigrate {
+			if err := applyMigrations(cfg); err != nil {
+				log.Fatalf("Error migrating database: %v", err)
+			}
+		}
+
+		userStore = models.NewUserStore(cfg.DB)
+		projectStore = models.NewProjectStore(cfg.DB)
+		taskStore = models.NewTaskStore(cfg.DB)
+		dependencies = models.NewTaskDependencyStore(cfg.DB)
+		labels = models.NewLabelStore(cfg.DB)
+		fields = models.NewCustomFieldStore(cfg.DB)
+		workflows = models.NewWorkflowStore(cfg.DB)
+		recurrences = models.NewRecurrenceStore(cfg.DB)
+		worklogs = models.NewWorklogStore(cfg.DB)
+		comments = models.NewCommentStore(cfg.DB)
+		attachments = models.NewAttachmentStore(cfg.DB)
+		sessionStore = models.NewSessionStore(cfg.DB)
+		tokenStore = models.NewUserTokenStore(cfg.DB)
+		apiTokens = models.NewAPITokenStore(cfg.DB)
+		attempts = models.NewLoginAttemptStore(cfg.DB)
+		twoFactors = models.NewTwoFactorStore(cfg.DB)
+		identities = models.NewIdentityStore(cfg.DB)
+		oidcFlows = models.NewOIDCFlowStore(cfg.DB)
 	}
-	
-	if err := taskStore.CreateTables(); err != nil {
-		log.Fatalf("Error creating task tables: %v", err)
+
+	// Initialize the token signing keys
+	var keys *signing.KeyManager
+	if cfg.JWTAlgorithm == signing.HS256 {
+		keys = signing.NewHMACKeyManager(cfg.JWTKey)
+	} else {
+		var err error
+		keys, err = signing.NewKeyManager(cfg.JWTAlgorithm, cfg.JWTKeysDir, cfg.JWTKeyRotation, cfg.JWTKeyGracePeriod)
+		if err != nil {
+			log.Fatalf("Error loading signing keys: %v", err)
+		}
+		keys.StartRotation(time.Minute)
 	}
 
 	// Initialize auth middleware
-	auth := middleware.NewAuth(cfg.JWTKey)
+	auth := middleware.NewAuth(keys, cfg.AccessTokenTTL, sessionStore, userStore, apiTokens, cfg.TrustProxyHeaders)
+
+	// Initialize the authorization policy
+	authz := policy.New(projectStore)
+
+	// Initialize the mailer
+	var mail mailer.Mailer
+	if cfg.Mailer == config.MailerSMTP {
+		mail = mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	} else {
+		mail = mailer.NewOutboxMailer(cfg.OutboxDir, cfg.MailFrom)
+	}
+
+	// Initialize login throttling
+	loginPolicy := lockout.Policy{
+		AccountThreshold: cfg.LoginMaxFailures,
+		IPThreshold:      cfg.LoginMaxFailuresPerIP,
+		BaseDelay:        cfg.LoginBackoffBase,
+		LockoutDuration:  cfg.LoginLockoutDuration,
+		Window:           cfg.LoginAttemptWindow,
+	}
+	var logins lockout.Tracker
+	if cfg.LoginTracker == config.LoginTrackerMemory {
+		logins = lockout.NewMemoryTracker(loginPolicy, attempts)
+	} else {
+		logins = lockout.NewStoreTracker(loginPolicy, attempts)
+	}
+
+	// Initialize the identity providers
+	var providers []*oidc.Provider
+	for _, providerConfig := range cfg.OIDCProviders {
+		redirectURL := cfg.APIURL + "/api/auth/oidc/" + providerConfig.ID + "/callback"
+		providers = append(providers, oidc.NewProvider(providerConfig, redirectURL))
+		log.Printf("SSO enabled with %s (%s), callback %s", providerConfig.ID, providerConfig.Issuer, redirectURL)
+	}
+
+	// Initialize the blob store for attachment contents
+	var store blobstore.BlobStore
+	if cfg.BlobStore == config.BlobStoreS3 {
+		s3Store, err := blobstore.NewS3Store(cfg.S3)
+		if err != nil {
+			log.Fatalf("Invalid S3 configuration: %v", err)
+		}
+		store = s3Store
+	} else {
+		store = blobstore.NewLocalStore(cfg.BlobDir)
+	}
+	blobs := controllers.NewAttachmentBlobs(attachments, store)
+	blobs.Start(cfg.BlobCleanupInterval)
+
+	// Create the occurrences of recurring tasks as they come due
+	scheduler := controllers.NewTaskRecurrences(recurrences, workflows)
+	scheduler.Start(cfg.RecurrenceInterval)
 
 	// Initialize controllers
-	authController := controllers.NewAuthController(userStore, auth)
-	projectController := controllers.NewProjectController(projectStore)
-	taskController := controllers.NewTaskController(taskStore, projectStore)
+	authController := controllers.NewAuthController(userStore, sessionStore, tokenStore, twoFactors, auth, mail, logins, controllers.AuthSettings{
+		RefreshTokenTTL:          cfg.RefreshTokenTTL,
+		AppURL:                   cfg.AppURL,
+		RequireEmailVerification: cfg.RequireEmailVerification,
+		TrustProxyHeaders:        cfg.TrustProxyHeaders,
+		PasswordPolicy:           passwordPolicy,
+	})
+	userController := controllers.NewUserController(userStore, sessionStore, projectStore, tokenStore, mail, cfg.AppURL, passwordPolicy)
+	sessionController := controllers.NewSessionController(sessionStore)
+	twoFactorController := controllers.NewTwoFactorController(userStore, twoFactors, cfg.TOTPIssuer)
+	oidcController := controllers.NewOIDCController(userStore, identities, oidcFlows, tokenStore, providers, cfg.AppURL, cfg.APIURL)
+	apiTokenController := controllers.NewAPITokenController(apiTokens)
+	jwksController := controllers.NewJWKSController(keys)
+	adminController := controllers.NewAdminController(userStore, sessionStore, tokenStore, twoFactors, logins, attempts, mail, cfg.AppURL)
+	projectController := controllers.NewProjectController(projectStore, userStore, authz, blobs)
+	taskController := controllers.NewTaskController(taskStore, projectStore, fields, workflows, authz, cfg.EnforceTaskDependencies, blobs, scheduler)
+	dependencyController := controllers.NewDependencyController(taskStore, dependencies, authz)
+	labelController := controllers.NewLabelController(labels, taskStore, projectStore, authz)
+	fieldController := controllers.NewCustomFieldController(fields, taskStore, projectStore, authz)
+	workflowController := controllers.NewWorkflowController(workflows, taskStore, projectStore, fields, authz)
+	recurrenceController := controllers.NewRecurrenceController(recurrences, taskStore, scheduler, authz)
+	worklogController := controllers.NewWorklogController(worklogs, taskStore, authz)
+	commentController := controllers.NewCommentController(comments, taskStore, userStore, authz)
+	attachmentController := controllers.NewAttachmentController(attachments, taskStore, blobs, authz, cfg.AttachmentMaxSize, cfg.AttachmentAllowedTypes)
 
 	// Setup routes
-	routes.SetupRoutes(router, auth, authController, projectController, taskController)
+	routes.SetupRoutes(router, auth, jwksController, authController, oidcController, userController, sessionController, twoFactorController, apiTokenController, adminController, projectController, taskController, dependencyController, labelController, fieldController, workflowController, recurrenceController, worklogController, commentController, attachmentController)
 
 	// CORS ayarlarını ekleyelim
 	corsMiddleware := handlers.CORS(
 		handlers.AllowedOrigins([]string{"*"}),
 		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"}),
-		handlers.ExposedHeaders([]string{"Content-Length"}),
+		handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization", "Range"}),
+		handlers.ExposedHeaders([]string{"Content-Length", "Content-Range", "Content-Disposition"}),
 		handlers.AllowCredentials(),
 		handlers.MaxAge(86400), // 24 saat
 	)
@@ -76,3 +232,18 @@ func main() {
 	fmt.Printf("Server starting on port %s...\n", port)
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), corsMiddleware(router)))
 }
+
+// setupPasswords makes new passwords hash with the configured algorithm while
+// hashes made with the other one keep working until they are upgraded at
+// login, and loads the password policy
+func setupPasswords(cfg *config.Config) (*password.Policy, error) {
+	argon2id := password.NewArgon2idHasher(cfg.Argon2id)
+	bcrypt := password.NewBcryptHasher(cfg.BcryptCost)
+	if cfg.PasswordHashAlgorithm == password.Bcrypt {
+		models.SetPasswordManager(password.NewManager(bcrypt, argon2id))
+	} else {
+		models.SetPasswordManager(password.NewManager(argon2id, bcrypt))
+	}
+
+	return password.NewPolicy(cfg.PasswordMinLength, cfg.PasswordMaxLength, cfg.PasswordBlocklist, cfg.PasswordBlocklistFile)
+}
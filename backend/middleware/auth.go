@@ -5,7 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"go-react-redux-app/models"
+	"go-react-redux-app/policy"
+	"go-react-redux-app/signing"
 	"go-react-redux-app/utils"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -13,51 +16,148 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// contextKey is the type of the values the middleware stores in request contexts.
+// Using an unexported type prevents collisions with keys set by other packages.
+type contextKey string
+
+const (
+	userContextKey      contextKey = "user"
+	principalContextKey contextKey = "principal"
+	sessionContextKey   contextKey = "session"
+	scopeContextKey     contextKey = "scope"
+)
+
+// MFATokenTTL is how long a user has to enter their second factor after the password
+const MFATokenTTL = 5 * time.Minute
+
+// mfaPurpose marks challenge tokens, so they can't be mistaken for access tokens or vice versa
+const mfaPurpose = "mfa"
+
+// apiTokenTouchInterval limits how often the last-used time of a personal access token is written
+const apiTokenTouchInterval = time.Minute
+
+// sessionTouchInterval limits how often the last-seen time of a session is written
+const sessionTouchInterval = time.Minute
+
 // Auth is a middleware for authentication
 type Auth struct {
-	JWTKey string
+	Keys           *signing.KeyManager
+	AccessTokenTTL time.Duration
+	Sessions       models.SessionRepository
+	Users          models.UserRepository
+	APITokens      models.APITokenRepository
+
+	// TrustProxyHeaders makes the client address recorded for sessions come
+	// from X-Forwarded-For / X-Real-IP
+	TrustProxyHeaders bool
 }
 
 // NewAuth creates a new Auth middleware
-func NewAuth(jwtKey string) *Auth {
-	return &Auth{JWTKey: jwtKey}
+func NewAuth(keys *signing.KeyManager, accessTokenTTL time.Duration, sessions models.SessionRepository, users models.UserRepository, apiTokens models.APITokenRepository, trustProxyHeaders bool) *Auth {
+	return &Auth{
+		Keys:              keys,
+		AccessTokenTTL:    accessTokenTTL,
+		Sessions:          sessions,
+		Users:             users,
+		APITokens:         apiTokens,
+		TrustProxyHeaders: trustProxyHeaders,
+	}
 }
 
-// GenerateToken generates a new JWT token for the given user ID, username, and role
-func (a *Auth) GenerateToken(userID string, username string, role string) (string, error) {
+// GenerateToken generates a new short-lived JWT access token for the given
+// user ID, username, and role, tied to the session it was issued for
+func (a *Auth) GenerateToken(userID string, username string, role string, sessionID string) (string, error) {
 	// Create the claims
-	expiration := time.Now().Add(time.Hour * 72) // Token expires in 72 hours
-	
+	now := time.Now()
+	expiration := now.Add(a.AccessTokenTTL)
+
 	claims := jwt.MapClaims{
 		"id":       userID,
 		"username": username,
 		"role":     role,
+		"sid":      sessionID,
+		"iat":      now.Unix(),
 		"exp":      expiration.Unix(),
 	}
 
-	fmt.Printf("Generating token for user: %s, role: %s, expires: %s\n", 
-		username, role, expiration.Format(time.RFC3339))
-
-	// Create the token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// Create the token, naming the key it is signed with
+	key := a.Keys.SigningKey()
+	token := jwt.NewWithClaims(key.Method(), claims)
+	token.Header["kid"] = key.ID
 
 	// Sign the token
-	tokenString, err := token.SignedString([]byte(a.JWTKey))
+	tokenString, err := token.SignedString(key.SigningKey())
 	if err != nil {
-		fmt.Printf("Error signing token: %v\n", err)
 		return "", err
 	}
 
-	fmt.Printf("Token generated successfully: %s...\n", tokenString[:10])
 	return tokenString, nil
 }
 
-// Middleware is a middleware function that checks for a valid JWT token
+// GenerateMFAToken generates a short-lived challenge token proving that the
+// user passed the password step of a two-factor login. It is not an access
+// token: it carries no session and the middleware rejects it.
+func (a *Auth) GenerateMFAToken(userID string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"id":      userID,
+		"purpose": mfaPurpose,
+		"iat":     now.Unix(),
+		"exp":     now.Add(MFATokenTTL).Unix(),
+	}
+
+	key := a.Keys.SigningKey()
+	token := jwt.NewWithClaims(key.Method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.SigningKey())
+}
+
+// ParseMFAToken verifies a challenge token and returns the ID of its user
+func (a *Auth) ParseMFAToken(tokenString string) (string, error) {
+	token, err := a.ParseToken(tokenString)
+	if err != nil {
+		return "", err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return "", errors.New("invalid token")
+	}
+	if purpose, _ := claims["purpose"].(string); purpose != mfaPurpose {
+		return "", errors.New("not a two-factor challenge token")
+	}
+
+	userID, ok := claims["id"].(string)
+	if !ok || userID == "" {
+		return "", errors.New("missing user ID")
+	}
+	return userID, nil
+}
+
+// ParseToken parses a JWT and verifies its signature with the key named by its kid
+func (a *Auth) ParseToken(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := a.Keys.VerificationKey(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown or retired signing key: %q", kid)
+		}
+
+		// Validate the signing method against the key, so a token can't pick its own algorithm
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.VerificationKey(), nil
+	}, jwt.WithValidMethods([]string{signing.HS256, signing.RS256, signing.EdDSA}))
+}
+
+// Middleware is a middleware function that checks for a valid JWT token or
+// personal access token. Requests authenticated with a personal access token
+// are only let through by RequireScope.
 func (a *Auth) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Get the Authorization header
 		authHeader := r.Header.Get("Authorization")
-		fmt.Printf("Auth Middleware - Authorization header: %s\n", authHeader)
 		
 		if authHeader == "" {
 			fmt.Println("Auth Middleware - Missing Authorization header")
@@ -74,17 +174,16 @@ func (a *Auth) Middleware(next http.Handler) http.Handler {
 
 		// Extract the token
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		fmt.Printf("Auth Middleware - Token: %s...\n", tokenString[:10])
+
+		if strings.HasPrefix(tokenString, models.APITokenPrefix) {
+			a.serveAPIToken(w, r, next, tokenString)
+			return
+		}
+
+		fmt.Printf("Auth Middleware - Token: %s...\n", tokenString[:min(10, len(tokenString))])
 
 		// Parse the token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Validate the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				fmt.Printf("Auth Middleware - Unexpected signing method: %v\n", token.Header["alg"])
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(a.JWTKey), nil
-		})
+		token, err := a.ParseToken(tokenString)
 
 		if err != nil {
 			fmt.Printf("Auth Middleware - Token parse error: %v\n", err)
@@ -107,8 +206,11 @@ func (a *Auth) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Debug claims
-		fmt.Printf("Auth Middleware - Token claims: %+v\n", claims)
+		// Two-factor challenge tokens only unlock the second login step
+		if _, ok := claims["purpose"]; ok {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token: not an access token")
+			return
+		}
 
 		// Extract the user ID and role
 		userID, ok := claims["id"].(string)
@@ -125,30 +227,134 @@ func (a *Auth) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
-		role, ok := claims["role"].(string)
-		if !ok {
+		if _, ok := claims["role"].(string); !ok {
 			fmt.Println("Auth Middleware - Missing role in token")
 			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token claims: missing role")
 			return
 		}
 
-		fmt.Printf("Auth Middleware - Valid token for user: %s, role: %s\n", username, role)
+		// Reject tokens whose session has been revoked (logout, refresh token reuse)
+		sessionID, ok := claims["sid"].(string)
+		if !ok {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token claims: missing session")
+			return
+		}
+
+		session, err := a.Sessions.GetByID(sessionID)
+		if err != nil || session.UserID != userID || !session.IsActive() {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Session has been revoked or has expired")
+			return
+		}
+
+		// Load the account so that disabling it or changing its role takes effect immediately
+		account, err := a.Users.GetByID(userID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "User not found")
+			return
+		}
+		if account.IsDisabled() {
+			utils.RespondWithError(w, http.StatusForbidden, "Account is disabled")
+			return
+		}
+
+		// Keep the device list current without writing on every request
+		now := time.Now()
+		ip := utils.ClientIP(r, a.TrustProxyHeaders)
+		if now.Sub(session.LastSeenAt) > sessionTouchInterval || session.IP != ip {
+			if err := a.Sessions.Touch(sessionID, ip, now); err != nil {
+				log.Printf("Error updating last use of session %s: %v", sessionID, err)
+			}
+		}
+
+		fmt.Printf("Auth Middleware - Valid token for user: %s, role: %s\n", username, account.Role)
 
 		// Create a user object
 		user := &models.User{
 			ID:       userID,
-			Username: username,
-			Role:     role,
+			Username: account.Username,
+			Role:     account.Role,
 		}
 
-		// Create a new context with the user
-		ctx := context.WithValue(r.Context(), "user", user)
+		// Create a new context with the user, session and the principal used for authorization
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		ctx = context.WithValue(ctx, sessionContextKey, sessionID)
+		ctx = context.WithValue(ctx, principalContextKey, policy.Principal{
+			UserID: user.ID,
+			Role:   user.Role,
+		})
 
 		// Call the next handler with the new context
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// serveAPIToken authenticates a request with a personal access token
+func (a *Auth) serveAPIToken(w http.ResponseWriter, r *http.Request, next http.Handler, tokenString string) {
+	token, err := a.APITokens.GetByHash(utils.HashToken(tokenString))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	if token.IsExpired() {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Token has expired")
+		return
+	}
+
+	// Personal access tokens only work on routes that declare a scope
+	scope, ok := r.Context().Value(scopeContextKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusForbidden, "Personal access tokens cannot be used for this endpoint")
+		return
+	}
+	if !token.HasScope(scope) {
+		utils.RespondWithError(w, http.StatusForbidden, "Token is missing the required scope: "+scope)
+		return
+	}
+
+	account, err := a.Users.GetByID(token.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+	if account.IsDisabled() {
+		utils.RespondWithError(w, http.StatusForbidden, "Account is disabled")
+		return
+	}
+
+	now := time.Now()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > apiTokenTouchInterval {
+		if err := a.APITokens.Touch(token.ID, now); err != nil {
+			log.Printf("Error updating last use of token %s: %v", token.ID, err)
+		}
+	}
+
+	user := &models.User{
+		ID:       account.ID,
+		Username: account.Username,
+		Role:     account.Role,
+	}
+
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	ctx = context.WithValue(ctx, principalContextKey, policy.Principal{
+		UserID: user.ID,
+		Role:   user.Role,
+	})
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequireScope is a middleware function that allows personal access tokens
+// with the given scope. It must run before Middleware, which rejects personal
+// access tokens on routes that don't declare a scope. Requests authenticated
+// with a session are not restricted.
+func (a *Auth) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), scopeContextKey, scope)))
+		})
+	}
+}
+
 // RoleMiddleware is a middleware function that checks if the user has the required role
 func (a *Auth) RoleMiddleware(role string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -174,9 +380,27 @@ func (a *Auth) RoleMiddleware(role string) func(http.Handler) http.Handler {
 
 // GetUserFromContext gets the user from the context
 func GetUserFromContext(ctx context.Context) (*models.User, error) {
-	user, ok := ctx.Value("user").(*models.User)
+	user, ok := ctx.Value(userContextKey).(*models.User)
 	if !ok {
 		return nil, errors.New("user not found in context")
 	}
 	return user, nil
 }
+
+// GetSessionIDFromContext gets the ID of the session the request was authenticated with
+func GetSessionIDFromContext(ctx context.Context) (string, error) {
+	sessionID, ok := ctx.Value(sessionContextKey).(string)
+	if !ok {
+		return "", errors.New("session not found in context")
+	}
+	return sessionID, nil
+}
+
+// GetPrincipalFromContext gets the authorization principal from the context
+func GetPrincipalFromContext(ctx context.Context) (policy.Principal, error) {
+	principal, ok := ctx.Value(principalContextKey).(policy.Principal)
+	if !ok {
+		return policy.Principal{}, errors.New("principal not found in context")
+	}
+	return principal, nil
+}
@@ -0,0 +1,318 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"go-react-redux-app/database"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// fileNamePattern matches migration files such as 0001_initial_schema.up.sql.
+// An optional dialect segment (0002_example.sqlite.up.sql) provides SQL that
+// replaces the portable file for that dialect only.
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)(?:\.(\w+))?\.(up|down)\.sql$`)
+
+// Migration is a single numbered schema change with its up and down SQL
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status describes whether a migration has been applied to the database
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+	Modified  bool       `json:"modified"`
+}
+
+// appliedMigration is a row of the schema_migrations table
+type appliedMigration struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Migrator applies and rolls back the embedded migrations
+type Migrator struct {
+	DB         *database.DB
+	Migrations []Migration
+}
+
+// New creates a Migrator for the migrations embedded in the binary
+func New(db *database.DB) (*Migrator, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is not established")
+	}
+
+	migrations, err := Load(files, "sql", db.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// Load reads the numbered up/down migration pairs for a dialect from dir in fsys
+func Load(fsys fs.FS, dir string, dialect database.Dialect) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %v", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	overridden := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		matches := fileNamePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		fileDialect, direction := matches[3], matches[4]
+		if fileDialect != "" && database.Dialect(fileDialect) != dialect {
+			continue
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %v", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %v", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		} else if migration.Name != matches[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names: %s and %s", version, migration.Name, matches[2])
+		}
+
+		// A dialect-specific file always wins over the portable one
+		key := matches[1] + "." + direction
+		if overridden[key] {
+			continue
+		}
+		if fileDialect != "" {
+			overridden[key] = true
+		}
+
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s is missing its up file", migration.Version, migration.Name)
+		}
+		if migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s is missing its down file", migration.Version, migration.Name)
+		}
+		sum := sha256.Sum256([]byte(migration.Up))
+		migration.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up applies all pending migrations in order and returns how many were applied
+func (m *Migrator) Up() (int, error) {
+	applied, err := m.verify()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range m.Migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err := m.inTx(func(tx *database.Tx) error {
+			if _, err := tx.Exec(migration.Up); err != nil {
+				return err
+			}
+			_, err := tx.Exec(
+				`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)`,
+				migration.Version,
+				migration.Name,
+				migration.Checksum,
+				time.Now(),
+			)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("error applying migration %04d_%s: %v", migration.Version, migration.Name, err)
+		}
+
+		log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
+		count++
+	}
+
+	return count, nil
+}
+
+// Down rolls back the given number of most recently applied migrations
+func (m *Migrator) Down(steps int) (int, error) {
+	applied, err := m.verify()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(m.Migrations) - 1; i >= 0 && count < steps; i-- {
+		migration := m.Migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		err := m.inTx(func(tx *database.Tx) error {
+			if _, err := tx.Exec(migration.Down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("error rolling back migration %04d_%s: %v", migration.Version, migration.Name, err)
+		}
+
+		log.Printf("Rolled back migration %04d_%s", migration.Version, migration.Name)
+		count++
+	}
+
+	return count, nil
+}
+
+// Status reports every known migration and whether it has been applied
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.Modified = row.Checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// verify checks that every applied migration still exists with an unchanged checksum
+func (m *Migrator) verify() (map[int64]appliedMigration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int64]Migration, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		known[migration.Version] = migration
+	}
+
+	for version, row := range applied {
+		migration, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("applied migration %04d_%s is missing from this build", version, row.Name)
+		}
+		if migration.Checksum != row.Checksum {
+			return nil, fmt.Errorf("checksum mismatch for applied migration %04d_%s: the file was modified after it was applied", version, row.Name)
+		}
+	}
+
+	return applied, nil
+}
+
+// applied loads the schema_migrations table, creating it if necessary
+func (m *Migrator) applied() (map[int64]appliedMigration, error) {
+	if m.DB == nil {
+		return nil, fmt.Errorf("database connection is not established")
+	}
+
+	_, err := m.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		checksum VARCHAR(64) NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("error creating schema_migrations table: %v", err)
+	}
+
+	rows, err := m.DB.Query(`SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var row appliedMigration
+		if err := rows.Scan(&row.Version, &row.Name, &row.Checksum, &row.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[row.Version] = row
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// inTx runs fn inside a transaction, rolling back on error
+func (m *Migrator) inTx(fn func(tx *database.Tx) error) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
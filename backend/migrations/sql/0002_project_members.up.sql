@@ -0,0 +1,16 @@
+-- Create project members table
+CREATE TABLE IF NOT EXISTS project_members (
+    project_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'viewer',
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (project_id, user_id),
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_project_members_user_id ON project_members(user_id);
+
+-- Every existing project owner becomes an owner member
+INSERT INTO project_members (project_id, user_id, role, created_at)
+SELECT id, owner_id, 'owner', created_at FROM projects;
@@ -0,0 +1,4 @@
+-- Disabled accounts cannot log in; NULL means the account is enabled
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(created_at);
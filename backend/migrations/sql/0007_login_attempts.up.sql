@@ -0,0 +1,14 @@
+-- Create login attempts table. It is the history of every login attempt and,
+-- with LOGIN_TRACKER=database, the state brute-force protection works from.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id VARCHAR(36) PRIMARY KEY,
+    username VARCHAR(50) NOT NULL,
+    user_id VARCHAR(36),
+    ip VARCHAR(45) NOT NULL,
+    outcome VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_username ON login_attempts(username, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts(ip, created_at);
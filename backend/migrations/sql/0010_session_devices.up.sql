@@ -0,0 +1,6 @@
+-- Record the device behind each session so users can recognise and revoke them
+ALTER TABLE sessions ADD COLUMN user_agent VARCHAR(255);
+ALTER TABLE sessions ADD COLUMN ip VARCHAR(45);
+ALTER TABLE sessions ADD COLUMN last_seen_at TIMESTAMP;
+
+UPDATE sessions SET last_seen_at = created_at WHERE last_seen_at IS NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_tasks_parent_task_id;
+ALTER TABLE tasks DROP COLUMN parent_task_id;
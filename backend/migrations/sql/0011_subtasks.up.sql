@@ -0,0 +1,5 @@
+-- Tasks can be broken down into subtasks of any depth. A subtask lives in the
+-- same project as its parent and is deleted along with it.
+ALTER TABLE tasks ADD COLUMN parent_task_id VARCHAR(36) REFERENCES tasks(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_parent_task_id ON tasks(parent_task_id);
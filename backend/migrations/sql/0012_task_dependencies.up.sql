@@ -0,0 +1,15 @@
+-- "Blocks / blocked by" links between tasks, which may be in different
+-- projects. The blocker has to be done before the blocked task can be.
+CREATE TABLE IF NOT EXISTS task_dependencies (
+    blocker_id VARCHAR(36) NOT NULL,
+    blocked_id VARCHAR(36) NOT NULL,
+    created_by VARCHAR(36),
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id),
+    FOREIGN KEY (blocker_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_dependencies_blocked_id ON task_dependencies(blocked_id);
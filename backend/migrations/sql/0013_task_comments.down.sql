@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_comment_mentions_user_id;
+DROP TABLE IF EXISTS comment_mentions;
+DROP INDEX IF EXISTS idx_task_comments_task_id;
+DROP TABLE IF EXISTS task_comments;
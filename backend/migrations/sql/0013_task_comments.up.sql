@@ -0,0 +1,25 @@
+-- Comments on tasks. Bodies are Markdown; edited_at is set when a comment is edited.
+CREATE TABLE IF NOT EXISTS task_comments (
+    id VARCHAR(36) PRIMARY KEY,
+    task_id VARCHAR(36) NOT NULL,
+    author_id VARCHAR(36),
+    body TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    edited_at TIMESTAMP,
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_comments_task_id ON task_comments(task_id, created_at);
+
+-- Users @mentioned in a comment, for notifications
+CREATE TABLE IF NOT EXISTS comment_mentions (
+    comment_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (comment_id, user_id),
+    FOREIGN KEY (comment_id) REFERENCES task_comments(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_comment_mentions_user_id ON comment_mentions(user_id, created_at);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_task_attachments_blob_hash;
+DROP INDEX IF EXISTS idx_task_attachments_task_id;
+DROP TABLE IF EXISTS task_attachments;
+DROP TABLE IF EXISTS blobs;
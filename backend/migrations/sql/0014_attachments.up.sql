@@ -0,0 +1,25 @@
+-- Attachment contents, stored once per SHA-256 hash in the blob store. A blob
+-- is deleted from the blob store once no attachment refers to it anymore.
+CREATE TABLE IF NOT EXISTS blobs (
+    hash VARCHAR(64) PRIMARY KEY,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Files attached to tasks
+CREATE TABLE IF NOT EXISTS task_attachments (
+    id VARCHAR(36) PRIMARY KEY,
+    task_id VARCHAR(36) NOT NULL,
+    blob_hash VARCHAR(64) NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    uploaded_by VARCHAR(36),
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    FOREIGN KEY (blob_hash) REFERENCES blobs(hash),
+    FOREIGN KEY (uploaded_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_attachments_task_id ON task_attachments(task_id);
+CREATE INDEX IF NOT EXISTS idx_task_attachments_blob_hash ON task_attachments(blob_hash);
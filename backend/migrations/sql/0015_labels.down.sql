@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_task_labels_label_id;
+DROP TABLE IF EXISTS task_labels;
+DROP INDEX IF EXISTS idx_labels_project_name;
+DROP TABLE IF EXISTS labels;
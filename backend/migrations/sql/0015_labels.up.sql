@@ -0,0 +1,22 @@
+-- Labels are defined per project and assigned to any number of its tasks.
+-- Names are unique within a project, ignoring case.
+CREATE TABLE IF NOT EXISTS labels (
+    id VARCHAR(36) PRIMARY KEY,
+    project_id VARCHAR(36) NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    color VARCHAR(7) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_labels_project_name ON labels(project_id, LOWER(name));
+
+CREATE TABLE IF NOT EXISTS task_labels (
+    task_id VARCHAR(36) NOT NULL,
+    label_id VARCHAR(36) NOT NULL,
+    PRIMARY KEY (task_id, label_id),
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    FOREIGN KEY (label_id) REFERENCES labels(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_labels_label_id ON task_labels(label_id);
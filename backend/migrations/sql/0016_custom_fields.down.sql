@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_task_field_values_user_id;
+DROP INDEX IF EXISTS idx_task_field_values_field_id;
+DROP TABLE IF EXISTS task_field_values;
+DROP INDEX IF EXISTS idx_custom_fields_project_name;
+DROP TABLE IF EXISTS custom_fields;
@@ -0,0 +1,30 @@
+-- Custom fields are defined per project. Options holds the JSON array of
+-- choices of select and multi_select fields. Names are unique within a
+-- project, ignoring case.
+CREATE TABLE IF NOT EXISTS custom_fields (
+    id VARCHAR(36) PRIMARY KEY,
+    project_id VARCHAR(36) NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    options TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_custom_fields_project_name ON custom_fields(project_id, LOWER(name));
+
+-- The value of a custom field on a task, JSON encoded. user_id repeats the
+-- value of user fields so that it goes away with the user.
+CREATE TABLE IF NOT EXISTS task_field_values (
+    task_id VARCHAR(36) NOT NULL,
+    field_id VARCHAR(36) NOT NULL,
+    value TEXT NOT NULL,
+    user_id VARCHAR(36),
+    PRIMARY KEY (task_id, field_id),
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    FOREIGN KEY (field_id) REFERENCES custom_fields(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_field_values_field_id ON task_field_values(field_id);
+CREATE INDEX IF NOT EXISTS idx_task_field_values_user_id ON task_field_values(user_id);
@@ -0,0 +1,3 @@
+-- Normalized statuses are left as they are
+ALTER TABLE tasks DROP COLUMN status_category;
+DROP TABLE IF EXISTS project_workflows;
@@ -0,0 +1,27 @@
+-- A project's workflow lists the statuses its tasks can have, each in the
+-- todo, in_progress or done category, and the transitions allowed between
+-- them. Projects without a row use the default Pending / In Progress /
+-- Completed workflow.
+CREATE TABLE IF NOT EXISTS project_workflows (
+    project_id VARCHAR(36) PRIMARY KEY,
+    states TEXT NOT NULL,
+    transitions TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+-- Tasks keep the category of their status so that finished tasks can be told
+-- apart without looking up the workflow of their project.
+ALTER TABLE tasks ADD COLUMN status_category VARCHAR(20) NOT NULL DEFAULT 'todo';
+
+-- Statuses used to be free-form. Spellings of the default workflow's statuses
+-- are normalized; other statuses are kept, in the todo category, until the
+-- project's workflow maps them to one of its states.
+UPDATE tasks SET status = 'Pending'
+WHERE LOWER(status) IN ('todo', 'to do', 'pending', 'open', 'new');
+
+UPDATE tasks SET status = 'In Progress', status_category = 'in_progress'
+WHERE LOWER(status) IN ('in progress', 'in_progress', 'in-progress', 'inprogress', 'doing', 'started');
+
+UPDATE tasks SET status = 'Completed', status_category = 'done'
+WHERE LOWER(status) IN ('completed', 'complete', 'done', 'closed', 'finished');
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_tasks_recurrence_occurrence;
+ALTER TABLE tasks DROP COLUMN occurrence_at;
+ALTER TABLE tasks DROP COLUMN recurrence_id;
+DROP INDEX IF EXISTS idx_task_recurrences_project_id;
+DROP TABLE IF EXISTS task_recurrences;
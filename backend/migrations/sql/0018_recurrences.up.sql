@@ -0,0 +1,30 @@
+-- A recurrence repeats a task on an RFC 5545 rule in a time zone. Each
+-- occurrence is an ordinary task created from the recurrence's template.
+-- last_occurrence_at is the latest occurrence created so far; it only moves
+-- forward, so that occurrences are never created twice.
+CREATE TABLE IF NOT EXISTS task_recurrences (
+    id VARCHAR(36) PRIMARY KEY,
+    project_id VARCHAR(36) NOT NULL,
+    rule VARCHAR(500) NOT NULL,
+    timezone VARCHAR(64) NOT NULL,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP,
+    last_occurrence_at TIMESTAMP NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    priority VARCHAR(20) NOT NULL,
+    assignee_id VARCHAR(36),
+    created_by VARCHAR(36),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+    FOREIGN KEY (assignee_id) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_recurrences_project_id ON task_recurrences(project_id);
+
+ALTER TABLE tasks ADD COLUMN recurrence_id VARCHAR(36) REFERENCES task_recurrences(id) ON DELETE SET NULL;
+ALTER TABLE tasks ADD COLUMN occurrence_at TIMESTAMP;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_recurrence_occurrence ON tasks(recurrence_id, occurrence_at);
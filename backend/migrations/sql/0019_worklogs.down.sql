@@ -0,0 +1,8 @@
+ALTER TABLE tasks DROP COLUMN remaining_estimate;
+ALTER TABLE tasks DROP COLUMN original_estimate;
+DROP INDEX IF EXISTS idx_timers_task_id;
+DROP TABLE IF EXISTS timers;
+DROP INDEX IF EXISTS idx_worklogs_started_at;
+DROP INDEX IF EXISTS idx_worklogs_user_id;
+DROP INDEX IF EXISTS idx_worklogs_task_id;
+DROP TABLE IF EXISTS worklogs;
@@ -0,0 +1,34 @@
+-- Worklogs record time spent on tasks, in seconds. They stay when their user
+-- is deleted so that the time can still be billed.
+CREATE TABLE IF NOT EXISTS worklogs (
+    id VARCHAR(36) PRIMARY KEY,
+    task_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36),
+    started_at TIMESTAMP NOT NULL,
+    duration INTEGER NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_worklogs_task_id ON worklogs(task_id, started_at);
+CREATE INDEX IF NOT EXISTS idx_worklogs_user_id ON worklogs(user_id, started_at);
+CREATE INDEX IF NOT EXISTS idx_worklogs_started_at ON worklogs(started_at);
+
+-- A user has at most one running timer; stopping it turns it into a worklog
+CREATE TABLE IF NOT EXISTS timers (
+    user_id VARCHAR(36) PRIMARY KEY,
+    task_id VARCHAR(36) NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_timers_task_id ON timers(task_id);
+
+-- Estimates in seconds
+ALTER TABLE tasks ADD COLUMN original_estimate INTEGER;
+ALTER TABLE tasks ADD COLUMN remaining_estimate INTEGER;
@@ -0,0 +1,3 @@
+-- Intentionally left wide: narrowing users.password back to VARCHAR(100)
+-- would fail, or lock users out, once longer argon2id hashes are stored.
+SELECT 1;
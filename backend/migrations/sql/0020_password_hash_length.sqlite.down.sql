@@ -0,0 +1,2 @@
+-- SQLite doesn't enforce the length of VARCHAR columns, so there is nothing to narrow
+SELECT 1;
@@ -0,0 +1,3 @@
+-- Argon2id hashes in PHC format grow with their memory, time and salt
+-- parameters and no longer fit in 100 characters once those are raised.
+ALTER TABLE users ALTER COLUMN password TYPE VARCHAR(255);
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"go-react-redux-app/database"
+)
+
+// APITokenPrefix starts every personal access token, which tells them apart from JWTs
+const APITokenPrefix = "pmt_"
+
+// Scopes a personal access token can be granted
+const (
+	ScopeReadProjects  = "read:projects"
+	ScopeWriteProjects = "write:projects"
+	ScopeReadTasks     = "read:tasks"
+	ScopeWriteTasks    = "write:tasks"
+)
+
+// Scopes lists every valid scope
+var Scopes = []string{ScopeReadProjects, ScopeWriteProjects, ScopeReadTasks, ScopeWriteTasks}
+
+// IsValidScope checks if a scope is one of Scopes
+func IsValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIToken is a personal access token used by scripts and CI instead of a password login
+type APIToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// IsExpired checks if the token has passed its expiry
+func (t *APIToken) IsExpired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
+
+// HasScope checks if the token was granted a scope
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APITokenStore handles database operations for personal access tokens
+type APITokenStore struct {
+	DB *database.DB
+}
+
+// NewAPITokenStore creates a new APITokenStore
+func NewAPITokenStore(db *database.DB) *APITokenStore {
+	return &APITokenStore{DB: db}
+}
+
+// apiTokenColumns lists the api_tokens columns in the order scanAPIToken reads them
+const apiTokenColumns = `id, user_id, name, prefix, token_hash, scopes, created_at, expires_at, last_used_at`
+
+// scanAPIToken scans a row selected with apiTokenColumns into an APIToken
+func scanAPIToken(row rowScanner) (*APIToken, error) {
+	token := &APIToken{}
+	var scopes string
+	var expiresAt, lastUsedAt sql.NullTime
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.Prefix,
+		&token.TokenHash,
+		&scopes,
+		&token.CreatedAt,
+		&expiresAt,
+		&lastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Scopes = strings.Fields(scopes)
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return token, nil
+}
+
+// Create stores a new token
+func (s *APITokenStore) Create(token *APIToken) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `
+	INSERT INTO api_tokens (id, user_id, name, prefix, token_hash, scopes, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := s.DB.Exec(
+		query,
+		token.ID,
+		token.UserID,
+		token.Name,
+		token.Prefix,
+		token.TokenHash,
+		strings.Join(token.Scopes, " "),
+		token.CreatedAt,
+		token.ExpiresAt,
+	)
+	return err
+}
+
+// GetByHash gets a token by the hash of its value
+func (s *APITokenStore) GetByHash(hash string) (*APIToken, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	query := `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE token_hash = $1`
+	token, err := scanAPIToken(s.DB.QueryRow(query, hash))
+	if err == sql.ErrNoRows {
+		return nil, ErrAPITokenNotFound
+	}
+	return token, err
+}
+
+// GetByUser gets all tokens of a user, newest first
+func (s *APITokenStore) GetByUser(userID string) ([]*APIToken, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	query := `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE user_id = $1 ORDER BY created_at DESC`
+	rows, err := s.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*APIToken{}
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// Touch records that a token was just used
+func (s *APITokenStore) Touch(id string, usedAt time.Time) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `UPDATE api_tokens SET last_used_at = $1 WHERE id = $2`
+	_, err := s.DB.Exec(query, usedAt, id)
+	return err
+}
+
+// Delete revokes a token of a user
+func (s *APITokenStore) Delete(userID string, id string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`
+	result, err := s.DB.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, ErrAPITokenNotFound)
+}
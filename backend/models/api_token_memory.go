@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// MemoryAPITokenStore is an in-memory implementation of APITokenRepository
+type MemoryAPITokenStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryAPITokenStore creates a new MemoryAPITokenStore
+func NewMemoryAPITokenStore(db *MemoryDB) *MemoryAPITokenStore {
+	return &MemoryAPITokenStore{DB: db}
+}
+
+// Create stores a new token
+func (s *MemoryAPITokenStore) Create(token *APIToken) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.users[token.UserID]; !ok {
+		return errors.New("user does not exist")
+	}
+
+	stored := *token
+	stored.Scopes = append([]string(nil), token.Scopes...)
+	s.DB.apiTokens[token.ID] = stored
+	return nil
+}
+
+// GetByHash gets a token by the hash of its value
+func (s *MemoryAPITokenStore) GetByHash(hash string) (*APIToken, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	for _, token := range s.DB.apiTokens {
+		if token.TokenHash == hash {
+			return &token, nil
+		}
+	}
+	return nil, ErrAPITokenNotFound
+}
+
+// GetByUser gets all tokens of a user, newest first
+func (s *MemoryAPITokenStore) GetByUser(userID string) ([]*APIToken, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	tokens := []*APIToken{}
+	for _, token := range s.DB.apiTokens {
+		if token.UserID == userID {
+			token := token
+			tokens = append(tokens, &token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+// Touch records that a token was just used
+func (s *MemoryAPITokenStore) Touch(id string, usedAt time.Time) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	token, ok := s.DB.apiTokens[id]
+	if ok {
+		token.LastUsedAt = &usedAt
+		s.DB.apiTokens[id] = token
+	}
+	return nil
+}
+
+// Delete revokes a token of a user
+func (s *MemoryAPITokenStore) Delete(userID string, id string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	token, ok := s.DB.apiTokens[id]
+	if !ok || token.UserID != userID {
+		return ErrAPITokenNotFound
+	}
+	delete(s.DB.apiTokens, id)
+	return nil
+}
@@ -0,0 +1,196 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-react-redux-app/database"
+)
+
+// ErrAttachmentNotFound is returned when an attachment does not exist
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+// Attachment is a file attached to a task. Its contents are kept in the blob
+// store under their SHA-256 hash, so identical files are stored once.
+type Attachment struct {
+	ID          string    `json:"id"`
+	TaskID      string    `json:"taskId"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	UploadedBy  string    `json:"uploadedBy,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// AttachmentStore handles database operations for attachments and the blobs they refer to
+type AttachmentStore struct {
+	DB *database.DB
+}
+
+// NewAttachmentStore creates a new AttachmentStore
+func NewAttachmentStore(db *database.DB) *AttachmentStore {
+	return &AttachmentStore{DB: db}
+}
+
+// attachmentColumns lists the task_attachments columns in the order scanAttachment reads them
+const attachmentColumns = `id, task_id, filename, content_type, size, blob_hash, uploaded_by, created_at`
+
+// scanAttachment scans a row selected with attachmentColumns into an Attachment
+func scanAttachment(row rowScanner) (*Attachment, error) {
+	attachment := &Attachment{}
+	var uploadedBy sql.NullString
+	err := row.Scan(
+		&attachment.ID,
+		&attachment.TaskID,
+		&attachment.Filename,
+		&attachment.ContentType,
+		&attachment.Size,
+		&attachment.SHA256,
+		&uploadedBy,
+		&attachment.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment.UploadedBy = uploadedBy.String
+	return attachment, nil
+}
+
+// Create creates an attachment, recording its blob if no other attachment has the same contents
+func (s *AttachmentStore) Create(attachment *Attachment) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO blobs (hash, size, created_at) VALUES ($1, $2, $3) ON CONFLICT (hash) DO NOTHING`,
+		attachment.SHA256, attachment.Size, attachment.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO task_attachments (id, task_id, blob_hash, filename, content_type, size, uploaded_by, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err = tx.Exec(
+		query,
+		attachment.ID,
+		attachment.TaskID,
+		attachment.SHA256,
+		attachment.Filename,
+		attachment.ContentType,
+		attachment.Size,
+		nullString(attachment.UploadedBy),
+		attachment.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetByID gets an attachment by ID
+func (s *AttachmentStore) GetByID(id string) (*Attachment, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	attachment, err := scanAttachment(s.DB.QueryRow(`SELECT `+attachmentColumns+` FROM task_attachments WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrAttachmentNotFound
+	}
+	return attachment, err
+}
+
+// GetByTask gets the attachments of a task, oldest first
+func (s *AttachmentStore) GetByTask(taskID string) ([]*Attachment, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	rows, err := s.DB.Query(`SELECT `+attachmentColumns+` FROM task_attachments WHERE task_id = $1 ORDER BY created_at ASC, id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []*Attachment{}
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, rows.Err()
+}
+
+// Delete deletes an attachment. Its blob is left for DeleteBlob.
+func (s *AttachmentStore) Delete(id string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	result, err := s.DB.Exec(`DELETE FROM task_attachments WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, ErrAttachmentNotFound)
+}
+
+// GetOrphanedBlobs gets the hashes of the blobs no attachment refers to anymore
+func (s *AttachmentStore) GetOrphanedBlobs() ([]string, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	rows, err := s.DB.Query(`
+	SELECT hash FROM blobs
+	WHERE NOT EXISTS (SELECT 1 FROM task_attachments WHERE blob_hash = blobs.hash)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := []string{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
+
+// DeleteBlob forgets a blob if no attachment refers to it, reporting whether
+// it did. The contents are then removed from the blob store by the caller.
+func (s *AttachmentStore) DeleteBlob(hash string) (bool, error) {
+	if s.DB == nil {
+		return false, errors.New("database connection is nil")
+	}
+
+	result, err := s.DB.Exec(`
+	DELETE FROM blobs
+	WHERE hash = $1 AND NOT EXISTS (SELECT 1 FROM task_attachments WHERE blob_hash = $1)`, hash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
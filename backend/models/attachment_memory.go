@@ -0,0 +1,117 @@
+package models
+
+import (
+	"errors"
+	"sort"
+)
+
+// MemoryAttachmentStore is an in-memory implementation of AttachmentRepository
+type MemoryAttachmentStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryAttachmentStore creates a new MemoryAttachmentStore
+func NewMemoryAttachmentStore(db *MemoryDB) *MemoryAttachmentStore {
+	return &MemoryAttachmentStore{DB: db}
+}
+
+// Create creates an attachment, recording its blob if no other attachment has the same contents
+func (s *MemoryAttachmentStore) Create(attachment *Attachment) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, exists := s.DB.attachments[attachment.ID]; exists {
+		return errors.New("attachment already exists")
+	}
+	if _, ok := s.DB.tasks[attachment.TaskID]; !ok {
+		return errors.New("task does not exist")
+	}
+
+	if _, ok := s.DB.blobs[attachment.SHA256]; !ok {
+		s.DB.blobs[attachment.SHA256] = attachment.CreatedAt
+	}
+	s.DB.attachments[attachment.ID] = *attachment
+	return nil
+}
+
+// GetByID gets an attachment by ID
+func (s *MemoryAttachmentStore) GetByID(id string) (*Attachment, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	attachment, ok := s.DB.attachments[id]
+	if !ok {
+		return nil, ErrAttachmentNotFound
+	}
+	return &attachment, nil
+}
+
+// GetByTask gets the attachments of a task, oldest first
+func (s *MemoryAttachmentStore) GetByTask(taskID string) ([]*Attachment, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	attachments := []*Attachment{}
+	for _, attachment := range s.DB.attachments {
+		if attachment.TaskID == taskID {
+			attachment := attachment
+			attachments = append(attachments, &attachment)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool {
+		if !attachments[i].CreatedAt.Equal(attachments[j].CreatedAt) {
+			return attachments[i].CreatedAt.Before(attachments[j].CreatedAt)
+		}
+		return attachments[i].ID < attachments[j].ID
+	})
+	return attachments, nil
+}
+
+// Delete deletes an attachment. Its blob is left for DeleteBlob.
+func (s *MemoryAttachmentStore) Delete(id string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.attachments[id]; !ok {
+		return ErrAttachmentNotFound
+	}
+	delete(s.DB.attachments, id)
+	return nil
+}
+
+// GetOrphanedBlobs gets the hashes of the blobs no attachment refers to anymore
+func (s *MemoryAttachmentStore) GetOrphanedBlobs() ([]string, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	hashes := []string{}
+	for hash := range s.DB.blobs {
+		if !s.DB.blobReferencedLocked(hash) {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes, nil
+}
+
+// DeleteBlob forgets a blob if no attachment refers to it, reporting whether
+// it did. The contents are then removed from the blob store by the caller.
+func (s *MemoryAttachmentStore) DeleteBlob(hash string) (bool, error) {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.blobs[hash]; !ok || s.DB.blobReferencedLocked(hash) {
+		return false, nil
+	}
+	delete(s.DB.blobs, hash)
+	return true, nil
+}
+
+// blobReferencedLocked checks if any attachment refers to a blob. The caller must hold the lock.
+func (db *MemoryDB) blobReferencedLocked(hash string) bool {
+	for _, attachment := range db.attachments {
+		if attachment.SHA256 == hash {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,334 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-react-redux-app/database"
+)
+
+// ErrCommentNotFound is returned when a comment does not exist
+var ErrCommentNotFound = errors.New("comment not found")
+
+// Comment is a Markdown comment on a task
+type Comment struct {
+	ID       string `json:"id"`
+	TaskID   string `json:"taskId"`
+	AuthorID string `json:"authorId,omitempty"`
+	Body     string `json:"body"`
+	// Mentions are the users @mentioned in the body, sorted by username
+	Mentions  []MentionedUser `json:"mentions"`
+	Edited    bool            `json:"edited"`
+	CreatedAt time.Time       `json:"createdAt"`
+	EditedAt  *time.Time      `json:"editedAt,omitempty"`
+}
+
+// MentionedUser is a user @mentioned in a comment
+type MentionedUser struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+}
+
+// Mention tells a user that they were @mentioned in a comment
+type Mention struct {
+	CommentID string    `json:"commentId"`
+	TaskID    string    `json:"taskId"`
+	ProjectID string    `json:"projectId"`
+	AuthorID  string    `json:"authorId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CommentStore handles database operations for comments
+type CommentStore struct {
+	DB *database.DB
+}
+
+// NewCommentStore creates a new CommentStore
+func NewCommentStore(db *database.DB) *CommentStore {
+	return &CommentStore{DB: db}
+}
+
+// commentColumns lists the task_comments columns in the order scanComment reads them
+const commentColumns = `id, task_id, author_id, body, created_at, edited_at`
+
+// scanComment scans a row selected with commentColumns into a Comment
+func scanComment(row rowScanner) (*Comment, error) {
+	comment := &Comment{Mentions: []MentionedUser{}}
+	var authorID sql.NullString
+	var editedAt sql.NullTime
+	err := row.Scan(&comment.ID, &comment.TaskID, &authorID, &comment.Body, &comment.CreatedAt, &editedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	comment.AuthorID = authorID.String
+	if editedAt.Valid {
+		comment.EditedAt = &editedAt.Time
+		comment.Edited = true
+	}
+	return comment, nil
+}
+
+// Create creates a comment and records its mentions
+func (s *CommentStore) Create(comment *Comment) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO task_comments (id, task_id, author_id, body, created_at)
+	VALUES ($1, $2, $3, $4, $5)`
+
+	_, err = tx.Exec(query, comment.ID, comment.TaskID, nullString(comment.AuthorID), comment.Body, comment.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for _, mention := range comment.Mentions {
+		if err := insertMention(tx, comment.ID, mention.UserID, comment.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// insertMention records that a user was mentioned in a comment
+func insertMention(tx *database.Tx, commentID string, userID string, createdAt time.Time) error {
+	_, err := tx.Exec(
+		`INSERT INTO comment_mentions (comment_id, user_id, created_at) VALUES ($1, $2, $3)`,
+		commentID, userID, createdAt,
+	)
+	return err
+}
+
+// GetByID gets a comment by ID
+func (s *CommentStore) GetByID(id string) (*Comment, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	comment, err := scanComment(s.DB.QueryRow(`SELECT `+commentColumns+` FROM task_comments WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.loadMentions([]*Comment{comment}); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// GetByTask gets a page of a task's comments, oldest first, along with the
+// total number of comments on the task
+func (s *CommentStore) GetByTask(taskID string, limit int, offset int) ([]*Comment, int, error) {
+	if s.DB == nil {
+		return nil, 0, errors.New("database connection is nil")
+	}
+
+	var total int
+	if err := s.DB.QueryRow(`SELECT COUNT(*) FROM task_comments WHERE task_id = $1`, taskID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + commentColumns + ` FROM task_comments
+	WHERE task_id = $1
+	ORDER BY created_at ASC, id
+	LIMIT $2 OFFSET $3`
+
+	rows, err := s.DB.Query(query, taskID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if err := s.loadMentions(comments); err != nil {
+		return nil, 0, err
+	}
+	return comments, total, nil
+}
+
+// loadMentions fills in the mentions of the comments with a single query
+func (s *CommentStore) loadMentions(comments []*Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]*Comment, len(comments))
+	placeholders := make([]string, len(comments))
+	args := make([]interface{}, len(comments))
+	for i, comment := range comments {
+		byID[comment.ID] = comment
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+		args[i] = comment.ID
+	}
+
+	query := `
+	SELECT m.comment_id, m.user_id, u.username
+	FROM comment_mentions m
+	JOIN users u ON u.id = m.user_id
+	WHERE m.comment_id IN (` + strings.Join(placeholders, ", ") + `)
+	ORDER BY u.username`
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var commentID string
+		var mention MentionedUser
+		if err := rows.Scan(&commentID, &mention.UserID, &mention.Username); err != nil {
+			return err
+		}
+		comment := byID[commentID]
+		comment.Mentions = append(comment.Mentions, mention)
+	}
+	return rows.Err()
+}
+
+// Update stores an edited comment body and replaces its mentions. Users who
+// were already mentioned keep their original mention.
+func (s *CommentStore) Update(comment *Comment) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE task_comments SET body = $1, edited_at = $2 WHERE id = $3`,
+		comment.Body, comment.EditedAt, comment.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result, ErrCommentNotFound); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT user_id FROM comment_mentions WHERE comment_id = $1`, comment.ID)
+	if err != nil {
+		return err
+	}
+	previous := make(map[string]bool)
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return err
+		}
+		previous[userID] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, mention := range comment.Mentions {
+		if previous[mention.UserID] {
+			delete(previous, mention.UserID)
+			continue
+		}
+		if err := insertMention(tx, comment.ID, mention.UserID, *comment.EditedAt); err != nil {
+			return err
+		}
+	}
+	for userID := range previous {
+		_, err := tx.Exec(`DELETE FROM comment_mentions WHERE comment_id = $1 AND user_id = $2`, comment.ID, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Delete deletes a comment along with its mentions
+func (s *CommentStore) Delete(id string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	result, err := s.DB.Exec(`DELETE FROM task_comments WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, ErrCommentNotFound)
+}
+
+// GetMentions gets a page of the mentions of a user, newest first, along with
+// their total number. Mentions in projects the user can no longer access are
+// left out.
+func (s *CommentStore) GetMentions(userID string, limit int, offset int) ([]*Mention, int, error) {
+	if s.DB == nil {
+		return nil, 0, errors.New("database connection is nil")
+	}
+
+	from := `
+	FROM comment_mentions m
+	JOIN task_comments c ON c.id = m.comment_id
+	JOIN tasks t ON t.id = c.task_id
+	WHERE m.user_id = $1 AND (
+		EXISTS (SELECT 1 FROM users WHERE id = $1 AND role = '` + UserRoleAdmin + `')
+		OR t.project_id IN (SELECT id FROM projects WHERE owner_id = $1)
+		OR t.project_id IN (SELECT project_id FROM project_members WHERE user_id = $1)
+	)`
+
+	var total int
+	if err := s.DB.QueryRow(`SELECT COUNT(*)`+from, userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT m.comment_id, c.task_id, t.project_id, c.author_id, m.created_at` + from + `
+	ORDER BY m.created_at DESC, m.comment_id
+	LIMIT $2 OFFSET $3`
+
+	rows, err := s.DB.Query(query, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	mentions := []*Mention{}
+	for rows.Next() {
+		mention := &Mention{}
+		var authorID sql.NullString
+		if err := rows.Scan(&mention.CommentID, &mention.TaskID, &mention.ProjectID, &authorID, &mention.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		mention.AuthorID = authorID.String
+		mentions = append(mentions, mention)
+	}
+
+	return mentions, total, rows.Err()
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"errors"
+	"sort"
+)
+
+// mentionKey identifies a mention by its comment and the mentioned user
+type mentionKey struct {
+	commentID string
+	userID    string
+}
+
+// commentLocked returns a copy of a stored comment with its mentions filled in. The caller must hold the lock.
+func (db *MemoryDB) commentLocked(comment Comment) *Comment {
+	comment.Mentions = []MentionedUser{}
+	for key := range db.mentions {
+		if key.commentID == comment.ID {
+			comment.Mentions = append(comment.Mentions, MentionedUser{
+				UserID:   key.userID,
+				Username: db.users[key.userID].Username,
+			})
+		}
+	}
+	sort.Slice(comment.Mentions, func(i, j int) bool {
+		return comment.Mentions[i].Username < comment.Mentions[j].Username
+	})
+	comment.Edited = comment.EditedAt != nil
+	return &comment
+}
+
+// deleteCommentLocked removes a comment and its mentions. The caller must hold the write lock.
+func (db *MemoryDB) deleteCommentLocked(id string) {
+	delete(db.comments, id)
+	for key := range db.mentions {
+		if key.commentID == id {
+			delete(db.mentions, key)
+		}
+	}
+}
+
+// MemoryCommentStore is an in-memory implementation of CommentRepository
+type MemoryCommentStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryCommentStore creates a new MemoryCommentStore
+func NewMemoryCommentStore(db *MemoryDB) *MemoryCommentStore {
+	return &MemoryCommentStore{DB: db}
+}
+
+// Create creates a comment and records its mentions
+func (s *MemoryCommentStore) Create(comment *Comment) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.tasks[comment.TaskID]; !ok {
+		return errors.New("task does not exist")
+	}
+	for _, mention := range comment.Mentions {
+		if _, ok := s.DB.users[mention.UserID]; !ok {
+			return errors.New("mentioned user does not exist")
+		}
+	}
+
+	stored := *comment
+	stored.Mentions = nil
+	stored.Edited = false
+	stored.EditedAt = nil
+	s.DB.comments[comment.ID] = stored
+	for _, mention := range comment.Mentions {
+		s.DB.mentions[mentionKey{commentID: comment.ID, userID: mention.UserID}] = comment.CreatedAt
+	}
+	return nil
+}
+
+// GetByID gets a comment by ID
+func (s *MemoryCommentStore) GetByID(id string) (*Comment, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	comment, ok := s.DB.comments[id]
+	if !ok {
+		return nil, ErrCommentNotFound
+	}
+	return s.DB.commentLocked(comment), nil
+}
+
+// GetByTask gets a page of a task's comments, oldest first, along with the
+// total number of comments on the task
+func (s *MemoryCommentStore) GetByTask(taskID string, limit int, offset int) ([]*Comment, int, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	var matched []Comment
+	for _, comment := range s.DB.comments {
+		if comment.TaskID == taskID {
+			matched = append(matched, comment)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].ID < matched[j].ID
+		}
+		return matched[i].CreatedAt.Before(matched[j].CreatedAt)
+	})
+
+	total := len(matched)
+	start := min(offset, total)
+	end := min(start+limit, total)
+	comments := []*Comment{}
+	for _, comment := range matched[start:end] {
+		comments = append(comments, s.DB.commentLocked(comment))
+	}
+	return comments, total, nil
+}
+
+// Update stores an edited comment body and replaces its mentions. Users who
+// were already mentioned keep their original mention.
+func (s *MemoryCommentStore) Update(comment *Comment) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	stored, ok := s.DB.comments[comment.ID]
+	if !ok {
+		return ErrCommentNotFound
+	}
+	for _, mention := range comment.Mentions {
+		if _, ok := s.DB.users[mention.UserID]; !ok {
+			return errors.New("mentioned user does not exist")
+		}
+	}
+
+	stored.Body = comment.Body
+	editedAt := *comment.EditedAt
+	stored.EditedAt = &editedAt
+	s.DB.comments[comment.ID] = stored
+
+	keep := make(map[string]bool, len(comment.Mentions))
+	for _, mention := range comment.Mentions {
+		keep[mention.UserID] = true
+		key := mentionKey{commentID: comment.ID, userID: mention.UserID}
+		if _, exists := s.DB.mentions[key]; !exists {
+			s.DB.mentions[key] = editedAt
+		}
+	}
+	for key := range s.DB.mentions {
+		if key.commentID == comment.ID && !keep[key.userID] {
+			delete(s.DB.mentions, key)
+		}
+	}
+	return nil
+}
+
+// Delete deletes a comment along with its mentions
+func (s *MemoryCommentStore) Delete(id string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.comments[id]; !ok {
+		return ErrCommentNotFound
+	}
+	s.DB.deleteCommentLocked(id)
+	return nil
+}
+
+// GetMentions gets a page of the mentions of a user, newest first, along with
+// their total number. Mentions in projects the user can no longer access are
+// left out.
+func (s *MemoryCommentStore) GetMentions(userID string, limit int, offset int) ([]*Mention, int, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	isAdmin := s.DB.users[userID].Role == UserRoleAdmin
+	var matched []*Mention
+	for key, createdAt := range s.DB.mentions {
+		if key.userID != userID {
+			continue
+		}
+		comment := s.DB.comments[key.commentID]
+		projectID := s.DB.tasks[comment.TaskID].ProjectID
+		_, member := s.DB.members[projectID][userID]
+		if !isAdmin && !member && s.DB.projects[projectID].OwnerID != userID {
+			continue
+		}
+		matched = append(matched, &Mention{
+			CommentID: comment.ID,
+			TaskID:    comment.TaskID,
+			ProjectID: projectID,
+			AuthorID:  comment.AuthorID,
+			CreatedAt: createdAt,
+		})
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CommentID < matched[j].CommentID
+		}
+		return matched[i].CreatedAt.After(matched[j].CreatedAt)
+	})
+
+	total := len(matched)
+	start := min(offset, total)
+	end := min(start+limit, total)
+	return append([]*Mention{}, matched[start:end]...), total, nil
+}
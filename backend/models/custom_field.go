@@ -0,0 +1,393 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-react-redux-app/database"
+)
+
+var (
+	// ErrCustomFieldNotFound is returned when a custom field does not exist
+	ErrCustomFieldNotFound = errors.New("custom field not found")
+	// ErrCustomFieldExists is returned when a project already has a custom field with the same name, ignoring case
+	ErrCustomFieldExists = errors.New("custom field already exists")
+)
+
+// Custom field types
+const (
+	FieldTypeText        = "text"
+	FieldTypeNumber      = "number"
+	FieldTypeDate        = "date"
+	FieldTypeSelect      = "select"
+	FieldTypeMultiSelect = "multi_select"
+	FieldTypeUser        = "user"
+)
+
+// CustomField is a field a project defines for its tasks. Values are stored
+// JSON encoded: a string for text fields, a number, a YYYY-MM-DD string for
+// dates, one of the options for select fields, an array of options for
+// multi_select fields and a user ID for user fields.
+type CustomField struct {
+	ID        string `json:"id"`
+	ProjectID string `json:"projectId"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	// Options are the choices of select and multi_select fields, in display order
+	Options   []string  `json:"options,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// HasOptions checks if the field's values are chosen from its options
+func (f *CustomField) HasOptions() bool {
+	return f.Type == FieldTypeSelect || f.Type == FieldTypeMultiSelect
+}
+
+// FieldValue is a custom field value to store on a task
+type FieldValue struct {
+	FieldID string
+	// Value is the JSON encoded value, or nil to clear the field
+	Value json.RawMessage
+	// UserID is the user a user field refers to
+	UserID string
+}
+
+// RemapOptions applies renamed options to a value of a select or
+// multi_select field and drops the options the field no longer has. It
+// reports false when nothing is left of the value.
+func RemapOptions(field *CustomField, value json.RawMessage, renamed map[string]string) (json.RawMessage, bool) {
+	valid := make(map[string]bool, len(field.Options))
+	for _, option := range field.Options {
+		valid[option] = true
+	}
+	remap := func(option string) string {
+		if name, ok := renamed[option]; ok {
+			return name
+		}
+		return option
+	}
+
+	if field.Type == FieldTypeSelect {
+		var option string
+		if json.Unmarshal(value, &option) != nil || !valid[remap(option)] {
+			return nil, false
+		}
+		encoded, _ := json.Marshal(remap(option))
+		return encoded, true
+	}
+
+	var options []string
+	if json.Unmarshal(value, &options) != nil {
+		return nil, false
+	}
+	kept := []string{}
+	seen := make(map[string]bool)
+	for _, option := range options {
+		option = remap(option)
+		if valid[option] && !seen[option] {
+			seen[option] = true
+			kept = append(kept, option)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, false
+	}
+	encoded, _ := json.Marshal(kept)
+	return encoded, true
+}
+
+// CustomFieldStore handles database operations for custom fields and their values on tasks
+type CustomFieldStore struct {
+	DB *database.DB
+}
+
+// NewCustomFieldStore creates a new CustomFieldStore
+func NewCustomFieldStore(db *database.DB) *CustomFieldStore {
+	return &CustomFieldStore{DB: db}
+}
+
+// customFieldColumns lists the custom_fields columns in the order scanCustomField reads them
+const customFieldColumns = `id, project_id, name, type, options, created_at`
+
+// scanCustomField scans a row selected with customFieldColumns into a CustomField
+func scanCustomField(row rowScanner) (*CustomField, error) {
+	field := &CustomField{}
+	var options string
+	err := row.Scan(&field.ID, &field.ProjectID, &field.Name, &field.Type, &options, &field.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(options), &field.Options); err != nil {
+		return nil, err
+	}
+	return field, nil
+}
+
+// encodeOptions encodes the options of a field for the options column
+func encodeOptions(field *CustomField) (string, error) {
+	options := field.Options
+	if options == nil {
+		options = []string{}
+	}
+	encoded, err := json.Marshal(options)
+	return string(encoded), err
+}
+
+// Create creates a custom field. It returns ErrCustomFieldExists if the
+// project already has a field with the same name.
+func (s *CustomFieldStore) Create(field *CustomField) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	options, err := encodeOptions(field)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkCustomFieldName(tx, field); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO custom_fields (id, project_id, name, type, options, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		field.ID, field.ProjectID, field.Name, field.Type, options, field.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkCustomFieldName returns ErrCustomFieldExists if another field of the project has the field's name
+func checkCustomFieldName(tx *database.Tx, field *CustomField) error {
+	var exists bool
+	err := tx.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM custom_fields WHERE project_id = $1 AND LOWER(name) = LOWER($2) AND id <> $3)`,
+		field.ProjectID, field.Name, field.ID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrCustomFieldExists
+	}
+	return nil
+}
+
+// GetByID gets a custom field by ID
+func (s *CustomFieldStore) GetByID(id string) (*CustomField, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	field, err := scanCustomField(s.DB.QueryRow(`SELECT `+customFieldColumns+` FROM custom_fields WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrCustomFieldNotFound
+	}
+	return field, err
+}
+
+// GetByProject gets the custom fields of a project, oldest first
+func (s *CustomFieldStore) GetByProject(projectID string) ([]CustomField, error) {
+	return s.GetByProjects([]string{projectID})
+}
+
+// GetByProjects gets the custom fields of several projects, oldest first
+func (s *CustomFieldStore) GetByProjects(projectIDs []string) ([]CustomField, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	fields := []CustomField{}
+	if len(projectIDs) == 0 {
+		return fields, nil
+	}
+
+	placeholders := make([]string, len(projectIDs))
+	args := make([]interface{}, len(projectIDs))
+	for i, projectID := range projectIDs {
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+		args[i] = projectID
+	}
+
+	query := `SELECT ` + customFieldColumns + ` FROM custom_fields
+	WHERE project_id IN (` + strings.Join(placeholders, ", ") + `)
+	ORDER BY created_at, id`
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		field, err := scanCustomField(rows)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, *field)
+	}
+
+	return fields, rows.Err()
+}
+
+// Update renames a custom field or changes its options. Values of removed
+// options are taken off the tasks, and values of options in renamed (old
+// name to new name) follow the rename. It returns ErrCustomFieldExists if the
+// project already has another field with the new name.
+func (s *CustomFieldStore) Update(field *CustomField, renamed map[string]string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	options, err := encodeOptions(field)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkCustomFieldName(tx, field); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`UPDATE custom_fields SET name = $1, options = $2 WHERE id = $3`, field.Name, options, field.ID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result, ErrCustomFieldNotFound); err != nil {
+		return err
+	}
+
+	if field.HasOptions() {
+		if err := remapFieldValues(tx, field, renamed); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// remapFieldValues rewrites the stored values of a select or multi_select
+// field after its options changed
+func remapFieldValues(tx *database.Tx, field *CustomField, renamed map[string]string) error {
+	rows, err := tx.Query(`SELECT task_id, value FROM task_field_values WHERE field_id = $1`, field.ID)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]string)
+	for rows.Next() {
+		var taskID, value string
+		if err := rows.Scan(&taskID, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		values[taskID] = value
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for taskID, value := range values {
+		remapped, ok := RemapOptions(field, json.RawMessage(value), renamed)
+		switch {
+		case !ok:
+			_, err = tx.Exec(`DELETE FROM task_field_values WHERE task_id = $1 AND field_id = $2`, taskID, field.ID)
+		case string(remapped) != value:
+			_, err = tx.Exec(`UPDATE task_field_values SET value = $1 WHERE task_id = $2 AND field_id = $3`, string(remapped), taskID, field.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete deletes a custom field along with its values
+func (s *CustomFieldStore) Delete(id string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	result, err := s.DB.Exec(`DELETE FROM custom_fields WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, ErrCustomFieldNotFound)
+}
+
+// SetTaskValues sets or clears custom field values on a task
+func (s *CustomFieldStore) SetTaskValues(taskID string, values []FieldValue) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, value := range values {
+		if _, err := tx.Exec(`DELETE FROM task_field_values WHERE task_id = $1 AND field_id = $2`, taskID, value.FieldID); err != nil {
+			return err
+		}
+		if value.Value == nil {
+			continue
+		}
+
+		_, err := tx.Exec(
+			`INSERT INTO task_field_values (task_id, field_id, value, user_id) VALUES ($1, $2, $3, $4)`,
+			taskID, value.FieldID, string(value.Value), nullString(value.UserID),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// loadTaskFieldValues fills in the custom field values of the tasks
+func loadTaskFieldValues(db *database.DB, tasks []Task) error {
+	byID := make(map[string]*Task, len(tasks))
+	for i := range tasks {
+		tasks[i].CustomFields = map[string]json.RawMessage{}
+		byID[tasks[i].ID] = &tasks[i]
+	}
+
+	return inTaskBatches(tasks, func(in string, args []interface{}) error {
+		rows, err := db.Query(`SELECT task_id, field_id, value FROM task_field_values WHERE task_id IN (`+in+`)`, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var taskID, fieldID, value string
+			if err := rows.Scan(&taskID, &fieldID, &value); err != nil {
+				return err
+			}
+			byID[taskID].CustomFields[fieldID] = json.RawMessage(value)
+		}
+		return rows.Err()
+	})
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// fieldValueKey identifies a custom field value by its task and field
+type fieldValueKey struct {
+	taskID  string
+	fieldID string
+}
+
+// MemoryCustomFieldStore is an in-memory implementation of CustomFieldRepository
+type MemoryCustomFieldStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryCustomFieldStore creates a new MemoryCustomFieldStore
+func NewMemoryCustomFieldStore(db *MemoryDB) *MemoryCustomFieldStore {
+	return &MemoryCustomFieldStore{DB: db}
+}
+
+// Create creates a custom field. It returns ErrCustomFieldExists if the
+// project already has a field with the same name.
+func (s *MemoryCustomFieldStore) Create(field *CustomField) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.projects[field.ProjectID]; !ok {
+		return errors.New("project does not exist")
+	}
+	if s.DB.fieldNameTakenLocked(field) {
+		return ErrCustomFieldExists
+	}
+
+	s.DB.fields[field.ID] = copyCustomField(*field)
+	return nil
+}
+
+// fieldNameTakenLocked checks if another field of the project has the field's name. The caller must hold the lock.
+func (db *MemoryDB) fieldNameTakenLocked(field *CustomField) bool {
+	for _, other := range db.fields {
+		if other.ProjectID == field.ProjectID && other.ID != field.ID && strings.EqualFold(other.Name, field.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyCustomField copies a field so that callers and the store don't share its options
+func copyCustomField(field CustomField) CustomField {
+	field.Options = append([]string(nil), field.Options...)
+	return field
+}
+
+// GetByID gets a custom field by ID
+func (s *MemoryCustomFieldStore) GetByID(id string) (*CustomField, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	field, ok := s.DB.fields[id]
+	if !ok {
+		return nil, ErrCustomFieldNotFound
+	}
+	field = copyCustomField(field)
+	return &field, nil
+}
+
+// GetByProject gets the custom fields of a project, oldest first
+func (s *MemoryCustomFieldStore) GetByProject(projectID string) ([]CustomField, error) {
+	return s.GetByProjects([]string{projectID})
+}
+
+// GetByProjects gets the custom fields of several projects, oldest first
+func (s *MemoryCustomFieldStore) GetByProjects(projectIDs []string) ([]CustomField, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(projectIDs))
+	for _, projectID := range projectIDs {
+		wanted[projectID] = true
+	}
+
+	fields := []CustomField{}
+	for _, field := range s.DB.fields {
+		if wanted[field.ProjectID] {
+			fields = append(fields, copyCustomField(field))
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if !fields[i].CreatedAt.Equal(fields[j].CreatedAt) {
+			return fields[i].CreatedAt.Before(fields[j].CreatedAt)
+		}
+		return fields[i].ID < fields[j].ID
+	})
+	return fields, nil
+}
+
+// Update renames a custom field or changes its options. Values of removed
+// options are taken off the tasks, and values of options in renamed (old
+// name to new name) follow the rename. It returns ErrCustomFieldExists if the
+// project already has another field with the new name.
+func (s *MemoryCustomFieldStore) Update(field *CustomField, renamed map[string]string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	stored, ok := s.DB.fields[field.ID]
+	if !ok {
+		return ErrCustomFieldNotFound
+	}
+	if s.DB.fieldNameTakenLocked(field) {
+		return ErrCustomFieldExists
+	}
+
+	stored.Name = field.Name
+	stored.Options = append([]string(nil), field.Options...)
+	s.DB.fields[field.ID] = stored
+
+	if stored.HasOptions() {
+		for key, value := range s.DB.fieldValues {
+			if key.fieldID != field.ID {
+				continue
+			}
+			remapped, ok := RemapOptions(&stored, value.Value, renamed)
+			if !ok {
+				delete(s.DB.fieldValues, key)
+				continue
+			}
+			value.Value = remapped
+			s.DB.fieldValues[key] = value
+		}
+	}
+	return nil
+}
+
+// Delete deletes a custom field along with its values
+func (s *MemoryCustomFieldStore) Delete(id string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.fields[id]; !ok {
+		return ErrCustomFieldNotFound
+	}
+	s.DB.deleteFieldLocked(id)
+	return nil
+}
+
+// deleteFieldLocked removes a custom field and its values. The caller must hold the write lock.
+func (db *MemoryDB) deleteFieldLocked(id string) {
+	delete(db.fields, id)
+	for key := range db.fieldValues {
+		if key.fieldID == id {
+			delete(db.fieldValues, key)
+		}
+	}
+}
+
+// SetTaskValues sets or clears custom field values on a task
+func (s *MemoryCustomFieldStore) SetTaskValues(taskID string, values []FieldValue) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.tasks[taskID]; !ok {
+		return errors.New("task does not exist")
+	}
+	for _, value := range values {
+		if _, ok := s.DB.fields[value.FieldID]; !ok {
+			return errors.New("custom field does not exist")
+		}
+		if value.UserID != "" {
+			if _, ok := s.DB.users[value.UserID]; !ok {
+				return errors.New("user does not exist")
+			}
+		}
+	}
+
+	for _, value := range values {
+		key := fieldValueKey{taskID: taskID, fieldID: value.FieldID}
+		if value.Value == nil {
+			delete(s.DB.fieldValues, key)
+			continue
+		}
+		value.Value = append(json.RawMessage(nil), value.Value...)
+		s.DB.fieldValues[key] = value
+	}
+	return nil
+}
+
+// taskFieldValuesLocked returns the custom field values of a task, keyed by field ID. The caller must hold the lock.
+func (db *MemoryDB) taskFieldValuesLocked(taskID string) map[string]json.RawMessage {
+	values := map[string]json.RawMessage{}
+	for key, value := range db.fieldValues {
+		if key.taskID == taskID {
+			values[key.fieldID] = value.Value
+		}
+	}
+	return values
+}
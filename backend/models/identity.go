@@ -0,0 +1,159 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-react-redux-app/database"
+)
+
+// Identity links a user to their account at an identity provider
+type Identity struct {
+	ID       string `json:"id"`
+	UserID   string `json:"-"`
+	Provider string `json:"provider"`
+	// Subject is the provider's stable identifier of the user
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+}
+
+// IdentityStore handles database operations for linked identities
+type IdentityStore struct {
+	DB *database.DB
+}
+
+// NewIdentityStore creates a new IdentityStore
+func NewIdentityStore(db *database.DB) *IdentityStore {
+	return &IdentityStore{DB: db}
+}
+
+// identityColumns lists the user_identities columns in the order scanIdentity reads them
+const identityColumns = `id, user_id, provider, subject, email, created_at, last_login_at`
+
+// scanIdentity scans a row selected with identityColumns into an Identity
+func scanIdentity(row rowScanner) (*Identity, error) {
+	identity := &Identity{}
+	var email sql.NullString
+	var lastLoginAt sql.NullTime
+	err := row.Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&email,
+		&identity.CreatedAt,
+		&lastLoginAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	identity.Email = email.String
+	if lastLoginAt.Valid {
+		identity.LastLoginAt = &lastLoginAt.Time
+	}
+	return identity, nil
+}
+
+// Create links a new identity. It returns ErrIdentityExists if the provider
+// account or the provider for this user is already linked.
+func (s *IdentityStore) Create(identity *Identity) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	var exists bool
+	err := s.DB.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM user_identities WHERE (provider = $1 AND subject = $2) OR (provider = $1 AND user_id = $3))`,
+		identity.Provider, identity.Subject, identity.UserID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrIdentityExists
+	}
+
+	query := `
+	INSERT INTO user_identities (id, user_id, provider, subject, email, created_at, last_login_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err = s.DB.Exec(
+		query,
+		identity.ID,
+		identity.UserID,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+		identity.CreatedAt,
+		identity.LastLoginAt,
+	)
+	return err
+}
+
+// GetBySubject gets the identity of a provider account
+func (s *IdentityStore) GetBySubject(provider string, subject string) (*Identity, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	query := `SELECT ` + identityColumns + ` FROM user_identities WHERE provider = $1 AND subject = $2`
+	identity, err := scanIdentity(s.DB.QueryRow(query, provider, subject))
+	if err == sql.ErrNoRows {
+		return nil, ErrIdentityNotFound
+	}
+	return identity, err
+}
+
+// GetByUser gets all identities linked to a user
+func (s *IdentityStore) GetByUser(userID string) ([]*Identity, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	query := `SELECT ` + identityColumns + ` FROM user_identities WHERE user_id = $1 ORDER BY created_at`
+	rows, err := s.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []*Identity{}
+	for rows.Next() {
+		identity, err := scanIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, rows.Err()
+}
+
+// Touch records a login with an identity and the email address the provider reported
+func (s *IdentityStore) Touch(id string, email string, loginAt time.Time) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `UPDATE user_identities SET email = $1, last_login_at = $2 WHERE id = $3`
+	_, err := s.DB.Exec(query, email, loginAt, id)
+	return err
+}
+
+// Delete unlinks an identity of a user
+func (s *IdentityStore) Delete(userID string, id string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `DELETE FROM user_identities WHERE id = $1 AND user_id = $2`
+	result, err := s.DB.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, ErrIdentityNotFound)
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// MemoryIdentityStore is an in-memory implementation of IdentityRepository
+type MemoryIdentityStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryIdentityStore creates a new MemoryIdentityStore
+func NewMemoryIdentityStore(db *MemoryDB) *MemoryIdentityStore {
+	return &MemoryIdentityStore{DB: db}
+}
+
+// Create links a new identity. It returns ErrIdentityExists if the provider
+// account or the provider for this user is already linked.
+func (s *MemoryIdentityStore) Create(identity *Identity) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	for _, existing := range s.DB.identities {
+		if existing.Provider != identity.Provider {
+			continue
+		}
+		if existing.Subject == identity.Subject || existing.UserID == identity.UserID {
+			return ErrIdentityExists
+		}
+	}
+
+	s.DB.identities[identity.ID] = *identity
+	return nil
+}
+
+// GetBySubject gets the identity of a provider account
+func (s *MemoryIdentityStore) GetBySubject(provider string, subject string) (*Identity, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	for _, identity := range s.DB.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+	return nil, ErrIdentityNotFound
+}
+
+// GetByUser gets all identities linked to a user
+func (s *MemoryIdentityStore) GetByUser(userID string) ([]*Identity, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	identities := []*Identity{}
+	for _, identity := range s.DB.identities {
+		if identity.UserID == userID {
+			identity := identity
+			identities = append(identities, &identity)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool {
+		return identities[i].CreatedAt.Before(identities[j].CreatedAt)
+	})
+	return identities, nil
+}
+
+// Touch records a login with an identity and the email address the provider reported
+func (s *MemoryIdentityStore) Touch(id string, email string, loginAt time.Time) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	identity, ok := s.DB.identities[id]
+	if ok {
+		identity.Email = email
+		identity.LastLoginAt = &loginAt
+		s.DB.identities[id] = identity
+	}
+	return nil
+}
+
+// Delete unlinks an identity of a user
+func (s *MemoryIdentityStore) Delete(userID string, id string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	identity, ok := s.DB.identities[id]
+	if !ok || identity.UserID != userID {
+		return ErrIdentityNotFound
+	}
+	delete(s.DB.identities, id)
+	return nil
+}
+
+// MemoryOIDCFlowStore is an in-memory implementation of OIDCFlowRepository
+type MemoryOIDCFlowStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryOIDCFlowStore creates a new MemoryOIDCFlowStore
+func NewMemoryOIDCFlowStore(db *MemoryDB) *MemoryOIDCFlowStore {
+	return &MemoryOIDCFlowStore{DB: db}
+}
+
+// Create stores a new flow and removes expired ones
+func (s *MemoryOIDCFlowStore) Create(flow *OIDCFlow) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	now := time.Now()
+	for hash, existing := range s.DB.oidcFlows {
+		if now.After(existing.ExpiresAt) {
+			delete(s.DB.oidcFlows, hash)
+		}
+	}
+
+	s.DB.oidcFlows[flow.StateHash] = *flow
+	return nil
+}
+
+// Consume removes the flow with the given state hash and returns it, or
+// returns ErrOIDCFlowInvalid
+func (s *MemoryOIDCFlowStore) Consume(stateHash string) (*OIDCFlow, error) {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	flow, ok := s.DB.oidcFlows[stateHash]
+	if !ok {
+		return nil, ErrOIDCFlowInvalid
+	}
+	delete(s.DB.oidcFlows, stateHash)
+
+	if time.Now().After(flow.ExpiresAt) {
+		return nil, ErrOIDCFlowInvalid
+	}
+	return &flow, nil
+}
@@ -0,0 +1,254 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-react-redux-app/database"
+)
+
+var (
+	// ErrLabelNotFound is returned when a label does not exist
+	ErrLabelNotFound = errors.New("label not found")
+	// ErrLabelExists is returned when a project already has a label with the same name, ignoring case
+	ErrLabelExists = errors.New("label already exists")
+)
+
+// Label is a named, colored tag defined in a project and assigned to its tasks
+type Label struct {
+	ID        string    `json:"id"`
+	ProjectID string    `json:"projectId"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// LabelStore handles database operations for labels and their assignment to tasks
+type LabelStore struct {
+	DB *database.DB
+}
+
+// NewLabelStore creates a new LabelStore
+func NewLabelStore(db *database.DB) *LabelStore {
+	return &LabelStore{DB: db}
+}
+
+// labelColumns lists the labels columns in the order scanLabel reads them
+const labelColumns = `labels.id, labels.project_id, labels.name, labels.color, labels.created_at`
+
+// scanLabel scans a row selected with labelColumns into a Label
+func scanLabel(row rowScanner) (*Label, error) {
+	label := &Label{}
+	err := row.Scan(&label.ID, &label.ProjectID, &label.Name, &label.Color, &label.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return label, nil
+}
+
+// Create creates a label. It returns ErrLabelExists if the project already
+// has a label with the same name.
+func (s *LabelStore) Create(label *Label) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkLabelName(tx, label); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO labels (id, project_id, name, color, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		label.ID, label.ProjectID, label.Name, label.Color, label.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkLabelName returns ErrLabelExists if another label of the project has the label's name
+func checkLabelName(tx *database.Tx, label *Label) error {
+	var exists bool
+	err := tx.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM labels WHERE project_id = $1 AND LOWER(name) = LOWER($2) AND id <> $3)`,
+		label.ProjectID, label.Name, label.ID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrLabelExists
+	}
+	return nil
+}
+
+// GetByID gets a label by ID
+func (s *LabelStore) GetByID(id string) (*Label, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	label, err := scanLabel(s.DB.QueryRow(`SELECT `+labelColumns+` FROM labels WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrLabelNotFound
+	}
+	return label, err
+}
+
+// GetByProject gets the labels of a project, ordered by name
+func (s *LabelStore) GetByProject(projectID string) ([]Label, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	rows, err := s.DB.Query(`SELECT `+labelColumns+` FROM labels WHERE project_id = $1 ORDER BY LOWER(name)`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labels := []Label{}
+	for rows.Next() {
+		label, err := scanLabel(rows)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, *label)
+	}
+
+	return labels, rows.Err()
+}
+
+// Update renames or recolors a label. It returns ErrLabelExists if the
+// project already has another label with the new name.
+func (s *LabelStore) Update(label *Label) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkLabelName(tx, label); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`UPDATE labels SET name = $1, color = $2 WHERE id = $3`, label.Name, label.Color, label.ID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result, ErrLabelNotFound); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete deletes a label, removing it from every task
+func (s *LabelStore) Delete(id string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	result, err := s.DB.Exec(`DELETE FROM labels WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, ErrLabelNotFound)
+}
+
+// AddToTask assigns a label to a task. Assigning a label twice is not an error.
+func (s *LabelStore) AddToTask(taskID string, labelID string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	_, err := s.DB.Exec(`INSERT INTO task_labels (task_id, label_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, taskID, labelID)
+	return err
+}
+
+// RemoveFromTask takes a label off a task. It returns ErrLabelNotFound if the
+// task does not have the label.
+func (s *LabelStore) RemoveFromTask(taskID string, labelID string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	result, err := s.DB.Exec(`DELETE FROM task_labels WHERE task_id = $1 AND label_id = $2`, taskID, labelID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, ErrLabelNotFound)
+}
+
+// SetTaskLabels replaces the labels of a task
+func (s *LabelStore) SetTaskLabels(taskID string, labelIDs []string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM task_labels WHERE task_id = $1`, taskID); err != nil {
+		return err
+	}
+	for _, labelID := range labelIDs {
+		_, err := tx.Exec(`INSERT INTO task_labels (task_id, label_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, taskID, labelID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// loadTaskLabels fills in the labels of the tasks, ordered by name
+func loadTaskLabels(db *database.DB, tasks []Task) error {
+	byID := make(map[string]*Task, len(tasks))
+	for i := range tasks {
+		tasks[i].Labels = []Label{}
+		byID[tasks[i].ID] = &tasks[i]
+	}
+
+	return inTaskBatches(tasks, func(in string, args []interface{}) error {
+		query := `
+		SELECT task_labels.task_id, ` + labelColumns + `
+		FROM task_labels
+		JOIN labels ON labels.id = task_labels.label_id
+		WHERE task_labels.task_id IN (` + in + `)
+		ORDER BY LOWER(labels.name)`
+
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var taskID string
+			var label Label
+			if err := rows.Scan(&taskID, &label.ID, &label.ProjectID, &label.Name, &label.Color, &label.CreatedAt); err != nil {
+				return err
+			}
+			task := byID[taskID]
+			task.Labels = append(task.Labels, label)
+		}
+		return rows.Err()
+	})
+}
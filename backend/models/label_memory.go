@@ -0,0 +1,182 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// MemoryLabelStore is an in-memory implementation of LabelRepository
+type MemoryLabelStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryLabelStore creates a new MemoryLabelStore
+func NewMemoryLabelStore(db *MemoryDB) *MemoryLabelStore {
+	return &MemoryLabelStore{DB: db}
+}
+
+// Create creates a label. It returns ErrLabelExists if the project already
+// has a label with the same name.
+func (s *MemoryLabelStore) Create(label *Label) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.projects[label.ProjectID]; !ok {
+		return errors.New("project does not exist")
+	}
+	if s.DB.labelNameTakenLocked(label) {
+		return ErrLabelExists
+	}
+
+	s.DB.labels[label.ID] = *label
+	return nil
+}
+
+// labelNameTakenLocked checks if another label of the project has the label's name. The caller must hold the lock.
+func (db *MemoryDB) labelNameTakenLocked(label *Label) bool {
+	for _, other := range db.labels {
+		if other.ProjectID == label.ProjectID && other.ID != label.ID && strings.EqualFold(other.Name, label.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetByID gets a label by ID
+func (s *MemoryLabelStore) GetByID(id string) (*Label, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	label, ok := s.DB.labels[id]
+	if !ok {
+		return nil, ErrLabelNotFound
+	}
+	return &label, nil
+}
+
+// GetByProject gets the labels of a project, ordered by name
+func (s *MemoryLabelStore) GetByProject(projectID string) ([]Label, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	labels := []Label{}
+	for _, label := range s.DB.labels {
+		if label.ProjectID == projectID {
+			labels = append(labels, label)
+		}
+	}
+	sortLabelsByName(labels)
+	return labels, nil
+}
+
+// Update renames or recolors a label. It returns ErrLabelExists if the
+// project already has another label with the new name.
+func (s *MemoryLabelStore) Update(label *Label) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	stored, ok := s.DB.labels[label.ID]
+	if !ok {
+		return ErrLabelNotFound
+	}
+	if s.DB.labelNameTakenLocked(label) {
+		return ErrLabelExists
+	}
+
+	stored.Name = label.Name
+	stored.Color = label.Color
+	s.DB.labels[label.ID] = stored
+	return nil
+}
+
+// Delete deletes a label, removing it from every task
+func (s *MemoryLabelStore) Delete(id string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.labels[id]; !ok {
+		return ErrLabelNotFound
+	}
+	s.DB.deleteLabelLocked(id)
+	return nil
+}
+
+// deleteLabelLocked removes a label and its assignments. The caller must hold the write lock.
+func (db *MemoryDB) deleteLabelLocked(id string) {
+	delete(db.labels, id)
+	for _, labelIDs := range db.taskLabels {
+		delete(labelIDs, id)
+	}
+}
+
+// AddToTask assigns a label to a task. Assigning a label twice is not an error.
+func (s *MemoryLabelStore) AddToTask(taskID string, labelID string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.tasks[taskID]; !ok {
+		return errors.New("task does not exist")
+	}
+	if _, ok := s.DB.labels[labelID]; !ok {
+		return errors.New("label does not exist")
+	}
+
+	if s.DB.taskLabels[taskID] == nil {
+		s.DB.taskLabels[taskID] = make(map[string]bool)
+	}
+	s.DB.taskLabels[taskID][labelID] = true
+	return nil
+}
+
+// RemoveFromTask takes a label off a task. It returns ErrLabelNotFound if the
+// task does not have the label.
+func (s *MemoryLabelStore) RemoveFromTask(taskID string, labelID string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if !s.DB.taskLabels[taskID][labelID] {
+		return ErrLabelNotFound
+	}
+	delete(s.DB.taskLabels[taskID], labelID)
+	return nil
+}
+
+// SetTaskLabels replaces the labels of a task
+func (s *MemoryLabelStore) SetTaskLabels(taskID string, labelIDs []string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.tasks[taskID]; !ok {
+		return errors.New("task does not exist")
+	}
+	for _, labelID := range labelIDs {
+		if _, ok := s.DB.labels[labelID]; !ok {
+			return errors.New("label does not exist")
+		}
+	}
+
+	assigned := make(map[string]bool, len(labelIDs))
+	for _, labelID := range labelIDs {
+		assigned[labelID] = true
+	}
+	s.DB.taskLabels[taskID] = assigned
+	return nil
+}
+
+// taskLabelsLocked returns copies of the labels of a task, ordered by name. The caller must hold the lock.
+func (db *MemoryDB) taskLabelsLocked(taskID string) []Label {
+	labels := []Label{}
+	for labelID := range db.taskLabels[taskID] {
+		labels = append(labels, db.labels[labelID])
+	}
+	sortLabelsByName(labels)
+	return labels
+}
+
+// sortLabelsByName sorts labels by name, ignoring case, like the SQL queries
+func sortLabelsByName(labels []Label) {
+	sort.Slice(labels, func(i, j int) bool {
+		return strings.ToLower(labels[i].Name) < strings.ToLower(labels[j].Name)
+	})
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-react-redux-app/database"
+)
+
+// Outcomes of a login attempt
+const (
+	// LoginSucceeded is a login with the correct password
+	LoginSucceeded = "success"
+	// LoginChallenged is a login with the correct password still waiting for its second factor
+	LoginChallenged = "challenged"
+	// LoginFailed is a login with an unknown username or a wrong password
+	LoginFailed = "failure"
+	// LoginBlocked is a login refused without checking the password because of too many failures
+	LoginBlocked = "blocked"
+	// LoginRefused is a login with the correct password to a disabled or unverified account
+	LoginRefused = "refused"
+	// LoginUnlocked is not a login but an admin clearing the failures of an account
+	LoginUnlocked = "unlocked"
+)
+
+// LoginAttempt is an entry in the login history
+type LoginAttempt struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	UserID    string    `json:"userId,omitempty"`
+	IP        string    `json:"ip"`
+	Outcome   string    `json:"outcome"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Failures summarizes the recent failed logins of an account or IP address
+type Failures struct {
+	Count int
+	Last  time.Time
+}
+
+// LoginAttemptStore handles database operations for the login history
+type LoginAttemptStore struct {
+	DB *database.DB
+}
+
+// NewLoginAttemptStore creates a new LoginAttemptStore
+func NewLoginAttemptStore(db *database.DB) *LoginAttemptStore {
+	return &LoginAttemptStore{DB: db}
+}
+
+// Record adds an attempt to the history
+func (s *LoginAttemptStore) Record(attempt *LoginAttempt) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `
+	INSERT INTO login_attempts (id, user_id, username, ip, outcome, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+
+	var userID interface{}
+	if attempt.UserID != "" {
+		userID = attempt.UserID
+	}
+
+	_, err := s.DB.Exec(query, attempt.ID, userID, attempt.Username, attempt.IP, attempt.Outcome, attempt.CreatedAt)
+	return err
+}
+
+// AccountFailures counts the failed logins for a username since the given
+// time, ignoring failures before the last successful login or unlock
+func (s *LoginAttemptStore) AccountFailures(username string, since time.Time) (Failures, error) {
+	if s.DB == nil {
+		return Failures{}, errors.New("database connection is nil")
+	}
+
+	var lastReset time.Time
+	err := s.DB.QueryRow(
+		`SELECT created_at FROM login_attempts
+		WHERE username = $1 AND outcome IN ($2, $3) AND created_at > $4
+		ORDER BY created_at DESC LIMIT 1`,
+		username, LoginSucceeded, LoginUnlocked, since,
+	).Scan(&lastReset)
+	if err == nil {
+		since = lastReset
+	} else if err != sql.ErrNoRows {
+		return Failures{}, err
+	}
+
+	return s.failures(`username = $1`, username, since)
+}
+
+// IPFailures counts the failed logins from an IP address since the given time
+func (s *LoginAttemptStore) IPFailures(ip string, since time.Time) (Failures, error) {
+	if s.DB == nil {
+		return Failures{}, errors.New("database connection is nil")
+	}
+
+	return s.failures(`ip = $1`, ip, since)
+}
+
+// failures counts the failed logins matching a condition on one column. The
+// latest one is selected separately rather than with MAX, whose result SQLite
+// returns as text instead of a timestamp.
+func (s *LoginAttemptStore) failures(condition string, value string, since time.Time) (Failures, error) {
+	where := ` FROM login_attempts WHERE ` + condition + ` AND outcome = $2 AND created_at > $3`
+
+	var failures Failures
+	err := s.DB.QueryRow(`SELECT COUNT(*)`+where, value, LoginFailed, since).Scan(&failures.Count)
+	if err != nil || failures.Count == 0 {
+		return failures, err
+	}
+
+	err = s.DB.QueryRow(`SELECT created_at`+where+` ORDER BY created_at DESC LIMIT 1`, value, LoginFailed, since).Scan(&failures.Last)
+	if err != nil {
+		return Failures{}, err
+	}
+	return failures, nil
+}
+
+// GetByUsername gets the most recent attempts for a username, newest first
+func (s *LoginAttemptStore) GetByUsername(username string, limit int) ([]*LoginAttempt, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	query := `
+	SELECT id, username, user_id, ip, outcome, created_at
+	FROM login_attempts
+	WHERE username = $1
+	ORDER BY created_at DESC
+	LIMIT $2`
+
+	rows, err := s.DB.Query(query, username, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []*LoginAttempt{}
+	for rows.Next() {
+		attempt := &LoginAttempt{}
+		var userID sql.NullString
+		err := rows.Scan(
+			&attempt.ID,
+			&attempt.Username,
+			&userID,
+			&attempt.IP,
+			&attempt.Outcome,
+			&attempt.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		attempt.UserID = userID.String
+		attempts = append(attempts, attempt)
+	}
+
+	return attempts, rows.Err()
+}
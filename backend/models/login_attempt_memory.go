@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+)
+
+// maxMemoryLoginAttempts bounds the in-memory login history; the oldest entries are dropped first
+const maxMemoryLoginAttempts = 10000
+
+// MemoryLoginAttemptStore is an in-memory implementation of LoginAttemptRepository
+type MemoryLoginAttemptStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryLoginAttemptStore creates a new MemoryLoginAttemptStore
+func NewMemoryLoginAttemptStore(db *MemoryDB) *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{DB: db}
+}
+
+// Record adds an attempt to the history
+func (s *MemoryLoginAttemptStore) Record(attempt *LoginAttempt) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	s.DB.loginAttempts = append(s.DB.loginAttempts, *attempt)
+	if len(s.DB.loginAttempts) > maxMemoryLoginAttempts {
+		s.DB.loginAttempts = append([]LoginAttempt(nil), s.DB.loginAttempts[len(s.DB.loginAttempts)-maxMemoryLoginAttempts:]...)
+	}
+	return nil
+}
+
+// AccountFailures counts the failed logins for a username since the given
+// time, ignoring failures before the last successful login or unlock
+func (s *MemoryLoginAttemptStore) AccountFailures(username string, since time.Time) (Failures, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	var failures Failures
+	for _, attempt := range s.DB.loginAttempts {
+		if attempt.Username != username || !attempt.CreatedAt.After(since) {
+			continue
+		}
+		switch attempt.Outcome {
+		case LoginSucceeded, LoginUnlocked:
+			failures = Failures{}
+		case LoginFailed:
+			failures.Count++
+			failures.Last = attempt.CreatedAt
+		}
+	}
+	return failures, nil
+}
+
+// IPFailures counts the failed logins from an IP address since the given time
+func (s *MemoryLoginAttemptStore) IPFailures(ip string, since time.Time) (Failures, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	var failures Failures
+	for _, attempt := range s.DB.loginAttempts {
+		if attempt.IP == ip && attempt.Outcome == LoginFailed && attempt.CreatedAt.After(since) {
+			failures.Count++
+			failures.Last = attempt.CreatedAt
+		}
+	}
+	return failures, nil
+}
+
+// GetByUsername gets the most recent attempts for a username, newest first
+func (s *MemoryLoginAttemptStore) GetByUsername(username string, limit int) ([]*LoginAttempt, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	attempts := []*LoginAttempt{}
+	for i := len(s.DB.loginAttempts) - 1; i >= 0 && len(attempts) < limit; i-- {
+		if s.DB.loginAttempts[i].Username == username {
+			attempt := s.DB.loginAttempts[i]
+			attempts = append(attempts, &attempt)
+		}
+	}
+	return attempts, nil
+}
@@ -0,0 +1,288 @@
+package models
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryDB is a thread-safe in-memory database shared by the in-memory stores.
+// It mirrors the relational constraints of the SQL schema (unique usernames,
+// cascading deletes) so the application behaves the same without Postgres.
+type MemoryDB struct {
+	mu       sync.RWMutex
+	users    map[string]User
+	projects map[string]Project
+	members  map[string]map[string]ProjectMember
+	tasks    map[string]Task
+	// dependencies is keyed by blocker and blocked task ID
+	dependencies map[dependencyKey]TaskDependency
+	sessions     map[string]Session
+	refresh      map[string]RefreshToken
+
+	comments map[string]Comment
+	// mentions holds when each user was mentioned, keyed by comment and user ID
+	mentions map[mentionKey]time.Time
+
+	labels map[string]Label
+	// taskLabels holds the IDs of the labels assigned to each task, keyed by task ID
+	taskLabels map[string]map[string]bool
+
+	fields      map[string]CustomField
+	fieldValues map[fieldValueKey]FieldValue
+
+	// workflows holds the workflows projects defined, keyed by project ID
+	workflows map[string]Workflow
+
+	recurrences map[string]Recurrence
+
+	worklogs map[string]Worklog
+	// timers holds the running timers, keyed by user ID
+	timers map[string]Timer
+
+	attachments map[string]Attachment
+	// blobs holds when each blob was first stored, keyed by hash
+	blobs map[string]time.Time
+
+	userTokens map[string]UserToken
+	apiTokens  map[string]APIToken
+
+	twoFactors    map[string]TwoFactor
+	recoveryCodes map[string][]RecoveryCode
+
+	identities map[string]Identity
+	oidcFlows  map[string]OIDCFlow
+
+	// loginAttempts is kept in insertion order, oldest first
+	loginAttempts []LoginAttempt
+}
+
+// NewMemoryDB creates a new empty MemoryDB
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		users:    make(map[string]User),
+		projects: make(map[string]Project),
+		members:  make(map[string]map[string]ProjectMember),
+		tasks:    make(map[string]Task),
+
+		dependencies: make(map[dependencyKey]TaskDependency),
+		sessions:     make(map[string]Session),
+		refresh:      make(map[string]RefreshToken),
+
+		comments: make(map[string]Comment),
+		mentions: make(map[mentionKey]time.Time),
+
+		labels:     make(map[string]Label),
+		taskLabels: make(map[string]map[string]bool),
+
+		fields:      make(map[string]CustomField),
+		fieldValues: make(map[fieldValueKey]FieldValue),
+
+		workflows: make(map[string]Workflow),
+
+		recurrences: make(map[string]Recurrence),
+
+		worklogs: make(map[string]Worklog),
+		timers:   make(map[string]Timer),
+
+		attachments: make(map[string]Attachment),
+		blobs:       make(map[string]time.Time),
+
+		userTokens: make(map[string]UserToken),
+		apiTokens:  make(map[string]APIToken),
+
+		twoFactors:    make(map[string]TwoFactor),
+		recoveryCodes: make(map[string][]RecoveryCode),
+
+		identities: make(map[string]Identity),
+		oidcFlows:  make(map[string]OIDCFlow),
+	}
+}
+
+// deleteProjectLocked removes a project with its members, tasks, labels,
+// custom fields, workflow and recurrences. The caller must hold the write lock.
+func (db *MemoryDB) deleteProjectLocked(id string) {
+	delete(db.projects, id)
+	delete(db.members, id)
+	for taskID, task := range db.tasks {
+		if task.ProjectID == id {
+			db.deleteTaskLocked(taskID)
+		}
+	}
+	for labelID, label := range db.labels {
+		if label.ProjectID == id {
+			db.deleteLabelLocked(labelID)
+		}
+	}
+	for fieldID, field := range db.fields {
+		if field.ProjectID == id {
+			db.deleteFieldLocked(fieldID)
+		}
+	}
+	delete(db.workflows, id)
+	for recurrenceID, rec := range db.recurrences {
+		if rec.ProjectID == id {
+			db.deleteRecurrenceLocked(recurrenceID)
+		}
+	}
+}
+
+// deleteTaskLocked removes a task with its dependencies, labels, custom field
+// values, comments, attachments, worklogs and timers. Their blobs are left for DeleteBlob and
+// subtasks to the caller. The caller must hold the write lock.
+func (db *MemoryDB) deleteTaskLocked(id string) {
+	delete(db.tasks, id)
+	delete(db.taskLabels, id)
+	for key := range db.fieldValues {
+		if key.taskID == id {
+			delete(db.fieldValues, key)
+		}
+	}
+	for key := range db.dependencies {
+		if key.blockerID == id || key.blockedID == id {
+			delete(db.dependencies, key)
+		}
+	}
+	for commentID, comment := range db.comments {
+		if comment.TaskID == id {
+			db.deleteCommentLocked(commentID)
+		}
+	}
+	for attachmentID, attachment := range db.attachments {
+		if attachment.TaskID == id {
+			delete(db.attachments, attachmentID)
+		}
+	}
+	for worklogID, worklog := range db.worklogs {
+		if worklog.TaskID == id {
+			delete(db.worklogs, worklogID)
+		}
+	}
+	for userID, timer := range db.timers {
+		if timer.TaskID == id {
+			delete(db.timers, userID)
+		}
+	}
+}
+
+// deleteUserLocked removes a user, cascading to owned projects and clearing
+// task assignments. The caller must hold the write lock.
+func (db *MemoryDB) deleteUserLocked(id string) {
+	delete(db.users, id)
+	for _, members := range db.members {
+		delete(members, id)
+	}
+	for sessionID, session := range db.sessions {
+		if session.UserID == id {
+			db.deleteSessionLocked(sessionID)
+		}
+	}
+	for tokenID, token := range db.userTokens {
+		if token.UserID == id {
+			delete(db.userTokens, tokenID)
+		}
+	}
+	for tokenID, token := range db.apiTokens {
+		if token.UserID == id {
+			delete(db.apiTokens, tokenID)
+		}
+	}
+	delete(db.twoFactors, id)
+	delete(db.recoveryCodes, id)
+	for identityID, identity := range db.identities {
+		if identity.UserID == id {
+			delete(db.identities, identityID)
+		}
+	}
+	for hash, flow := range db.oidcFlows {
+		if flow.UserID == id {
+			delete(db.oidcFlows, hash)
+		}
+	}
+	for i := range db.loginAttempts {
+		if db.loginAttempts[i].UserID == id {
+			db.loginAttempts[i].UserID = ""
+		}
+	}
+	for projectID, project := range db.projects {
+		if project.OwnerID == id {
+			db.deleteProjectLocked(projectID)
+		}
+	}
+	for taskID, task := range db.tasks {
+		if task.AssigneeID == id {
+			task.AssigneeID = ""
+			db.tasks[taskID] = task
+		}
+	}
+	for key, dependency := range db.dependencies {
+		if dependency.CreatedBy == id {
+			dependency.CreatedBy = ""
+			db.dependencies[key] = dependency
+		}
+	}
+	for commentID, comment := range db.comments {
+		if comment.AuthorID == id {
+			comment.AuthorID = ""
+			db.comments[commentID] = comment
+		}
+	}
+	for key := range db.mentions {
+		if key.userID == id {
+			delete(db.mentions, key)
+		}
+	}
+	for key, value := range db.fieldValues {
+		if value.UserID == id {
+			delete(db.fieldValues, key)
+		}
+	}
+	for attachmentID, attachment := range db.attachments {
+		if attachment.UploadedBy == id {
+			attachment.UploadedBy = ""
+			db.attachments[attachmentID] = attachment
+		}
+	}
+	for worklogID, worklog := range db.worklogs {
+		if worklog.UserID == id {
+			worklog.UserID = ""
+			db.worklogs[worklogID] = worklog
+		}
+	}
+	delete(db.timers, id)
+	for recurrenceID, rec := range db.recurrences {
+		if rec.AssigneeID == id || rec.CreatedBy == id {
+			if rec.AssigneeID == id {
+				rec.AssigneeID = ""
+			}
+			if rec.CreatedBy == id {
+				rec.CreatedBy = ""
+			}
+			db.recurrences[recurrenceID] = rec
+		}
+	}
+}
+
+// deleteSessionLocked removes a session and its refresh tokens. The caller must hold the write lock.
+func (db *MemoryDB) deleteSessionLocked(id string) {
+	delete(db.sessions, id)
+	for tokenID, token := range db.refresh {
+		if token.SessionID == id {
+			delete(db.refresh, tokenID)
+		}
+	}
+}
+
+// sortProjectsByCreatedDesc sorts projects newest first, like the SQL queries
+func sortProjectsByCreatedDesc(projects []*Project) {
+	sort.SliceStable(projects, func(i, j int) bool {
+		return projects[i].CreatedAt.After(projects[j].CreatedAt)
+	})
+}
+
+// sortTasksByCreatedDesc sorts tasks newest first, like the SQL queries
+func sortTasksByCreatedDesc(tasks []Task) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].CreatedAt.After(tasks[j].CreatedAt)
+	})
+}
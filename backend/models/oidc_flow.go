@@ -0,0 +1,104 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-react-redux-app/database"
+)
+
+// OIDCFlow is a login at an identity provider that has not returned yet
+type OIDCFlow struct {
+	// StateHash is the SHA-256 hash of the state parameter sent to the provider
+	StateHash    string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	// UserID is set when a logged in user is linking the provider account
+	// rather than logging in with it
+	UserID    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// OIDCFlowStore handles database operations for OIDC flows
+type OIDCFlowStore struct {
+	DB *database.DB
+}
+
+// NewOIDCFlowStore creates a new OIDCFlowStore
+func NewOIDCFlowStore(db *database.DB) *OIDCFlowStore {
+	return &OIDCFlowStore{DB: db}
+}
+
+// Create stores a new flow and removes expired ones
+func (s *OIDCFlowStore) Create(flow *OIDCFlow) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	_, err := s.DB.Exec(`DELETE FROM oidc_flows WHERE expires_at < $1`, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var userID interface{}
+	if flow.UserID != "" {
+		userID = flow.UserID
+	}
+
+	query := `
+	INSERT INTO oidc_flows (state_hash, provider, nonce, code_verifier, user_id, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err = s.DB.Exec(query, flow.StateHash, flow.Provider, flow.Nonce, flow.CodeVerifier, userID, flow.CreatedAt, flow.ExpiresAt)
+	return err
+}
+
+// Consume removes the flow with the given state hash and returns it. It
+// returns ErrOIDCFlowInvalid if there is no such flow or it has expired, so
+// each flow can be completed exactly once.
+func (s *OIDCFlowStore) Consume(stateHash string) (*OIDCFlow, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	query := `
+	SELECT state_hash, provider, nonce, code_verifier, user_id, created_at, expires_at
+	FROM oidc_flows
+	WHERE state_hash = $1`
+
+	flow := &OIDCFlow{}
+	var userID sql.NullString
+	err := s.DB.QueryRow(query, stateHash).Scan(
+		&flow.StateHash,
+		&flow.Provider,
+		&flow.Nonce,
+		&flow.CodeVerifier,
+		&userID,
+		&flow.CreatedAt,
+		&flow.ExpiresAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOIDCFlowInvalid
+		}
+		return nil, err
+	}
+	flow.UserID = userID.String
+
+	// Only the request that deletes the row may complete the flow
+	result, err := s.DB.Exec(`DELETE FROM oidc_flows WHERE state_hash = $1`, stateHash)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireAffected(result, ErrOIDCFlowInvalid); err != nil {
+		return nil, err
+	}
+
+	if time.Now().After(flow.ExpiresAt) {
+		return nil, ErrOIDCFlowInvalid
+	}
+	return flow, nil
+}
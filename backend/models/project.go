@@ -1,9 +1,10 @@
 package models
 
 import (
-	"database/sql"
 	"errors"
 	"time"
+
+	"go-react-redux-app/database"
 )
 
 // Project represents a project in the system
@@ -19,52 +20,31 @@ type Project struct {
 
 // ProjectStore handles database operations for projects
 type ProjectStore struct {
-	DB *sql.DB
+	DB *database.DB
 }
 
 // NewProjectStore creates a new ProjectStore
-func NewProjectStore(db *sql.DB) *ProjectStore {
+func NewProjectStore(db *database.DB) *ProjectStore {
 	return &ProjectStore{DB: db}
 }
 
-// CreateTables creates the necessary tables for projects
-func (s *ProjectStore) CreateTables() error {
+// Create creates a new project
+func (s *ProjectStore) Create(project *Project) error {
 	if s.DB == nil {
 		return errors.New("database connection is nil")
 	}
 
-	// Create projects table
-	projectsQuery := `
-	CREATE TABLE IF NOT EXISTS projects (
-		id VARCHAR(36) PRIMARY KEY,
-		name VARCHAR(100) NOT NULL,
-		description TEXT,
-		status VARCHAR(20) NOT NULL DEFAULT 'active',
-		owner_id VARCHAR(36) NOT NULL,
-		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
-	)`
-
-	_, err := s.DB.Exec(projectsQuery)
+	tx, err := s.DB.Begin()
 	if err != nil {
 		return err
 	}
-
-	return err
-}
-
-// Create creates a new project
-func (s *ProjectStore) Create(project *Project) error {
-	if s.DB == nil {
-		return errors.New("database connection is nil")
-	}
+	defer tx.Rollback()
 
 	query := `
 	INSERT INTO projects (id, name, description, status, owner_id, created_at, updated_at)
 	VALUES ($1, $2, $3, $4, $5, $6, $7)`
 
-	_, err := s.DB.Exec(
+	_, err = tx.Exec(
 		query,
 		project.ID,
 		project.Name,
@@ -74,8 +54,21 @@ func (s *ProjectStore) Create(project *Project) error {
 		project.CreatedAt,
 		project.UpdatedAt,
 	)
+	if err != nil {
+		return err
+	}
 
-	return err
+	// The creator is always an owner member of the project
+	memberQuery := `
+	INSERT INTO project_members (project_id, user_id, role, created_at)
+	VALUES ($1, $2, $3, $4)`
+
+	_, err = tx.Exec(memberQuery, project.ID, project.OwnerID, ProjectRoleOwner, project.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // GetByID gets a project by ID
@@ -184,12 +177,17 @@ func (s *ProjectStore) GetByOwner(ownerID string) ([]*Project, error) {
 	return projects, nil
 }
 
-// GetByUser gets all projects a user has access to
+// GetByUser gets all projects a user has access to, either as owner or as a member
 func (s *ProjectStore) GetByUser(userID string) ([]*Project, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
 	query := `
 		SELECT id, name, description, status, owner_id, created_at, updated_at
 		FROM projects
 		WHERE owner_id = $1
+		OR id IN (SELECT project_id FROM project_members WHERE user_id = $1)
 		ORDER BY created_at DESC
 	`
 	rows, err := s.DB.Query(query, userID)
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Project member roles, from most to least privileged
+const (
+	ProjectRoleOwner  = "owner"
+	ProjectRoleEditor = "editor"
+	ProjectRoleViewer = "viewer"
+)
+
+// ProjectMember represents a user's membership in a project
+type ProjectMember struct {
+	ProjectID string    `json:"projectId"`
+	UserID    string    `json:"userId"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	FirstName string    `json:"firstName"`
+	LastName  string    `json:"lastName"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// IsValidProjectRole checks if the role is a known project member role
+func IsValidProjectRole(role string) bool {
+	switch role {
+	case ProjectRoleOwner, ProjectRoleEditor, ProjectRoleViewer:
+		return true
+	}
+	return false
+}
+
+// AddMember adds a user to a project with the given role
+func (s *ProjectStore) AddMember(member *ProjectMember) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `
+	INSERT INTO project_members (project_id, user_id, role, created_at)
+	VALUES ($1, $2, $3, $4)`
+
+	_, err := s.DB.Exec(query, member.ProjectID, member.UserID, member.Role, member.CreatedAt)
+	return err
+}
+
+// GetMembers gets all members of a project with their user details
+func (s *ProjectStore) GetMembers(projectID string) ([]*ProjectMember, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	query := `
+	SELECT m.project_id, m.user_id, u.username, u.email, u.first_name, u.last_name, m.role, m.created_at
+	FROM project_members m
+	JOIN users u ON u.id = m.user_id
+	WHERE m.project_id = $1
+	ORDER BY m.created_at ASC`
+
+	rows, err := s.DB.Query(query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*ProjectMember{}
+	for rows.Next() {
+		member := &ProjectMember{}
+		var firstName, lastName sql.NullString
+		err := rows.Scan(
+			&member.ProjectID,
+			&member.UserID,
+			&member.Username,
+			&member.Email,
+			&firstName,
+			&lastName,
+			&member.Role,
+			&member.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		member.FirstName = firstName.String
+		member.LastName = lastName.String
+		members = append(members, member)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// GetMemberRole gets a user's role in a project, or ErrMemberNotFound if they are not a member
+func (s *ProjectStore) GetMemberRole(projectID, userID string) (string, error) {
+	if s.DB == nil {
+		return "", errors.New("database connection is nil")
+	}
+
+	query := `SELECT role FROM project_members WHERE project_id = $1 AND user_id = $2`
+
+	var role string
+	err := s.DB.QueryRow(query, projectID, userID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrMemberNotFound
+		}
+		return "", err
+	}
+
+	return role, nil
+}
+
+// UpdateMemberRole changes a member's role in a project
+func (s *ProjectStore) UpdateMemberRole(projectID, userID, role string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `UPDATE project_members SET role = $1 WHERE project_id = $2 AND user_id = $3`
+
+	result, err := s.DB.Exec(query, role, projectID, userID)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(result, ErrMemberNotFound)
+}
+
+// RemoveMember removes a user from a project
+func (s *ProjectStore) RemoveMember(projectID, userID string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `DELETE FROM project_members WHERE project_id = $1 AND user_id = $2`
+
+	result, err := s.DB.Exec(query, projectID, userID)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(result, ErrMemberNotFound)
+}
+
+// requireAffected returns notFound if the statement did not change any rows
+func requireAffected(result sql.Result, notFound error) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return notFound
+	}
+	return nil
+}
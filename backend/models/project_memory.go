@@ -0,0 +1,202 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+)
+
+// MemoryProjectStore is an in-memory implementation of ProjectRepository
+type MemoryProjectStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryProjectStore creates a new MemoryProjectStore
+func NewMemoryProjectStore(db *MemoryDB) *MemoryProjectStore {
+	return &MemoryProjectStore{DB: db}
+}
+
+// Create creates a new project
+func (s *MemoryProjectStore) Create(project *Project) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, exists := s.DB.projects[project.ID]; exists {
+		return errors.New("project already exists")
+	}
+	if _, ok := s.DB.users[project.OwnerID]; !ok {
+		return errors.New("project owner does not exist")
+	}
+
+	s.DB.projects[project.ID] = *project
+
+	// The creator is always an owner member of the project
+	s.DB.members[project.ID] = map[string]ProjectMember{
+		project.OwnerID: {
+			ProjectID: project.ID,
+			UserID:    project.OwnerID,
+			Role:      ProjectRoleOwner,
+			CreatedAt: project.CreatedAt,
+		},
+	}
+	return nil
+}
+
+// GetByID gets a project by ID
+func (s *MemoryProjectStore) GetByID(id string) (*Project, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	project, ok := s.DB.projects[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &project, nil
+}
+
+// GetAll gets all projects
+func (s *MemoryProjectStore) GetAll() ([]*Project, error) {
+	return s.filter(func(*Project) bool { return true }), nil
+}
+
+// GetByOwner gets all projects owned by a user
+func (s *MemoryProjectStore) GetByOwner(ownerID string) ([]*Project, error) {
+	return s.filter(func(p *Project) bool { return p.OwnerID == ownerID }), nil
+}
+
+// GetByUser gets all projects a user has access to, either as owner or as a member
+func (s *MemoryProjectStore) GetByUser(userID string) ([]*Project, error) {
+	return s.filter(func(p *Project) bool {
+		_, isMember := s.DB.members[p.ID][userID]
+		return p.OwnerID == userID || isMember
+	}), nil
+}
+
+// Update updates a project
+func (s *MemoryProjectStore) Update(project *Project) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	stored, ok := s.DB.projects[project.ID]
+	if !ok {
+		return nil
+	}
+	stored.Name = project.Name
+	stored.Description = project.Description
+	stored.Status = project.Status
+	stored.UpdatedAt = time.Now()
+	s.DB.projects[project.ID] = stored
+	return nil
+}
+
+// Delete deletes a project
+func (s *MemoryProjectStore) Delete(id string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	s.DB.deleteProjectLocked(id)
+	return nil
+}
+
+// AddMember adds a user to a project with the given role
+func (s *MemoryProjectStore) AddMember(member *ProjectMember) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.projects[member.ProjectID]; !ok {
+		return errors.New("project does not exist")
+	}
+	if _, ok := s.DB.users[member.UserID]; !ok {
+		return errors.New("user does not exist")
+	}
+	if _, exists := s.DB.members[member.ProjectID][member.UserID]; exists {
+		return errors.New("user is already a member of the project")
+	}
+
+	if s.DB.members[member.ProjectID] == nil {
+		s.DB.members[member.ProjectID] = make(map[string]ProjectMember)
+	}
+	s.DB.members[member.ProjectID][member.UserID] = ProjectMember{
+		ProjectID: member.ProjectID,
+		UserID:    member.UserID,
+		Role:      member.Role,
+		CreatedAt: member.CreatedAt,
+	}
+	return nil
+}
+
+// GetMembers gets all members of a project with their user details
+func (s *MemoryProjectStore) GetMembers(projectID string) ([]*ProjectMember, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	members := []*ProjectMember{}
+	for _, member := range s.DB.members[projectID] {
+		member := member
+		user := s.DB.users[member.UserID]
+		member.Username = user.Username
+		member.Email = user.Email
+		member.FirstName = user.FirstName
+		member.LastName = user.LastName
+		members = append(members, &member)
+	}
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].CreatedAt.Before(members[j].CreatedAt)
+	})
+	return members, nil
+}
+
+// GetMemberRole gets a user's role in a project, or ErrMemberNotFound if they are not a member
+func (s *MemoryProjectStore) GetMemberRole(projectID, userID string) (string, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	member, ok := s.DB.members[projectID][userID]
+	if !ok {
+		return "", ErrMemberNotFound
+	}
+	return member.Role, nil
+}
+
+// UpdateMemberRole changes a member's role in a project
+func (s *MemoryProjectStore) UpdateMemberRole(projectID, userID, role string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	member, ok := s.DB.members[projectID][userID]
+	if !ok {
+		return ErrMemberNotFound
+	}
+	member.Role = role
+	s.DB.members[projectID][userID] = member
+	return nil
+}
+
+// RemoveMember removes a user from a project
+func (s *MemoryProjectStore) RemoveMember(projectID, userID string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.members[projectID][userID]; !ok {
+		return ErrMemberNotFound
+	}
+	delete(s.DB.members[projectID], userID)
+	return nil
+}
+
+// filter returns copies of the projects matching the predicate, newest first
+func (s *MemoryProjectStore) filter(match func(*Project) bool) []*Project {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	projects := []*Project{}
+	for _, project := range s.DB.projects {
+		project := project
+		if match(&project) {
+			projects = append(projects, &project)
+		}
+	}
+	sortProjectsByCreatedDesc(projects)
+	return projects
+}
@@ -0,0 +1,298 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-react-redux-app/database"
+	"go-react-redux-app/recurrence"
+)
+
+// ErrRecurrenceNotFound is returned when a recurrence does not exist
+var ErrRecurrenceNotFound = errors.New("recurrence not found")
+
+// Recurrence repeats a task on a recurrence rule in a time zone. Each
+// occurrence is a task of the project created from the recurrence's title,
+// description, priority and assignee, due at the moment of the occurrence.
+type Recurrence struct {
+	ID        string `json:"id"`
+	ProjectID string `json:"projectId"`
+	// Rule is an RFC 5545 recurrence rule such as FREQ=WEEKLY;BYDAY=MO
+	Rule string `json:"rule"`
+	// Timezone is the IANA time zone occurrences are computed in
+	Timezone string `json:"timezone"`
+	// StartsAt is the first occurrence
+	StartsAt time.Time `json:"startsAt"`
+	// EndsAt is set when the recurrence was continued by another one from
+	// that moment on; it has no occurrences from then on
+	EndsAt *time.Time `json:"endsAt,omitempty"`
+	// LastOccurrenceAt is the latest occurrence created so far
+	LastOccurrenceAt time.Time `json:"lastOccurrenceAt"`
+
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Priority    string `json:"priority"`
+	AssigneeID  string `json:"assigneeId,omitempty"`
+
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Schedule parses the rule and time zone of the recurrence
+func (r *Recurrence) Schedule() (recurrence.Schedule, error) {
+	rule, err := recurrence.Parse(r.Rule)
+	if err != nil {
+		return recurrence.Schedule{}, err
+	}
+	location, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return recurrence.Schedule{}, err
+	}
+	return recurrence.NewSchedule(rule, r.StartsAt, location), nil
+}
+
+// RecurrenceStore handles database operations for task recurrences
+type RecurrenceStore struct {
+	DB *database.DB
+}
+
+// NewRecurrenceStore creates a new RecurrenceStore
+func NewRecurrenceStore(db *database.DB) *RecurrenceStore {
+	return &RecurrenceStore{DB: db}
+}
+
+// recurrenceColumns lists the task_recurrences columns in the order scanRecurrence reads them
+const recurrenceColumns = `id, project_id, rule, timezone, starts_at, ends_at, last_occurrence_at,
+	title, description, priority, assignee_id, created_by, created_at, updated_at`
+
+// scanRecurrence scans a row selected with recurrenceColumns into a Recurrence
+func scanRecurrence(row rowScanner) (*Recurrence, error) {
+	rec := &Recurrence{}
+	var endsAt sql.NullTime
+	var assigneeID, createdBy sql.NullString
+	err := row.Scan(
+		&rec.ID, &rec.ProjectID, &rec.Rule, &rec.Timezone, &rec.StartsAt, &endsAt, &rec.LastOccurrenceAt,
+		&rec.Title, &rec.Description, &rec.Priority, &assigneeID, &createdBy, &rec.CreatedAt, &rec.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if endsAt.Valid {
+		rec.EndsAt = &endsAt.Time
+	}
+	rec.AssigneeID = assigneeID.String
+	rec.CreatedBy = createdBy.String
+	return rec, nil
+}
+
+// insertRecurrence inserts a recurrence row. Its times are stored in UTC so
+// that they compare correctly as text on SQLite.
+func insertRecurrence(tx *database.Tx, rec *Recurrence) error {
+	_, err := tx.Exec(
+		`INSERT INTO task_recurrences (`+recurrenceColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+		rec.ID, rec.ProjectID, rec.Rule, rec.Timezone, rec.StartsAt.UTC(), utcOrNil(rec.EndsAt), rec.LastOccurrenceAt.UTC(),
+		rec.Title, rec.Description, rec.Priority, nullString(rec.AssigneeID), nullString(rec.CreatedBy), rec.CreatedAt, rec.UpdatedAt,
+	)
+	return err
+}
+
+// utcOrNil stores an optional time in UTC, or as NULL when it is unset
+func utcOrNil(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+// Create stores a recurrence and makes the task its first occurrence, due at the start of the recurrence
+func (s *RecurrenceStore) Create(rec *Recurrence, taskID string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertRecurrence(tx, rec); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(
+		`UPDATE tasks SET recurrence_id = $1, occurrence_at = $2, due_date = $2 WHERE id = $3 AND recurrence_id IS NULL`,
+		rec.ID, rec.StartsAt.UTC(), taskID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result, ErrTaskNotFound); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetByID gets a recurrence by ID
+func (s *RecurrenceStore) GetByID(id string) (*Recurrence, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	rec, err := scanRecurrence(s.DB.QueryRow(`SELECT `+recurrenceColumns+` FROM task_recurrences WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrRecurrenceNotFound
+	}
+	return rec, err
+}
+
+// GetActive gets the recurrences that were not continued by another one
+func (s *RecurrenceStore) GetActive() ([]Recurrence, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	rows, err := s.DB.Query(`SELECT ` + recurrenceColumns + ` FROM task_recurrences WHERE ends_at IS NULL ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recs := []Recurrence{}
+	for rows.Next() {
+		rec, err := scanRecurrence(rows)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, *rec)
+	}
+	return recs, rows.Err()
+}
+
+// GetOccurrences gets the tasks that are occurrences of a recurrence, oldest first
+func (s *RecurrenceStore) GetOccurrences(id string) ([]Task, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	tasks, err := queryTasks(s.DB, `SELECT `+taskColumns+` FROM tasks WHERE recurrence_id = $1 ORDER BY occurrence_at`, id)
+	if tasks == nil && err == nil {
+		tasks = []Task{}
+	}
+	return tasks, err
+}
+
+// Update changes the rule, time zone, template and latest occurrence of a recurrence
+func (s *RecurrenceStore) Update(rec *Recurrence) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	result, err := s.DB.Exec(
+		`UPDATE task_recurrences
+		SET rule = $1, timezone = $2, last_occurrence_at = $3, title = $4, description = $5, priority = $6, assignee_id = $7, updated_at = $8
+		WHERE id = $9`,
+		rec.Rule, rec.Timezone, rec.LastOccurrenceAt.UTC(), rec.Title, rec.Description, rec.Priority, nullString(rec.AssigneeID), rec.UpdatedAt, rec.ID,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, ErrRecurrenceNotFound)
+}
+
+// Split ends a recurrence where next starts and continues it with next.
+// Occurrences from the start of next on move over to it.
+func (s *RecurrenceStore) Split(rec *Recurrence, next *Recurrence) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE task_recurrences SET ends_at = $1, updated_at = $2 WHERE id = $3 AND ends_at IS NULL`,
+		next.StartsAt.UTC(), next.CreatedAt, rec.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result, ErrRecurrenceNotFound); err != nil {
+		return err
+	}
+
+	if err := insertRecurrence(tx, next); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`UPDATE tasks SET recurrence_id = $1 WHERE recurrence_id = $2 AND occurrence_at >= $3`,
+		next.ID, rec.ID, next.StartsAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AddOccurrence creates the next occurrence of a recurrence. It reports
+// false, creating nothing, if an occurrence at or after the task's
+// OccurrenceAt already exists or the recurrence has ended or is gone, so
+// that an occurrence is never created twice.
+func (s *RecurrenceStore) AddOccurrence(rec *Recurrence, task *Task) (bool, error) {
+	if s.DB == nil {
+		return false, errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	occurrenceAt := task.OccurrenceAt.UTC()
+	task.OccurrenceAt = &occurrenceAt
+	result, err := tx.Exec(
+		`UPDATE task_recurrences SET last_occurrence_at = $1
+		WHERE id = $2 AND last_occurrence_at < $1 AND ends_at IS NULL`,
+		occurrenceAt, rec.ID,
+	)
+	if err != nil {
+		return false, err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return false, err
+	}
+
+	if err := insertTask(tx, task); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	rec.LastOccurrenceAt = occurrenceAt
+	return true, nil
+}
+
+// Delete deletes a recurrence. Its occurrences stay as ordinary tasks.
+func (s *RecurrenceStore) Delete(id string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	result, err := s.DB.Exec(`DELETE FROM task_recurrences WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, ErrRecurrenceNotFound)
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"errors"
+	"sort"
+)
+
+// MemoryRecurrenceStore is an in-memory implementation of RecurrenceRepository
+type MemoryRecurrenceStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryRecurrenceStore creates a new MemoryRecurrenceStore
+func NewMemoryRecurrenceStore(db *MemoryDB) *MemoryRecurrenceStore {
+	return &MemoryRecurrenceStore{DB: db}
+}
+
+// Create stores a recurrence and makes the task its first occurrence, due at the start of the recurrence
+func (s *MemoryRecurrenceStore) Create(rec *Recurrence, taskID string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.projects[rec.ProjectID]; !ok {
+		return errors.New("project does not exist")
+	}
+	if err := s.checkAssigneeLocked(rec); err != nil {
+		return err
+	}
+	task, ok := s.DB.tasks[taskID]
+	if !ok || task.RecurrenceID != "" {
+		return ErrTaskNotFound
+	}
+
+	s.DB.recurrences[rec.ID] = *rec
+	startsAt := rec.StartsAt
+	task.RecurrenceID = rec.ID
+	task.OccurrenceAt = &startsAt
+	task.DueDate = startsAt
+	s.DB.tasks[taskID] = task
+	return nil
+}
+
+// GetByID gets a recurrence by ID
+func (s *MemoryRecurrenceStore) GetByID(id string) (*Recurrence, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	rec, ok := s.DB.recurrences[id]
+	if !ok {
+		return nil, ErrRecurrenceNotFound
+	}
+	return &rec, nil
+}
+
+// GetActive gets the recurrences that were not continued by another one
+func (s *MemoryRecurrenceStore) GetActive() ([]Recurrence, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	recs := []Recurrence{}
+	for _, rec := range s.DB.recurrences {
+		if rec.EndsAt == nil {
+			recs = append(recs, rec)
+		}
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		return recs[i].CreatedAt.Before(recs[j].CreatedAt)
+	})
+	return recs, nil
+}
+
+// GetOccurrences gets the tasks that are occurrences of a recurrence, oldest first
+func (s *MemoryRecurrenceStore) GetOccurrences(id string) ([]Task, error) {
+	tasks := (&MemoryTaskStore{DB: s.DB}).filter(func(task *Task) bool {
+		return task.RecurrenceID == id
+	})
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].OccurrenceAt.Before(*tasks[j].OccurrenceAt)
+	})
+	return tasks, nil
+}
+
+// Update changes the rule, time zone, template and latest occurrence of a recurrence
+func (s *MemoryRecurrenceStore) Update(rec *Recurrence) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	stored, ok := s.DB.recurrences[rec.ID]
+	if !ok {
+		return ErrRecurrenceNotFound
+	}
+	if err := s.checkAssigneeLocked(rec); err != nil {
+		return err
+	}
+	stored.Rule = rec.Rule
+	stored.Timezone = rec.Timezone
+	stored.LastOccurrenceAt = rec.LastOccurrenceAt
+	stored.Title = rec.Title
+	stored.Description = rec.Description
+	stored.Priority = rec.Priority
+	stored.AssigneeID = rec.AssigneeID
+	stored.UpdatedAt = rec.UpdatedAt
+	s.DB.recurrences[rec.ID] = stored
+	return nil
+}
+
+// Split ends a recurrence where next starts and continues it with next.
+// Occurrences from the start of next on move over to it.
+func (s *MemoryRecurrenceStore) Split(rec *Recurrence, next *Recurrence) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	stored, ok := s.DB.recurrences[rec.ID]
+	if !ok || stored.EndsAt != nil {
+		return ErrRecurrenceNotFound
+	}
+	if err := s.checkAssigneeLocked(next); err != nil {
+		return err
+	}
+	endsAt := next.StartsAt
+	stored.EndsAt = &endsAt
+	stored.UpdatedAt = next.CreatedAt
+	s.DB.recurrences[rec.ID] = stored
+	s.DB.recurrences[next.ID] = *next
+
+	for id, task := range s.DB.tasks {
+		if task.RecurrenceID == rec.ID && !task.OccurrenceAt.Before(next.StartsAt) {
+			task.RecurrenceID = next.ID
+			s.DB.tasks[id] = task
+		}
+	}
+	return nil
+}
+
+// AddOccurrence creates the next occurrence of a recurrence. It reports
+// false, creating nothing, if an occurrence at or after the task's
+// OccurrenceAt already exists or the recurrence has ended or is gone, so
+// that an occurrence is never created twice.
+func (s *MemoryRecurrenceStore) AddOccurrence(rec *Recurrence, task *Task) (bool, error) {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	stored, ok := s.DB.recurrences[rec.ID]
+	if !ok || stored.EndsAt != nil || !stored.LastOccurrenceAt.Before(*task.OccurrenceAt) {
+		return false, nil
+	}
+	if err := (&MemoryTaskStore{DB: s.DB}).checkReferencesLocked(task); err != nil {
+		return false, err
+	}
+
+	stored.LastOccurrenceAt = *task.OccurrenceAt
+	s.DB.recurrences[rec.ID] = stored
+
+	occurrence := *task
+	occurrence.Blocked = false
+	occurrence.Progress = nil
+	occurrence.Labels = nil
+	occurrence.CustomFields = nil
+	s.DB.tasks[task.ID] = occurrence
+
+	rec.LastOccurrenceAt = stored.LastOccurrenceAt
+	return true, nil
+}
+
+// checkAssigneeLocked checks that the assignee of a recurrence exists, like
+// the foreign key of the SQL schema. The caller must hold the lock.
+func (s *MemoryRecurrenceStore) checkAssigneeLocked(rec *Recurrence) error {
+	if rec.AssigneeID != "" {
+		if _, ok := s.DB.users[rec.AssigneeID]; !ok {
+			return errors.New("assignee does not exist")
+		}
+	}
+	return nil
+}
+
+// Delete deletes a recurrence. Its occurrences stay as ordinary tasks.
+func (s *MemoryRecurrenceStore) Delete(id string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.recurrences[id]; !ok {
+		return ErrRecurrenceNotFound
+	}
+	s.DB.deleteRecurrenceLocked(id)
+	return nil
+}
+
+// deleteRecurrenceLocked removes a recurrence, leaving its occurrences as
+// ordinary tasks. The caller must hold the write lock.
+func (db *MemoryDB) deleteRecurrenceLocked(id string) {
+	delete(db.recurrences, id)
+	for taskID, task := range db.tasks {
+		if task.RecurrenceID == id {
+			task.RecurrenceID = ""
+			db.tasks[taskID] = task
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrTaskNotFound is returned when a task does not exist
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrMemberNotFound is returned when a user is not a member of a project
+	ErrMemberNotFound = errors.New("project member not found")
+	// ErrSessionNotFound is returned when a session does not exist
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrRefreshTokenNotFound is returned when a refresh token does not exist
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	// ErrUserTokenInvalid is returned when an emailed token is unknown, used or expired
+	ErrUserTokenInvalid = errors.New("token is invalid or has expired")
+	// ErrAPITokenNotFound is returned when a personal access token does not exist
+	ErrAPITokenNotFound = errors.New("api token not found")
+	// ErrTwoFactorNotFound is returned when a user has no (pending) two-factor setup
+	ErrTwoFactorNotFound = errors.New("two-factor authentication not found")
+	// ErrIdentityNotFound is returned when a linked identity does not exist
+	ErrIdentityNotFound = errors.New("identity not found")
+	// ErrIdentityExists is returned when a provider account or provider is already linked
+	ErrIdentityExists = errors.New("identity is already linked")
+	// ErrOIDCFlowInvalid is returned when an OIDC login is unknown, completed or expired
+	ErrOIDCFlowInvalid = errors.New("login is invalid or has expired")
+)
+
+// UserRepository defines the storage operations for users
+type UserRepository interface {
+	Create(user *User) error
+	GetByID(id string) (*User, error)
+	GetByUsername(username string) (*User, error)
+	GetByEmail(email string) (*User, error)
+	List(query UserQuery) ([]*User, int, error)
+	Update(user *User) error
+	UpdatePassword(id string, hashedPassword string) error
+	SetEmailVerified(id string, verified bool) error
+	SetDisabled(id string, disabled bool) error
+	Delete(id string) error
+}
+
+// ProjectRepository defines the storage operations for projects
+type ProjectRepository interface {
+	Create(project *Project) error
+	GetByID(id string) (*Project, error)
+	GetAll() ([]*Project, error)
+	GetByOwner(ownerID string) ([]*Project, error)
+	GetByUser(userID string) ([]*Project, error)
+	Update(project *Project) error
+	Delete(id string) error
+	AddMember(member *ProjectMember) error
+	GetMembers(projectID string) ([]*ProjectMember, error)
+	GetMemberRole(projectID, userID string) (string, error)
+	UpdateMemberRole(projectID, userID, role string) error
+	RemoveMember(projectID, userID string) error
+}
+
+// TaskRepository defines the storage operations for tasks
+type TaskRepository interface {
+	Create(task Task) error
+	GetAll() ([]Task, error)
+	GetByID(id string) (*Task, error)
+	GetByProject(projectID string) ([]Task, error)
+	GetByUser(userID string) ([]Task, error)
+	GetByAssignee(assigneeID string) ([]*Task, error)
+	GetChildren(parentID string) ([]Task, error)
+	GetDescendants(id string) ([]Task, error)
+	Update(task *Task) error
+	Delete(id string) error
+	DeleteKeepingChildren(id string) error
+	DeleteByProject(projectID string) error
+}
+
+// TaskDependencyRepository defines the storage operations for links between blocking and blocked tasks
+type TaskDependencyRepository interface {
+	Create(dependency *TaskDependency) error
+	Delete(blockerID string, blockedID string) error
+	GetBlockers(taskID string) ([]Task, error)
+	GetBlocking(taskID string) ([]Task, error)
+}
+
+// LabelRepository defines the storage operations for project labels and their assignment to tasks
+type LabelRepository interface {
+	Create(label *Label) error
+	GetByID(id string) (*Label, error)
+	GetByProject(projectID string) ([]Label, error)
+	Update(label *Label) error
+	Delete(id string) error
+	AddToTask(taskID string, labelID string) error
+	RemoveFromTask(taskID string, labelID string) error
+	SetTaskLabels(taskID string, labelIDs []string) error
+}
+
+// CustomFieldRepository defines the storage operations for project custom fields and their values on tasks
+type CustomFieldRepository interface {
+	Create(field *CustomField) error
+	GetByID(id string) (*CustomField, error)
+	GetByProject(projectID string) ([]CustomField, error)
+	GetByProjects(projectIDs []string) ([]CustomField, error)
+	Update(field *CustomField, renamed map[string]string) error
+	Delete(id string) error
+	SetTaskValues(taskID string, values []FieldValue) error
+}
+
+// WorkflowRepository defines the storage operations for project workflows
+type WorkflowRepository interface {
+	Get(projectID string) (*Workflow, error)
+	Set(workflow *Workflow, statusMap map[string]string) error
+}
+
+// RecurrenceRepository defines the storage operations for task recurrences
+type RecurrenceRepository interface {
+	Create(rec *Recurrence, taskID string) error
+	GetByID(id string) (*Recurrence, error)
+	GetActive() ([]Recurrence, error)
+	GetOccurrences(id string) ([]Task, error)
+	Update(rec *Recurrence) error
+	Split(rec *Recurrence, next *Recurrence) error
+	AddOccurrence(rec *Recurrence, task *Task) (bool, error)
+	Delete(id string) error
+}
+
+// WorklogRepository defines the storage operations for worklogs and running timers
+type WorklogRepository interface {
+	Create(worklog *Worklog) error
+	GetByID(id string) (*Worklog, error)
+	GetByTask(taskID string, limit int, offset int) ([]*Worklog, int, error)
+	Update(worklog *Worklog) error
+	Delete(id string) error
+	GetTotals(filter WorklogFilter) ([]WorklogTotal, error)
+	GetTimer(userID string) (*Timer, error)
+	StartTimer(timer *Timer) error
+	StopTimer(timer *Timer, worklog *Worklog) error
+	DeleteTimer(userID string) error
+}
+
+// CommentRepository defines the storage operations for task comments and the mentions in them
+type CommentRepository interface {
+	Create(comment *Comment) error
+	GetByID(id string) (*Comment, error)
+	GetByTask(taskID string, limit int, offset int) ([]*Comment, int, error)
+	Update(comment *Comment) error
+	Delete(id string) error
+	GetMentions(userID string, limit int, offset int) ([]*Mention, int, error)
+}
+
+// AttachmentRepository defines the storage operations for task attachments and
+// the blobs holding their contents
+type AttachmentRepository interface {
+	Create(attachment *Attachment) error
+	GetByID(id string) (*Attachment, error)
+	GetByTask(taskID string) ([]*Attachment, error)
+	Delete(id string) error
+	GetOrphanedBlobs() ([]string, error)
+	DeleteBlob(hash string) (bool, error)
+}
+
+// SessionRepository defines the storage operations for sessions and refresh tokens
+type SessionRepository interface {
+	Create(session *Session) error
+	GetByID(id string) (*Session, error)
+	GetActiveByUser(userID string) ([]*Session, error)
+	Touch(id string, ip string, seenAt time.Time) error
+	Extend(id string, expiresAt time.Time) error
+	Revoke(id string) error
+	RevokeAllForUser(userID string) error
+	RevokeAllForUserExcept(userID string, sessionID string) error
+	CreateRefreshToken(token *RefreshToken) error
+	GetRefreshTokenByHash(hash string) (*RefreshToken, error)
+	MarkRefreshTokenUsed(id string) (bool, error)
+}
+
+// UserTokenRepository defines the storage operations for single-use user tokens
+type UserTokenRepository interface {
+	Create(token *UserToken) error
+	Get(hash string, purpose string) (*UserToken, error)
+	Consume(hash string, purpose string) (*UserToken, error)
+	ResetPassword(token *UserToken, hashedPassword string) error
+}
+
+// APITokenRepository defines the storage operations for personal access tokens
+type APITokenRepository interface {
+	Create(token *APIToken) error
+	GetByHash(hash string) (*APIToken, error)
+	GetByUser(userID string) ([]*APIToken, error)
+	Touch(id string, usedAt time.Time) error
+	Delete(userID string, id string) error
+}
+
+// LoginAttemptRepository defines the storage operations for the login history
+type LoginAttemptRepository interface {
+	Record(attempt *LoginAttempt) error
+	AccountFailures(username string, since time.Time) (Failures, error)
+	IPFailures(ip string, since time.Time) (Failures, error)
+	GetByUsername(username string, limit int) ([]*LoginAttempt, error)
+}
+
+// TwoFactorRepository defines the storage operations for two-factor authentication
+type TwoFactorRepository interface {
+	Get(userID string) (*TwoFactor, error)
+	Enroll(twoFactor *TwoFactor) error
+	Confirm(userID string, step int64, recoveryCodeHashes []string) error
+	UseStep(userID string, step int64) (bool, error)
+	ReplaceRecoveryCodes(userID string, hashes []string) error
+	UseRecoveryCode(userID string, hash string) (bool, error)
+	CountRecoveryCodes(userID string) (int, error)
+	Delete(userID string) error
+}
+
+// IdentityRepository defines the storage operations for linked identities
+type IdentityRepository interface {
+	Create(identity *Identity) error
+	GetBySubject(provider string, subject string) (*Identity, error)
+	GetByUser(userID string) ([]*Identity, error)
+	Touch(id string, email string, loginAt time.Time) error
+	Delete(userID string, id string) error
+}
+
+// OIDCFlowRepository defines the storage operations for OIDC logins in progress
+type OIDCFlowRepository interface {
+	Create(flow *OIDCFlow) error
+	Consume(stateHash string) (*OIDCFlow, error)
+}
+
+// Compile-time checks that the SQL and in-memory stores satisfy the repositories
+var (
+	_ UserRepository    = (*UserStore)(nil)
+	_ UserRepository    = (*MemoryUserStore)(nil)
+	_ ProjectRepository = (*ProjectStore)(nil)
+	_ ProjectRepository = (*MemoryProjectStore)(nil)
+	_ TaskRepository    = (*TaskStore)(nil)
+	_ TaskRepository    = (*MemoryTaskStore)(nil)
+	_ SessionRepository = (*SessionStore)(nil)
+	_ SessionRepository = (*MemorySessionStore)(nil)
+
+	_ TaskDependencyRepository = (*TaskDependencyStore)(nil)
+	_ TaskDependencyRepository = (*MemoryTaskDependencyStore)(nil)
+	_ LabelRepository          = (*LabelStore)(nil)
+	_ LabelRepository          = (*MemoryLabelStore)(nil)
+	_ CustomFieldRepository    = (*CustomFieldStore)(nil)
+	_ CustomFieldRepository    = (*MemoryCustomFieldStore)(nil)
+	_ WorkflowRepository       = (*WorkflowStore)(nil)
+	_ WorkflowRepository       = (*MemoryWorkflowStore)(nil)
+	_ RecurrenceRepository     = (*RecurrenceStore)(nil)
+	_ RecurrenceRepository     = (*MemoryRecurrenceStore)(nil)
+	_ WorklogRepository        = (*WorklogStore)(nil)
+	_ WorklogRepository        = (*MemoryWorklogStore)(nil)
+	_ CommentRepository        = (*CommentStore)(nil)
+	_ CommentRepository        = (*MemoryCommentStore)(nil)
+	_ AttachmentRepository     = (*AttachmentStore)(nil)
+	_ AttachmentRepository     = (*MemoryAttachmentStore)(nil)
+
+	_ UserTokenRepository = (*UserTokenStore)(nil)
+	_ UserTokenRepository = (*MemoryUserTokenStore)(nil)
+
+	_ APITokenRepository = (*APITokenStore)(nil)
+	_ APITokenRepository = (*MemoryAPITokenStore)(nil)
+
+	_ LoginAttemptRepository = (*LoginAttemptStore)(nil)
+	_ LoginAttemptRepository = (*MemoryLoginAttemptStore)(nil)
+
+	_ TwoFactorRepository = (*TwoFactorStore)(nil)
+	_ TwoFactorRepository = (*MemoryTwoFactorStore)(nil)
+
+	_ IdentityRepository = (*IdentityStore)(nil)
+	_ IdentityRepository = (*MemoryIdentityStore)(nil)
+	_ OIDCFlowRepository = (*OIDCFlowStore)(nil)
+	_ OIDCFlowRepository = (*MemoryOIDCFlowStore)(nil)
+)
@@ -0,0 +1,279 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-react-redux-app/database"
+)
+
+// Session represents a login. Every token issued for the login is tied to it.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"userId"`
+	UserAgent  string     `json:"userAgent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt time.Time  `json:"lastSeenAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// IsActive checks if the session is neither revoked nor expired
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// RefreshToken represents a single-use refresh token belonging to a session
+type RefreshToken struct {
+	ID        string
+	SessionID string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// SessionStore handles database operations for sessions and refresh tokens
+type SessionStore struct {
+	DB *database.DB
+}
+
+// NewSessionStore creates a new SessionStore
+func NewSessionStore(db *database.DB) *SessionStore {
+	return &SessionStore{DB: db}
+}
+
+// Create creates a new session
+func (s *SessionStore) Create(session *Session) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `
+	INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_seen_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := s.DB.Exec(
+		query,
+		session.ID,
+		session.UserID,
+		session.UserAgent,
+		session.IP,
+		session.CreatedAt,
+		session.LastSeenAt,
+		session.ExpiresAt,
+	)
+	return err
+}
+
+// sessionColumns lists the sessions columns in the order scanSession reads them
+const sessionColumns = `id, user_id, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at`
+
+// scanSession scans a row selected with sessionColumns into a Session
+func scanSession(row rowScanner) (*Session, error) {
+	session := &Session{}
+	var userAgent, ip sql.NullString
+	var lastSeenAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&userAgent,
+		&ip,
+		&session.CreatedAt,
+		&lastSeenAt,
+		&session.ExpiresAt,
+		&revokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	session.UserAgent = userAgent.String
+	session.IP = ip.String
+	session.LastSeenAt = session.CreatedAt
+	if lastSeenAt.Valid {
+		session.LastSeenAt = lastSeenAt.Time
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return session, nil
+}
+
+// GetByID gets a session by ID
+func (s *SessionStore) GetByID(id string) (*Session, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+
+	session, err := scanSession(s.DB.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// GetActiveByUser gets the sessions of a user that are neither revoked nor
+// expired, most recently used first
+func (s *SessionStore) GetActiveByUser(userID string) ([]*Session, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	query := `
+	SELECT ` + sessionColumns + `
+	FROM sessions
+	WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+	ORDER BY last_seen_at DESC, id`
+
+	rows, err := s.DB.Query(query, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Touch records that a session was just used and the address it was used from
+func (s *SessionStore) Touch(id string, ip string, seenAt time.Time) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `UPDATE sessions SET last_seen_at = $1, ip = $2 WHERE id = $3`
+	_, err := s.DB.Exec(query, seenAt, ip, id)
+	return err
+}
+
+// Extend moves the expiry of an active session
+func (s *SessionStore) Extend(id string, expiresAt time.Time) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `UPDATE sessions SET expires_at = $1 WHERE id = $2 AND revoked_at IS NULL`
+	_, err := s.DB.Exec(query, expiresAt, id)
+	return err
+}
+
+// Revoke revokes a session, invalidating all of its tokens
+func (s *SessionStore) Revoke(id string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `UPDATE sessions SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`
+	_, err := s.DB.Exec(query, time.Now(), id)
+	return err
+}
+
+// RevokeAllForUser revokes every active session of a user
+func (s *SessionStore) RevokeAllForUser(userID string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`
+	_, err := s.DB.Exec(query, time.Now(), userID)
+	return err
+}
+
+// RevokeAllForUserExcept revokes every active session of a user but the given one
+func (s *SessionStore) RevokeAllForUserExcept(userID string, sessionID string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND id <> $3 AND revoked_at IS NULL`
+	_, err := s.DB.Exec(query, time.Now(), userID, sessionID)
+	return err
+}
+
+// CreateRefreshToken stores a new refresh token
+func (s *SessionStore) CreateRefreshToken(token *RefreshToken) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `
+	INSERT INTO refresh_tokens (id, session_id, token_hash, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := s.DB.Exec(query, token.ID, token.SessionID, token.TokenHash, token.CreatedAt, token.ExpiresAt)
+	return err
+}
+
+// GetRefreshTokenByHash gets a refresh token by the hash of its value
+func (s *SessionStore) GetRefreshTokenByHash(hash string) (*RefreshToken, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	query := `
+	SELECT id, session_id, token_hash, created_at, expires_at, used_at
+	FROM refresh_tokens
+	WHERE token_hash = $1`
+
+	token := &RefreshToken{}
+	var usedAt sql.NullTime
+	err := s.DB.QueryRow(query, hash).Scan(
+		&token.ID,
+		&token.SessionID,
+		&token.TokenHash,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&usedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+
+	return token, nil
+}
+
+// MarkRefreshTokenUsed marks a refresh token as used. It returns false if the
+// token had already been used, which happens when a token is replayed.
+func (s *SessionStore) MarkRefreshTokenUsed(id string) (bool, error) {
+	if s.DB == nil {
+		return false, errors.New("database connection is nil")
+	}
+
+	query := `UPDATE refresh_tokens SET used_at = $1 WHERE id = $2 AND used_at IS NULL`
+
+	result, err := s.DB.Exec(query, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
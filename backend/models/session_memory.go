@@ -0,0 +1,187 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// MemorySessionStore is an in-memory implementation of SessionRepository
+type MemorySessionStore struct {
+	DB *MemoryDB
+}
+
+// NewMemorySessionStore creates a new MemorySessionStore
+func NewMemorySessionStore(db *MemoryDB) *MemorySessionStore {
+	return &MemorySessionStore{DB: db}
+}
+
+// Create creates a new session
+func (s *MemorySessionStore) Create(session *Session) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.users[session.UserID]; !ok {
+		return errors.New("user does not exist")
+	}
+
+	stored := *session
+	stored.RevokedAt = nil
+	s.DB.sessions[session.ID] = stored
+	return nil
+}
+
+// GetByID gets a session by ID
+func (s *MemorySessionStore) GetByID(id string) (*Session, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	session, ok := s.DB.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+// GetActiveByUser gets the sessions of a user that are neither revoked nor
+// expired, most recently used first
+func (s *MemorySessionStore) GetActiveByUser(userID string) ([]*Session, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	sessions := []*Session{}
+	for _, session := range s.DB.sessions {
+		if session.UserID == userID && session.IsActive() {
+			session := session
+			sessions = append(sessions, &session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+		}
+		return sessions[i].ID < sessions[j].ID
+	})
+	return sessions, nil
+}
+
+// Touch records that a session was just used and the address it was used from
+func (s *MemorySessionStore) Touch(id string, ip string, seenAt time.Time) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	session, ok := s.DB.sessions[id]
+	if ok {
+		session.LastSeenAt = seenAt
+		session.IP = ip
+		s.DB.sessions[id] = session
+	}
+	return nil
+}
+
+// Extend moves the expiry of an active session
+func (s *MemorySessionStore) Extend(id string, expiresAt time.Time) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	session, ok := s.DB.sessions[id]
+	if ok && session.RevokedAt == nil {
+		session.ExpiresAt = expiresAt
+		s.DB.sessions[id] = session
+	}
+	return nil
+}
+
+// Revoke revokes a session, invalidating all of its tokens
+func (s *MemorySessionStore) Revoke(id string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	session, ok := s.DB.sessions[id]
+	if ok && session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+		s.DB.sessions[id] = session
+	}
+	return nil
+}
+
+// RevokeAllForUser revokes every active session of a user
+func (s *MemorySessionStore) RevokeAllForUser(userID string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	now := time.Now()
+	for id, session := range s.DB.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			s.DB.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+// RevokeAllForUserExcept revokes every active session of a user but the given one
+func (s *MemorySessionStore) RevokeAllForUserExcept(userID string, sessionID string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	now := time.Now()
+	for id, session := range s.DB.sessions {
+		if session.UserID == userID && id != sessionID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			s.DB.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+// CreateRefreshToken stores a new refresh token
+func (s *MemorySessionStore) CreateRefreshToken(token *RefreshToken) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.sessions[token.SessionID]; !ok {
+		return errors.New("session does not exist")
+	}
+	for _, existing := range s.DB.refresh {
+		if existing.TokenHash == token.TokenHash {
+			return errors.New("refresh token already exists")
+		}
+	}
+
+	stored := *token
+	stored.UsedAt = nil
+	s.DB.refresh[token.ID] = stored
+	return nil
+}
+
+// GetRefreshTokenByHash gets a refresh token by the hash of its value
+func (s *MemorySessionStore) GetRefreshTokenByHash(hash string) (*RefreshToken, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	for _, token := range s.DB.refresh {
+		if token.TokenHash == hash {
+			return &token, nil
+		}
+	}
+	return nil, ErrRefreshTokenNotFound
+}
+
+// MarkRefreshTokenUsed marks a refresh token as used. It returns false if the
+// token had already been used, which happens when a token is replayed.
+func (s *MemorySessionStore) MarkRefreshTokenUsed(id string) (bool, error) {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	token, ok := s.DB.refresh[id]
+	if !ok || token.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	s.DB.refresh[id] = token
+	return true, nil
+}
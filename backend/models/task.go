@@ -2,173 +2,242 @@ package models
 
 import (
 	"database/sql"
-	"errors"
-	"fmt"
+	"encoding/json"
+	"strconv"
+	"strings"
 	"time"
+
+	"go-react-redux-app/database"
 )
 
 // Task represents a task in the system
 type Task struct {
-	ID          string    `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Status      string    `json:"status"`
-	Priority    string    `json:"priority"`
-	ProjectID   string    `json:"projectId"`
-	AssigneeID  string    `json:"assigneeId,omitempty"`
-	DueDate     time.Time `json:"dueDate,omitempty"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Status      string `json:"status"`
+	// StatusCategory is the workflow category of the status
+	StatusCategory string    `json:"statusCategory"`
+	Priority       string    `json:"priority"`
+	ProjectID      string    `json:"projectId"`
+	ParentTaskID   string    `json:"parentTaskId,omitempty"`
+	AssigneeID     string    `json:"assigneeId,omitempty"`
+	DueDate        time.Time `json:"dueDate,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+
+	// Blocked is set while any task blocking this one is not done
+	Blocked bool `json:"blocked"`
+
+	// Progress rolls up the completion of the task's subtasks; it is only
+	// set on tasks that have subtasks
+	Progress *TaskProgress `json:"progress,omitempty"`
+
+	// Labels are the project labels assigned to the task, ordered by name
+	Labels []Label `json:"labels"`
+
+	// RecurrenceID is the recurrence the task is an occurrence of, and
+	// OccurrenceAt the moment of that occurrence
+	RecurrenceID string     `json:"recurrenceId,omitempty"`
+	OccurrenceAt *time.Time `json:"occurrenceAt,omitempty"`
+
+	// OriginalEstimate and RemainingEstimate are in seconds. Logging work on
+	// the task lowers the remaining estimate by the time logged.
+	OriginalEstimate  *int64 `json:"originalEstimate,omitempty"`
+	RemainingEstimate *int64 `json:"remainingEstimate,omitempty"`
+
+	// CustomFields holds the JSON encoded values of the project's custom
+	// fields that are set on the task, keyed by field ID
+	CustomFields map[string]json.RawMessage `json:"customFields"`
+}
+
+// TaskProgress counts the subtasks below a task, at any depth, and how many of them are done
+type TaskProgress struct {
+	Total   int `json:"total"`
+	Done    int `json:"done"`
+	Percent int `json:"percent"`
+}
+
+// IsDone checks if the task's status is in the done category
+func (t Task) IsDone() bool {
+	return t.StatusCategory == StatusCategoryDone
+}
+
+// notDoneCondition is an SQL condition on the status category of the named
+// tasks table that holds for tasks that are not finished
+func notDoneCondition(table string) string {
+	return table + ".status_category <> '" + StatusCategoryDone + "'"
+}
+
+// RollUpProgress sets the progress of every task in the list that has
+// subtasks in the list. The list has to contain whole subtrees, such as all
+// tasks of a project or a task with its descendants.
+func RollUpProgress(tasks []Task) {
+	children := make(map[string][]int)
+	for i := range tasks {
+		if tasks[i].ParentTaskID != "" {
+			children[tasks[i].ParentTaskID] = append(children[tasks[i].ParentTaskID], i)
+		}
+	}
+
+	// count returns the number of subtasks below the task and how many are done
+	var count func(id string, seen map[string]bool) (int, int)
+	count = func(id string, seen map[string]bool) (int, int) {
+		total, done := 0, 0
+		for _, i := range children[id] {
+			if seen[tasks[i].ID] {
+				continue
+			}
+			seen[tasks[i].ID] = true
+			total++
+			if tasks[i].IsDone() {
+				done++
+			}
+			t, d := count(tasks[i].ID, seen)
+			total += t
+			done += d
+		}
+		return total, done
+	}
+
+	for i := range tasks {
+		tasks[i].Progress = nil
+		if len(children[tasks[i].ID]) == 0 {
+			continue
+		}
+		total, done := count(tasks[i].ID, map[string]bool{tasks[i].ID: true})
+		tasks[i].Progress = &TaskProgress{
+			Total:   total,
+			Done:    done,
+			Percent: done * 100 / total,
+		}
+	}
 }
 
 // TaskStore provides methods for interacting with tasks in the database
 type TaskStore struct {
-	DB *sql.DB
+	DB *database.DB
 }
 
 // NewTaskStore creates a new TaskStore
-func NewTaskStore(db *sql.DB) *TaskStore {
+func NewTaskStore(db *database.DB) *TaskStore {
 	return &TaskStore{DB: db}
 }
 
 // Create creates a new task
 func (s *TaskStore) Create(task Task) error {
+	return insertTask(s.DB, &task)
+}
+
+// execer runs statements on a database or in a transaction
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertTask inserts a task row
+func insertTask(db execer, task *Task) error {
 	// Handle empty assigneeID as NULL in the database
 	var assigneeID interface{} = nil
 	if task.AssigneeID != "" {
 		assigneeID = task.AssigneeID
 	}
 
-	println("Creating task with assigneeID:", task.AssigneeID)
-	println("Using assigneeID value for DB:", fmt.Sprintf("%v", assigneeID))
-
 	query := `
-		INSERT INTO tasks (id, title, description, status, priority, project_id, assignee_id, due_date, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO tasks (id, title, description, status, status_category, priority, project_id, parent_task_id, assignee_id, due_date, recurrence_id, occurrence_at, original_estimate, remaining_estimate, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 	`
-	_, err := s.DB.Exec(
+	_, err := db.Exec(
 		query,
 		task.ID,
 		task.Title,
 		task.Description,
 		task.Status,
+		task.StatusCategory,
 		task.Priority,
 		task.ProjectID,
+		nullString(task.ParentTaskID),
 		assigneeID,
 		task.DueDate,
+		nullString(task.RecurrenceID),
+		task.OccurrenceAt,
+		task.OriginalEstimate,
+		task.RemainingEstimate,
 		task.CreatedAt,
 		task.UpdatedAt,
 	)
-	if err != nil {
-		println("Database error:", err.Error())
-	}
 	return err
 }
 
-// GetAll gets all tasks
-func (s *TaskStore) GetAll() ([]Task, error) {
-	query := `
-		SELECT id, title, description, status, priority, project_id, assignee_id, due_date, created_at, updated_at
-		FROM tasks
-		ORDER BY created_at DESC
-	`
-	rows, err := s.DB.Query(query)
-	if err != nil {
-		return nil, err
+// nullString stores an empty string as NULL, for optional references
+func nullString(value string) interface{} {
+	if value == "" {
+		return nil
 	}
-	defer rows.Close()
-
-	var tasks []Task
-	for rows.Next() {
-		var task Task
-		var assigneeID sql.NullString
-		var dueDate sql.NullTime
-
-		err := rows.Scan(
-			&task.ID,
-			&task.Title,
-			&task.Description,
-			&task.Status,
-			&task.Priority,
-			&task.ProjectID,
-			&assigneeID,
-			&dueDate,
-			&task.CreatedAt,
-			&task.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		if assigneeID.Valid {
-			task.AssigneeID = assigneeID.String
-		}
-		if dueDate.Valid {
-			task.DueDate = dueDate.Time
-		}
-
-		tasks = append(tasks, task)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return tasks, nil
+	return value
 }
 
-// GetByID gets a task by ID
-func (s *TaskStore) GetByID(id string) (*Task, error) {
-	query := `
-		SELECT id, title, description, status, priority, project_id, assignee_id, due_date, created_at, updated_at
-		FROM tasks
-		WHERE id = $1
-	`
-	row := s.DB.QueryRow(query, id)
-
+// taskColumns lists the tasks columns in the order scanTask reads them. The
+// blocked flag is computed from the task's open blockers.
+var taskColumns = `id, title, description, status, status_category, priority, project_id, parent_task_id, assignee_id, due_date, recurrence_id, occurrence_at,
+	original_estimate, remaining_estimate, created_at, updated_at,
+	EXISTS (
+		SELECT 1 FROM task_dependencies
+		JOIN tasks blocker ON blocker.id = task_dependencies.blocker_id
+		WHERE task_dependencies.blocked_id = tasks.id AND ` + notDoneCondition("blocker") + `
+	)`
+
+// scanTask scans a row selected with taskColumns into a Task
+func scanTask(row rowScanner) (*Task, error) {
 	task := &Task{}
-	var assigneeID sql.NullString
-	var dueDate sql.NullTime
+	var parentTaskID, assigneeID, recurrenceID sql.NullString
+	var dueDate, occurrenceAt sql.NullTime
+	var originalEstimate, remainingEstimate sql.NullInt64
 
 	err := row.Scan(
 		&task.ID,
 		&task.Title,
 		&task.Description,
 		&task.Status,
+		&task.StatusCategory,
 		&task.Priority,
 		&task.ProjectID,
+		&parentTaskID,
 		&assigneeID,
 		&dueDate,
+		&recurrenceID,
+		&occurrenceAt,
+		&originalEstimate,
+		&remainingEstimate,
 		&task.CreatedAt,
 		&task.UpdatedAt,
+		&task.Blocked,
 	)
-
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("task not found")
-		}
 		return nil, err
 	}
 
-	if assigneeID.Valid {
-		task.AssigneeID = assigneeID.String
-	}
+	task.ParentTaskID = parentTaskID.String
+	task.AssigneeID = assigneeID.String
 	if dueDate.Valid {
 		task.DueDate = dueDate.Time
 	}
-
+	task.RecurrenceID = recurrenceID.String
+	if occurrenceAt.Valid {
+		task.OccurrenceAt = &occurrenceAt.Time
+	}
+	if originalEstimate.Valid {
+		task.OriginalEstimate = &originalEstimate.Int64
+	}
+	if remainingEstimate.Valid {
+		task.RemainingEstimate = &remainingEstimate.Int64
+	}
 	return task, nil
 }
 
-// GetByProject gets all tasks for a project
-func (s *TaskStore) GetByProject(projectID string) ([]Task, error) {
-	query := `
-		SELECT id, title, description, status, priority, project_id, assignee_id, due_date, created_at, updated_at
-		FROM tasks
-		WHERE project_id = $1
-		ORDER BY created_at DESC
-	`
-	rows, err := s.DB.Query(query, projectID)
+// queryTasks runs a query selecting taskColumns and scans every row, loading
+// the labels and custom field values of all tasks at once
+func queryTasks(db *database.DB, query string, args ...interface{}) ([]Task, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -176,140 +245,234 @@ func (s *TaskStore) GetByProject(projectID string) ([]Task, error) {
 
 	var tasks []Task
 	for rows.Next() {
-		var task Task
-		var assigneeID sql.NullString
-		var dueDate sql.NullTime
-
-		err := rows.Scan(
-			&task.ID,
-			&task.Title,
-			&task.Description,
-			&task.Status,
-			&task.Priority,
-			&task.ProjectID,
-			&assigneeID,
-			&dueDate,
-			&task.CreatedAt,
-			&task.UpdatedAt,
-		)
+		task, err := scanTask(rows)
 		if err != nil {
 			return nil, err
 		}
+		tasks = append(tasks, *task)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
 
-		if assigneeID.Valid {
-			task.AssigneeID = assigneeID.String
+	if err := loadTaskDetails(db, tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// loadTaskDetails fills in the labels and custom field values of the tasks
+func loadTaskDetails(db *database.DB, tasks []Task) error {
+	if err := loadTaskLabels(db, tasks); err != nil {
+		return err
+	}
+	return loadTaskFieldValues(db, tasks)
+}
+
+// taskBatchSize bounds the number of task IDs inTaskBatches puts in one query
+const taskBatchSize = 500
+
+// inTaskBatches calls load with a list of placeholders for an IN clause and
+// the matching task IDs for every batch of tasks, so details of a task list
+// are loaded with a query per batch rather than one per task
+func inTaskBatches(tasks []Task, load func(in string, args []interface{}) error) error {
+	for start := 0; start < len(tasks); start += taskBatchSize {
+		batch := tasks[start:min(start+taskBatchSize, len(tasks))]
+		placeholders := make([]string, len(batch))
+		args := make([]interface{}, len(batch))
+		for i := range batch {
+			placeholders[i] = "$" + strconv.Itoa(i+1)
+			args[i] = batch[i].ID
 		}
-		if dueDate.Valid {
-			task.DueDate = dueDate.Time
+
+		if err := load(strings.Join(placeholders, ", "), args); err != nil {
+			return err
 		}
+	}
+	return nil
+}
+
+// GetAll gets all tasks
+func (s *TaskStore) GetAll() ([]Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks ORDER BY created_at DESC`
+	return queryTasks(s.DB, query)
+}
+
+// GetByID gets a task by ID
+func (s *TaskStore) GetByID(id string) (*Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE id = $1`
 
-		tasks = append(tasks, task)
+	task, err := scanTask(s.DB.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTaskNotFound
+		}
+		return nil, err
 	}
 
-	if err = rows.Err(); err != nil {
+	tasks := []Task{*task}
+	if err := loadTaskDetails(s.DB, tasks); err != nil {
 		return nil, err
 	}
+	return &tasks[0], nil
+}
 
-	return tasks, nil
+// GetByProject gets all tasks for a project
+func (s *TaskStore) GetByProject(projectID string) ([]Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE project_id = $1 ORDER BY created_at DESC`
+	return queryTasks(s.DB, query, projectID)
 }
 
-// GetByAssignee gets all tasks assigned to a user
-func (s *TaskStore) GetByAssignee(assigneeID string) ([]*Task, error) {
+// GetByUser gets all tasks in projects the user owns or is a member of
+func (s *TaskStore) GetByUser(userID string) ([]Task, error) {
 	query := `
-		SELECT id, title, description, status, priority, project_id, assignee_id, due_date, created_at, updated_at
+		SELECT ` + taskColumns + `
 		FROM tasks
-		WHERE assignee_id = $1
+		WHERE project_id IN (
+			SELECT id FROM projects WHERE owner_id = $1
+			UNION
+			SELECT project_id FROM project_members WHERE user_id = $1
+		)
 		ORDER BY created_at DESC
 	`
-	rows, err := s.DB.Query(query, assigneeID)
+	return queryTasks(s.DB, query, userID)
+}
+
+// GetByAssignee gets all tasks assigned to a user
+func (s *TaskStore) GetByAssignee(assigneeID string) ([]*Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE assignee_id = $1 ORDER BY created_at DESC`
+	tasks, err := queryTasks(s.DB, query, assigneeID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	tasks := []*Task{}
-	for rows.Next() {
-		task := &Task{}
-		var dueDate sql.NullTime
-
-		err := rows.Scan(
-			&task.ID,
-			&task.Title,
-			&task.Description,
-			&task.Status,
-			&task.Priority,
-			&task.ProjectID,
-			&task.AssigneeID,
-			&dueDate,
-			&task.CreatedAt,
-			&task.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
+	result := make([]*Task, len(tasks))
+	for i := range tasks {
+		result[i] = &tasks[i]
+	}
+	return result, nil
+}
 
-		if dueDate.Valid {
-			task.DueDate = dueDate.Time
-		}
+// GetChildren gets the direct subtasks of a task
+func (s *TaskStore) GetChildren(parentID string) ([]Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE parent_task_id = $1 ORDER BY created_at DESC`
+	return queryTasks(s.DB, query, parentID)
+}
 
-		tasks = append(tasks, task)
-	}
-	return tasks, nil
+// descendantsQuery selects the IDs of every subtask below the task $1, at any depth
+const descendantsQuery = `
+	WITH RECURSIVE descendants(id) AS (
+		SELECT id FROM tasks WHERE parent_task_id = $1
+		UNION
+		SELECT tasks.id FROM tasks JOIN descendants ON tasks.parent_task_id = descendants.id
+	)
+	SELECT id FROM descendants`
+
+// GetDescendants gets every subtask below a task, at any depth
+func (s *TaskStore) GetDescendants(id string) ([]Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE id IN (` + descendantsQuery + `) ORDER BY created_at DESC`
+	return queryTasks(s.DB, query, id)
 }
 
-// Update updates a task
+// Update updates a task. Its subtasks follow it when it moves to another
+// project, and they all lose the labels and custom field values of the
+// project they leave.
 func (s *TaskStore) Update(task *Task) error {
+	// Handle empty assigneeID as NULL in the database
+	var assigneeID interface{} = nil
+	if task.AssigneeID != "" {
+		assigneeID = task.AssigneeID
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
 		UPDATE tasks
-		SET title = $1, description = $2, status = $3, priority = $4, project_id = $5, assignee_id = $6, due_date = $7, updated_at = $8
-		WHERE id = $9
+		SET title = $1, description = $2, status = $3, status_category = $4, priority = $5, project_id = $6, parent_task_id = $7, assignee_id = $8, due_date = $9,
+			original_estimate = $10, remaining_estimate = $11, updated_at = $12
+		WHERE id = $13
 	`
-	_, err := s.DB.Exec(
+	_, err = tx.Exec(
 		query,
 		task.Title,
 		task.Description,
 		task.Status,
+		task.StatusCategory,
 		task.Priority,
 		task.ProjectID,
-		task.AssigneeID,
+		nullString(task.ParentTaskID),
+		assigneeID,
 		task.DueDate,
+		task.OriginalEstimate,
+		task.RemainingEstimate,
 		time.Now(),
 		task.ID,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	moveQuery := `UPDATE tasks SET project_id = $2 WHERE project_id <> $2 AND id IN (` + descendantsQuery + `)`
+	if _, err := tx.Exec(moveQuery, task.ID, task.ProjectID); err != nil {
+		return err
+	}
+
+	unlabelQuery := `
+		DELETE FROM task_labels
+		WHERE label_id IN (SELECT id FROM labels WHERE project_id <> $2)
+		AND (task_id = $1 OR task_id IN (` + descendantsQuery + `))`
+	if _, err := tx.Exec(unlabelQuery, task.ID, task.ProjectID); err != nil {
+		return err
+	}
+
+	clearFieldsQuery := `
+		DELETE FROM task_field_values
+		WHERE field_id IN (SELECT id FROM custom_fields WHERE project_id <> $2)
+		AND (task_id = $1 OR task_id IN (` + descendantsQuery + `))`
+	if _, err := tx.Exec(clearFieldsQuery, task.ID, task.ProjectID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// Delete deletes a task
+// Delete deletes a task along with its subtasks
 func (s *TaskStore) Delete(id string) error {
 	query := `DELETE FROM tasks WHERE id = $1`
 	_, err := s.DB.Exec(query, id)
 	return err
 }
 
+// DeleteKeepingChildren deletes a task and hands its direct subtasks to its
+// own parent, or makes them top-level tasks if it had none
+func (s *TaskStore) DeleteKeepingChildren(id string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE tasks SET parent_task_id = (SELECT parent_task_id FROM tasks WHERE id = $1) WHERE parent_task_id = $1`
+	if _, err := tx.Exec(query, id); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM tasks WHERE id = $1`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 // DeleteByProject deletes all tasks for a project
 func (s *TaskStore) DeleteByProject(projectID string) error {
 	query := `DELETE FROM tasks WHERE project_id = $1`
 	_, err := s.DB.Exec(query, projectID)
 	return err
 }
-
-// CreateTables creates the necessary tables for tasks
-func (s *TaskStore) CreateTables() error {
-	query := `
-		CREATE TABLE IF NOT EXISTS tasks (
-			id TEXT PRIMARY KEY,
-			title TEXT NOT NULL,
-			description TEXT,
-			status TEXT NOT NULL,
-			priority TEXT NOT NULL,
-			project_id TEXT NOT NULL,
-			assignee_id TEXT,
-			due_date TIMESTAMP,
-			created_at TIMESTAMP NOT NULL,
-			updated_at TIMESTAMP NOT NULL,
-			FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE CASCADE
-		)
-	`
-	_, err := s.DB.Exec(query)
-	return err
-}
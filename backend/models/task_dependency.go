@@ -0,0 +1,143 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go-react-redux-app/database"
+)
+
+var (
+	// ErrDependencyNotFound is returned when a task is not blocked by the given task
+	ErrDependencyNotFound = errors.New("task dependency not found")
+	// ErrDependencyExists is returned when a task is already blocked by the given task
+	ErrDependencyExists = errors.New("task dependency already exists")
+	// ErrDependencyCycle is returned when a dependency would make a task (indirectly) block itself
+	ErrDependencyCycle = errors.New("task dependency would create a cycle")
+)
+
+// TaskDependency records that one task blocks another: the blocked task
+// cannot be finished before its blocker
+type TaskDependency struct {
+	BlockerID string    `json:"blockerId"`
+	BlockedID string    `json:"blockedId"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TaskDependencyStore handles database operations for task dependencies
+type TaskDependencyStore struct {
+	DB *database.DB
+}
+
+// NewTaskDependencyStore creates a new TaskDependencyStore
+func NewTaskDependencyStore(db *database.DB) *TaskDependencyStore {
+	return &TaskDependencyStore{DB: db}
+}
+
+// Create adds a dependency. It returns ErrDependencyExists if the link is
+// already there and ErrDependencyCycle if the blocked task already blocks the
+// blocker, directly or through other tasks.
+func (s *TaskDependencyStore) Create(dependency *TaskDependency) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+	if dependency.BlockerID == dependency.BlockedID {
+		return ErrDependencyCycle
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Two links added at once could each pass the cycle check and close a
+	// cycle together, so links are added one at a time: the transaction takes
+	// a lock that conflicts with itself before reading. On SQLite a write
+	// statement takes the database write lock, waiting for the busy timeout.
+	lock := `LOCK TABLE task_dependencies IN SHARE ROW EXCLUSIVE MODE`
+	if s.DB.Dialect == database.SQLite {
+		lock = `DELETE FROM task_dependencies WHERE 1 = 0`
+	}
+	if _, err := tx.Exec(lock); err != nil {
+		return err
+	}
+
+	var exists bool
+	err = tx.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM task_dependencies WHERE blocker_id = $1 AND blocked_id = $2)`,
+		dependency.BlockerID, dependency.BlockedID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrDependencyExists
+	}
+
+	// Walk everything the blocked task blocks; reaching the blocker means a cycle
+	cycleQuery := `
+	WITH RECURSIVE blocked(id) AS (
+		SELECT blocked_id FROM task_dependencies WHERE blocker_id = $1
+		UNION
+		SELECT task_dependencies.blocked_id FROM task_dependencies JOIN blocked ON task_dependencies.blocker_id = blocked.id
+	)
+	SELECT COUNT(*) FROM blocked WHERE id = $2`
+
+	var reached int
+	if err := tx.QueryRow(cycleQuery, dependency.BlockedID, dependency.BlockerID).Scan(&reached); err != nil {
+		return err
+	}
+	if reached > 0 {
+		return ErrDependencyCycle
+	}
+
+	query := `
+	INSERT INTO task_dependencies (blocker_id, blocked_id, created_by, created_at)
+	VALUES ($1, $2, $3, $4)`
+
+	_, err = tx.Exec(query, dependency.BlockerID, dependency.BlockedID, nullString(dependency.CreatedBy), dependency.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a dependency
+func (s *TaskDependencyStore) Delete(blockerID string, blockedID string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	result, err := s.DB.Exec(`DELETE FROM task_dependencies WHERE blocker_id = $1 AND blocked_id = $2`, blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, ErrDependencyNotFound)
+}
+
+// GetBlockers gets the tasks blocking a task
+func (s *TaskDependencyStore) GetBlockers(taskID string) ([]Task, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	query := `SELECT ` + taskColumns + ` FROM tasks
+	WHERE id IN (SELECT blocker_id FROM task_dependencies WHERE blocked_id = $1)
+	ORDER BY created_at DESC`
+	return queryTasks(s.DB, query, taskID)
+}
+
+// GetBlocking gets the tasks a task blocks
+func (s *TaskDependencyStore) GetBlocking(taskID string) ([]Task, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	query := `SELECT ` + taskColumns + ` FROM tasks
+	WHERE id IN (SELECT blocked_id FROM task_dependencies WHERE blocker_id = $1)
+	ORDER BY created_at DESC`
+	return queryTasks(s.DB, query, taskID)
+}
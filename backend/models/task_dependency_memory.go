@@ -0,0 +1,115 @@
+package models
+
+import "errors"
+
+// dependencyKey identifies a dependency by its blocker and blocked task
+type dependencyKey struct {
+	blockerID string
+	blockedID string
+}
+
+// blockedLocked checks if any blocker of a task is not done. The caller must hold the lock.
+func (db *MemoryDB) blockedLocked(taskID string) bool {
+	for key := range db.dependencies {
+		if key.blockedID == taskID && !db.tasks[key.blockerID].IsDone() {
+			return true
+		}
+	}
+	return false
+}
+
+// MemoryTaskDependencyStore is an in-memory implementation of TaskDependencyRepository
+type MemoryTaskDependencyStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryTaskDependencyStore creates a new MemoryTaskDependencyStore
+func NewMemoryTaskDependencyStore(db *MemoryDB) *MemoryTaskDependencyStore {
+	return &MemoryTaskDependencyStore{DB: db}
+}
+
+// Create adds a dependency. It returns ErrDependencyExists if the link is
+// already there and ErrDependencyCycle if the blocked task already blocks the
+// blocker, directly or through other tasks.
+func (s *MemoryTaskDependencyStore) Create(dependency *TaskDependency) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.tasks[dependency.BlockerID]; !ok {
+		return errors.New("blocking task does not exist")
+	}
+	if _, ok := s.DB.tasks[dependency.BlockedID]; !ok {
+		return errors.New("blocked task does not exist")
+	}
+
+	key := dependencyKey{blockerID: dependency.BlockerID, blockedID: dependency.BlockedID}
+	if _, exists := s.DB.dependencies[key]; exists {
+		return ErrDependencyExists
+	}
+
+	// Walk everything the blocked task blocks; reaching the blocker means a cycle
+	seen := map[string]bool{dependency.BlockedID: true}
+	pending := []string{dependency.BlockedID}
+	for len(pending) > 0 {
+		id := pending[0]
+		pending = pending[1:]
+		if id == dependency.BlockerID {
+			return ErrDependencyCycle
+		}
+		for next := range s.DB.dependencies {
+			if next.blockerID == id && !seen[next.blockedID] {
+				seen[next.blockedID] = true
+				pending = append(pending, next.blockedID)
+			}
+		}
+	}
+
+	s.DB.dependencies[key] = *dependency
+	return nil
+}
+
+// Delete removes a dependency
+func (s *MemoryTaskDependencyStore) Delete(blockerID string, blockedID string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	key := dependencyKey{blockerID: blockerID, blockedID: blockedID}
+	if _, ok := s.DB.dependencies[key]; !ok {
+		return ErrDependencyNotFound
+	}
+	delete(s.DB.dependencies, key)
+	return nil
+}
+
+// GetBlockers gets the tasks blocking a task
+func (s *MemoryTaskDependencyStore) GetBlockers(taskID string) ([]Task, error) {
+	return s.linked(func(key dependencyKey) (string, bool) {
+		return key.blockerID, key.blockedID == taskID
+	}), nil
+}
+
+// GetBlocking gets the tasks a task blocks
+func (s *MemoryTaskDependencyStore) GetBlocking(taskID string) ([]Task, error) {
+	return s.linked(func(key dependencyKey) (string, bool) {
+		return key.blockedID, key.blockerID == taskID
+	}), nil
+}
+
+// linked returns copies of the tasks on the other end of the matching dependencies, newest first
+func (s *MemoryTaskDependencyStore) linked(match func(dependencyKey) (string, bool)) []Task {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	var tasks []Task
+	for key := range s.DB.dependencies {
+		if id, ok := match(key); ok {
+			task := s.DB.tasks[id]
+			task.Blocked = s.DB.blockedLocked(id)
+			task.Labels = s.DB.taskLabelsLocked(id)
+			task.CustomFields = s.DB.taskFieldValuesLocked(id)
+			tasks = append(tasks, task)
+		}
+	}
+	sortTasksByCreatedDesc(tasks)
+	return tasks
+}
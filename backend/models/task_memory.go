@@ -0,0 +1,244 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// MemoryTaskStore is an in-memory implementation of TaskRepository
+type MemoryTaskStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryTaskStore creates a new MemoryTaskStore
+func NewMemoryTaskStore(db *MemoryDB) *MemoryTaskStore {
+	return &MemoryTaskStore{DB: db}
+}
+
+// Create creates a new task
+func (s *MemoryTaskStore) Create(task Task) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, exists := s.DB.tasks[task.ID]; exists {
+		return errors.New("task already exists")
+	}
+	if err := s.checkReferencesLocked(&task); err != nil {
+		return err
+	}
+
+	task.Blocked = false
+	task.Progress = nil
+	task.Labels = nil
+	task.CustomFields = nil
+	s.DB.tasks[task.ID] = task
+	return nil
+}
+
+// GetAll gets all tasks
+func (s *MemoryTaskStore) GetAll() ([]Task, error) {
+	return s.filter(func(*Task) bool { return true }), nil
+}
+
+// GetByID gets a task by ID
+func (s *MemoryTaskStore) GetByID(id string) (*Task, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	task, ok := s.DB.tasks[id]
+	if !ok {
+		return nil, ErrTaskNotFound
+	}
+	task.Blocked = s.DB.blockedLocked(id)
+	task.Labels = s.DB.taskLabelsLocked(id)
+	task.CustomFields = s.DB.taskFieldValuesLocked(id)
+	return &task, nil
+}
+
+// GetByProject gets all tasks for a project
+func (s *MemoryTaskStore) GetByProject(projectID string) ([]Task, error) {
+	return s.filter(func(t *Task) bool { return t.ProjectID == projectID }), nil
+}
+
+// GetByUser gets all tasks in projects the user owns or is a member of
+func (s *MemoryTaskStore) GetByUser(userID string) ([]Task, error) {
+	return s.filter(func(t *Task) bool {
+		_, isMember := s.DB.members[t.ProjectID][userID]
+		return isMember || s.DB.projects[t.ProjectID].OwnerID == userID
+	}), nil
+}
+
+// GetByAssignee gets all tasks assigned to a user
+func (s *MemoryTaskStore) GetByAssignee(assigneeID string) ([]*Task, error) {
+	tasks := s.filter(func(t *Task) bool { return t.AssigneeID == assigneeID })
+
+	result := make([]*Task, len(tasks))
+	for i := range tasks {
+		result[i] = &tasks[i]
+	}
+	return result, nil
+}
+
+// GetChildren gets the direct subtasks of a task
+func (s *MemoryTaskStore) GetChildren(parentID string) ([]Task, error) {
+	return s.filter(func(t *Task) bool { return t.ParentTaskID == parentID }), nil
+}
+
+// GetDescendants gets every subtask below a task, at any depth
+func (s *MemoryTaskStore) GetDescendants(id string) ([]Task, error) {
+	s.DB.mu.RLock()
+	ids := s.descendantsLocked(id)
+	s.DB.mu.RUnlock()
+
+	return s.filter(func(t *Task) bool { return ids[t.ID] }), nil
+}
+
+// Update updates a task. Its subtasks follow it when it moves to another
+// project, and they all lose the labels and custom field values of the
+// project they leave.
+func (s *MemoryTaskStore) Update(task *Task) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	stored, ok := s.DB.tasks[task.ID]
+	if !ok {
+		return nil
+	}
+	if err := s.checkReferencesLocked(task); err != nil {
+		return err
+	}
+
+	stored.Title = task.Title
+	stored.Description = task.Description
+	stored.Status = task.Status
+	stored.StatusCategory = task.StatusCategory
+	stored.Priority = task.Priority
+	stored.ProjectID = task.ProjectID
+	stored.ParentTaskID = task.ParentTaskID
+	stored.AssigneeID = task.AssigneeID
+	stored.DueDate = task.DueDate
+	stored.OriginalEstimate = task.OriginalEstimate
+	stored.RemainingEstimate = task.RemainingEstimate
+	stored.UpdatedAt = time.Now()
+	s.DB.tasks[task.ID] = stored
+
+	moved := s.descendantsLocked(task.ID)
+	for id := range moved {
+		descendant := s.DB.tasks[id]
+		descendant.ProjectID = task.ProjectID
+		s.DB.tasks[id] = descendant
+	}
+
+	moved[task.ID] = true
+	for id := range moved {
+		for labelID := range s.DB.taskLabels[id] {
+			if s.DB.labels[labelID].ProjectID != task.ProjectID {
+				delete(s.DB.taskLabels[id], labelID)
+			}
+		}
+	}
+	for key := range s.DB.fieldValues {
+		if moved[key.taskID] && s.DB.fields[key.fieldID].ProjectID != task.ProjectID {
+			delete(s.DB.fieldValues, key)
+		}
+	}
+	return nil
+}
+
+// Delete deletes a task along with its subtasks
+func (s *MemoryTaskStore) Delete(id string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	for descendantID := range s.descendantsLocked(id) {
+		s.DB.deleteTaskLocked(descendantID)
+	}
+	s.DB.deleteTaskLocked(id)
+	return nil
+}
+
+// DeleteKeepingChildren deletes a task and hands its direct subtasks to its
+// own parent, or makes them top-level tasks if it had none
+func (s *MemoryTaskStore) DeleteKeepingChildren(id string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	task, ok := s.DB.tasks[id]
+	if !ok {
+		return nil
+	}
+	for childID, child := range s.DB.tasks {
+		if child.ParentTaskID == id {
+			child.ParentTaskID = task.ParentTaskID
+			s.DB.tasks[childID] = child
+		}
+	}
+	s.DB.deleteTaskLocked(id)
+	return nil
+}
+
+// descendantsLocked returns the IDs of every subtask below a task. The caller must hold the lock.
+func (s *MemoryTaskStore) descendantsLocked(id string) map[string]bool {
+	ids := make(map[string]bool)
+	pending := []string{id}
+	for len(pending) > 0 {
+		parentID := pending[0]
+		pending = pending[1:]
+		for childID, child := range s.DB.tasks {
+			if child.ParentTaskID == parentID && !ids[childID] && childID != id {
+				ids[childID] = true
+				pending = append(pending, childID)
+			}
+		}
+	}
+	return ids
+}
+
+// DeleteByProject deletes all tasks for a project
+func (s *MemoryTaskStore) DeleteByProject(projectID string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	for id, task := range s.DB.tasks {
+		if task.ProjectID == projectID {
+			s.DB.deleteTaskLocked(id)
+		}
+	}
+	return nil
+}
+
+// checkReferencesLocked enforces the task foreign keys. The caller must hold the lock.
+func (s *MemoryTaskStore) checkReferencesLocked(task *Task) error {
+	if _, ok := s.DB.projects[task.ProjectID]; !ok {
+		return errors.New("project does not exist")
+	}
+	if task.AssigneeID != "" {
+		if _, ok := s.DB.users[task.AssigneeID]; !ok {
+			return errors.New("assignee does not exist")
+		}
+	}
+	if task.ParentTaskID != "" {
+		if _, ok := s.DB.tasks[task.ParentTaskID]; !ok {
+			return errors.New("parent task does not exist")
+		}
+	}
+	return nil
+}
+
+// filter returns copies of the tasks matching the predicate, newest first
+func (s *MemoryTaskStore) filter(match func(*Task) bool) []Task {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	var tasks []Task
+	for _, task := range s.DB.tasks {
+		if match(&task) {
+			task.Blocked = s.DB.blockedLocked(task.ID)
+			task.Labels = s.DB.taskLabelsLocked(task.ID)
+			task.CustomFields = s.DB.taskFieldValuesLocked(task.ID)
+			tasks = append(tasks, task)
+		}
+	}
+	sortTasksByCreatedDesc(tasks)
+	return tasks
+}
@@ -0,0 +1,235 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"go-react-redux-app/database"
+)
+
+// TwoFactor is the TOTP two-factor authentication setup of a user
+type TwoFactor struct {
+	UserID string `json:"-"`
+	Secret string `json:"-"`
+	// LastUsedStep is the time step of the last accepted code, so a code can't be replayed
+	LastUsedStep int64      `json:"-"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	ConfirmedAt  *time.Time `json:"confirmedAt,omitempty"`
+}
+
+// IsEnabled checks if the enrollment was confirmed, which turns two-factor authentication on
+func (t *TwoFactor) IsEnabled() bool {
+	return t.ConfirmedAt != nil
+}
+
+// TwoFactorStore handles database operations for two-factor authentication
+type TwoFactorStore struct {
+	DB *database.DB
+}
+
+// NewTwoFactorStore creates a new TwoFactorStore
+func NewTwoFactorStore(db *database.DB) *TwoFactorStore {
+	return &TwoFactorStore{DB: db}
+}
+
+// Get gets the two-factor setup of a user
+func (s *TwoFactorStore) Get(userID string) (*TwoFactor, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	query := `
+	SELECT user_id, secret, last_used_step, created_at, confirmed_at
+	FROM user_two_factor
+	WHERE user_id = $1`
+
+	twoFactor := &TwoFactor{}
+	var confirmedAt sql.NullTime
+	err := s.DB.QueryRow(query, userID).Scan(
+		&twoFactor.UserID,
+		&twoFactor.Secret,
+		&twoFactor.LastUsedStep,
+		&twoFactor.CreatedAt,
+		&confirmedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTwoFactorNotFound
+		}
+		return nil, err
+	}
+
+	if confirmedAt.Valid {
+		twoFactor.ConfirmedAt = &confirmedAt.Time
+	}
+	return twoFactor, nil
+}
+
+// Enroll stores a new unconfirmed setup, replacing any earlier unconfirmed one
+func (s *TwoFactorStore) Enroll(twoFactor *TwoFactor) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM user_two_factor WHERE user_id = $1 AND confirmed_at IS NULL`, twoFactor.UserID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO user_two_factor (user_id, secret, last_used_step, created_at)
+	VALUES ($1, $2, $3, $4)`
+
+	_, err = tx.Exec(query, twoFactor.UserID, twoFactor.Secret, twoFactor.LastUsedStep, twoFactor.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Confirm turns two-factor authentication on, records the step of the code
+// that confirmed it and replaces the user's recovery codes
+func (s *TwoFactorStore) Confirm(userID string, step int64, recoveryCodeHashes []string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(
+		`UPDATE user_two_factor SET confirmed_at = $1, last_used_step = $2 WHERE user_id = $3 AND confirmed_at IS NULL`,
+		now, step, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result, ErrTwoFactorNotFound); err != nil {
+		return err
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes, now); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseStep records that the code of a time step was accepted. It returns false
+// if a code of that step or a later one was already used.
+func (s *TwoFactorStore) UseStep(userID string, step int64) (bool, error) {
+	if s.DB == nil {
+		return false, errors.New("database connection is nil")
+	}
+
+	query := `UPDATE user_two_factor SET last_used_step = $1 WHERE user_id = $2 AND last_used_step < $1`
+	result, err := s.DB.Exec(query, step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// ReplaceRecoveryCodes replaces all recovery codes of a user
+func (s *TwoFactorStore) ReplaceRecoveryCodes(userID string, hashes []string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, hashes, time.Now()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// replaceRecoveryCodes deletes the recovery codes of a user and inserts new ones within a transaction
+func replaceRecoveryCodes(tx *database.Tx, userID string, hashes []string, now time.Time) error {
+	_, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO recovery_codes (id, user_id, code_hash, created_at)
+	VALUES ($1, $2, $3, $4)`
+
+	for _, hash := range hashes {
+		if _, err := tx.Exec(query, uuid.New().String(), userID, hash, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode marks the unused recovery code with the given hash as used.
+// It returns false if the user has no such unused code.
+func (s *TwoFactorStore) UseRecoveryCode(userID string, hash string) (bool, error) {
+	if s.DB == nil {
+		return false, errors.New("database connection is nil")
+	}
+
+	query := `UPDATE recovery_codes SET used_at = $1 WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL`
+	result, err := s.DB.Exec(query, time.Now(), userID, hash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CountRecoveryCodes counts the unused recovery codes of a user
+func (s *TwoFactorStore) CountRecoveryCodes(userID string) (int, error) {
+	if s.DB == nil {
+		return 0, errors.New("database connection is nil")
+	}
+
+	var count int
+	query := `SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+	err := s.DB.QueryRow(query, userID).Scan(&count)
+	return count, err
+}
+
+// Delete turns two-factor authentication off and deletes the recovery codes
+func (s *TwoFactorStore) Delete(userID string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM user_two_factor WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
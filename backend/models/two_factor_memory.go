@@ -0,0 +1,148 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// RecoveryCode is a single-use code that replaces a TOTP code when the authenticator is lost
+type RecoveryCode struct {
+	CodeHash string
+	UsedAt   *time.Time
+}
+
+// MemoryTwoFactorStore is an in-memory implementation of TwoFactorRepository
+type MemoryTwoFactorStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryTwoFactorStore creates a new MemoryTwoFactorStore
+func NewMemoryTwoFactorStore(db *MemoryDB) *MemoryTwoFactorStore {
+	return &MemoryTwoFactorStore{DB: db}
+}
+
+// Get gets the two-factor setup of a user
+func (s *MemoryTwoFactorStore) Get(userID string) (*TwoFactor, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	twoFactor, ok := s.DB.twoFactors[userID]
+	if !ok {
+		return nil, ErrTwoFactorNotFound
+	}
+	return &twoFactor, nil
+}
+
+// Enroll stores a new unconfirmed setup, replacing any earlier unconfirmed one
+func (s *MemoryTwoFactorStore) Enroll(twoFactor *TwoFactor) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.users[twoFactor.UserID]; !ok {
+		return errors.New("user does not exist")
+	}
+	if existing, ok := s.DB.twoFactors[twoFactor.UserID]; ok && existing.IsEnabled() {
+		return errors.New("two-factor authentication is already enabled")
+	}
+
+	stored := *twoFactor
+	stored.ConfirmedAt = nil
+	s.DB.twoFactors[twoFactor.UserID] = stored
+	return nil
+}
+
+// Confirm turns two-factor authentication on, records the step of the code
+// that confirmed it and replaces the user's recovery codes
+func (s *MemoryTwoFactorStore) Confirm(userID string, step int64, recoveryCodeHashes []string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	twoFactor, ok := s.DB.twoFactors[userID]
+	if !ok || twoFactor.IsEnabled() {
+		return ErrTwoFactorNotFound
+	}
+
+	now := time.Now()
+	twoFactor.ConfirmedAt = &now
+	twoFactor.LastUsedStep = step
+	s.DB.twoFactors[userID] = twoFactor
+	s.DB.recoveryCodes[userID] = newRecoveryCodes(recoveryCodeHashes)
+	return nil
+}
+
+// UseStep records that the code of a time step was accepted. It returns false
+// if a code of that step or a later one was already used.
+func (s *MemoryTwoFactorStore) UseStep(userID string, step int64) (bool, error) {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	twoFactor, ok := s.DB.twoFactors[userID]
+	if !ok || twoFactor.LastUsedStep >= step {
+		return false, nil
+	}
+	twoFactor.LastUsedStep = step
+	s.DB.twoFactors[userID] = twoFactor
+	return true, nil
+}
+
+// ReplaceRecoveryCodes replaces all recovery codes of a user
+func (s *MemoryTwoFactorStore) ReplaceRecoveryCodes(userID string, hashes []string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.users[userID]; !ok {
+		return errors.New("user does not exist")
+	}
+	s.DB.recoveryCodes[userID] = newRecoveryCodes(hashes)
+	return nil
+}
+
+// newRecoveryCodes creates unused recovery codes from their hashes
+func newRecoveryCodes(hashes []string) []RecoveryCode {
+	codes := make([]RecoveryCode, len(hashes))
+	for i, hash := range hashes {
+		codes[i] = RecoveryCode{CodeHash: hash}
+	}
+	return codes
+}
+
+// UseRecoveryCode marks the unused recovery code with the given hash as used.
+// It returns false if the user has no such unused code.
+func (s *MemoryTwoFactorStore) UseRecoveryCode(userID string, hash string) (bool, error) {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	codes := s.DB.recoveryCodes[userID]
+	for i := range codes {
+		if codes[i].CodeHash == hash && codes[i].UsedAt == nil {
+			now := time.Now()
+			codes[i].UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CountRecoveryCodes counts the unused recovery codes of a user
+func (s *MemoryTwoFactorStore) CountRecoveryCodes(userID string) (int, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	count := 0
+	for _, code := range s.DB.recoveryCodes[userID] {
+		if code.UsedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Delete turns two-factor authentication off and deletes the recovery codes
+func (s *MemoryTwoFactorStore) Delete(userID string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	delete(s.DB.twoFactors, userID)
+	delete(s.DB.recoveryCodes, userID)
+	return nil
+}
@@ -3,57 +3,74 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"go-react-redux-app/database"
+	"go-react-redux-app/password"
 )
 
+// passwords hashes and verifies user passwords. The stores and User methods
+// share it so that every hash is made the same way.
+var passwords = password.NewDefaultManager()
+
+// SetPasswordManager sets how user passwords are hashed. It is meant to be
+// called once at startup, before any request is served.
+func SetPasswordManager(manager *password.Manager) {
+	passwords = manager
+}
+
 // User represents a user in the system
 type User struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"` // Password is never sent to client
-	FirstName string    `json:"firstName"`
-	LastName  string    `json:"lastName"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID            string     `json:"id"`
+	Username      string     `json:"username"`
+	Email         string     `json:"email"`
+	Password      string     `json:"-"` // Password is never sent to client
+	FirstName     string     `json:"firstName"`
+	LastName      string     `json:"lastName"`
+	Role          string     `json:"role"`
+	EmailVerified bool       `json:"emailVerified"`
+	DisabledAt    *time.Time `json:"disabledAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// Global user roles
+const (
+	UserRoleUser  = "user"
+	UserRoleAdmin = "admin"
+)
+
+// IsValidUserRole checks if a role is one of the global user roles
+func IsValidUserRole(role string) bool {
+	return role == UserRoleUser || role == UserRoleAdmin
+}
+
+// IsDisabled checks if the account has been disabled by an admin
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+// UserQuery filters and paginates the users returned by List
+type UserQuery struct {
+	// Search matches a case-insensitive substring of the username, email or name
+	Search string
+	Role   string
+	Limit  int
+	Offset int
 }
 
 // UserStore handles database operations for users
 type UserStore struct {
-	DB *sql.DB
+	DB *database.DB
 }
 
 // NewUserStore creates a new UserStore
-func NewUserStore(db *sql.DB) *UserStore {
+func NewUserStore(db *database.DB) *UserStore {
 	return &UserStore{DB: db}
 }
 
-// CreateTables creates the necessary tables for users
-func (s *UserStore) CreateTables() error {
-	if s.DB == nil {
-		return errors.New("database connection is nil")
-	}
-
-	query := `
-	CREATE TABLE IF NOT EXISTS users (
-		id VARCHAR(36) PRIMARY KEY,
-		username VARCHAR(50) UNIQUE NOT NULL,
-		email VARCHAR(100) UNIQUE NOT NULL,
-		password VARCHAR(100) NOT NULL,
-		first_name VARCHAR(50),
-		last_name VARCHAR(50),
-		role VARCHAR(20) NOT NULL DEFAULT 'user',
-		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
-	)`
-
-	_, err := s.DB.Exec(query)
-	return err
-}
-
 // Create creates a new user
 func (s *UserStore) Create(user *User) error {
 	if s.DB == nil {
@@ -61,24 +78,25 @@ func (s *UserStore) Create(user *User) error {
 	}
 
 	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hashedPassword, err := passwords.Hash(user.Password)
 	if err != nil {
 		return err
 	}
 
 	query := `
-	INSERT INTO users (id, username, email, password, first_name, last_name, role, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	INSERT INTO users (id, username, email, password, first_name, last_name, role, email_verified, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
 
 	_, err = s.DB.Exec(
 		query,
 		user.ID,
 		user.Username,
 		user.Email,
-		string(hashedPassword),
+		hashedPassword,
 		user.FirstName,
 		user.LastName,
 		user.Role,
+		user.EmailVerified,
 		user.CreatedAt,
 		user.UpdatedAt,
 	)
@@ -86,66 +104,120 @@ func (s *UserStore) Create(user *User) error {
 	return err
 }
 
-// GetByID gets a user by ID
-func (s *UserStore) GetByID(id string) (*User, error) {
-	if s.DB == nil {
-		return nil, errors.New("database connection is nil")
-	}
+// userColumns lists the users columns in the order scanUser reads them
+const userColumns = `id, username, email, password, first_name, last_name, role, email_verified, disabled_at, created_at, updated_at`
 
-	query := `
-	SELECT id, username, email, password, first_name, last_name, role, created_at, updated_at
-	FROM users
-	WHERE id = $1`
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
+// scanUser scans a row selected with userColumns into a User
+func scanUser(row rowScanner) (*User, error) {
 	user := &User{}
-	err := s.DB.QueryRow(query, id).Scan(
+	var firstName, lastName sql.NullString
+	var disabledAt sql.NullTime
+	err := row.Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.Password,
-		&user.FirstName,
-		&user.LastName,
+		&firstName,
+		&lastName,
 		&user.Role,
+		&user.EmailVerified,
+		&disabledAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
-
 	if err != nil {
 		return nil, err
 	}
 
+	user.FirstName = firstName.String
+	user.LastName = lastName.String
+	if disabledAt.Valid {
+		user.DisabledAt = &disabledAt.Time
+	}
 	return user, nil
 }
 
+// getOne gets the single user matching a column value
+func (s *UserStore) getOne(column string, value string) (*User, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE ` + column + ` = $1`
+	return scanUser(s.DB.QueryRow(query, value))
+}
+
+// GetByID gets a user by ID
+func (s *UserStore) GetByID(id string) (*User, error) {
+	return s.getOne("id", id)
+}
+
 // GetByUsername gets a user by username
 func (s *UserStore) GetByUsername(username string) (*User, error) {
+	return s.getOne("username", username)
+}
+
+// GetByEmail gets a user by email address
+func (s *UserStore) GetByEmail(email string) (*User, error) {
+	return s.getOne("email", email)
+}
+
+// List gets the users matching the query, newest first, along with the
+// total number of matching users
+func (s *UserStore) List(q UserQuery) ([]*User, int, error) {
 	if s.DB == nil {
-		return nil, errors.New("database connection is nil")
+		return nil, 0, errors.New("database connection is nil")
 	}
 
-	query := `
-	SELECT id, username, email, password, first_name, last_name, role, created_at, updated_at
-	FROM users
-	WHERE username = $1`
+	var conditions []string
+	var args []interface{}
+	if q.Search != "" {
+		args = append(args, "%"+strings.ToLower(q.Search)+"%")
+		n := len(args)
+		conditions = append(conditions, fmt.Sprintf(
+			"(LOWER(username) LIKE $%[1]d OR LOWER(email) LIKE $%[1]d OR LOWER(first_name) LIKE $%[1]d OR LOWER(last_name) LIKE $%[1]d)", n))
+	}
+	if q.Role != "" {
+		args = append(args, q.Role)
+		conditions = append(conditions, fmt.Sprintf("role = $%d", len(args)))
+	}
 
-	user := &User{}
-	err := s.DB.QueryRow(query, username).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.Password,
-		&user.FirstName,
-		&user.LastName,
-		&user.Role,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
 
+	var total int
+	err := s.DB.QueryRow(`SELECT COUNT(*) FROM users`+where, args...).Scan(&total)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	return user, nil
+	args = append(args, q.Limit, q.Offset)
+	query := fmt.Sprintf(`SELECT %s FROM users%s ORDER BY created_at DESC, id LIMIT $%d OFFSET $%d`,
+		userColumns, where, len(args)-1, len(args))
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+
+	return users, total, rows.Err()
 }
 
 // Update updates a user
@@ -173,6 +245,44 @@ func (s *UserStore) Update(user *User) error {
 	return err
 }
 
+// UpdatePassword stores a new password hash for a user
+func (s *UserStore) UpdatePassword(id string, hashedPassword string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `UPDATE users SET password = $1, updated_at = $2 WHERE id = $3`
+	_, err := s.DB.Exec(query, hashedPassword, time.Now(), id)
+	return err
+}
+
+// SetEmailVerified marks a user's email address as verified or unverified
+func (s *UserStore) SetEmailVerified(id string, verified bool) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	query := `UPDATE users SET email_verified = $1, updated_at = $2 WHERE id = $3`
+	_, err := s.DB.Exec(query, verified, time.Now(), id)
+	return err
+}
+
+// SetDisabled disables or re-enables a user's account
+func (s *UserStore) SetDisabled(id string, disabled bool) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	var disabledAt interface{}
+	if disabled {
+		disabledAt = time.Now()
+	}
+
+	query := `UPDATE users SET disabled_at = $1, updated_at = $2 WHERE id = $3`
+	_, err := s.DB.Exec(query, disabledAt, time.Now(), id)
+	return err
+}
+
 // Delete deletes a user
 func (s *UserStore) Delete(id string) error {
 	if s.DB == nil {
@@ -186,16 +296,22 @@ func (s *UserStore) Delete(id string) error {
 
 // CheckPassword checks if the provided password matches the stored hash
 func (u *User) CheckPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-	return err == nil
+	ok, err := passwords.Verify(password, u.Password)
+	return err == nil && ok
+}
+
+// PasswordNeedsRehash checks if the stored hash was made with another
+// algorithm or outdated parameters and should be replaced at the next login
+func (u *User) PasswordNeedsRehash() bool {
+	return passwords.NeedsRehash(u.Password)
 }
 
 // SetPassword sets a new password for the user
 func (u *User) SetPassword(password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := passwords.Hash(password)
 	if err != nil {
 		return err
 	}
-	u.Password = string(hashedPassword)
+	u.Password = hashedPassword
 	return nil
 }
@@ -0,0 +1,214 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MemoryUserStore is an in-memory implementation of UserRepository
+type MemoryUserStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryUserStore creates a new MemoryUserStore
+func NewMemoryUserStore(db *MemoryDB) *MemoryUserStore {
+	return &MemoryUserStore{DB: db}
+}
+
+// Create creates a new user
+func (s *MemoryUserStore) Create(user *User) error {
+	// Hash the password
+	hashedPassword, err := passwords.Hash(user.Password)
+	if err != nil {
+		return err
+	}
+
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, exists := s.DB.users[user.ID]; exists {
+		return errors.New("user already exists")
+	}
+	for _, existing := range s.DB.users {
+		if existing.Username == user.Username {
+			return errors.New("username already exists")
+		}
+		if existing.Email == user.Email {
+			return errors.New("email already exists")
+		}
+	}
+
+	stored := *user
+	stored.Password = hashedPassword
+	s.DB.users[user.ID] = stored
+	return nil
+}
+
+// GetByID gets a user by ID
+func (s *MemoryUserStore) GetByID(id string) (*User, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	user, ok := s.DB.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &user, nil
+}
+
+// GetByUsername gets a user by username
+func (s *MemoryUserStore) GetByUsername(username string) (*User, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	for _, user := range s.DB.users {
+		if user.Username == username {
+			return &user, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetByEmail gets a user by email address
+func (s *MemoryUserStore) GetByEmail(email string) (*User, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	for _, user := range s.DB.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// List gets the users matching the query, newest first, along with the
+// total number of matching users
+func (s *MemoryUserStore) List(q UserQuery) ([]*User, int, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	search := strings.ToLower(q.Search)
+	var matches []*User
+	for _, user := range s.DB.users {
+		if q.Role != "" && user.Role != q.Role {
+			continue
+		}
+		if search != "" && !containsFold(search, user.Username, user.Email, user.FirstName, user.LastName) {
+			continue
+		}
+		user := user
+		matches = append(matches, &user)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].CreatedAt.Equal(matches[j].CreatedAt) {
+			return matches[i].CreatedAt.After(matches[j].CreatedAt)
+		}
+		return matches[i].ID < matches[j].ID
+	})
+
+	total := len(matches)
+	start := min(q.Offset, total)
+	end := min(start+q.Limit, total)
+	return append([]*User{}, matches[start:end]...), total, nil
+}
+
+// containsFold checks if any of the values contains the lower-cased substring
+func containsFold(substr string, values ...string) bool {
+	for _, value := range values {
+		if strings.Contains(strings.ToLower(value), substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Update updates a user
+func (s *MemoryUserStore) Update(user *User) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	stored, ok := s.DB.users[user.ID]
+	if !ok {
+		return nil
+	}
+	for id, existing := range s.DB.users {
+		if id == user.ID {
+			continue
+		}
+		if existing.Username == user.Username {
+			return errors.New("username already exists")
+		}
+		if existing.Email == user.Email {
+			return errors.New("email already exists")
+		}
+	}
+
+	stored.Username = user.Username
+	stored.Email = user.Email
+	stored.FirstName = user.FirstName
+	stored.LastName = user.LastName
+	stored.Role = user.Role
+	stored.UpdatedAt = time.Now()
+	s.DB.users[user.ID] = stored
+	return nil
+}
+
+// UpdatePassword stores a new password hash for a user
+func (s *MemoryUserStore) UpdatePassword(id string, hashedPassword string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	user, ok := s.DB.users[id]
+	if ok {
+		user.Password = hashedPassword
+		user.UpdatedAt = time.Now()
+		s.DB.users[id] = user
+	}
+	return nil
+}
+
+// SetEmailVerified marks a user's email address as verified or unverified
+func (s *MemoryUserStore) SetEmailVerified(id string, verified bool) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	user, ok := s.DB.users[id]
+	if ok {
+		user.EmailVerified = verified
+		user.UpdatedAt = time.Now()
+		s.DB.users[id] = user
+	}
+	return nil
+}
+
+// SetDisabled disables or re-enables a user's account
+func (s *MemoryUserStore) SetDisabled(id string, disabled bool) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	user, ok := s.DB.users[id]
+	if ok {
+		user.DisabledAt = nil
+		if disabled {
+			now := time.Now()
+			user.DisabledAt = &now
+		}
+		user.UpdatedAt = time.Now()
+		s.DB.users[id] = user
+	}
+	return nil
+}
+
+// Delete deletes a user
+func (s *MemoryUserStore) Delete(id string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	s.DB.deleteUserLocked(id)
+	return nil
+}
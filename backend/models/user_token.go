@@ -0,0 +1,174 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-react-redux-app/database"
+)
+
+// Purposes of single-use user tokens
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	// TokenPurposeOIDCLogin is the one-time code the frontend exchanges for a session after an SSO login
+	TokenPurposeOIDCLogin = "oidc_login"
+	// TokenPurposeOIDCLink is the ticket that lets a browser start linking a provider account
+	TokenPurposeOIDCLink = "oidc_link"
+)
+
+// UserToken is a single-use, expiring token handed to a user, usually by email
+type UserToken struct {
+	ID        string
+	UserID    string
+	Purpose   string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// UserTokenStore handles database operations for user tokens
+type UserTokenStore struct {
+	DB *database.DB
+}
+
+// NewUserTokenStore creates a new UserTokenStore
+func NewUserTokenStore(db *database.DB) *UserTokenStore {
+	return &UserTokenStore{DB: db}
+}
+
+// Create stores a new token, invalidating any unused token the user has for the same purpose
+func (s *UserTokenStore) Create(token *UserToken) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`,
+		token.UserID,
+		token.Purpose,
+	)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO user_tokens (id, user_id, purpose, token_hash, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err = tx.Exec(query, token.ID, token.UserID, token.Purpose, token.TokenHash, token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Get returns the unused, unexpired token with the given hash and purpose
+// without marking it as used, or ErrUserTokenInvalid
+func (s *UserTokenStore) Get(hash string, purpose string) (*UserToken, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	query := `
+	SELECT id, user_id, purpose, token_hash, created_at, expires_at, used_at
+	FROM user_tokens
+	WHERE token_hash = $1 AND purpose = $2`
+
+	token := &UserToken{}
+	var usedAt sql.NullTime
+	err := s.DB.QueryRow(query, hash, purpose).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Purpose,
+		&token.TokenHash,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&usedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserTokenInvalid
+		}
+		return nil, err
+	}
+
+	if usedAt.Valid || time.Now().After(token.ExpiresAt) {
+		return nil, ErrUserTokenInvalid
+	}
+
+	return token, nil
+}
+
+// Consume marks the unused, unexpired token with the given hash and purpose
+// as used and returns it. It returns ErrUserTokenInvalid otherwise, so each
+// token can be redeemed exactly once.
+func (s *UserTokenStore) Consume(hash string, purpose string) (*UserToken, error) {
+	token, err := s.Get(hash, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only one concurrent request can flip used_at
+	result, err := s.DB.Exec(`UPDATE user_tokens SET used_at = $1 WHERE id = $2 AND used_at IS NULL`, time.Now(), token.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireAffected(result, ErrUserTokenInvalid); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// ResetPassword consumes a password reset token and stores the new password
+// hash of its user in one transaction, so a token is only used up by a reset
+// that happens. It returns ErrUserTokenInvalid if the token can't be redeemed.
+func (s *UserTokenStore) ResetPassword(token *UserToken, hashedPassword string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	now := time.Now()
+	if token.Purpose != TokenPurposePasswordReset || now.After(token.ExpiresAt) {
+		return ErrUserTokenInvalid
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE user_tokens SET used_at = $1 WHERE id = $2 AND purpose = $3 AND used_at IS NULL`,
+		now,
+		token.ID,
+		TokenPurposePasswordReset,
+	)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result, ErrUserTokenInvalid); err != nil {
+		return err
+	}
+
+	result, err = tx.Exec(`UPDATE users SET password = $1, updated_at = $2 WHERE id = $3`, hashedPassword, now, token.UserID)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result, ErrUserTokenInvalid); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// MemoryUserTokenStore is an in-memory implementation of UserTokenRepository
+type MemoryUserTokenStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryUserTokenStore creates a new MemoryUserTokenStore
+func NewMemoryUserTokenStore(db *MemoryDB) *MemoryUserTokenStore {
+	return &MemoryUserTokenStore{DB: db}
+}
+
+// Create stores a new token, invalidating any unused token the user has for the same purpose
+func (s *MemoryUserTokenStore) Create(token *UserToken) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.users[token.UserID]; !ok {
+		return errors.New("user does not exist")
+	}
+
+	for id, existing := range s.DB.userTokens {
+		if existing.UserID == token.UserID && existing.Purpose == token.Purpose && existing.UsedAt == nil {
+			delete(s.DB.userTokens, id)
+		}
+	}
+
+	stored := *token
+	stored.UsedAt = nil
+	s.DB.userTokens[token.ID] = stored
+	return nil
+}
+
+// Get returns the unused, unexpired token with the given hash and purpose
+// without marking it as used, or ErrUserTokenInvalid
+func (s *MemoryUserTokenStore) Get(hash string, purpose string) (*UserToken, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	token, ok := s.DB.validUserTokenLocked(hash, purpose)
+	if !ok {
+		return nil, ErrUserTokenInvalid
+	}
+	return &token, nil
+}
+
+// Consume marks the unused, unexpired token with the given hash and purpose
+// as used and returns it, or returns ErrUserTokenInvalid
+func (s *MemoryUserTokenStore) Consume(hash string, purpose string) (*UserToken, error) {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	token, ok := s.DB.validUserTokenLocked(hash, purpose)
+	if !ok {
+		return nil, ErrUserTokenInvalid
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	s.DB.userTokens[token.ID] = token
+	return &token, nil
+}
+
+// ResetPassword consumes a password reset token and stores the new password
+// hash of its user at once, or returns ErrUserTokenInvalid
+func (s *MemoryUserTokenStore) ResetPassword(token *UserToken, hashedPassword string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	stored, ok := s.DB.validUserTokenLocked(token.TokenHash, TokenPurposePasswordReset)
+	if !ok || stored.ID != token.ID {
+		return ErrUserTokenInvalid
+	}
+	user, ok := s.DB.users[stored.UserID]
+	if !ok {
+		return ErrUserTokenInvalid
+	}
+
+	now := time.Now()
+	stored.UsedAt = &now
+	s.DB.userTokens[stored.ID] = stored
+	user.Password = hashedPassword
+	user.UpdatedAt = now
+	s.DB.users[user.ID] = user
+	return nil
+}
+
+// validUserTokenLocked finds the unused, unexpired token with the given hash
+// and purpose. The caller must hold the lock.
+func (db *MemoryDB) validUserTokenLocked(hash string, purpose string) (UserToken, bool) {
+	for _, token := range db.userTokens {
+		if token.TokenHash != hash || token.Purpose != purpose {
+			continue
+		}
+		if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+			return UserToken{}, false
+		}
+		return token, true
+	}
+	return UserToken{}, false
+}
@@ -0,0 +1,250 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go-react-redux-app/database"
+)
+
+// Status categories group the states of a workflow by how far along a task is
+const (
+	StatusCategoryTodo       = "todo"
+	StatusCategoryInProgress = "in_progress"
+	StatusCategoryDone       = "done"
+)
+
+// AnyState as the source of a transition matches every state
+const AnyState = "*"
+
+// Task properties a transition can require besides custom fields
+const (
+	RequiredAssignee    = "assignee"
+	RequiredDueDate     = "dueDate"
+	RequiredDescription = "description"
+)
+
+// WorkflowState is a status tasks of a project can have
+type WorkflowState struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	// aliases are other spellings of the state that statuses resolve to
+	aliases []string
+}
+
+// WorkflowTransition allows tasks to move from one state to another
+type WorkflowTransition struct {
+	// From is the name of a state, or AnyState
+	From string `json:"from"`
+	To   string `json:"to"`
+	// RequiredFields lists the task properties (assignee, dueDate and
+	// description) and custom field IDs that have to be set to make the move
+	RequiredFields []string `json:"requiredFields,omitempty"`
+}
+
+// Workflow defines the statuses of a project's tasks and how they may change
+type Workflow struct {
+	ProjectID string `json:"projectId"`
+	// States lists the statuses in display order; new tasks start in the first
+	States []WorkflowState `json:"states"`
+	// Transitions lists the allowed status changes. A workflow without
+	// transitions lets tasks move between any of its states.
+	Transitions []WorkflowTransition `json:"transitions"`
+	// Default is set when the project has not defined a workflow of its own
+	Default   bool       `json:"default"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// DefaultWorkflow returns the workflow of projects that have not defined
+// their own: Pending, In Progress and Completed, with any move allowed. The
+// spellings tasks used before workflows existed, such as the todo status
+// tasks were created with, resolve to its states.
+func DefaultWorkflow(projectID string) *Workflow {
+	return &Workflow{
+		ProjectID: projectID,
+		States: []WorkflowState{
+			{Name: "Pending", Category: StatusCategoryTodo, aliases: []string{"todo", "to do", "open", "new"}},
+			{Name: "In Progress", Category: StatusCategoryInProgress, aliases: []string{"in_progress", "in-progress", "inprogress", "doing", "started"}},
+			{Name: "Completed", Category: StatusCategoryDone, aliases: []string{"complete", "done", "closed", "finished"}},
+		},
+		Transitions: []WorkflowTransition{},
+		Default:     true,
+	}
+}
+
+// IsStatusCategory checks if a string is one of the status categories
+func IsStatusCategory(category string) bool {
+	switch category {
+	case StatusCategoryTodo, StatusCategoryInProgress, StatusCategoryDone:
+		return true
+	}
+	return false
+}
+
+// State finds a state by name or alias, ignoring case
+func (w *Workflow) State(name string) *WorkflowState {
+	for i := range w.States {
+		if strings.EqualFold(w.States[i].Name, name) {
+			return &w.States[i]
+		}
+	}
+	for i := range w.States {
+		for _, alias := range w.States[i].aliases {
+			if strings.EqualFold(alias, name) {
+				return &w.States[i]
+			}
+		}
+	}
+	return nil
+}
+
+// Initial returns the state new tasks start in
+func (w *Workflow) Initial() WorkflowState {
+	return w.States[0]
+}
+
+// Transition finds the transition that allows moving from one state to
+// another, preferring one from the exact state over one from AnyState. It
+// reports false if the move is not allowed, and returns a nil transition
+// when the workflow allows every move.
+func (w *Workflow) Transition(from string, to string) (*WorkflowTransition, bool) {
+	if len(w.Transitions) == 0 {
+		return nil, true
+	}
+
+	var fallback *WorkflowTransition
+	for i := range w.Transitions {
+		transition := &w.Transitions[i]
+		if transition.To != to {
+			continue
+		}
+		if transition.From == from {
+			return transition, true
+		}
+		if transition.From == AnyState && fallback == nil {
+			fallback = transition
+		}
+	}
+	return fallback, fallback != nil
+}
+
+// WorkflowStore handles database operations for project workflows
+type WorkflowStore struct {
+	DB *database.DB
+}
+
+// NewWorkflowStore creates a new WorkflowStore
+func NewWorkflowStore(db *database.DB) *WorkflowStore {
+	return &WorkflowStore{DB: db}
+}
+
+// Get gets the workflow of a project, or the default workflow if it has not defined one
+func (s *WorkflowStore) Get(projectID string) (*Workflow, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	workflow := &Workflow{ProjectID: projectID}
+	var states, transitions string
+	var updatedAt time.Time
+	err := s.DB.QueryRow(
+		`SELECT states, transitions, updated_at FROM project_workflows WHERE project_id = $1`,
+		projectID,
+	).Scan(&states, &transitions, &updatedAt)
+	if err == sql.ErrNoRows {
+		return DefaultWorkflow(projectID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(states), &workflow.States); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(transitions), &workflow.Transitions); err != nil {
+		return nil, err
+	}
+	workflow.UpdatedAt = &updatedAt
+	return workflow, nil
+}
+
+// Set replaces the workflow of a project, or goes back to the default
+// workflow if the workflow is marked as the default. The project's tasks are
+// first moved from each status in statusMap, ignoring case, to the status it
+// maps to, and then take the category of their state.
+func (s *WorkflowStore) Set(workflow *Workflow, statusMap map[string]string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	states, err := json.Marshal(workflow.States)
+	if err != nil {
+		return err
+	}
+	transitions, err := json.Marshal(workflow.Transitions)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if workflow.Default {
+		_, err = tx.Exec(`DELETE FROM project_workflows WHERE project_id = $1`, workflow.ProjectID)
+	} else {
+		_, err = tx.Exec(
+			`INSERT INTO project_workflows (project_id, states, transitions, updated_at) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (project_id) DO UPDATE SET states = excluded.states, transitions = excluded.transitions, updated_at = excluded.updated_at`,
+			workflow.ProjectID, string(states), string(transitions), workflow.UpdatedAt,
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(statusMap) > 0 {
+		// One statement moves every task at once, so that a status mapped to
+		// another one that is mapped in turn is only moved once
+		froms := make([]string, 0, len(statusMap))
+		for from := range statusMap {
+			froms = append(froms, from)
+		}
+		sort.Strings(froms)
+
+		args := []interface{}{workflow.ProjectID}
+		cases := make([]string, len(froms))
+		matches := make([]string, len(froms))
+		for i, from := range froms {
+			args = append(args, from, statusMap[from])
+			cases[i] = fmt.Sprintf("WHEN LOWER($%d) THEN $%d", len(args)-1, len(args))
+			matches[i] = fmt.Sprintf("LOWER($%d)", len(args)-1)
+		}
+		_, err := tx.Exec(
+			`UPDATE tasks SET status = CASE LOWER(status) `+strings.Join(cases, " ")+` END
+			WHERE project_id = $1 AND LOWER(status) IN (`+strings.Join(matches, ", ")+`)`,
+			args...,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	for _, state := range workflow.States {
+		_, err := tx.Exec(
+			`UPDATE tasks SET status_category = $1 WHERE project_id = $2 AND status = $3`,
+			state.Category, workflow.ProjectID, state.Name,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
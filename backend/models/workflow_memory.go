@@ -0,0 +1,82 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// MemoryWorkflowStore is an in-memory implementation of WorkflowRepository
+type MemoryWorkflowStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryWorkflowStore creates a new MemoryWorkflowStore
+func NewMemoryWorkflowStore(db *MemoryDB) *MemoryWorkflowStore {
+	return &MemoryWorkflowStore{DB: db}
+}
+
+// Get gets the workflow of a project, or the default workflow if it has not defined one
+func (s *MemoryWorkflowStore) Get(projectID string) (*Workflow, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	workflow, ok := s.DB.workflows[projectID]
+	if !ok {
+		return DefaultWorkflow(projectID), nil
+	}
+	workflow = copyWorkflow(workflow)
+	return &workflow, nil
+}
+
+// copyWorkflow copies a workflow so that callers and the store don't share its states and transitions
+func copyWorkflow(workflow Workflow) Workflow {
+	workflow.States = append([]WorkflowState(nil), workflow.States...)
+	transitions := make([]WorkflowTransition, len(workflow.Transitions))
+	for i, transition := range workflow.Transitions {
+		transition.RequiredFields = append([]string(nil), transition.RequiredFields...)
+		transitions[i] = transition
+	}
+	workflow.Transitions = transitions
+	return workflow
+}
+
+// Set replaces the workflow of a project, or goes back to the default
+// workflow if the workflow is marked as the default. The project's tasks are
+// first moved from each status in statusMap to the status it maps to, and
+// then take the category of their state.
+func (s *MemoryWorkflowStore) Set(workflow *Workflow, statusMap map[string]string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.projects[workflow.ProjectID]; !ok {
+		return errors.New("project does not exist")
+	}
+
+	if workflow.Default {
+		delete(s.DB.workflows, workflow.ProjectID)
+	} else {
+		s.DB.workflows[workflow.ProjectID] = copyWorkflow(*workflow)
+	}
+
+	categories := make(map[string]string, len(workflow.States))
+	for _, state := range workflow.States {
+		categories[state.Name] = state.Category
+	}
+	mapped := make(map[string]string, len(statusMap))
+	for from, to := range statusMap {
+		mapped[strings.ToLower(from)] = to
+	}
+	for id, task := range s.DB.tasks {
+		if task.ProjectID != workflow.ProjectID {
+			continue
+		}
+		if to, ok := mapped[strings.ToLower(task.Status)]; ok {
+			task.Status = to
+		}
+		if category, ok := categories[task.Status]; ok {
+			task.StatusCategory = category
+		}
+		s.DB.tasks[id] = task
+	}
+	return nil
+}
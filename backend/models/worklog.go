@@ -0,0 +1,389 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-react-redux-app/database"
+)
+
+var (
+	// ErrWorklogNotFound is returned when a worklog does not exist
+	ErrWorklogNotFound = errors.New("worklog not found")
+	// ErrTimerNotFound is returned when a user has no running timer
+	ErrTimerNotFound = errors.New("timer not found")
+	// ErrTimerRunning is returned when starting a timer for a user who already has one running
+	ErrTimerRunning = errors.New("a timer is already running")
+)
+
+// Worklog records time a user spent on a task
+type Worklog struct {
+	ID     string `json:"id"`
+	TaskID string `json:"taskId"`
+	UserID string `json:"userId,omitempty"`
+	// StartedAt is when the work started and Duration how long it took, in seconds
+	StartedAt time.Time `json:"startedAt"`
+	Duration  int64     `json:"duration"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Timer is a user's running timer on a task. Stopping it logs the time since StartedAt.
+type Timer struct {
+	UserID    string    `json:"userId"`
+	TaskID    string    `json:"taskId"`
+	StartedAt time.Time `json:"startedAt"`
+	Note      string    `json:"note"`
+}
+
+// WorklogFilter selects the worklogs that totals are computed over
+type WorklogFilter struct {
+	// From and To bound when the work started; To is exclusive
+	From time.Time
+	To   time.Time
+	// MemberID, unless empty, leaves out projects the user neither owns nor is a member of
+	MemberID  string
+	ProjectID string
+	TaskID    string
+	UserID    string
+}
+
+// WorklogTotal is the time a user logged on a task
+type WorklogTotal struct {
+	TaskID      string
+	TaskTitle   string
+	ProjectID   string
+	ProjectName string
+	UserID      string
+	Username    string
+	Duration    int64
+	Entries     int
+}
+
+// WorklogStore handles database operations for worklogs and timers
+type WorklogStore struct {
+	DB *database.DB
+}
+
+// NewWorklogStore creates a new WorklogStore
+func NewWorklogStore(db *database.DB) *WorklogStore {
+	return &WorklogStore{DB: db}
+}
+
+// worklogColumns lists the worklogs columns in the order scanWorklog reads them
+const worklogColumns = `id, task_id, user_id, started_at, duration, note, created_at, updated_at`
+
+// scanWorklog scans a row selected with worklogColumns into a Worklog
+func scanWorklog(row rowScanner) (*Worklog, error) {
+	worklog := &Worklog{}
+	var userID sql.NullString
+	err := row.Scan(
+		&worklog.ID, &worklog.TaskID, &userID, &worklog.StartedAt, &worklog.Duration,
+		&worklog.Note, &worklog.CreatedAt, &worklog.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	worklog.UserID = userID.String
+	return worklog, nil
+}
+
+// insertWorklog inserts a worklog and lowers the remaining estimate of its task
+func insertWorklog(tx *database.Tx, worklog *Worklog) error {
+	_, err := tx.Exec(
+		`INSERT INTO worklogs (`+worklogColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		worklog.ID, worklog.TaskID, nullString(worklog.UserID), worklog.StartedAt.UTC(), worklog.Duration,
+		worklog.Note, worklog.CreatedAt, worklog.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	return adjustRemainingEstimate(tx, worklog.TaskID, worklog.Duration)
+}
+
+// adjustRemainingEstimate lowers the remaining estimate of a task by the
+// seconds logged, down to zero, or raises it when logged time is taken back.
+// Tasks without a remaining estimate are left alone.
+func adjustRemainingEstimate(tx *database.Tx, taskID string, logged int64) error {
+	if logged == 0 {
+		return nil
+	}
+	_, err := tx.Exec(
+		`UPDATE tasks SET remaining_estimate = CASE WHEN remaining_estimate > $1 THEN remaining_estimate - $1 ELSE 0 END
+		WHERE id = $2 AND remaining_estimate IS NOT NULL`,
+		logged, taskID,
+	)
+	return err
+}
+
+// Create creates a worklog
+func (s *WorklogStore) Create(worklog *Worklog) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertWorklog(tx, worklog); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetByID gets a worklog by ID
+func (s *WorklogStore) GetByID(id string) (*Worklog, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	worklog, err := scanWorklog(s.DB.QueryRow(`SELECT `+worklogColumns+` FROM worklogs WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrWorklogNotFound
+	}
+	return worklog, err
+}
+
+// GetByTask gets a page of a task's worklogs, latest first, along with the
+// total number of worklogs on the task
+func (s *WorklogStore) GetByTask(taskID string, limit int, offset int) ([]*Worklog, int, error) {
+	if s.DB == nil {
+		return nil, 0, errors.New("database connection is nil")
+	}
+
+	var total int
+	if err := s.DB.QueryRow(`SELECT COUNT(*) FROM worklogs WHERE task_id = $1`, taskID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + worklogColumns + ` FROM worklogs
+	WHERE task_id = $1
+	ORDER BY started_at DESC, id
+	LIMIT $2 OFFSET $3`
+
+	rows, err := s.DB.Query(query, taskID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	worklogs := []*Worklog{}
+	for rows.Next() {
+		worklog, err := scanWorklog(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		worklogs = append(worklogs, worklog)
+	}
+	return worklogs, total, rows.Err()
+}
+
+// Update changes when a worklog started, its duration and note, adjusting
+// the remaining estimate of its task by the change in duration
+func (s *WorklogStore) Update(worklog *Worklog) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previous int64
+	err = tx.QueryRow(`SELECT duration FROM worklogs WHERE id = $1`, worklog.ID).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return ErrWorklogNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`UPDATE worklogs SET started_at = $1, duration = $2, note = $3, updated_at = $4 WHERE id = $5`,
+		worklog.StartedAt.UTC(), worklog.Duration, worklog.Note, worklog.UpdatedAt, worklog.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := adjustRemainingEstimate(tx, worklog.TaskID, worklog.Duration-previous); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete deletes a worklog, giving its time back to the remaining estimate of its task
+func (s *WorklogStore) Delete(id string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var taskID string
+	var duration int64
+	err = tx.QueryRow(`SELECT task_id, duration FROM worklogs WHERE id = $1`, id).Scan(&taskID, &duration)
+	if err == sql.ErrNoRows {
+		return ErrWorklogNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM worklogs WHERE id = $1`, id); err != nil {
+		return err
+	}
+	if err := adjustRemainingEstimate(tx, taskID, -duration); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetTotals sums up the worklogs matching the filter per task and user
+func (s *WorklogStore) GetTotals(filter WorklogFilter) ([]WorklogTotal, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	args := []interface{}{filter.From.UTC(), filter.To.UTC()}
+	conditions := []string{"worklogs.started_at >= $1", "worklogs.started_at < $2"}
+	if filter.MemberID != "" {
+		args = append(args, filter.MemberID)
+		conditions = append(conditions, fmt.Sprintf(
+			"(projects.owner_id = $%[1]d OR projects.id IN (SELECT project_id FROM project_members WHERE user_id = $%[1]d))", len(args)))
+	}
+	if filter.ProjectID != "" {
+		args = append(args, filter.ProjectID)
+		conditions = append(conditions, fmt.Sprintf("tasks.project_id = $%d", len(args)))
+	}
+	if filter.TaskID != "" {
+		args = append(args, filter.TaskID)
+		conditions = append(conditions, fmt.Sprintf("worklogs.task_id = $%d", len(args)))
+	}
+	if filter.UserID != "" {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("worklogs.user_id = $%d", len(args)))
+	}
+
+	query := `SELECT worklogs.task_id, tasks.title, tasks.project_id, projects.name, worklogs.user_id, users.username,
+		SUM(worklogs.duration), COUNT(*)
+	FROM worklogs
+	JOIN tasks ON tasks.id = worklogs.task_id
+	JOIN projects ON projects.id = tasks.project_id
+	LEFT JOIN users ON users.id = worklogs.user_id
+	WHERE ` + strings.Join(conditions, " AND ") + `
+	GROUP BY worklogs.task_id, tasks.title, tasks.project_id, projects.name, worklogs.user_id, users.username`
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []WorklogTotal{}
+	for rows.Next() {
+		var total WorklogTotal
+		var userID, username sql.NullString
+		err := rows.Scan(
+			&total.TaskID, &total.TaskTitle, &total.ProjectID, &total.ProjectName, &userID, &username,
+			&total.Duration, &total.Entries,
+		)
+		if err != nil {
+			return nil, err
+		}
+		total.UserID = userID.String
+		total.Username = username.String
+		totals = append(totals, total)
+	}
+	return totals, rows.Err()
+}
+
+// GetTimer gets a user's running timer
+func (s *WorklogStore) GetTimer(userID string) (*Timer, error) {
+	if s.DB == nil {
+		return nil, errors.New("database connection is nil")
+	}
+
+	timer := &Timer{}
+	err := s.DB.QueryRow(
+		`SELECT user_id, task_id, started_at, note FROM timers WHERE user_id = $1`,
+		userID,
+	).Scan(&timer.UserID, &timer.TaskID, &timer.StartedAt, &timer.Note)
+	if err == sql.ErrNoRows {
+		return nil, ErrTimerNotFound
+	}
+	return timer, err
+}
+
+// StartTimer starts a timer, unless its user already has one running
+func (s *WorklogStore) StartTimer(timer *Timer) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	result, err := s.DB.Exec(
+		`INSERT INTO timers (user_id, task_id, started_at, note) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO NOTHING`,
+		timer.UserID, timer.TaskID, timer.StartedAt.UTC(), timer.Note,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, ErrTimerRunning)
+}
+
+// StopTimer stops a timer and logs its time as the given worklog. It
+// returns ErrTimerNotFound if the timer was stopped or replaced meanwhile.
+func (s *WorklogStore) StopTimer(timer *Timer, worklog *Worklog) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`DELETE FROM timers WHERE user_id = $1 AND task_id = $2 AND started_at = $3`,
+		timer.UserID, timer.TaskID, timer.StartedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result, ErrTimerNotFound); err != nil {
+		return err
+	}
+
+	if err := insertWorklog(tx, worklog); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteTimer discards a user's running timer without logging its time
+func (s *WorklogStore) DeleteTimer(userID string) error {
+	if s.DB == nil {
+		return errors.New("database connection is nil")
+	}
+
+	result, err := s.DB.Exec(`DELETE FROM timers WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, ErrTimerNotFound)
+}
@@ -0,0 +1,237 @@
+package models
+
+import (
+	"errors"
+	"sort"
+)
+
+// MemoryWorklogStore is an in-memory implementation of WorklogRepository
+type MemoryWorklogStore struct {
+	DB *MemoryDB
+}
+
+// NewMemoryWorklogStore creates a new MemoryWorklogStore
+func NewMemoryWorklogStore(db *MemoryDB) *MemoryWorklogStore {
+	return &MemoryWorklogStore{DB: db}
+}
+
+// createLocked stores a worklog and lowers the remaining estimate of its
+// task. The caller must hold the write lock.
+func (s *MemoryWorklogStore) createLocked(worklog *Worklog) error {
+	if _, ok := s.DB.tasks[worklog.TaskID]; !ok {
+		return errors.New("task does not exist")
+	}
+	if worklog.UserID != "" {
+		if _, ok := s.DB.users[worklog.UserID]; !ok {
+			return errors.New("user does not exist")
+		}
+	}
+
+	s.DB.worklogs[worklog.ID] = *worklog
+	s.DB.adjustRemainingEstimateLocked(worklog.TaskID, worklog.Duration)
+	return nil
+}
+
+// adjustRemainingEstimateLocked lowers the remaining estimate of a task by
+// the seconds logged, down to zero, or raises it when logged time is taken
+// back. The caller must hold the write lock.
+func (db *MemoryDB) adjustRemainingEstimateLocked(taskID string, logged int64) {
+	task, ok := db.tasks[taskID]
+	if !ok || task.RemainingEstimate == nil {
+		return
+	}
+	remaining := *task.RemainingEstimate - logged
+	if remaining < 0 {
+		remaining = 0
+	}
+	task.RemainingEstimate = &remaining
+	db.tasks[taskID] = task
+}
+
+// Create creates a worklog
+func (s *MemoryWorklogStore) Create(worklog *Worklog) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	return s.createLocked(worklog)
+}
+
+// GetByID gets a worklog by ID
+func (s *MemoryWorklogStore) GetByID(id string) (*Worklog, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	worklog, ok := s.DB.worklogs[id]
+	if !ok {
+		return nil, ErrWorklogNotFound
+	}
+	return &worklog, nil
+}
+
+// GetByTask gets a page of a task's worklogs, latest first, along with the
+// total number of worklogs on the task
+func (s *MemoryWorklogStore) GetByTask(taskID string, limit int, offset int) ([]*Worklog, int, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	var all []Worklog
+	for _, worklog := range s.DB.worklogs {
+		if worklog.TaskID == taskID {
+			all = append(all, worklog)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if !all[i].StartedAt.Equal(all[j].StartedAt) {
+			return all[i].StartedAt.After(all[j].StartedAt)
+		}
+		return all[i].ID < all[j].ID
+	})
+
+	worklogs := []*Worklog{}
+	for i := offset; i < len(all) && i < offset+limit; i++ {
+		worklog := all[i]
+		worklogs = append(worklogs, &worklog)
+	}
+	return worklogs, len(all), nil
+}
+
+// Update changes when a worklog started, its duration and note, adjusting
+// the remaining estimate of its task by the change in duration
+func (s *MemoryWorklogStore) Update(worklog *Worklog) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	stored, ok := s.DB.worklogs[worklog.ID]
+	if !ok {
+		return ErrWorklogNotFound
+	}
+	s.DB.adjustRemainingEstimateLocked(stored.TaskID, worklog.Duration-stored.Duration)
+
+	stored.StartedAt = worklog.StartedAt
+	stored.Duration = worklog.Duration
+	stored.Note = worklog.Note
+	stored.UpdatedAt = worklog.UpdatedAt
+	s.DB.worklogs[worklog.ID] = stored
+	return nil
+}
+
+// Delete deletes a worklog, giving its time back to the remaining estimate of its task
+func (s *MemoryWorklogStore) Delete(id string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	worklog, ok := s.DB.worklogs[id]
+	if !ok {
+		return ErrWorklogNotFound
+	}
+	delete(s.DB.worklogs, id)
+	s.DB.adjustRemainingEstimateLocked(worklog.TaskID, -worklog.Duration)
+	return nil
+}
+
+// GetTotals sums up the worklogs matching the filter per task and user
+func (s *MemoryWorklogStore) GetTotals(filter WorklogFilter) ([]WorklogTotal, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	type key struct{ taskID, userID string }
+	sums := make(map[key]*WorklogTotal)
+	totals := []WorklogTotal{}
+	for _, worklog := range s.DB.worklogs {
+		if worklog.StartedAt.Before(filter.From) || !worklog.StartedAt.Before(filter.To) {
+			continue
+		}
+		task := s.DB.tasks[worklog.TaskID]
+		project := s.DB.projects[task.ProjectID]
+		_, isMember := s.DB.members[project.ID][filter.MemberID]
+		switch {
+		case filter.MemberID != "" && project.OwnerID != filter.MemberID && !isMember:
+			continue
+		case filter.ProjectID != "" && task.ProjectID != filter.ProjectID:
+			continue
+		case filter.TaskID != "" && worklog.TaskID != filter.TaskID:
+			continue
+		case filter.UserID != "" && worklog.UserID != filter.UserID:
+			continue
+		}
+
+		k := key{worklog.TaskID, worklog.UserID}
+		sum, ok := sums[k]
+		if !ok {
+			sum = &WorklogTotal{
+				TaskID:      task.ID,
+				TaskTitle:   task.Title,
+				ProjectID:   project.ID,
+				ProjectName: project.Name,
+				UserID:      worklog.UserID,
+				Username:    s.DB.users[worklog.UserID].Username,
+			}
+			sums[k] = sum
+		}
+		sum.Duration += worklog.Duration
+		sum.Entries++
+	}
+	for _, sum := range sums {
+		totals = append(totals, *sum)
+	}
+	return totals, nil
+}
+
+// GetTimer gets a user's running timer
+func (s *MemoryWorklogStore) GetTimer(userID string) (*Timer, error) {
+	s.DB.mu.RLock()
+	defer s.DB.mu.RUnlock()
+
+	timer, ok := s.DB.timers[userID]
+	if !ok {
+		return nil, ErrTimerNotFound
+	}
+	return &timer, nil
+}
+
+// StartTimer starts a timer, unless its user already has one running
+func (s *MemoryWorklogStore) StartTimer(timer *Timer) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.timers[timer.UserID]; ok {
+		return ErrTimerRunning
+	}
+	if _, ok := s.DB.users[timer.UserID]; !ok {
+		return errors.New("user does not exist")
+	}
+	if _, ok := s.DB.tasks[timer.TaskID]; !ok {
+		return errors.New("task does not exist")
+	}
+	s.DB.timers[timer.UserID] = *timer
+	return nil
+}
+
+// StopTimer stops a timer and logs its time as the given worklog. It
+// returns ErrTimerNotFound if the timer was stopped or replaced meanwhile.
+func (s *MemoryWorklogStore) StopTimer(timer *Timer, worklog *Worklog) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	stored, ok := s.DB.timers[timer.UserID]
+	if !ok || stored.TaskID != timer.TaskID || !stored.StartedAt.Equal(timer.StartedAt) {
+		return ErrTimerNotFound
+	}
+	if err := s.createLocked(worklog); err != nil {
+		return err
+	}
+	delete(s.DB.timers, timer.UserID)
+	return nil
+}
+
+// DeleteTimer discards a user's running timer without logging its time
+func (s *MemoryWorklogStore) DeleteTimer(userID string) error {
+	s.DB.mu.Lock()
+	defer s.DB.mu.Unlock()
+
+	if _, ok := s.DB.timers[userID]; !ok {
+		return ErrTimerNotFound
+	}
+	delete(s.DB.timers, userID)
+	return nil
+}
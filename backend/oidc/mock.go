@@ -0,0 +1,274 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go-react-redux-app/signing"
+	"go-react-redux-app/utils"
+)
+
+// mockCodeTTL is how long an authorization code of the mock provider can be redeemed
+const mockCodeTTL = time.Minute
+
+// MockProvider is a minimal OpenID Connect provider for local development.
+// Its login page lets you sign in as anyone, with any email and groups, so
+// never expose it outside a development machine.
+type MockProvider struct {
+	Issuer string
+
+	key   *rsa.PrivateKey
+	keyID string
+
+	mu    sync.Mutex
+	codes map[string]mockCode
+}
+
+// mockCode is an issued authorization code and what it was issued for
+type mockCode struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+	claims      jwt.MapClaims
+	expiresAt   time.Time
+}
+
+// NewMockProvider creates a MockProvider with a fresh signing key
+func NewMockProvider(issuer string) (*MockProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := utils.GenerateRandomToken(8)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MockProvider{
+		Issuer: strings.TrimRight(issuer, "/"),
+		key:    key,
+		keyID:  keyID,
+		codes:  make(map[string]mockCode),
+	}, nil
+}
+
+// Handler returns the HTTP handler serving the provider endpoints
+func (m *MockProvider) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.serveDiscovery)
+	mux.HandleFunc("/authorize", m.serveAuthorize)
+	mux.HandleFunc("/token", m.serveToken)
+	mux.HandleFunc("/jwks", m.serveJWKS)
+	return mux
+}
+
+// serveDiscovery serves the provider metadata
+func (m *MockProvider) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                m.Issuer,
+		"authorization_endpoint":                m.Issuer + "/authorize",
+		"token_endpoint":                        m.Issuer + "/token",
+		"jwks_uri":                              m.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// mockLoginPage lets the developer choose who to log in as
+var mockLoginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Mock OpenID Connect provider</title></head>
+<body>
+<h1>Mock OpenID Connect provider</h1>
+<p>Log in to <code>{{.ClientID}}</code> as:</p>
+<form method="post">
+{{range $name, $value := .Query}}<input type="hidden" name="{{$name}}" value="{{index $value 0}}">
+{{end}}<p><label>Username <input name="username" value="jane" required></label></p>
+<p><label>Email <input name="email" value="jane@example.com" required></label></p>
+<p><label>First name <input name="given_name" value="Jane"></label></p>
+<p><label>Last name <input name="family_name" value="Doe"></label></p>
+<p><label>Groups (comma separated) <input name="groups" value="developers"></label></p>
+<p><label><input type="checkbox" name="email_verified" value="true" checked> Email verified</label></p>
+<p><button type="submit">Log in</button></p>
+</form>
+</body>
+</html>
+`))
+
+// serveAuthorize shows the login page, and issues a code when it is submitted
+func (m *MockProvider) serveAuthorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	redirectURI := r.Form.Get("redirect_uri")
+	if _, err := url.ParseRequestURI(redirectURI); err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if r.Form.Get("response_type") != "code" || r.Form.Get("code_challenge_method") != "S256" || r.Form.Get("code_challenge") == "" {
+		http.Error(w, "only the authorization code flow with S256 PKCE is supported", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		mockLoginPage.Execute(w, map[string]interface{}{
+			"ClientID": r.Form.Get("client_id"),
+			"Query":    r.URL.Query(),
+		})
+		return
+	}
+
+	username := r.PostForm.Get("username")
+	if username == "" {
+		http.Error(w, "username is required", http.StatusBadRequest)
+		return
+	}
+
+	var groups []string
+	for _, group := range strings.Split(r.PostForm.Get("groups"), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+
+	code, err := utils.GenerateRandomToken(24)
+	if err != nil {
+		http.Error(w, "error issuing code", http.StatusInternalServerError)
+		return
+	}
+
+	m.mu.Lock()
+	m.codes[code] = mockCode{
+		clientID:    r.Form.Get("client_id"),
+		redirectURI: redirectURI,
+		challenge:   r.Form.Get("code_challenge"),
+		nonce:       r.Form.Get("nonce"),
+		claims: jwt.MapClaims{
+			// The username doubles as a stable subject, so logging in again finds the same identity
+			"sub":                "mock|" + username,
+			"preferred_username": username,
+			"email":              r.PostForm.Get("email"),
+			"email_verified":     r.PostForm.Get("email_verified") == "true",
+			"given_name":         r.PostForm.Get("given_name"),
+			"family_name":        r.PostForm.Get("family_name"),
+			"groups":             groups,
+		},
+		expiresAt: time.Now().Add(mockCodeTTL),
+	}
+	m.mu.Unlock()
+
+	params := url.Values{}
+	params.Set("code", code)
+	if state := r.Form.Get("state"); state != "" {
+		params.Set("state", state)
+	}
+	separator := "?"
+	if strings.Contains(redirectURI, "?") {
+		separator = "&"
+	}
+	http.Redirect(w, r, redirectURI+separator+params.Encode(), http.StatusFound)
+}
+
+// serveToken redeems an authorization code for an ID token
+func (m *MockProvider) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeTokenError(w, "unsupported_grant_type")
+		return
+	}
+
+	clientID := r.PostForm.Get("client_id")
+	if user, _, ok := r.BasicAuth(); ok {
+		clientID, _ = url.QueryUnescape(user)
+	}
+
+	m.mu.Lock()
+	code, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	if !ok || time.Now().After(code.expiresAt) || code.clientID != clientID || code.redirectURI != r.PostForm.Get("redirect_uri") {
+		writeTokenError(w, "invalid_grant")
+		return
+	}
+	challenge := Challenge(r.PostForm.Get("code_verifier"))
+	if subtle.ConstantTimeCompare([]byte(challenge), []byte(code.challenge)) != 1 {
+		writeTokenError(w, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": m.Issuer,
+		"aud": code.clientID,
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+	}
+	if code.nonce != "" {
+		claims["nonce"] = code.nonce
+	}
+	for name, value := range code.claims {
+		claims[name] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = m.keyID
+	idToken, err := token.SignedString(m.key)
+	if err != nil {
+		writeTokenError(w, "server_error")
+		return
+	}
+
+	accessToken, _ := utils.GenerateRandomToken(24)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+// serveJWKS serves the public signing key
+func (m *MockProvider) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, signing.JWKS{Keys: []signing.JWK{{
+		Kty: "RSA",
+		Kid: m.keyID,
+		Use: "sig",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+	}}})
+}
+
+// writeTokenError writes an OAuth 2.0 token error response (RFC 6749 section 5.2)
+func writeTokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+// writeJSON writes a bare JSON response, as OAuth endpoints don't use the API envelope
+func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
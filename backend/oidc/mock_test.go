@@ -0,0 +1,204 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go-react-redux-app/models"
+)
+
+// newTestProvider serves a MockProvider and returns a Provider configured for it
+func newTestProvider(t *testing.T, cfg Config) *Provider {
+	t.Helper()
+
+	var mock *MockProvider
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mock.Handler().ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	var err error
+	mock, err = NewMockProvider(server.URL)
+	if err != nil {
+		t.Fatalf("NewMockProvider: %v", err)
+	}
+
+	cfg.Issuer = server.URL
+	if cfg.ClientID == "" {
+		cfg.ClientID = "app"
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	return NewProvider(cfg, "http://app.test/callback")
+}
+
+// logIn submits the mock provider's login page for the authorization URL
+// and returns the query of the redirect back to us
+func logIn(t *testing.T, authURL string, form url.Values) url.Values {
+	t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parsing authorization URL: %v", err)
+	}
+	for name, values := range parsed.Query() {
+		form[name] = values
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.PostForm(parsed.Scheme+"://"+parsed.Host+parsed.Path, form)
+	if err != nil {
+		t.Fatalf("submitting login: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("login returned %s, want a redirect", resp.Status)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parsing redirect: %v", err)
+	}
+	if !strings.HasPrefix(location.String(), "http://app.test/callback?") {
+		t.Fatalf("redirected to %s, want the redirect URL", location)
+	}
+	return location.Query()
+}
+
+func janeForm() url.Values {
+	return url.Values{
+		"username":       {"jane"},
+		"email":          {"jane@example.com"},
+		"email_verified": {"true"},
+		"given_name":     {"Jane"},
+		"family_name":    {"Doe"},
+		"groups":         {"developers, admins"},
+	}
+}
+
+func TestExchange(t *testing.T) {
+	provider := newTestProvider(t, Config{ID: "mock"})
+	verifier, err := GenerateVerifier()
+	if err != nil {
+		t.Fatalf("GenerateVerifier: %v", err)
+	}
+
+	authURL, err := provider.AuthCodeURL("state-1", "nonce-1", verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	callback := logIn(t, authURL, janeForm())
+	if got := callback.Get("state"); got != "state-1" {
+		t.Errorf("state = %q, want state-1", got)
+	}
+
+	claims, err := provider.Exchange(context.Background(), callback.Get("code"), verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if claims.Subject != "mock|jane" || claims.Email != "jane@example.com" || !claims.EmailVerified {
+		t.Errorf("claims = %+v", claims)
+	}
+	if claims.PreferredUsername != "jane" || claims.GivenName != "Jane" || claims.FamilyName != "Doe" {
+		t.Errorf("claims = %+v", claims)
+	}
+	if len(claims.Groups) != 2 || claims.Groups[0] != "developers" || claims.Groups[1] != "admins" {
+		t.Errorf("groups = %v, want [developers admins]", claims.Groups)
+	}
+
+	// Codes can only be redeemed once
+	if _, err := provider.Exchange(context.Background(), callback.Get("code"), verifier, "nonce-1"); err == nil {
+		t.Error("Exchange redeemed a code twice")
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier func(verifier string) string
+		nonce    string
+	}{
+		{"PKCE verifier mismatch", func(string) string { return "another-verifier-that-is-long-enough-to-be-valid" }, "nonce-1"},
+		{"nonce mismatch", func(verifier string) string { return verifier }, "nonce-2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestProvider(t, Config{ID: "mock"})
+			verifier, err := GenerateVerifier()
+			if err != nil {
+				t.Fatalf("GenerateVerifier: %v", err)
+			}
+
+			authURL, err := provider.AuthCodeURL("state-1", "nonce-1", verifier)
+			if err != nil {
+				t.Fatalf("AuthCodeURL: %v", err)
+			}
+			callback := logIn(t, authURL, janeForm())
+
+			if _, err := provider.Exchange(context.Background(), callback.Get("code"), tt.verifier(verifier), tt.nonce); err == nil {
+				t.Error("Exchange succeeded")
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsOtherIssuer(t *testing.T) {
+	provider := newTestProvider(t, Config{ID: "mock"})
+	provider.Issuer += "/other"
+
+	if _, err := provider.AuthCodeURL("state-1", "nonce-1", "verifier"); err == nil {
+		t.Error("AuthCodeURL accepted metadata for another issuer")
+	}
+}
+
+func TestRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		adminGroups []string
+		groups      []string
+		want        string
+	}{
+		{"no mapping", nil, []string{"admins"}, ""},
+		{"admin group", []string{"admins"}, []string{"developers", "admins"}, models.UserRoleAdmin},
+		{"other groups", []string{"admins"}, []string{"developers"}, models.UserRoleUser},
+		{"no groups", []string{"admins"}, nil, models.UserRoleUser},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &Provider{Config: Config{AdminGroups: tt.adminGroups}}
+			if got := provider.Role(tt.groups); got != tt.want {
+				t.Errorf("Role = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupClaim(t *testing.T) {
+	claims := map[string]interface{}{
+		"groups":       []interface{}{"a", "b"},
+		"realm_access": map[string]interface{}{"roles": []interface{}{"admin"}},
+		"scope":        "x y",
+	}
+
+	if got := stringList(lookupClaim(claims, "groups")); len(got) != 2 {
+		t.Errorf("groups = %v", got)
+	}
+	if got := stringList(lookupClaim(claims, "realm_access.roles")); len(got) != 1 || got[0] != "admin" {
+		t.Errorf("realm_access.roles = %v", got)
+	}
+	if got := stringList(lookupClaim(claims, "scope")); len(got) != 2 {
+		t.Errorf("scope = %v", got)
+	}
+	if got := lookupClaim(claims, "missing.path"); got != nil {
+		t.Errorf("missing.path = %v", got)
+	}
+}
@@ -0,0 +1,20 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+
+	"go-react-redux-app/utils"
+)
+
+// GenerateVerifier returns a new random PKCE code verifier (RFC 7636)
+func GenerateVerifier() (string, error) {
+	// 32 random bytes encode to 43 characters, the minimum length allowed
+	return utils.GenerateRandomToken(32)
+}
+
+// Challenge returns the S256 code challenge of a code verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
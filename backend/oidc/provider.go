@@ -0,0 +1,308 @@
+// Package oidc implements the OpenID Connect authorization code flow with
+// PKCE for logging in through external identity providers.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go-react-redux-app/models"
+)
+
+// idTokenLeeway allows for clock drift between us and the provider
+const idTokenLeeway = time.Minute
+
+// idTokenMethods are the ID token signing algorithms we accept
+var idTokenMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// Config configures an identity provider
+type Config struct {
+	// ID names the provider in URLs and linked identities, e.g. "corp"
+	ID string
+	// Name is shown on the login button
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// GroupsClaim is the ID token claim holding the user's groups. Nested
+	// claims are written with dots, e.g. "realm_access.roles".
+	GroupsClaim string
+	// AdminGroups grants the admin role to members of any of these groups and
+	// the user role to everyone else. When empty, roles are managed locally.
+	AdminGroups []string
+	// AutoProvision creates accounts for users logging in for the first time
+	AutoProvision bool
+	// TrustEmail links a first login to the local account with the same email
+	// address, if the provider reports that address as verified
+	TrustEmail bool
+}
+
+// Claims are the user details taken from a verified ID token
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	GivenName         string
+	FamilyName        string
+	Groups            []string
+}
+
+// discovery is the part of the provider metadata (OpenID Connect Discovery 1.0) we use
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is an OpenID Connect identity provider
+type Provider struct {
+	Config
+	// RedirectURL is our callback address registered with the provider
+	RedirectURL string
+
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *discovery
+	keys     *keySet
+}
+
+// NewProvider creates a new Provider. The provider metadata is fetched on
+// first use, so the server starts even while the provider is unreachable.
+func NewProvider(cfg Config, redirectURL string) *Provider {
+	return &Provider{
+		Config:      cfg,
+		RedirectURL: redirectURL,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// discover fetches and caches the provider metadata
+func (p *Provider) discover() (*discovery, *keySet, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, p.keys, nil
+	}
+
+	resp, err := p.client.Get(strings.TrimRight(p.Issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetching provider metadata: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("fetching provider metadata: %s", resp.Status)
+	}
+
+	var metadata discovery
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return nil, nil, fmt.Errorf("decoding provider metadata: %w", err)
+	}
+
+	// The metadata must belong to the issuer we were configured with (section 4.3)
+	if metadata.Issuer != p.Issuer {
+		return nil, nil, fmt.Errorf("provider metadata is for issuer %q, expected %q", metadata.Issuer, p.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, nil, errors.New("provider metadata is missing endpoints")
+	}
+
+	p.metadata = &metadata
+	p.keys = &keySet{uri: metadata.JWKSURI, client: p.client}
+	return p.metadata, p.keys, nil
+}
+
+// AuthCodeURL returns the address to send the browser to for logging in
+func (p *Provider) AuthCodeURL(state string, nonce string, codeVerifier string) (string, error) {
+	metadata, _, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.ClientID)
+	params.Set("redirect_uri", p.RedirectURL)
+	params.Set("scope", strings.Join(p.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", Challenge(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code at the token endpoint and returns
+// the claims of the verified ID token
+func (p *Provider) Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*Claims, error) {
+	metadata, keys, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", p.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		// client_secret_basic, the default client authentication (RFC 6749 section 2.3.1)
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("redeeming authorization code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("redeeming authorization code: %w", err)
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("redeeming authorization code: %s", resp.Status)
+	}
+	if token.Error != "" {
+		return nil, fmt.Errorf("redeeming authorization code: %s %s", token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response has no ID token")
+	}
+
+	return p.verify(token.IDToken, nonce, metadata, keys)
+}
+
+// verify checks the signature and standard claims of an ID token
+// (OpenID Connect Core section 3.1.3.7) and extracts the user details
+func (p *Provider) verify(idToken string, nonce string, metadata *discovery, keys *keySet) (*Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return keys.get(kid)
+	},
+		jwt.WithValidMethods(idTokenMethods),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(idTokenLeeway),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, errors.New("invalid ID token: nonce does not match")
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != p.ClientID {
+		return nil, errors.New("invalid ID token: issued to another client")
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.New("invalid ID token: missing subject")
+	}
+
+	result := &Claims{Subject: subject}
+	result.Email, _ = claims["email"].(string)
+	result.EmailVerified = isTrue(claims["email_verified"])
+	result.PreferredUsername, _ = claims["preferred_username"].(string)
+	result.GivenName, _ = claims["given_name"].(string)
+	result.FamilyName, _ = claims["family_name"].(string)
+	result.Groups = stringList(lookupClaim(claims, p.GroupsClaim))
+	return result, nil
+}
+
+// Role maps groups to a global role. It returns an empty string when the
+// provider has no group mapping, in which case the local role is kept.
+func (p *Provider) Role(groups []string) string {
+	if len(p.AdminGroups) == 0 {
+		return ""
+	}
+	for _, group := range groups {
+		for _, admin := range p.AdminGroups {
+			if group == admin {
+				return models.UserRoleAdmin
+			}
+		}
+	}
+	return models.UserRoleUser
+}
+
+// lookupClaim finds a claim by its dotted path
+func lookupClaim(claims map[string]interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+
+	var value interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+	return value
+}
+
+// stringList converts a claim holding a string or a list of strings into a list
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
+
+// isTrue reads a boolean claim, which some providers send as a string
+func isTrue(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,53 @@
+package password
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultBcryptCost is the bcrypt cost used when none is configured
+const DefaultBcryptCost = bcrypt.DefaultCost
+
+// BcryptMaxLength is the number of password bytes bcrypt uses; it rejects longer passwords
+const BcryptMaxLength = 72
+
+// BcryptHasher hashes passwords with bcrypt. Its hashes are in the modular
+// crypt format: $2a$<cost>$<salt and key>
+type BcryptHasher struct {
+	Cost int
+}
+
+// NewBcryptHasher creates a new BcryptHasher
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{Cost: cost}
+}
+
+// Hash hashes a password with a fresh salt
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// Recognizes checks if a hash was made with bcrypt
+func (h *BcryptHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// Verify checks a password against a bcrypt hash
+func (h *BcryptHasher) Verify(password string, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// NeedsRehash checks if a bcrypt hash uses another cost than the hasher
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
@@ -0,0 +1,71 @@
+// Package password hashes and verifies user passwords and checks new ones
+// against the password policy.
+package password
+
+import "errors"
+
+// Algorithms supported for hashing passwords
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+// ErrUnknownHash is returned when a stored hash was made with an unsupported algorithm
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// Hasher is one password hashing algorithm. Its hashes are self-describing
+// strings that carry the algorithm and parameters they were made with.
+type Hasher interface {
+	// Hash hashes a password with a fresh salt
+	Hash(password string) (string, error)
+	// Recognizes checks if a hash was made with this algorithm
+	Recognizes(encoded string) bool
+	// Verify checks a password against a hash made with this algorithm
+	Verify(password string, encoded string) (bool, error)
+	// NeedsRehash checks if a hash made with this algorithm uses other parameters than the hasher
+	NeedsRehash(encoded string) bool
+}
+
+// Manager hashes new passwords with the preferred algorithm and verifies
+// hashes made with any supported one, so the algorithm or its parameters can
+// change without invalidating stored passwords
+type Manager struct {
+	preferred Hasher
+	hashers   []Hasher
+}
+
+// NewManager creates a Manager that hashes with preferred and also verifies
+// hashes of the other hashers
+func NewManager(preferred Hasher, others ...Hasher) *Manager {
+	return &Manager{
+		preferred: preferred,
+		hashers:   append([]Hasher{preferred}, others...),
+	}
+}
+
+// NewDefaultManager creates a Manager that hashes with argon2id at the default
+// parameters and also verifies bcrypt hashes
+func NewDefaultManager() *Manager {
+	return NewManager(NewArgon2idHasher(DefaultArgon2idParams), NewBcryptHasher(DefaultBcryptCost))
+}
+
+// Hash hashes a password with the preferred algorithm
+func (m *Manager) Hash(password string) (string, error) {
+	return m.preferred.Hash(password)
+}
+
+// Verify checks a password against a hash made with any supported algorithm
+func (m *Manager) Verify(password string, encoded string) (bool, error) {
+	for _, hasher := range m.hashers {
+		if hasher.Recognizes(encoded) {
+			return hasher.Verify(password, encoded)
+		}
+	}
+	return false, ErrUnknownHash
+}
+
+// NeedsRehash checks if a hash should be replaced because it was made with
+// another algorithm or outdated parameters
+func (m *Manager) NeedsRehash(encoded string) bool {
+	return !m.preferred.Recognizes(encoded) || m.preferred.NeedsRehash(encoded)
+}
@@ -0,0 +1,95 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// commonPasswords is the blocklist shipped with the server, so checking it
+// needs no network access
+//
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// Policy is the set of rules a new password has to follow
+type Policy struct {
+	// MinLength is the minimum number of characters
+	MinLength int
+	// MaxLength is the maximum number of bytes, which bounds the hashing work
+	MaxLength int
+	// blocklist holds lower-cased passwords that are too common to allow
+	blocklist map[string]struct{}
+}
+
+// NewPolicy creates a password policy. With useBlocklist the built-in list of
+// common passwords is rejected, extended by the lines of blocklistFile if given.
+func NewPolicy(minLength int, maxLength int, useBlocklist bool, blocklistFile string) (*Policy, error) {
+	if minLength < 1 || maxLength < minLength {
+		return nil, errors.New("password length limits are invalid")
+	}
+
+	policy := &Policy{
+		MinLength: minLength,
+		MaxLength: maxLength,
+		blocklist: make(map[string]struct{}),
+	}
+
+	if useBlocklist {
+		policy.addToBlocklist(strings.NewReader(commonPasswords))
+	}
+	if blocklistFile != "" {
+		file, err := os.Open(blocklistFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading password blocklist: %v", err)
+		}
+		defer file.Close()
+		if err := policy.addToBlocklist(file); err != nil {
+			return nil, fmt.Errorf("error reading password blocklist: %v", err)
+		}
+	}
+
+	return policy, nil
+}
+
+// addToBlocklist adds one password per line, skipping blank lines and # comments
+func (p *Policy) addToBlocklist(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.blocklist[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Validate checks a new password against the policy. The user's own details,
+// such as the username and email address, may not be used as the password.
+// The error message is meant to be shown to the user.
+func (p *Policy) Validate(password string, personal ...string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("Password must be at least %d characters long", p.MinLength)
+	}
+	if len(password) > p.MaxLength {
+		return fmt.Errorf("Password must be at most %d bytes long", p.MaxLength)
+	}
+
+	lowered := strings.ToLower(password)
+	if _, blocked := p.blocklist[lowered]; blocked {
+		return errors.New("Password is too common, choose a less predictable one")
+	}
+	for _, detail := range personal {
+		if detail != "" && lowered == strings.ToLower(detail) {
+			return errors.New("Password must not be the same as your username or email address")
+		}
+	}
+
+	return nil
+}
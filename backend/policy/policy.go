@@ -0,0 +1,198 @@
+package policy
+
+import (
+	"go-react-redux-app/models"
+)
+
+// Principal is the authenticated caller an authorization decision is made for
+type Principal struct {
+	UserID string
+	Role   string
+}
+
+// IsAdmin reports whether the principal has the global admin role
+func (p Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+// RoleAdmin is the global user role that bypasses project membership checks
+const RoleAdmin = models.UserRoleAdmin
+
+// Action is something a principal may attempt on a resource
+type Action string
+
+// Actions understood by the policy
+const (
+	ActionView          Action = "view"
+	ActionCreate        Action = "create"
+	ActionUpdate        Action = "update"
+	ActionDelete        Action = "delete"
+	ActionManageMembers Action = "manage_members"
+	ActionModerate      Action = "moderate"
+)
+
+// Kind is the type of resource being accessed
+type Kind string
+
+// Resource kinds understood by the policy
+const (
+	KindProject  Kind = "project"
+	KindTask     Kind = "task"
+	KindComment  Kind = "comment"
+	KindLabel    Kind = "label"
+	KindField    Kind = "custom_field"
+	KindWorkflow Kind = "workflow"
+	KindWorklog  Kind = "worklog"
+)
+
+// Resource identifies what is being accessed. Tasks, their comments, labels,
+// custom fields, worklogs and the workflow are authorized through the
+// project they belong to.
+type Resource struct {
+	Kind      Kind
+	ProjectID string
+}
+
+// Project returns the resource for a project
+func Project(projectID string) Resource {
+	return Resource{Kind: KindProject, ProjectID: projectID}
+}
+
+// Task returns the resource for a task in the given project
+func Task(projectID string) Resource {
+	return Resource{Kind: KindTask, ProjectID: projectID}
+}
+
+// Comment returns the resource for a comment on a task in the given project
+func Comment(projectID string) Resource {
+	return Resource{Kind: KindComment, ProjectID: projectID}
+}
+
+// Label returns the resource for a label defined in the given project
+func Label(projectID string) Resource {
+	return Resource{Kind: KindLabel, ProjectID: projectID}
+}
+
+// CustomField returns the resource for a custom field defined in the given project
+func CustomField(projectID string) Resource {
+	return Resource{Kind: KindField, ProjectID: projectID}
+}
+
+// Workflow returns the resource for the workflow of the given project
+func Workflow(projectID string) Resource {
+	return Resource{Kind: KindWorkflow, ProjectID: projectID}
+}
+
+// Worklog returns the resource for a worklog on a task in the given project
+func Worklog(projectID string) Resource {
+	return Resource{Kind: KindWorklog, ProjectID: projectID}
+}
+
+// Shorthands for the project member roles used in the rules below
+const (
+	owner  = models.ProjectRoleOwner
+	editor = models.ProjectRoleEditor
+	viewer = models.ProjectRoleViewer
+)
+
+// rules lists, per resource kind and action, the project roles that are allowed
+var rules = map[Kind]map[Action][]string{
+	KindProject: {
+		ActionView:          {owner, editor, viewer},
+		ActionUpdate:        {owner, editor},
+		ActionDelete:        {owner},
+		ActionManageMembers: {owner},
+	},
+	KindTask: {
+		ActionView:   {owner, editor, viewer},
+		ActionCreate: {owner, editor},
+		ActionUpdate: {owner, editor},
+		ActionDelete: {owner, editor},
+	},
+	// Only authors edit and delete their own comments; moderators delete anyone's
+	KindComment: {
+		ActionView:     {owner, editor, viewer},
+		ActionCreate:   {owner, editor},
+		ActionUpdate:   {owner, editor},
+		ActionDelete:   {owner, editor},
+		ActionModerate: {owner},
+	},
+	KindLabel: {
+		ActionView:   {owner, editor, viewer},
+		ActionCreate: {owner, editor},
+		ActionUpdate: {owner, editor},
+		ActionDelete: {owner, editor},
+	},
+	// Changing field definitions can drop values, so only owners may
+	KindField: {
+		ActionView:   {owner, editor, viewer},
+		ActionCreate: {owner},
+		ActionUpdate: {owner},
+		ActionDelete: {owner},
+	},
+	// Changing the workflow can rewrite the status of every task
+	KindWorkflow: {
+		ActionView:   {owner, editor, viewer},
+		ActionUpdate: {owner},
+	},
+	// Users log, edit and delete their own time; moderators correct anyone's
+	KindWorklog: {
+		ActionView:     {owner, editor, viewer},
+		ActionCreate:   {owner, editor},
+		ActionUpdate:   {owner, editor},
+		ActionDelete:   {owner, editor},
+		ActionModerate: {owner},
+	},
+}
+
+// MembershipSource looks up a user's role in a project with a single indexed
+// query. It returns models.ErrMemberNotFound when the user is not a member.
+type MembershipSource interface {
+	GetMemberRole(projectID, userID string) (string, error)
+}
+
+// Policy makes authorization decisions for principals on resources
+type Policy struct {
+	Memberships MembershipSource
+}
+
+// New creates a new Policy backed by the given membership source
+func New(memberships MembershipSource) *Policy {
+	return &Policy{Memberships: memberships}
+}
+
+// Can reports whether the principal may perform the action on the resource.
+// Admins may do anything; everyone else needs a project role that the rules allow.
+func (p *Policy) Can(principal Principal, action Action, resource Resource) (bool, error) {
+	if principal.UserID == "" {
+		return false, nil
+	}
+	if principal.IsAdmin() {
+		return true, nil
+	}
+
+	allowed, ok := rules[resource.Kind][action]
+	if !ok {
+		return false, nil
+	}
+
+	role, err := p.Memberships.GetMemberRole(resource.ProjectID, principal.UserID)
+	if err == models.ErrMemberNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return Allows(allowed, role), nil
+}
+
+// Allows reports whether role is in the allowed list
+func Allows(allowed []string, role string) bool {
+	for _, candidate := range allowed {
+		if candidate == role {
+			return true
+		}
+	}
+	return false
+}
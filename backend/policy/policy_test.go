@@ -0,0 +1,229 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"go-react-redux-app/models"
+)
+
+// stubMemberships is a MembershipSource backed by a map of project ID to
+// user ID to role. It returns err, when set, for every lookup.
+type stubMemberships struct {
+	roles map[string]map[string]string
+	err   error
+	calls int
+}
+
+func (s *stubMemberships) GetMemberRole(projectID, userID string) (string, error) {
+	s.calls++
+	if s.err != nil {
+		return "", s.err
+	}
+	role, ok := s.roles[projectID][userID]
+	if !ok {
+		return "", models.ErrMemberNotFound
+	}
+	return role, nil
+}
+
+const testProject = "project-1"
+
+func newTestPolicy() (*Policy, *stubMemberships) {
+	memberships := &stubMemberships{roles: map[string]map[string]string{
+		testProject: {
+			"owner":  models.ProjectRoleOwner,
+			"editor": models.ProjectRoleEditor,
+			"viewer": models.ProjectRoleViewer,
+		},
+	}}
+	return New(memberships), memberships
+}
+
+var allActions = []Action{
+	ActionView,
+	ActionCreate,
+	ActionUpdate,
+	ActionDelete,
+	ActionManageMembers,
+	ActionModerate,
+}
+
+var allKinds = []Kind{
+	KindProject,
+	KindTask,
+	KindComment,
+	KindLabel,
+	KindField,
+	KindWorkflow,
+	KindWorklog,
+}
+
+// matrix lists for every kind and action whether an owner, editor and viewer
+// of the project are allowed. Pairs missing from it are denied to everyone.
+var matrix = map[Kind]map[Action][3]bool{
+	KindProject: {
+		ActionView:          {true, true, true},
+		ActionUpdate:        {true, true, false},
+		ActionDelete:        {true, false, false},
+		ActionManageMembers: {true, false, false},
+	},
+	KindTask: {
+		ActionView:   {true, true, true},
+		ActionCreate: {true, true, false},
+		ActionUpdate: {true, true, false},
+		ActionDelete: {true, true, false},
+	},
+	KindComment: {
+		ActionView:     {true, true, true},
+		ActionCreate:   {true, true, false},
+		ActionUpdate:   {true, true, false},
+		ActionDelete:   {true, true, false},
+		ActionModerate: {true, false, false},
+	},
+	KindLabel: {
+		ActionView:   {true, true, true},
+		ActionCreate: {true, true, false},
+		ActionUpdate: {true, true, false},
+		ActionDelete: {true, true, false},
+	},
+	KindField: {
+		ActionView:   {true, true, true},
+		ActionCreate: {true, false, false},
+		ActionUpdate: {true, false, false},
+		ActionDelete: {true, false, false},
+	},
+	KindWorkflow: {
+		ActionView:   {true, true, true},
+		ActionUpdate: {true, false, false},
+	},
+	KindWorklog: {
+		ActionView:     {true, true, true},
+		ActionCreate:   {true, true, false},
+		ActionUpdate:   {true, true, false},
+		ActionDelete:   {true, true, false},
+		ActionModerate: {true, false, false},
+	},
+}
+
+func TestCanMatrix(t *testing.T) {
+	p, _ := newTestPolicy()
+
+	for _, kind := range allKinds {
+		for _, action := range allActions {
+			expected := matrix[kind][action]
+			tests := []struct {
+				name      string
+				principal Principal
+				want      bool
+			}{
+				{"owner", Principal{UserID: "owner", Role: models.UserRoleUser}, expected[0]},
+				{"editor", Principal{UserID: "editor", Role: models.UserRoleUser}, expected[1]},
+				{"viewer", Principal{UserID: "viewer", Role: models.UserRoleUser}, expected[2]},
+				{"non-member", Principal{UserID: "stranger", Role: models.UserRoleUser}, false},
+				{"admin", Principal{UserID: "admin", Role: RoleAdmin}, true},
+				{"empty principal", Principal{}, false},
+			}
+
+			for _, tt := range tests {
+				t.Run(string(kind)+"/"+string(action)+"/"+tt.name, func(t *testing.T) {
+					got, err := p.Can(tt.principal, action, Resource{Kind: kind, ProjectID: testProject})
+					if err != nil {
+						t.Fatalf("Can returned error: %v", err)
+					}
+					if got != tt.want {
+						t.Errorf("Can = %v, want %v", got, tt.want)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestCanCoversEveryRule(t *testing.T) {
+	for kind, actions := range rules {
+		for action := range actions {
+			if _, ok := matrix[kind][action]; !ok {
+				t.Errorf("rule %s/%s is missing from the test matrix", kind, action)
+			}
+		}
+	}
+}
+
+func TestCanOtherProject(t *testing.T) {
+	p, _ := newTestPolicy()
+
+	got, err := p.Can(Principal{UserID: "owner", Role: models.UserRoleUser}, ActionView, Project("project-2"))
+	if err != nil {
+		t.Fatalf("Can returned error: %v", err)
+	}
+	if got {
+		t.Error("owner of one project may view another")
+	}
+}
+
+func TestCanSkipsLookups(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal Principal
+		action    Action
+		resource  Resource
+		want      bool
+	}{
+		{"empty principal", Principal{}, ActionView, Project(testProject), false},
+		{"admin", Principal{UserID: "admin", Role: RoleAdmin}, ActionDelete, Project(testProject), true},
+		{"unknown action", Principal{UserID: "owner", Role: models.UserRoleUser}, ActionDelete, Workflow(testProject), false},
+		{"unknown kind", Principal{UserID: "owner", Role: models.UserRoleUser}, ActionView, Resource{Kind: "unknown", ProjectID: testProject}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, memberships := newTestPolicy()
+			memberships.err = errors.New("lookup must not happen")
+
+			got, err := p.Can(tt.principal, tt.action, tt.resource)
+			if err != nil {
+				t.Fatalf("Can returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Can = %v, want %v", got, tt.want)
+			}
+			if memberships.calls != 0 {
+				t.Errorf("GetMemberRole called %d times, want 0", memberships.calls)
+			}
+		})
+	}
+}
+
+func TestCanReturnsLookupError(t *testing.T) {
+	p, memberships := newTestPolicy()
+	lookupErr := errors.New("database is down")
+	memberships.err = lookupErr
+
+	got, err := p.Can(Principal{UserID: "owner", Role: models.UserRoleUser}, ActionView, Task(testProject))
+	if !errors.Is(err, lookupErr) {
+		t.Fatalf("Can error = %v, want %v", err, lookupErr)
+	}
+	if got {
+		t.Error("Can allowed access although the lookup failed")
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		allowed []string
+		role    string
+		want    bool
+	}{
+		{[]string{owner, editor}, owner, true},
+		{[]string{owner, editor}, viewer, false},
+		{nil, owner, false},
+		{[]string{owner}, "", false},
+	}
+
+	for _, tt := range tests {
+		if got := Allows(tt.allowed, tt.role); got != tt.want {
+			t.Errorf("Allows(%v, %q) = %v, want %v", tt.allowed, tt.role, got, tt.want)
+		}
+	}
+}
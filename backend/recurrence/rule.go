@@ -0,0 +1,498 @@
+// Package recurrence computes the occurrences of recurring tasks from a
+// subset of RFC 5545 recurrence rules: FREQ (DAILY, WEEKLY, MONTHLY or
+// YEARLY), INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY and BYMONTH. Occurrences
+// are computed on the wall clock of a time zone, so a task due at 09:00 stays
+// due at 09:00 when daylight saving time starts or ends.
+package recurrence
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// Time zones have to be available in minimal containers without zoneinfo
+	_ "time/tzdata"
+)
+
+// Frequency is how often a rule repeats
+type Frequency string
+
+// Supported frequencies
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// Limits on rule parts
+const (
+	maxInterval = 1000
+	maxCount    = 10000
+)
+
+// weekdays maps the two-letter day names of BYDAY to weekdays
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// WeekdayNum is a BYDAY entry: a weekday, and for monthly and yearly rules
+// optionally which one of the month, such as 2 for the second or -1 for the
+// last. N is 0 for every such weekday.
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+// Rule is a parsed recurrence rule
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	// Count limits the number of occurrences, counting the first; 0 for no limit
+	Count int
+	// Until is the last moment an occurrence may fall on; zero for no limit.
+	// When UntilFloating is set it is a wall clock time in the schedule's
+	// time zone, stored in UTC.
+	Until         time.Time
+	UntilFloating bool
+	ByDay         []WeekdayNum
+	ByMonthDay    []int
+	ByMonth       []time.Month
+}
+
+// Parse parses a recurrence rule such as FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH.
+// An RRULE: prefix is accepted.
+func Parse(value string) (*Rule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return nil, fmt.Errorf("rule is empty")
+	}
+
+	rule := &Rule{Interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ";") {
+		name, arg, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		arg = strings.ToUpper(strings.TrimSpace(arg))
+		if !ok || name == "" || arg == "" {
+			return nil, fmt.Errorf("%q is not a NAME=VALUE rule part", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s is given twice", name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			rule.Freq = Frequency(arg)
+			if rule.Freq != Daily && rule.Freq != Weekly && rule.Freq != Monthly && rule.Freq != Yearly {
+				err = fmt.Errorf("FREQ must be DAILY, WEEKLY, MONTHLY or YEARLY")
+			}
+		case "INTERVAL":
+			rule.Interval, err = parseNumber(name, arg, 1, maxInterval)
+		case "COUNT":
+			rule.Count, err = parseNumber(name, arg, 1, maxCount)
+		case "UNTIL":
+			rule.Until, rule.UntilFloating, err = parseUntil(arg)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(arg)
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(arg, ",") {
+				var n int
+				if n, err = parseNumber(name, day, -31, 31); err == nil && n == 0 {
+					err = fmt.Errorf("BYMONTHDAY can't be 0")
+				}
+				if err != nil {
+					break
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(arg, ",") {
+				var n int
+				if n, err = parseNumber(name, month, 1, 12); err != nil {
+					break
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			if arg != "MO" {
+				err = fmt.Errorf("only WKST=MO is supported")
+			}
+		default:
+			err = fmt.Errorf("%s is not supported", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// validate checks the combination of rule parts
+func (r *Rule) validate() error {
+	if r.Freq == "" {
+		return fmt.Errorf("FREQ is required")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return fmt.Errorf("COUNT and UNTIL can't be combined")
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return fmt.Errorf("BYMONTHDAY can't be used with FREQ=WEEKLY")
+	}
+	for _, day := range r.ByDay {
+		if day.N == 0 {
+			continue
+		}
+		if r.Freq != Monthly && (r.Freq != Yearly || len(r.ByMonth) == 0) {
+			return fmt.Errorf("numbered BYDAY entries need FREQ=MONTHLY, or FREQ=YEARLY with BYMONTH")
+		}
+	}
+	return nil
+}
+
+// parseNumber parses an integer rule part within bounds
+func parseNumber(name string, value string, min int, max int) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%s must be a number from %d to %d", name, min, max)
+	}
+	return n, nil
+}
+
+// parseUntil parses an UNTIL date or date-time. A date covers the whole day.
+// Times without a Z are wall clock times in the schedule's time zone.
+func parseUntil(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.Parse("20060102T150405", value); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse("20060102", value); err == nil {
+		return t.Add(24*time.Hour - time.Second), true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("UNTIL must be a date such as 20261231 or a time such as 20261231T170000Z")
+}
+
+// parseByDay parses a BYDAY list such as MO,WE or 1MO,-1FR
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) < 2 {
+			return nil, fmt.Errorf("%q is not a BYDAY entry", entry)
+		}
+		day, ok := weekdays[entry[len(entry)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("%q is not a BYDAY entry", entry)
+		}
+
+		n := 0
+		if prefix := entry[:len(entry)-2]; prefix != "" {
+			var err error
+			if n, err = strconv.Atoi(prefix); err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, fmt.Errorf("%q is not a BYDAY entry; numbers go from -5 to 5", entry)
+			}
+		}
+		days = append(days, WeekdayNum{N: n, Day: day})
+	}
+	return days, nil
+}
+
+// String formats the rule in its canonical form
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		layout := "20060102T150405Z"
+		if r.UntilFloating {
+			layout = "20060102T150405"
+		}
+		parts = append(parts, "UNTIL="+r.Until.Format(layout))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			name := strings.ToUpper(day.Day.String()[:2])
+			if day.N != 0 {
+				name = strconv.Itoa(day.N) + name
+			}
+			days[i] = name
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, month := range r.ByMonth {
+			months[i] = strconv.Itoa(int(month))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// maxPeriods bounds how many days, weeks, months or years a schedule is
+// walked through, so that rules which never match again can't loop forever
+const maxPeriods = 50000
+
+// Schedule is a rule started at a moment in a time zone. The start is always
+// the first occurrence.
+type Schedule struct {
+	Rule  *Rule
+	Start time.Time
+}
+
+// NewSchedule creates a schedule whose occurrences fall on the wall clock of the location
+func NewSchedule(rule *Rule, start time.Time, location *time.Location) Schedule {
+	return Schedule{Rule: rule, Start: start.In(location).Truncate(time.Second)}
+}
+
+// Next returns the first occurrence after the given moment. It reports false
+// when the schedule has no more occurrences.
+func (s Schedule) Next(after time.Time) (time.Time, bool) {
+	var next time.Time
+	s.each(func(occurrence time.Time) bool {
+		if occurrence.After(after) {
+			next = occurrence
+			return false
+		}
+		return true
+	})
+	return next, !next.IsZero()
+}
+
+// Upcoming returns at most n occurrences after the given moment
+func (s Schedule) Upcoming(after time.Time, n int) []time.Time {
+	occurrences := []time.Time{}
+	s.each(func(occurrence time.Time) bool {
+		if occurrence.After(after) {
+			occurrences = append(occurrences, occurrence)
+		}
+		return len(occurrences) < n
+	})
+	return occurrences
+}
+
+// Latest returns the last occurrence after the first moment that is not
+// after the second. It reports false when there is none.
+func (s Schedule) Latest(after time.Time, until time.Time) (time.Time, bool) {
+	var latest time.Time
+	s.each(func(occurrence time.Time) bool {
+		if occurrence.After(until) {
+			return false
+		}
+		if occurrence.After(after) {
+			latest = occurrence
+		}
+		return true
+	})
+	return latest, !latest.IsZero()
+}
+
+// CountBefore returns the number of occurrences before the given moment
+func (s Schedule) CountBefore(before time.Time) int {
+	count := 0
+	s.each(func(occurrence time.Time) bool {
+		if !occurrence.Before(before) {
+			return false
+		}
+		count++
+		return true
+	})
+	return count
+}
+
+// each calls fn with every occurrence in order until fn returns false or the
+// schedule ends
+func (s Schedule) each(fn func(time.Time) bool) {
+	location := s.Start.Location()
+	until := s.Rule.Until
+	if s.Rule.UntilFloating {
+		until = time.Date(until.Year(), until.Month(), until.Day(), until.Hour(), until.Minute(), until.Second(), 0, location)
+	}
+
+	count := 0
+	emit := func(occurrence time.Time) bool {
+		if !until.IsZero() && occurrence.After(until) {
+			return false
+		}
+		count++
+		if !fn(occurrence) {
+			return false
+		}
+		return s.Rule.Count == 0 || count < s.Rule.Count
+	}
+
+	if !emit(s.Start) {
+		return
+	}
+	for period := 0; period < maxPeriods; period++ {
+		for _, date := range s.candidates(period) {
+			occurrence := time.Date(date.Year(), date.Month(), date.Day(), s.Start.Hour(), s.Start.Minute(), s.Start.Second(), 0, location)
+			if !occurrence.After(s.Start) {
+				continue
+			}
+			if !emit(occurrence) {
+				return
+			}
+		}
+	}
+}
+
+// candidates returns the dates, at midnight UTC, the rule matches in the
+// given period after the one the schedule starts in, in order
+func (s Schedule) candidates(period int) []time.Time {
+	r := s.Rule
+	step := period * r.Interval
+	start := time.Date(s.Start.Year(), s.Start.Month(), s.Start.Day(), 0, 0, 0, 0, time.UTC)
+
+	var dates []time.Time
+	switch r.Freq {
+	case Daily:
+		dates = []time.Time{start.AddDate(0, 0, step)}
+
+	case Weekly:
+		monday := start.AddDate(0, 0, -((int(start.Weekday())+6)%7)+7*step)
+		if len(r.ByDay) == 0 {
+			dates = []time.Time{monday.AddDate(0, 0, (int(s.Start.Weekday())+6)%7)}
+			break
+		}
+		for i := 0; i < 7; i++ {
+			dates = append(dates, monday.AddDate(0, 0, i))
+		}
+
+	case Monthly:
+		month := time.Date(start.Year(), start.Month()+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		dates = s.monthDates(month)
+
+	case Yearly:
+		year := start.Year() + step
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{start.Month()}
+			if len(r.ByDay) > 0 || len(r.ByMonthDay) > 0 {
+				months = []time.Month{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+			}
+		}
+		for _, month := range sortedMonths(months) {
+			dates = append(dates, s.monthDates(time.Date(year, month, 1, 0, 0, 0, 0, time.UTC))...)
+		}
+	}
+
+	matching := dates[:0]
+	for _, date := range dates {
+		if s.matches(date) {
+			matching = append(matching, date)
+		}
+	}
+	return matching
+}
+
+// monthDates returns the dates of a month a monthly or yearly rule picks,
+// before they are filtered by matches. Without BYDAY and BYMONTHDAY it is
+// the day of the month the schedule starts on, if the month has it.
+func (s Schedule) monthDates(month time.Time) []time.Time {
+	r := s.Rule
+	last := month.AddDate(0, 1, -1).Day()
+
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if s.Start.Day() > last {
+			return nil
+		}
+		return []time.Time{month.AddDate(0, 0, s.Start.Day()-1)}
+	}
+
+	var dates []time.Time
+	for day := 1; day <= last; day++ {
+		dates = append(dates, month.AddDate(0, 0, day-1))
+	}
+	return dates
+}
+
+// matches checks a candidate date against the BYMONTH, BYMONTHDAY and BYDAY parts
+func (s Schedule) matches(date time.Time) bool {
+	r := s.Rule
+	if len(r.ByMonth) > 0 && !containsMonth(r.ByMonth, date.Month()) {
+		return false
+	}
+
+	last := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if len(r.ByMonthDay) > 0 {
+		found := false
+		for _, day := range r.ByMonthDay {
+			if day == date.Day() || (day < 0 && last+day+1 == date.Day()) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(r.ByDay) > 0 {
+		found := false
+		for _, day := range r.ByDay {
+			if day.Day != date.Weekday() {
+				continue
+			}
+			// The nth weekday of the month, counted from its start or end
+			if day.N == 0 || (day.N > 0 && (date.Day()-1)/7+1 == day.N) || (day.N < 0 && (last-date.Day())/7+1 == -day.N) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// containsMonth checks if a list of months contains a month
+func containsMonth(months []time.Month, month time.Month) bool {
+	for _, candidate := range months {
+		if candidate == month {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedMonths returns the months in calendar order without duplicates
+func sortedMonths(months []time.Month) []time.Month {
+	sorted := []time.Month{}
+	for _, month := range months {
+		if !containsMonth(sorted, month) {
+			sorted = append(sorted, month)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
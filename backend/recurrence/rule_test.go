@@ -0,0 +1,319 @@
+package recurrence
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("LoadLocation(%q): %v", name, err)
+	}
+	return location
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"RRULE:freq=weekly;byday=mo,th", "FREQ=WEEKLY;BYDAY=MO,TH"},
+		{"FREQ=WEEKLY;INTERVAL=1", "FREQ=WEEKLY"},
+		{"FREQ=MONTHLY;INTERVAL=2;BYDAY=2TU", "FREQ=MONTHLY;INTERVAL=2;BYDAY=2TU"},
+		{"FREQ=MONTHLY;BYDAY=-1FR;COUNT=5", "FREQ=MONTHLY;COUNT=5;BYDAY=-1FR"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1", "FREQ=MONTHLY;BYMONTHDAY=1,-1"},
+		{"FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", "FREQ=YEARLY;BYDAY=4TH;BYMONTH=11"},
+		{"FREQ=DAILY;UNTIL=20261231T170000Z", "FREQ=DAILY;UNTIL=20261231T170000Z"},
+		{"FREQ=DAILY;UNTIL=20261231T170000", "FREQ=DAILY;UNTIL=20261231T170000"},
+		{"FREQ=DAILY;UNTIL=20261231", "FREQ=DAILY;UNTIL=20261231T235959"},
+		{"FREQ=WEEKLY;WKST=MO", "FREQ=WEEKLY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			rule, err := Parse(tt.value)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := rule.String(); got != tt.want {
+				t.Errorf("String = %q, want %q", got, tt.want)
+			}
+
+			// The canonical form parses to the same rule
+			again, err := Parse(rule.String())
+			if err != nil {
+				t.Fatalf("parsing %q again: %v", rule.String(), err)
+			}
+			if again.String() != rule.String() {
+				t.Errorf("parsing again gives %q", again.String())
+			}
+		})
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		value string
+		err   string
+	}{
+		{"", "empty"},
+		{"INTERVAL=2", "FREQ is required"},
+		{"FREQ=HOURLY", "FREQ must be"},
+		{"FREQ=DAILY;FREQ=WEEKLY", "given twice"},
+		{"FREQ=DAILY;INTERVAL", "NAME=VALUE"},
+		{"FREQ=DAILY;INTERVAL=0", "INTERVAL must be"},
+		{"FREQ=DAILY;INTERVAL=1001", "INTERVAL must be"},
+		{"FREQ=DAILY;COUNT=0", "COUNT must be"},
+		{"FREQ=DAILY;COUNT=2;UNTIL=20261231", "can't be combined"},
+		{"FREQ=DAILY;UNTIL=tomorrow", "UNTIL must be"},
+		{"FREQ=WEEKLY;BYDAY=XX", "not a BYDAY entry"},
+		{"FREQ=MONTHLY;BYDAY=6MO", "numbers go from -5 to 5"},
+		{"FREQ=MONTHLY;BYDAY=0MO", "numbers go from -5 to 5"},
+		{"FREQ=WEEKLY;BYDAY=2MO", "numbered BYDAY"},
+		{"FREQ=YEARLY;BYDAY=1MO", "numbered BYDAY"},
+		{"FREQ=WEEKLY;BYMONTHDAY=1", "can't be used with FREQ=WEEKLY"},
+		{"FREQ=MONTHLY;BYMONTHDAY=0", "can't be 0"},
+		{"FREQ=MONTHLY;BYMONTHDAY=32", "BYMONTHDAY must be"},
+		{"FREQ=YEARLY;BYMONTH=13", "BYMONTH must be"},
+		{"FREQ=WEEKLY;WKST=SU", "WKST=MO"},
+		{"FREQ=DAILY;BYHOUR=9", "not supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			_, err := Parse(tt.value)
+			if err == nil {
+				t.Fatal("Parse succeeded")
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Parse error = %q, want it to mention %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestUpcoming(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		start    string
+		location string
+		// ends is set when the schedule has no occurrences after want
+		ends bool
+		want []string
+	}{
+		{
+			name:  "daily with interval",
+			rule:  "FREQ=DAILY;INTERVAL=2",
+			start: "2026-01-30T09:00:00Z",
+			want:  []string{"2026-01-30T09:00:00Z", "2026-02-01T09:00:00Z", "2026-02-03T09:00:00Z", "2026-02-05T09:00:00Z"},
+		},
+		{
+			name:  "weekly on the start's weekday",
+			rule:  "FREQ=WEEKLY",
+			start: "2026-01-01T09:00:00Z",
+			want:  []string{"2026-01-01T09:00:00Z", "2026-01-08T09:00:00Z", "2026-01-15T09:00:00Z"},
+		},
+		{
+			name:  "weekly on several days",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,TH",
+			start: "2026-03-02T09:00:00Z",
+			want:  []string{"2026-03-02T09:00:00Z", "2026-03-05T09:00:00Z", "2026-03-09T09:00:00Z", "2026-03-12T09:00:00Z"},
+		},
+		{
+			name:  "every other week",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR",
+			start: "2026-03-02T09:00:00Z",
+			want:  []string{"2026-03-02T09:00:00Z", "2026-03-06T09:00:00Z", "2026-03-16T09:00:00Z", "2026-03-20T09:00:00Z"},
+		},
+		{
+			name:  "second Tuesday of the month",
+			rule:  "FREQ=MONTHLY;BYDAY=2TU",
+			start: "2026-01-13T09:00:00Z",
+			want:  []string{"2026-01-13T09:00:00Z", "2026-02-10T09:00:00Z", "2026-03-10T09:00:00Z", "2026-04-14T09:00:00Z"},
+		},
+		{
+			name:  "last Friday of the month",
+			rule:  "FREQ=MONTHLY;BYDAY=-1FR",
+			start: "2026-01-30T09:00:00Z",
+			want:  []string{"2026-01-30T09:00:00Z", "2026-02-27T09:00:00Z", "2026-03-27T09:00:00Z", "2026-04-24T09:00:00Z"},
+		},
+		{
+			name:  "monthly on the 31st skips short months",
+			rule:  "FREQ=MONTHLY",
+			start: "2026-01-31T09:00:00Z",
+			want:  []string{"2026-01-31T09:00:00Z", "2026-03-31T09:00:00Z", "2026-05-31T09:00:00Z", "2026-07-31T09:00:00Z"},
+		},
+		{
+			name:  "last day of the month",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1",
+			start: "2026-01-31T09:00:00Z",
+			want:  []string{"2026-01-31T09:00:00Z", "2026-02-28T09:00:00Z", "2026-03-31T09:00:00Z", "2026-04-30T09:00:00Z"},
+		},
+		{
+			name:  "every three months",
+			rule:  "FREQ=MONTHLY;INTERVAL=3",
+			start: "2026-11-15T09:00:00Z",
+			want:  []string{"2026-11-15T09:00:00Z", "2027-02-15T09:00:00Z", "2027-05-15T09:00:00Z"},
+		},
+		{
+			name:  "yearly on February 29",
+			rule:  "FREQ=YEARLY",
+			start: "2024-02-29T09:00:00Z",
+			want:  []string{"2024-02-29T09:00:00Z", "2028-02-29T09:00:00Z", "2032-02-29T09:00:00Z"},
+		},
+		{
+			name:  "fourth Thursday of November",
+			rule:  "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH",
+			start: "2026-11-26T09:00:00Z",
+			want:  []string{"2026-11-26T09:00:00Z", "2027-11-25T09:00:00Z", "2028-11-23T09:00:00Z"},
+		},
+		{
+			name:  "count includes the start",
+			rule:  "FREQ=WEEKLY;COUNT=3",
+			start: "2026-01-01T09:00:00Z",
+			ends:  true,
+			want:  []string{"2026-01-01T09:00:00Z", "2026-01-08T09:00:00Z", "2026-01-15T09:00:00Z"},
+		},
+		{
+			name:  "until a date covers the whole day",
+			rule:  "FREQ=DAILY;UNTIL=20260105",
+			start: "2026-01-03T23:00:00Z",
+			ends:  true,
+			want:  []string{"2026-01-03T23:00:00Z", "2026-01-04T23:00:00Z", "2026-01-05T23:00:00Z"},
+		},
+		{
+			name:     "until a UTC time includes an occurrence at it",
+			rule:     "FREQ=WEEKLY;UNTIL=20260115T080000Z",
+			start:    "2026-01-01T09:00:00+01:00",
+			location: "Europe/Berlin",
+			ends:     true,
+			want:     []string{"2026-01-01T09:00:00+01:00", "2026-01-08T09:00:00+01:00", "2026-01-15T09:00:00+01:00"},
+		},
+		{
+			name:     "floating until is on the wall clock",
+			rule:     "FREQ=DAILY;UNTIL=20260103T090000",
+			start:    "2026-01-01T09:00:00-05:00",
+			location: "America/New_York",
+			ends:     true,
+			want:     []string{"2026-01-01T09:00:00-05:00", "2026-01-02T09:00:00-05:00", "2026-01-03T09:00:00-05:00"},
+		},
+		{
+			name:     "daylight saving time starts",
+			rule:     "FREQ=DAILY",
+			start:    "2026-03-07T09:00:00-05:00",
+			location: "America/New_York",
+			want:     []string{"2026-03-07T09:00:00-05:00", "2026-03-08T09:00:00-04:00", "2026-03-09T09:00:00-04:00"},
+		},
+		{
+			name:     "daylight saving time ends",
+			rule:     "FREQ=WEEKLY",
+			start:    "2026-10-18T09:00:00+02:00",
+			location: "Europe/Berlin",
+			want:     []string{"2026-10-18T09:00:00+02:00", "2026-10-25T09:00:00+01:00", "2026-11-01T09:00:00+01:00"},
+		},
+		{
+			name:     "monthly across daylight saving time",
+			rule:     "FREQ=MONTHLY;BYDAY=1SU",
+			start:    "2026-02-01T18:30:00+11:00",
+			location: "Australia/Sydney",
+			want:     []string{"2026-02-01T18:30:00+11:00", "2026-03-01T18:30:00+11:00", "2026-04-05T18:30:00+10:00", "2026-05-03T18:30:00+10:00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			start, err := time.Parse(time.RFC3339, tt.start)
+			if err != nil {
+				t.Fatalf("parsing start: %v", err)
+			}
+			location := time.UTC
+			if tt.location != "" {
+				location = mustLocation(t, tt.location)
+			}
+
+			n := len(tt.want)
+			if tt.ends {
+				n += 5
+			}
+			schedule := NewSchedule(rule, start, location)
+			occurrences := schedule.Upcoming(start.Add(-time.Second), n)
+
+			got := make([]string, len(occurrences))
+			for i, occurrence := range occurrences {
+				got[i] = occurrence.Format(time.RFC3339)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("occurrences:\n got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleQueries(t *testing.T) {
+	rule, err := Parse("FREQ=DAILY;COUNT=5")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	schedule := NewSchedule(rule, start, time.UTC)
+	day := 24 * time.Hour
+
+	if next, ok := schedule.Next(start); !ok || !next.Equal(start.Add(day)) {
+		t.Errorf("Next after the start = %v, %v, want the second day", next, ok)
+	}
+	if next, ok := schedule.Next(start.Add(-time.Hour)); !ok || !next.Equal(start) {
+		t.Errorf("Next before the start = %v, %v, want the start", next, ok)
+	}
+	if _, ok := schedule.Next(start.Add(4 * day)); ok {
+		t.Error("Next found an occurrence after the last one")
+	}
+
+	if latest, ok := schedule.Latest(start, start.Add(3*day+time.Hour)); !ok || !latest.Equal(start.Add(3*day)) {
+		t.Errorf("Latest = %v, %v, want the fourth day", latest, ok)
+	}
+	if _, ok := schedule.Latest(start, start.Add(time.Hour)); ok {
+		t.Error("Latest found an occurrence between the start and the next one")
+	}
+	if latest, ok := schedule.Latest(start, start.Add(30*day)); !ok || !latest.Equal(start.Add(4*day)) {
+		t.Errorf("Latest after the schedule ended = %v, %v, want the last occurrence", latest, ok)
+	}
+
+	tests := []struct {
+		before time.Time
+		want   int
+	}{
+		{start, 0},
+		{start.Add(time.Second), 1},
+		{start.Add(2 * day), 2},
+		{start.Add(30 * day), 5},
+	}
+	for _, tt := range tests {
+		if got := schedule.CountBefore(tt.before); got != tt.want {
+			t.Errorf("CountBefore(%v) = %d, want %d", tt.before, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleWithoutMoreMatches(t *testing.T) {
+	// February never has a 30th, so only the start is an occurrence
+	rule, err := Parse("FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	start := time.Date(2026, 1, 30, 9, 0, 0, 0, time.UTC)
+	schedule := NewSchedule(rule, start, time.UTC)
+
+	if next, ok := schedule.Next(start); ok {
+		t.Errorf("Next = %v, want none", next)
+	}
+}
@@ -1,19 +1,17 @@
 package routes
 
 import (
-	"fmt"
 	"github.com/gorilla/mux"
 	"go-react-redux-app/controllers"
 	"go-react-redux-app/middleware"
-	"go-react-redux-app/utils"
-	"net/http"
-	"strings"
-
-	"github.com/golang-jwt/jwt/v5"
+	"go-react-redux-app/models"
 )
 
 // SetupRoutes sets up the routes for the API
-func SetupRoutes(router *mux.Router, auth *middleware.Auth, authController *controllers.AuthController, projectController *controllers.ProjectController, taskController *controllers.TaskController) {
+func SetupRoutes(router *mux.Router, auth *middleware.Auth, jwksController *controllers.JWKSController, authController *controllers.AuthController, oidcController *controllers.OIDCController, userController *controllers.UserController, sessionController *controllers.SessionController, twoFactorController *controllers.TwoFactorController, apiTokenController *controllers.APITokenController, adminController *controllers.AdminController, projectController *controllers.ProjectController, taskController *controllers.TaskController, dependencyController *controllers.DependencyController, labelController *controllers.LabelController, fieldController *controllers.CustomFieldController, workflowController *controllers.WorkflowController, recurrenceController *controllers.RecurrenceController, worklogController *controllers.WorklogController, commentController *controllers.CommentController, attachmentController *controllers.AttachmentController) {
+	// Public keys other services verify our access tokens with
+	router.HandleFunc("/.well-known/jwks.json", jwksController.GetJWKS).Methods("GET", "OPTIONS")
+
 	// API router
 	apiRouter := router.PathPrefix("/api").Subrouter()
 
@@ -21,75 +19,171 @@ func SetupRoutes(router *mux.Router, auth *middleware.Auth, authController *cont
 	publicRouter := apiRouter.PathPrefix("").Subrouter()
 	publicRouter.HandleFunc("/auth/register", authController.Register).Methods("POST", "OPTIONS")
 	publicRouter.HandleFunc("/auth/login", authController.Login).Methods("POST", "OPTIONS")
-	
-	// Token doğrulama endpoint'i
-	publicRouter.HandleFunc("/auth/verify-token", func(w http.ResponseWriter, r *http.Request) {
-		// Token doğrulama işlemi
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			utils.RespondWithError(w, http.StatusUnauthorized, "Authorization header is required")
-			return
-		}
-
-		// Check if the Authorization header has the correct format
-		headerParts := strings.Split(authHeader, " ")
-		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-			utils.RespondWithError(w, http.StatusUnauthorized, "Authorization header format must be Bearer {token}")
-			return
-		}
-
-		// Get the token
-		tokenString := headerParts[1]
-		
-		// Parse the token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Validate the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(auth.JWTKey), nil
-		})
-
-		if err != nil {
-			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token: " + err.Error())
-			return
-		}
-
-		if !token.Valid {
-			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
-			return
-		}
-
-		// Token geçerli, başarılı yanıt döndür
-		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-			"success": true,
-			"message": "Token is valid",
-		})
-	}).Methods("GET", "OPTIONS")
+	publicRouter.HandleFunc("/auth/login/2fa", authController.LoginTwoFactor).Methods("POST", "OPTIONS")
+	publicRouter.HandleFunc("/auth/refresh", authController.Refresh).Methods("POST", "OPTIONS")
+
+	// Single sign-on routes; login and callback are browser redirects, not API calls
+	publicRouter.HandleFunc("/auth/oidc/providers", oidcController.GetProviders).Methods("GET", "OPTIONS")
+	publicRouter.HandleFunc("/auth/oidc/exchange", authController.ExchangeOIDCCode).Methods("POST", "OPTIONS")
+	publicRouter.HandleFunc("/auth/oidc/{provider}/login", oidcController.StartLogin).Methods("GET")
+	publicRouter.HandleFunc("/auth/oidc/{provider}/callback", oidcController.Callback).Methods("GET")
+
+	// Password reset and email verification routes
+	publicRouter.HandleFunc("/auth/forgot-password", authController.ForgotPassword).Methods("POST", "OPTIONS")
+	publicRouter.HandleFunc("/auth/reset-password", authController.ResetPassword).Methods("POST", "OPTIONS")
+	publicRouter.HandleFunc("/auth/verify-email", authController.VerifyEmail).Methods("POST", "OPTIONS")
+	publicRouter.HandleFunc("/auth/resend-verification", authController.ResendVerification).Methods("POST", "OPTIONS")
 
 	// Protected routes
 	protectedRouter := apiRouter.PathPrefix("").Subrouter()
 	protectedRouter.Use(auth.Middleware)
 
+	// Session routes
+	protectedRouter.HandleFunc("/auth/logout", authController.Logout).Methods("POST", "OPTIONS")
+	protectedRouter.HandleFunc("/auth/verify-token", authController.VerifyToken).Methods("GET", "OPTIONS")
+
+	// Account routes
+	protectedRouter.HandleFunc("/users/me", userController.GetMe).Methods("GET", "OPTIONS")
+	protectedRouter.HandleFunc("/users/me", userController.UpdateMe).Methods("PUT", "OPTIONS")
+	protectedRouter.HandleFunc("/users/me", userController.DeleteMe).Methods("DELETE", "OPTIONS")
+	protectedRouter.HandleFunc("/users/me/password", userController.ChangePassword).Methods("PUT", "OPTIONS")
+	protectedRouter.HandleFunc("/users/profile", userController.UpdateMe).Methods("PUT", "OPTIONS")
+
+	// Device routes; DELETE on the collection revokes every session but the current one
+	protectedRouter.HandleFunc("/users/me/sessions", sessionController.GetSessions).Methods("GET", "OPTIONS")
+	protectedRouter.HandleFunc("/users/me/sessions", sessionController.RevokeOtherSessions).Methods("DELETE", "OPTIONS")
+	protectedRouter.HandleFunc("/users/me/sessions/{id}", sessionController.RevokeSession).Methods("DELETE", "OPTIONS")
+
+	// Linked identity provider account routes
+	protectedRouter.HandleFunc("/users/me/identities", oidcController.GetIdentities).Methods("GET", "OPTIONS")
+	protectedRouter.HandleFunc("/users/me/identities/{provider}", oidcController.StartLink).Methods("POST", "OPTIONS")
+	protectedRouter.HandleFunc("/users/me/identities/{id}", oidcController.DeleteIdentity).Methods("DELETE", "OPTIONS")
+
+	// Two-factor authentication routes
+	protectedRouter.HandleFunc("/users/me/2fa", twoFactorController.GetStatus).Methods("GET", "OPTIONS")
+	protectedRouter.HandleFunc("/users/me/2fa", twoFactorController.Enroll).Methods("POST", "OPTIONS")
+	protectedRouter.HandleFunc("/users/me/2fa", twoFactorController.Disable).Methods("DELETE", "OPTIONS")
+	protectedRouter.HandleFunc("/users/me/2fa/confirm", twoFactorController.Confirm).Methods("POST", "OPTIONS")
+	protectedRouter.HandleFunc("/users/me/2fa/recovery-codes", twoFactorController.RegenerateRecoveryCodes).Methods("POST", "OPTIONS")
+
+	// Comments the user was @mentioned in
+	protectedRouter.HandleFunc("/users/me/mentions", commentController.GetMentions).Methods("GET", "OPTIONS")
+
+	// Personal access token routes
+	protectedRouter.HandleFunc("/users/me/tokens", apiTokenController.GetTokens).Methods("GET", "OPTIONS")
+	protectedRouter.HandleFunc("/users/me/tokens", apiTokenController.CreateToken).Methods("POST", "OPTIONS")
+	protectedRouter.HandleFunc("/users/me/tokens/{id}", apiTokenController.DeleteToken).Methods("DELETE", "OPTIONS")
+
+	// Routes below also accept personal access tokens with the scope of their router
+	readProjects := scopedRouter(apiRouter, auth, models.ScopeReadProjects)
+	writeProjects := scopedRouter(apiRouter, auth, models.ScopeWriteProjects)
+	readTasks := scopedRouter(apiRouter, auth, models.ScopeReadTasks)
+	writeTasks := scopedRouter(apiRouter, auth, models.ScopeWriteTasks)
+
 	// Project routes
-	protectedRouter.HandleFunc("/projects", projectController.GetProjects).Methods("GET", "OPTIONS")
-	protectedRouter.HandleFunc("/projects", projectController.CreateProject).Methods("POST", "OPTIONS")
-	protectedRouter.HandleFunc("/projects/{id}", projectController.GetProject).Methods("GET", "OPTIONS")
-	protectedRouter.HandleFunc("/projects/{id}", projectController.UpdateProject).Methods("PUT", "OPTIONS")
-	protectedRouter.HandleFunc("/projects/{id}", projectController.DeleteProject).Methods("DELETE", "OPTIONS")
+	readProjects.HandleFunc("/projects", projectController.GetProjects).Methods("GET", "OPTIONS")
+	writeProjects.HandleFunc("/projects", projectController.CreateProject).Methods("POST", "OPTIONS")
+	readProjects.HandleFunc("/projects/{id}", projectController.GetProject).Methods("GET", "OPTIONS")
+	writeProjects.HandleFunc("/projects/{id}", projectController.UpdateProject).Methods("PUT", "OPTIONS")
+	writeProjects.HandleFunc("/projects/{id}", projectController.DeleteProject).Methods("DELETE", "OPTIONS")
+
+	// Project member routes
+	readProjects.HandleFunc("/projects/{id}/members", projectController.GetMembers).Methods("GET", "OPTIONS")
+	writeProjects.HandleFunc("/projects/{id}/members", projectController.AddMember).Methods("POST", "OPTIONS")
+	writeProjects.HandleFunc("/projects/{id}/members/{userId}", projectController.UpdateMember).Methods("PUT", "OPTIONS")
+	writeProjects.HandleFunc("/projects/{id}/members/{userId}", projectController.RemoveMember).Methods("DELETE", "OPTIONS")
+
+	// Project label routes
+	readProjects.HandleFunc("/projects/{id}/labels", labelController.GetLabels).Methods("GET", "OPTIONS")
+	writeProjects.HandleFunc("/projects/{id}/labels", labelController.CreateLabel).Methods("POST", "OPTIONS")
+	writeProjects.HandleFunc("/projects/{id}/labels/{labelId}", labelController.UpdateLabel).Methods("PUT", "OPTIONS")
+	writeProjects.HandleFunc("/projects/{id}/labels/{labelId}", labelController.DeleteLabel).Methods("DELETE", "OPTIONS")
+
+	// Project custom field routes
+	readProjects.HandleFunc("/projects/{id}/fields", fieldController.GetFields).Methods("GET", "OPTIONS")
+	writeProjects.HandleFunc("/projects/{id}/fields", fieldController.CreateField).Methods("POST", "OPTIONS")
+	writeProjects.HandleFunc("/projects/{id}/fields/{fieldId}", fieldController.UpdateField).Methods("PUT", "OPTIONS")
+	writeProjects.HandleFunc("/projects/{id}/fields/{fieldId}", fieldController.DeleteField).Methods("DELETE", "OPTIONS")
+
+	// Project workflow routes
+	readProjects.HandleFunc("/projects/{id}/workflow", workflowController.GetWorkflow).Methods("GET", "OPTIONS")
+	writeProjects.HandleFunc("/projects/{id}/workflow", workflowController.SetWorkflow).Methods("PUT", "OPTIONS")
 
 	// Task routes
-	protectedRouter.HandleFunc("/tasks", taskController.GetAllTasks).Methods("GET", "OPTIONS")
-	protectedRouter.HandleFunc("/projects/{projectId}/tasks", taskController.GetTasks).Methods("GET", "OPTIONS")
-	protectedRouter.HandleFunc("/tasks", taskController.CreateTask).Methods("POST", "OPTIONS")
-	protectedRouter.HandleFunc("/tasks/{id}", taskController.GetTask).Methods("GET", "OPTIONS")
-	protectedRouter.HandleFunc("/tasks/{id}", taskController.UpdateTask).Methods("PUT", "OPTIONS")
-	protectedRouter.HandleFunc("/tasks/{id}", taskController.DeleteTask).Methods("DELETE", "OPTIONS")
+	readTasks.HandleFunc("/tasks", taskController.GetAllTasks).Methods("GET", "OPTIONS")
+	readTasks.HandleFunc("/projects/{projectId}/tasks", taskController.GetTasks).Methods("GET", "OPTIONS")
+	writeTasks.HandleFunc("/tasks", taskController.CreateTask).Methods("POST", "OPTIONS")
+	readTasks.HandleFunc("/tasks/{id}", taskController.GetTask).Methods("GET", "OPTIONS")
+	readTasks.HandleFunc("/tasks/{id}/subtasks", taskController.GetSubtasks).Methods("GET", "OPTIONS")
+	readTasks.HandleFunc("/tasks/{id}/tree", taskController.GetTaskTree).Methods("GET", "OPTIONS")
+
+	// Task dependency routes
+	readTasks.HandleFunc("/tasks/{id}/dependencies", dependencyController.GetDependencies).Methods("GET", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}/dependencies", dependencyController.AddDependency).Methods("POST", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}/dependencies/{blockerId}", dependencyController.RemoveDependency).Methods("DELETE", "OPTIONS")
+
+	// Task label routes; PUT replaces all of a task's labels
+	writeTasks.HandleFunc("/tasks/{id}/labels", labelController.AddTaskLabel).Methods("POST", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}/labels", labelController.SetTaskLabels).Methods("PUT", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}/labels/{labelId}", labelController.RemoveTaskLabel).Methods("DELETE", "OPTIONS")
+
+	// Task custom field routes; values left out of the body are kept
+	writeTasks.HandleFunc("/tasks/{id}/fields", fieldController.SetTaskFields).Methods("PUT", "OPTIONS")
+
+	// Task recurrence routes; PUT changes this occurrence or all future ones
+	readTasks.HandleFunc("/tasks/{id}/recurrence", recurrenceController.GetRecurrence).Methods("GET", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}/recurrence", recurrenceController.CreateRecurrence).Methods("POST", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}/recurrence", recurrenceController.UpdateRecurrence).Methods("PUT", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}/recurrence", recurrenceController.DeleteRecurrence).Methods("DELETE", "OPTIONS")
+
+	// Worklog and timer routes; a user has at most one running timer
+	readTasks.HandleFunc("/tasks/{id}/worklogs", worklogController.GetWorklogs).Methods("GET", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}/worklogs", worklogController.CreateWorklog).Methods("POST", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}/worklogs/{worklogId}", worklogController.UpdateWorklog).Methods("PUT", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}/worklogs/{worklogId}", worklogController.DeleteWorklog).Methods("DELETE", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}/timer", worklogController.StartTimer).Methods("POST", "OPTIONS")
+	readTasks.HandleFunc("/timer", worklogController.GetTimer).Methods("GET", "OPTIONS")
+	writeTasks.HandleFunc("/timer/stop", worklogController.StopTimer).Methods("POST", "OPTIONS")
+	writeTasks.HandleFunc("/timer", worklogController.DiscardTimer).Methods("DELETE", "OPTIONS")
+	readTasks.HandleFunc("/worklogs/report", worklogController.GetReport).Methods("GET", "OPTIONS")
+
+	// Task comment routes
+	readTasks.HandleFunc("/tasks/{id}/comments", commentController.GetComments).Methods("GET", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}/comments", commentController.CreateComment).Methods("POST", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}/comments/{commentId}", commentController.UpdateComment).Methods("PUT", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}/comments/{commentId}", commentController.DeleteComment).Methods("DELETE", "OPTIONS")
+
+	// Task attachment routes
+	readTasks.HandleFunc("/tasks/{id}/attachments", attachmentController.GetAttachments).Methods("GET", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}/attachments", attachmentController.UploadAttachments).Methods("POST", "OPTIONS")
+	readTasks.HandleFunc("/tasks/{id}/attachments/{attachmentId}", attachmentController.DownloadAttachment).Methods("GET", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}/attachments/{attachmentId}", attachmentController.DeleteAttachment).Methods("DELETE", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}", taskController.UpdateTask).Methods("PUT", "OPTIONS")
+	writeTasks.HandleFunc("/tasks/{id}", taskController.DeleteTask).Methods("DELETE", "OPTIONS")
 
 	// Admin routes
 	adminRouter := apiRouter.PathPrefix("/admin").Subrouter()
 	adminRouter.Use(auth.Middleware)
 	adminRouter.Use(auth.RoleMiddleware("admin"))
 
-	// Add admin routes here if needed
+	// User management routes
+	adminRouter.HandleFunc("/users", adminController.ListUsers).Methods("GET", "OPTIONS")
+	adminRouter.HandleFunc("/users/{id}", adminController.GetUser).Methods("GET", "OPTIONS")
+	adminRouter.HandleFunc("/users/{id}", adminController.DeleteUser).Methods("DELETE", "OPTIONS")
+	adminRouter.HandleFunc("/users/{id}/role", adminController.UpdateRole).Methods("PUT", "OPTIONS")
+	adminRouter.HandleFunc("/users/{id}/disable", adminController.DisableUser).Methods("POST", "OPTIONS")
+	adminRouter.HandleFunc("/users/{id}/enable", adminController.EnableUser).Methods("POST", "OPTIONS")
+	adminRouter.HandleFunc("/users/{id}/reset-password", adminController.ForcePasswordReset).Methods("POST", "OPTIONS")
+	adminRouter.HandleFunc("/users/{id}/reset-2fa", adminController.ResetTwoFactor).Methods("POST", "OPTIONS")
+	adminRouter.HandleFunc("/users/{id}/unlock", adminController.UnlockUser).Methods("POST", "OPTIONS")
+	adminRouter.HandleFunc("/users/{id}/login-attempts", adminController.GetLoginAttempts).Methods("GET", "OPTIONS")
+}
+
+// scopedRouter creates a protected router whose routes also accept personal
+// access tokens that were granted the scope
+func scopedRouter(apiRouter *mux.Router, auth *middleware.Auth, scope string) *mux.Router {
+	router := apiRouter.PathPrefix("").Subrouter()
+	router.Use(auth.RequireScope(scope))
+	router.Use(auth.Middleware)
+	return router
 }
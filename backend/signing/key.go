@@ -0,0 +1,98 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithms supported for signing tokens
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// rsaKeyBits is the size of generated RSA keys
+const rsaKeyBits = 2048
+
+// IsValidAlgorithm checks if an algorithm is supported
+func IsValidAlgorithm(algorithm string) bool {
+	return algorithm == HS256 || algorithm == RS256 || algorithm == EdDSA
+}
+
+// Key is a token signing key identified by its kid
+type Key struct {
+	ID        string
+	Algorithm string
+	CreatedAt time.Time
+	// secret is a []byte for HS256, *rsa.PrivateKey for RS256 and ed25519.PrivateKey for EdDSA
+	secret interface{}
+}
+
+// Method returns the JWT signing method of the key
+func (k *Key) Method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// SigningKey returns the key material used to sign tokens
+func (k *Key) SigningKey() interface{} {
+	return k.secret
+}
+
+// VerificationKey returns the key material used to verify tokens
+func (k *Key) VerificationKey() interface{} {
+	if signer, ok := k.secret.(crypto.Signer); ok {
+		return signer.Public()
+	}
+	return k.secret
+}
+
+// IsAsymmetric checks if the key has a public half that can be published
+func (k *Key) IsAsymmetric() bool {
+	return k.Algorithm != HS256
+}
+
+// generateKey creates a new random asymmetric key
+func generateKey(algorithm string) (*Key, error) {
+	var secret interface{}
+	var err error
+	switch algorithm {
+	case RS256:
+		secret, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case EdDSA:
+		_, secret, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("cannot generate keys for algorithm %q", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newKeyID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		ID:        id,
+		Algorithm: algorithm,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		secret:    secret,
+	}, nil
+}
+
+// newKeyID returns a random key ID
+func newKeyID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
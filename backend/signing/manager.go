@@ -0,0 +1,206 @@
+package signing
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// KeyManager holds the keys tokens are signed and verified with. The newest
+// key signs new tokens; a key that has been superseded keeps verifying tokens
+// for the grace period and is deleted afterwards.
+//
+// Asymmetric keys are kept in a directory so that they survive restarts and
+// can be shared by several instances.
+type KeyManager struct {
+	Algorithm        string
+	Dir              string
+	RotationInterval time.Duration
+	GracePeriod      time.Duration
+
+	mu   sync.RWMutex
+	keys []*Key // oldest first
+}
+
+// NewHMACKeyManager creates a KeyManager with a single static HS256 secret
+func NewHMACKeyManager(secret string) *KeyManager {
+	return &KeyManager{
+		Algorithm: HS256,
+		keys: []*Key{{
+			ID:        "default",
+			Algorithm: HS256,
+			secret:    []byte(secret),
+		}},
+	}
+}
+
+// NewKeyManager creates a KeyManager for RS256 or EdDSA keys stored in dir.
+// A new key is generated when there is none yet or the newest key is older
+// than the rotation interval; a zero interval disables scheduled rotation.
+func NewKeyManager(algorithm string, dir string, rotationInterval time.Duration, gracePeriod time.Duration) (*KeyManager, error) {
+	if algorithm != RS256 && algorithm != EdDSA {
+		return nil, errors.New("key rotation requires the RS256 or EdDSA algorithm")
+	}
+
+	m := &KeyManager{
+		Algorithm:        algorithm,
+		Dir:              dir,
+		RotationInterval: rotationInterval,
+		GracePeriod:      gracePeriod,
+	}
+	if err := m.Rotate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SigningKey returns the key new tokens are signed with
+func (m *KeyManager) SigningKey() *Key {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for i := len(m.keys) - 1; i >= 0; i-- {
+		if m.keys[i].Algorithm == m.Algorithm {
+			return m.keys[i]
+		}
+	}
+	return nil
+}
+
+// VerificationKey returns the key with the given ID if tokens signed with it
+// are still accepted. Tokens without a kid are only accepted with a static
+// HS256 secret, which predates key IDs.
+func (m *KeyManager) VerificationKey(id string) (*Key, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if id == "" {
+		if len(m.keys) == 1 && m.keys[0].Algorithm == HS256 {
+			return m.keys[0], true
+		}
+		return nil, false
+	}
+
+	now := time.Now()
+	for i, key := range m.keys {
+		if key.ID == id {
+			return key, m.isActive(i, now)
+		}
+	}
+	return nil, false
+}
+
+// Keys returns the keys that still verify tokens, oldest first
+func (m *KeyManager) Keys() []*Key {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	var keys []*Key
+	for i, key := range m.keys {
+		if m.isActive(i, now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Rotate reloads the key directory, picking up keys written by other
+// instances, generates a new signing key if one is due and deletes keys whose
+// grace period has passed. It does nothing for a static HS256 secret.
+func (m *KeyManager) Rotate() error {
+	if m.Algorithm == HS256 {
+		return nil
+	}
+
+	keys, err := loadKeys(m.Dir)
+	if err != nil {
+		return err
+	}
+	sortKeys(keys)
+
+	now := time.Now()
+	if m.rotationDue(keys, now) {
+		key, err := generateKey(m.Algorithm)
+		if err != nil {
+			return err
+		}
+		if err := writeKey(m.Dir, key); err != nil {
+			return err
+		}
+		log.Printf("Generated %s signing key %s", key.Algorithm, key.ID)
+		keys = append(keys, key)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keys = keys
+	var active []*Key
+	for i, key := range keys {
+		if m.isActive(i, now) {
+			active = append(active, key)
+			continue
+		}
+		if err := removeKey(m.Dir, key); err != nil {
+			log.Printf("Error removing expired signing key %s: %v", key.ID, err)
+			continue
+		}
+		log.Printf("Removed expired signing key %s", key.ID)
+	}
+	m.keys = active
+	return nil
+}
+
+// StartRotation calls Rotate periodically in the background
+func (m *KeyManager) StartRotation(interval time.Duration) {
+	if m.Algorithm == HS256 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := m.Rotate(); err != nil {
+				log.Printf("Error rotating signing keys: %v", err)
+			}
+		}
+	}()
+}
+
+// rotationDue checks if a new signing key must be generated
+func (m *KeyManager) rotationDue(keys []*Key, now time.Time) bool {
+	var newest *Key
+	for _, key := range keys {
+		if key.Algorithm == m.Algorithm {
+			newest = key
+		}
+	}
+	if newest == nil {
+		return true
+	}
+	return m.RotationInterval > 0 && now.Sub(newest.CreatedAt) >= m.RotationInterval
+}
+
+// isActive checks if the i-th key still verifies tokens: it is the newest
+// key, or the key that replaced it was created less than the grace period
+// ago. The caller must hold the lock.
+func (m *KeyManager) isActive(i int, now time.Time) bool {
+	if i == len(m.keys)-1 {
+		return true
+	}
+	return now.Sub(m.keys[i+1].CreatedAt) < m.GracePeriod
+}
+
+// sortKeys sorts keys oldest first
+func sortKeys(keys []*Key) {
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+}
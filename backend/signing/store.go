@@ -0,0 +1,130 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PEM block headers storing the key metadata
+const (
+	headerKeyID     = "Key-Id"
+	headerAlgorithm = "Algorithm"
+	headerCreated   = "Created"
+)
+
+// keyFileExt is the extension of the key files in a key directory
+const keyFileExt = ".pem"
+
+// loadKeys reads every key file in a directory. A missing directory holds no keys.
+func loadKeys(dir string) ([]*Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+keyFileExt))
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*Key
+	for _, path := range paths {
+		key, err := readKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading key %s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// readKey reads a PKCS #8 private key written by writeKey
+func readKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("not a PEM encoded private key")
+	}
+
+	secret, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm := block.Headers[headerAlgorithm]
+	switch secret.(type) {
+	case *rsa.PrivateKey:
+		if algorithm != RS256 {
+			return nil, fmt.Errorf("RSA key with algorithm %q", algorithm)
+		}
+	case ed25519.PrivateKey:
+		if algorithm != EdDSA {
+			return nil, fmt.Errorf("Ed25519 key with algorithm %q", algorithm)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", secret)
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, block.Headers[headerCreated])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header: %w", headerCreated, err)
+	}
+
+	id := block.Headers[headerKeyID]
+	if id == "" {
+		id = strings.TrimSuffix(filepath.Base(path), keyFileExt)
+	}
+
+	return &Key{
+		ID:        id,
+		Algorithm: algorithm,
+		CreatedAt: createdAt,
+		secret:    secret,
+	}, nil
+}
+
+// writeKey stores a key in the directory as <kid>.pem, readable only by the owner
+func writeKey(dir string, key *Key) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key.secret)
+	if err != nil {
+		return err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{
+		Type: "PRIVATE KEY",
+		Headers: map[string]string{
+			headerKeyID:     key.ID,
+			headerAlgorithm: key.Algorithm,
+			headerCreated:   key.CreatedAt.Format(time.RFC3339),
+		},
+		Bytes: der,
+	})
+
+	// Write to a temporary file first so other instances never read half a key
+	path := filepath.Join(dir, key.ID+keyFileExt)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// removeKey deletes the file of a key
+func removeKey(dir string, key *Key) error {
+	err := os.Remove(filepath.Join(dir, key.ID+keyFileExt))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
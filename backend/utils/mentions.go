@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"strings"
+)
+
+// ParseMentions returns the distinct usernames @mentioned in a Markdown text, in
+// the order they first appear. Mentions inside code spans and fenced code
+// blocks are ignored, as are email addresses.
+func ParseMentions(text string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+
+	fence := ""
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+
+		for _, username := range lineMentions(line) {
+			if !seen[username] {
+				seen[username] = true
+				usernames = append(usernames, username)
+			}
+		}
+	}
+	return usernames
+}
+
+// lineMentions returns the usernames mentioned in one line, skipping code spans
+func lineMentions(line string) []string {
+	var usernames []string
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '`':
+			// A code span ends at the next run of as many backticks
+			run := backtickRun(line[i:])
+			end := strings.Index(line[i+run:], line[i:i+run])
+			if end < 0 {
+				i += run - 1
+				continue
+			}
+			i += run + end + run - 1
+		case line[i] == '@' && (i == 0 || !isUsernameByte(line[i-1])):
+			j := i + 1
+			for j < len(line) && isUsernameByte(line[j]) {
+				j++
+			}
+			// Punctuation ending a sentence is not part of the username
+			username := strings.TrimRight(line[i+1:j], ".-")
+			if username != "" {
+				usernames = append(usernames, username)
+			}
+			i = j - 1
+		}
+	}
+	return usernames
+}
+
+// backtickRun returns the number of backticks s starts with
+func backtickRun(s string) int {
+	n := 0
+	for n < len(s) && s[n] == '`' {
+		n++
+	}
+	return n
+}
+
+// isUsernameByte checks if a byte can be part of a mentioned username
+func isUsernameByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '_' || b == '.' || b == '-'
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// maxIPLength is the length of the longest textual IPv6 address
+const maxIPLength = 45
+
+// maxUserAgentLength caps the user agents stored with sessions
+const maxUserAgentLength = 255
+
+// ClientIP returns the address of the client that sent the request. Proxy
+// headers are only honoured when trustProxy is set, since clients can forge them.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			// The first entry is the original client; proxies append themselves
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return truncate(ip, maxIPLength)
+			}
+		}
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+			return truncate(realIP, maxIPLength)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// UserAgent returns the user agent of the request, cut to the size stored with sessions
+func UserAgent(r *http.Request) string {
+	return truncate(r.UserAgent(), maxUserAgentLength)
+}
+
+// truncate cuts a string to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}